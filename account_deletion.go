@@ -0,0 +1,226 @@
+package main
+
+import (
+  "context"
+  "database/sql"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "log"
+  "net/http"
+  "os"
+  "time"
+  "chirpy/internal/auth"
+  "chirpy/internal/database"
+  "github.com/google/uuid"
+)
+
+const (
+  // what happens to a deleted account's quote chirps, which are the
+  // replies in other people's conversations
+  deletedRepliesAnonymize = "anonymize"
+  deletedRepliesDelete    = "delete"
+
+  jobPurgeAccounts = "accounts.purge"
+
+  accountDeletionGracePeriod = 30 * 24 * time.Hour
+)
+
+// deletedUserID owns the replies of deleted accounts when they are
+// anonymized rather than deleted.
+var deletedUserID = uuid.Nil
+
+// loadDeletedReplies reads ACCOUNT_DELETION_REPLIES, "anonymize" (the
+// default) or "delete".
+func loadDeletedReplies() (string, error) {
+  switch v := os.Getenv("ACCOUNT_DELETION_REPLIES"); v {
+  case "":
+    return deletedRepliesAnonymize, nil
+  case deletedRepliesAnonymize, deletedRepliesDelete:
+    return v, nil
+  default:
+    return "", fmt.Errorf("invalid ACCOUNT_DELETION_REPLIES: %q", v)
+  }
+}
+
+// handleDeleteAccount schedules the caller's account for deletion once the
+// grace period is over. They are logged out everywhere, and logging in again
+// before then keeps the account.
+func (cfg *apiConfig) handleDeleteAccount(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  var params struct {
+    Password string `json:"password"`
+  }
+  err = json.NewDecoder(r.Body).Decode(&params)
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+    return
+  }
+  err = auth.CheckPasswordHash(params.Password, user.HashedPassword)
+  if err != nil {
+    respondWithError(w, http.StatusForbidden, "Incorrect password", nil)
+    return
+  }
+
+  ctx := context.Background()
+  tx, err := cfg.sqlDB.BeginTx(ctx, nil)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error deleting account", err)
+    return
+  }
+  defer tx.Rollback()
+  qtx := cfg.db.WithTx(tx)
+
+  // asking again doesn't push the date back
+  deleteAfter, err := qtx.ScheduleAccountDeletion(ctx, database.ScheduleAccountDeletionParams{
+    ID:          user.ID,
+    DeleteAfter: time.Now().Add(accountDeletionGracePeriod),
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error deleting account", err)
+    return
+  }
+  err = qtx.RevokeUserRefreshTokens(ctx, user.ID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error deleting account", err)
+    return
+  }
+  err = recordAudit(ctx, qtx, r, auditEntry{
+    Type:    auditAccountDeletionRequested,
+    ActorID: auditUser(user.ID),
+    UserID:  auditUser(user.ID),
+    Details: map[string]any{"delete_after": deleteAfter.Time},
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error deleting account", err)
+    return
+  }
+  err = tx.Commit()
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error deleting account", err)
+    return
+  }
+
+  body := "Your Chirpy account and everything in it will be deleted on " +
+    deleteAfter.Time.Format("January 2, 2006") + ".\n\n" +
+    "Changed your mind? Log in before then and your account stays as it is.\n"
+  err = cfg.sendEmail(ctx, user.Email, "Your Chirpy account will be deleted", body)
+  if err != nil {
+    log.Printf("Error emailing account deletion notice: %v", err)
+  }
+
+  respondWithJSON(w, http.StatusAccepted, struct {
+    DeleteAfter time.Time `json:"delete_after"`
+  }{deleteAfter.Time})
+}
+
+// cancelAccountDeletion keeps an account that was scheduled for deletion;
+// logging in does this.
+func (cfg *apiConfig) cancelAccountDeletion(r *http.Request, user database.User) {
+  if !user.DeleteAfter.Valid {
+    return
+  }
+  cancelled, err := cfg.db.CancelAccountDeletion(context.Background(), user.ID)
+  if err != nil {
+    log.Printf("Error cancelling account deletion: %v", err)
+    return
+  }
+  if cancelled > 0 {
+    cfg.audit(r, auditEntry{
+      Type:    auditAccountDeletionCancelled,
+      ActorID: auditUser(user.ID),
+      UserID:  auditUser(user.ID),
+    })
+  }
+}
+
+// purgeDeletedAccounts removes the accounts whose grace period is over.
+func (cfg *apiConfig) purgeDeletedAccounts(ctx context.Context) error {
+  now := time.Now()
+  ids, err := cfg.db.ListAccountsDueForDeletion(ctx, sql.NullTime{Time: now, Valid: true})
+  if err != nil {
+    return fmt.Errorf("error listing accounts to delete: %v", err)
+  }
+  var failed error
+  for _, id := range ids {
+    err := cfg.purgeAccount(ctx, id, now)
+    if err != nil {
+      log.Printf("Error deleting account %s: %v", id, err)
+      failed = err
+    }
+  }
+  return failed
+}
+
+// purgeAccount permanently deletes an account. Its chirps, tokens, bookmarks
+// and everything else that belongs to it go with it through the foreign
+// keys, unless its replies are being anonymized; its export archives are
+// deleted from disk. The audit trail keeps its entries.
+func (cfg *apiConfig) purgeAccount(ctx context.Context, userID uuid.UUID, now time.Time) error {
+  tx, err := cfg.sqlDB.BeginTx(ctx, nil)
+  if err != nil {
+    return err
+  }
+  defer tx.Rollback()
+  qtx := cfg.db.WithTx(tx)
+
+  // the lock keeps a login from cancelling the deletion halfway through
+  _, err = qtx.LockAccountDueForDeletion(ctx, database.LockAccountDueForDeletionParams{
+    ID:          userID,
+    DeleteAfter: sql.NullTime{Time: now, Valid: true},
+  })
+  if errors.Is(err, sql.ErrNoRows) {
+    return nil
+  }
+  if err != nil {
+    return err
+  }
+
+  exports, err := qtx.ListDataExportsByUser(ctx, userID)
+  if err != nil {
+    return err
+  }
+  var anonymized int64
+  if cfg.deletedReplies == deletedRepliesAnonymize {
+    err = qtx.EnsureDeletedUser(ctx, deletedUserID)
+    if err != nil {
+      return err
+    }
+    anonymized, err = qtx.AnonymizeRepliesByAuthor(ctx, database.AnonymizeRepliesByAuthorParams{
+      AnonymousID: deletedUserID,
+      UserID:      userID,
+    })
+    if err != nil {
+      return err
+    }
+  }
+  err = qtx.DeleteUser(ctx, userID)
+  if err != nil {
+    return err
+  }
+  err = recordAudit(ctx, qtx, nil, auditEntry{
+    Type:    auditAccountDeleted,
+    UserID:  auditUser(userID),
+    Details: map[string]any{"replies": cfg.deletedReplies, "anonymized_replies": anonymized},
+  })
+  if err != nil {
+    return err
+  }
+  err = tx.Commit()
+  if err != nil {
+    return err
+  }
+
+  for _, e := range exports {
+    err := os.Remove(cfg.exportPath(e.ID))
+    if err != nil && !errors.Is(err, os.ErrNotExist) {
+      log.Printf("Error deleting export %s: %v", e.ID, err)
+    }
+  }
+  return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+  "context"
+  "database/sql"
+  "errors"
+  "fmt"
+  "time"
+  "chirpy/internal/auth"
+  "chirpy/internal/database"
+  "github.com/google/uuid"
+)
+
+const (
+  accountActive       = "active"
+  accountSuspended    = "suspended"
+  accountBanned       = "banned"
+  accountShadowBanned = "shadow_banned"
+)
+
+var errAccountRestricted = errors.New("account is banned or suspended")
+
+// accountRestriction returns why user can't sign in or use their tokens, or
+// "" if they can. Suspensions lapse on their own once suspended_until has
+// passed. Shadow-banned accounts carry on as normal; only others stop
+// seeing their chirps.
+func accountRestriction(user database.User, now time.Time) string {
+  switch user.AccountStatus {
+  case accountBanned:
+    return "This account has been banned"
+  case accountSuspended:
+    if user.SuspendedUntil.Valid && now.Before(user.SuspendedUntil.Time) {
+      return "This account is suspended until " + user.SuspendedUntil.Time.UTC().Format(time.RFC3339)
+    }
+  }
+  return ""
+}
+
+// accessTokenUser is auth.ValidateJWT plus a check that the account behind
+// the token is still allowed in, so a ban takes effect before the token
+// expires. It returns that account.
+func (cfg *apiConfig) accessTokenUser(token string) (database.User, error) {
+  userID, err := auth.ValidateJWT(token, cfg.SecretKey)
+  if err != nil {
+    return database.User{}, err
+  }
+  user, err := cfg.db.GetUserById(context.Background(), userID)
+  if err != nil {
+    return database.User{}, fmt.Errorf("error fetching token user: %v", err)
+  }
+  if accountRestriction(user, time.Now()) != "" {
+    return database.User{}, errAccountRestricted
+  }
+  return user, nil
+}
+
+// validateAccessToken is accessTokenUser for callers that only need the ID.
+func (cfg *apiConfig) validateAccessToken(token string) (uuid.UUID, error) {
+  user, err := cfg.accessTokenUser(token)
+  if err != nil {
+    return uuid.Nil, err
+  }
+  return user.ID, nil
+}
+
+// shadowBannedAmong returns which of userIDs are shadow-banned.
+func (cfg *apiConfig) shadowBannedAmong(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+  banned := map[uuid.UUID]bool{}
+  if len(userIDs) == 0 {
+    return banned, nil
+  }
+
+  ids, err := cfg.db.ListShadowBannedAmong(ctx, userIDs)
+  if err != nil {
+    return nil, fmt.Errorf("error fetching shadow bans: %v", err)
+  }
+  for _, id := range ids {
+    banned[id] = true
+  }
+  return banned, nil
+}
+
+// visibleChirps drops the chirps of shadow-banned authors and chirps held
+// for review, except for the author's own view of them.
+func (cfg *apiConfig) visibleChirps(ctx context.Context, chirps []database.Chirp, viewer uuid.NullUUID) ([]database.Chirp, error) {
+  authors := make([]uuid.UUID, 0, len(chirps))
+  for _, c := range chirps {
+    authors = append(authors, c.UserID)
+  }
+  banned, err := cfg.shadowBannedAmong(ctx, authors)
+  if err != nil {
+    return nil, err
+  }
+
+  visible := make([]database.Chirp, 0, len(chirps))
+  for _, c := range chirps {
+    hidden := banned[c.UserID] || c.HeldAt.Valid
+    if !hidden || (viewer.Valid && viewer.UUID == c.UserID) {
+      visible = append(visible, c)
+    }
+  }
+  return visible, nil
+}
+
+// visibleChirp fetches a chirp that viewer may see and act on: it isn't
+// deleted, visibleChirps keeps it, and its author hasn't blocked viewer.
+// It reports false when there is no such chirp.
+func (cfg *apiConfig) visibleChirp(ctx context.Context, id uuid.UUID, viewer uuid.NullUUID) (database.Chirp, bool, error) {
+  chirp, err := cfg.db.GetOneChirp(ctx, id)
+  if errors.Is(err, sql.ErrNoRows) {
+    return database.Chirp{}, false, nil
+  }
+  if err != nil {
+    return database.Chirp{}, false, err
+  }
+  visible, err := cfg.visibleChirps(ctx, []database.Chirp{chirp}, viewer)
+  if err != nil || len(visible) == 0 {
+    return database.Chirp{}, false, err
+  }
+  blocked, err := cfg.isBlockedBy(ctx, viewer, chirp.UserID)
+  if err != nil || blocked {
+    return database.Chirp{}, false, err
+  }
+  return chirp, true, nil
+}
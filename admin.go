@@ -0,0 +1,67 @@
+package main
+
+import (
+  "errors"
+  "net/http"
+  "chirpy/internal/auth"
+  "chirpy/internal/database"
+  "github.com/google/uuid"
+)
+
+const (
+  roleUser      = "user"
+  roleModerator = "moderator"
+  roleAdmin     = "admin"
+)
+
+var errForbidden = errors.New("insufficient role")
+
+// authenticatedUser resolves the bearer token on the request to the user it
+// was issued for.
+func (cfg *apiConfig) authenticatedUser(r *http.Request) (database.User, error) {
+  token, err := auth.GetBearerToken(r.Header)
+  if err != nil {
+    return database.User{}, err
+  }
+
+  return cfg.accessTokenUser(token)
+}
+
+// viewerID is the caller's user ID if the request carries a valid token.
+// Public endpoints use it to tailor what they show.
+func (cfg *apiConfig) viewerID(r *http.Request) uuid.NullUUID {
+  token, err := auth.GetBearerToken(r.Header)
+  if err != nil {
+    return uuid.NullUUID{}
+  }
+  userID, err := cfg.validateAccessToken(token)
+  if err != nil {
+    return uuid.NullUUID{}
+  }
+  return uuid.NullUUID{UUID: userID, Valid: true}
+}
+
+// requireRole only calls next when the caller is authenticated and holds one
+// of the given roles. Admins are always allowed through.
+func (cfg *apiConfig) requireRole(next http.HandlerFunc, roles ...string) http.HandlerFunc {
+  return func(w http.ResponseWriter, r *http.Request) {
+    user, err := cfg.authenticatedUser(r)
+    if err != nil {
+      respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+      return
+    }
+
+    if user.Role == roleAdmin {
+      next(w, r)
+      return
+    }
+    for _, role := range roles {
+      if user.Role == role {
+        next(w, r)
+        return
+      }
+    }
+
+    respondWithError(w, http.StatusForbidden, "Forbidden", errForbidden)
+  }
+}
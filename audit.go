@@ -0,0 +1,337 @@
+package main
+
+import (
+  "context"
+  "database/sql"
+  "encoding/csv"
+  "encoding/json"
+  "fmt"
+  "log"
+  "net/http"
+  "strconv"
+  "strings"
+  "time"
+  "chirpy/internal/database"
+  "github.com/google/uuid"
+)
+
+const (
+  auditLoginSucceeded           = "login.succeeded"
+  auditLoginFailed              = "login.failed"
+  auditTokenRefreshed           = "token.refreshed"
+  auditTokenRevoked             = "token.revoked"
+  auditPasswordChanged          = "password.changed"
+  auditPasswordResetRequest     = "password.reset_requested"
+  auditPasswordReset            = "password.reset"
+  auditEmailChanged             = "email.changed"
+  auditSubscriptionChanged      = "subscription.changed"
+  auditAdminReset               = "admin.reset"
+  auditModerationAction         = "moderation.action"
+  auditDataExportRequested      = "data_export.requested"
+  auditAccountDeletionRequested = "account.deletion_requested"
+  auditAccountDeletionCancelled = "account.deletion_cancelled"
+  auditAccountDeleted           = "account.deleted"
+
+  defaultAuditLimit = 50
+  maxAuditLimit     = 500
+  // the most rows a CSV export will hold; narrow the filters for more
+  maxAuditExportRows = 50000
+)
+
+// securityLogEvents are the events users see about their own account.
+// Moderation actions and admin resets stay in the admin trail.
+var securityLogEvents = []string{
+  auditLoginSucceeded,
+  auditLoginFailed,
+  auditTokenRefreshed,
+  auditTokenRevoked,
+  auditPasswordChanged,
+  auditPasswordResetRequest,
+  auditPasswordReset,
+  auditEmailChanged,
+  auditSubscriptionChanged,
+  auditDataExportRequested,
+  auditAccountDeletionRequested,
+  auditAccountDeletionCancelled,
+}
+
+type requestIDKey struct{}
+
+// withRequestID tags every request with an ID, taken from X-Request-ID when
+// a proxy in front already set one, and echoes it back so clients can quote
+// it.
+func withRequestID(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    id := r.Header.Get("X-Request-ID")
+    if !validRequestID(id) {
+      id = uuid.NewString()
+    }
+    w.Header().Set("X-Request-ID", id)
+    next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+  })
+}
+
+func validRequestID(id string) bool {
+  if id == "" || len(id) > 128 {
+    return false
+  }
+  for _, c := range id {
+    if c < 0x21 || c > 0x7e {
+      return false
+    }
+  }
+  return true
+}
+
+func requestID(r *http.Request) string {
+  id, _ := r.Context().Value(requestIDKey{}).(string)
+  return id
+}
+
+// auditEntry is an event for the audit trail. ActorID is who did it and
+// UserID whose account it concerns; for most events they are the same.
+type auditEntry struct {
+  Type    string
+  ActorID uuid.NullUUID
+  UserID  uuid.NullUUID
+  Details map[string]any
+}
+
+func auditUser(id uuid.UUID) uuid.NullUUID {
+  return uuid.NullUUID{UUID: id, Valid: true}
+}
+
+// recordAudit appends e to the audit trail through qtx, taking the IP, user
+// agent and request ID from r. r is nil for events that don't come from a
+// request of their own, such as replayed webhooks.
+func recordAudit(ctx context.Context, qtx *database.Queries, r *http.Request, e auditEntry) error {
+  details, err := json.Marshal(e.Details)
+  if err != nil || e.Details == nil {
+    details = []byte("{}")
+  }
+  params := database.CreateAuditEventParams{
+    ID:        uuid.New(),
+    CreatedAt: time.Now(),
+    EventType: e.Type,
+    ActorID:   e.ActorID,
+    UserID:    e.UserID,
+    Details:   details,
+  }
+  if r != nil {
+    params.Ip = clientIP(r)
+    params.UserAgent = r.UserAgent()
+    params.RequestID = requestID(r)
+  }
+  err = qtx.CreateAuditEvent(ctx, params)
+  if err != nil {
+    return fmt.Errorf("error recording audit event: %v", err)
+  }
+  return nil
+}
+
+// audit records e outside of any transaction. A failure is logged rather
+// than failing the request it describes.
+func (cfg *apiConfig) audit(r *http.Request, e auditEntry) {
+  err := recordAudit(context.Background(), cfg.db, r, e)
+  if err != nil {
+    log.Printf("%v", err)
+  }
+}
+
+type auditEventResponse struct {
+  ID        uuid.UUID       `json:"id"`
+  CreatedAt time.Time       `json:"created_at"`
+  Type      string          `json:"type"`
+  ActorID   *uuid.UUID      `json:"actor_id,omitempty"`
+  UserID    *uuid.UUID      `json:"user_id,omitempty"`
+  IP        string          `json:"ip,omitempty"`
+  UserAgent string          `json:"user_agent,omitempty"`
+  RequestID string          `json:"request_id,omitempty"`
+  Details   json.RawMessage `json:"details"`
+}
+
+func newAuditEventResponse(e database.AuditEvent) auditEventResponse {
+  resp := auditEventResponse{
+    ID:        e.ID,
+    CreatedAt: e.CreatedAt,
+    Type:      e.EventType,
+    IP:        e.Ip,
+    UserAgent: e.UserAgent,
+    RequestID: e.RequestID,
+    Details:   e.Details,
+  }
+  if e.ActorID.Valid {
+    resp.ActorID = &e.ActorID.UUID
+  }
+  if e.UserID.Valid {
+    resp.UserID = &e.UserID.UUID
+  }
+  return resp
+}
+
+type auditEventsResponse struct {
+  Events     []auditEventResponse `json:"events"`
+  NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+// parseAuditPage reads the limit and cursor shared by both audit listings
+// into params, and returns the limit. It responds and returns 0 when they
+// are invalid.
+func parseAuditPage(w http.ResponseWriter, r *http.Request, params *database.ListAuditEventsParams) int {
+  limit := defaultAuditLimit
+  if s := r.URL.Query().Get("limit"); s != "" {
+    n, err := strconv.Atoi(s)
+    if err != nil || n <= 0 || n > maxAuditLimit {
+      respondWithError(w, http.StatusBadRequest, "Invalid limit", err)
+      return 0
+    }
+    limit = n
+  }
+  if s := r.URL.Query().Get("cursor"); s != "" {
+    cursor, err := parsePageCursor(s)
+    if err != nil {
+      respondWithError(w, http.StatusBadRequest, "Invalid cursor", err)
+      return 0
+    }
+    params.BeforeCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+    params.BeforeID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+  }
+  // one extra row tells us whether there is another page
+  params.RowLimit = int32(limit + 1)
+  return limit
+}
+
+func (cfg *apiConfig) respondWithAuditEvents(w http.ResponseWriter, params database.ListAuditEventsParams, limit int) {
+  events, err := cfg.db.ListAuditEvents(context.Background(), params)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error listing audit events", err)
+    return
+  }
+
+  var response auditEventsResponse
+  if len(events) > limit {
+    events = events[:limit]
+    last := events[limit-1]
+    response.NextCursor = pageCursor{CreatedAt: last.CreatedAt, ID: last.ID}.String()
+  }
+  response.Events = make([]auditEventResponse, 0, len(events))
+  for _, e := range events {
+    response.Events = append(response.Events, newAuditEventResponse(e))
+  }
+  respondWithJSON(w, http.StatusOK, response)
+}
+
+// handleListAuditEvents is the admin view of the audit trail, newest first.
+// It filters by ?type= (comma-separated), ?actor_id=, ?user_id=, ?ip=,
+// ?since= and ?until=, and with ?format=csv exports every matching event
+// instead of a page.
+func (cfg *apiConfig) handleListAuditEvents(w http.ResponseWriter, r *http.Request) {
+  query := r.URL.Query()
+  params := database.ListAuditEventsParams{EventTypes: []string{}}
+  if s := query.Get("type"); s != "" {
+    for _, t := range strings.Split(s, ",") {
+      if t = strings.TrimSpace(t); t != "" {
+        params.EventTypes = append(params.EventTypes, t)
+      }
+    }
+  }
+  for name, dst := range map[string]*uuid.NullUUID{
+    "actor_id": &params.ActorID,
+    "user_id":  &params.UserID,
+  } {
+    if s := query.Get(name); s != "" {
+      id, err := uuid.Parse(s)
+      if err != nil {
+        respondWithError(w, http.StatusBadRequest, "Invalid "+name, err)
+        return
+      }
+      *dst = uuid.NullUUID{UUID: id, Valid: true}
+    }
+  }
+  if s := query.Get("ip"); s != "" {
+    params.Ip = sql.NullString{String: s, Valid: true}
+  }
+  for name, dst := range map[string]*sql.NullTime{
+    "since": &params.Since,
+    "until": &params.Until,
+  } {
+    if s := query.Get(name); s != "" {
+      t, err := time.Parse(time.RFC3339, s)
+      if err != nil {
+        respondWithError(w, http.StatusBadRequest, "Invalid "+name+", expected RFC 3339", err)
+        return
+      }
+      *dst = sql.NullTime{Time: t.Local(), Valid: true}
+    }
+  }
+
+  if query.Get("format") == "csv" {
+    params.RowLimit = maxAuditExportRows
+    cfg.exportAuditEvents(w, params)
+    return
+  }
+  limit := parseAuditPage(w, r, &params)
+  if limit == 0 {
+    return
+  }
+  cfg.respondWithAuditEvents(w, params, limit)
+}
+
+// exportAuditEvents writes the events matching params as CSV.
+func (cfg *apiConfig) exportAuditEvents(w http.ResponseWriter, params database.ListAuditEventsParams) {
+  events, err := cfg.db.ListAuditEvents(context.Background(), params)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error exporting audit events", err)
+    return
+  }
+
+  w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+  w.Header().Set("Content-Disposition", `attachment; filename="audit-events.csv"`)
+  w.WriteHeader(http.StatusOK)
+  out := csv.NewWriter(w)
+  out.Write([]string{"id", "created_at", "type", "actor_id", "user_id", "ip", "user_agent", "request_id", "details"})
+  for _, e := range events {
+    actorID, userID := "", ""
+    if e.ActorID.Valid {
+      actorID = e.ActorID.UUID.String()
+    }
+    if e.UserID.Valid {
+      userID = e.UserID.UUID.String()
+    }
+    out.Write([]string{
+      e.ID.String(),
+      e.CreatedAt.UTC().Format(time.RFC3339Nano),
+      e.EventType,
+      actorID,
+      userID,
+      e.Ip,
+      e.UserAgent,
+      e.RequestID,
+      string(e.Details),
+    })
+  }
+  out.Flush()
+  if err := out.Error(); err != nil {
+    log.Printf("Error writing audit export: %v", err)
+  }
+}
+
+// handleGetSecurityLog shows users the security events on their own
+// account, newest first.
+func (cfg *apiConfig) handleGetSecurityLog(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  params := database.ListAuditEventsParams{
+    EventTypes: securityLogEvents,
+    UserID:     uuid.NullUUID{UUID: user.ID, Valid: true},
+  }
+  limit := parseAuditPage(w, r, &params)
+  if limit == 0 {
+    return
+  }
+  cfg.respondWithAuditEvents(w, params, limit)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+  "context"
+  "encoding/json"
+  "fmt"
+  "net/http"
+  "time"
+  "chirpy/internal/database"
+  "github.com/google/uuid"
+)
+
+// blockersAmong returns which of userIDs have blocked viewer. Anonymous
+// viewers can't be blocked.
+func (cfg *apiConfig) blockersAmong(ctx context.Context, viewer uuid.NullUUID, userIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+  blockers := map[uuid.UUID]bool{}
+  if !viewer.Valid || len(userIDs) == 0 {
+    return blockers, nil
+  }
+
+  ids, err := cfg.db.ListBlockersAmong(ctx, database.ListBlockersAmongParams{
+    BlockedID: viewer.UUID,
+    UserIds:   userIDs,
+  })
+  if err != nil {
+    return nil, fmt.Errorf("error fetching blocks: %v", err)
+  }
+  for _, id := range ids {
+    blockers[id] = true
+  }
+  return blockers, nil
+}
+
+// isBlockedBy reports whether author has blocked viewer.
+func (cfg *apiConfig) isBlockedBy(ctx context.Context, viewer uuid.NullUUID, author uuid.UUID) (bool, error) {
+  blockers, err := cfg.blockersAmong(ctx, viewer, []uuid.UUID{author})
+  if err != nil {
+    return false, err
+  }
+  return blockers[author], nil
+}
+
+type blockResponse struct {
+  UserID    uuid.UUID `json:"user_id"`
+  CreatedAt time.Time `json:"created_at"`
+}
+
+func (cfg *apiConfig) handleBlockUser(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  var params struct {
+    UserID uuid.UUID `json:"user_id"`
+  }
+  err = json.NewDecoder(r.Body).Decode(&params)
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+    return
+  }
+  if params.UserID == user.ID {
+    respondWithError(w, http.StatusBadRequest, "You can't block yourself", nil)
+    return
+  }
+  _, err = cfg.db.GetUserById(context.Background(), params.UserID)
+  if err != nil {
+    respondWithError(w, http.StatusNotFound, "User not found", nil)
+    return
+  }
+
+  err = cfg.db.CreateBlock(context.Background(), database.CreateBlockParams{
+    BlockerID: user.ID,
+    BlockedID: params.UserID,
+    CreatedAt: time.Now(),
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error blocking user", err)
+    return
+  }
+
+  w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleListBlocks(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  blocks, err := cfg.db.ListBlocks(context.Background(), user.ID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error listing blocks", err)
+    return
+  }
+
+  response := make([]blockResponse, 0, len(blocks))
+  for _, b := range blocks {
+    response = append(response, blockResponse{
+      UserID:    b.BlockedID,
+      CreatedAt: b.CreatedAt,
+    })
+  }
+  respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handleUnblockUser(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+  blockedID, err := uuid.Parse(r.PathValue("userID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+    return
+  }
+
+  deleted, err := cfg.db.DeleteBlock(context.Background(), database.DeleteBlockParams{
+    BlockerID: user.ID,
+    BlockedID: blockedID,
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error unblocking user", err)
+    return
+  }
+  if deleted == 0 {
+    respondWithError(w, http.StatusNotFound, "Block not found", nil)
+    return
+  }
+
+  w.WriteHeader(http.StatusNoContent)
+}
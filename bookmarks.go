@@ -0,0 +1,404 @@
+package main
+
+import (
+  "context"
+  "database/sql"
+  "encoding/base64"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net/http"
+  "strconv"
+  "strings"
+  "time"
+  "unicode/utf8"
+  "chirpy/internal/database"
+  "github.com/google/uuid"
+  "github.com/lib/pq"
+)
+
+const (
+  maxCollectionNameLength = 50
+  defaultBookmarksLimit   = 20
+  maxBookmarksLimit       = 100
+)
+
+// isUniqueViolation reports whether err is Postgres rejecting a duplicate key.
+func isUniqueViolation(err error) bool {
+  var pqErr *pq.Error
+  return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// pageCursor marks the last row of a page of rows ordered newest first by
+// when they were made, with an ID breaking ties: the chirp ID for bookmarks,
+// the event ID for audit events.
+type pageCursor struct {
+  CreatedAt time.Time
+  ID        uuid.UUID
+}
+
+func (c pageCursor) String() string {
+  raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+  return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func parsePageCursor(s string) (pageCursor, error) {
+  raw, err := base64.RawURLEncoding.DecodeString(s)
+  if err != nil {
+    return pageCursor{}, err
+  }
+  createdAt, id, ok := strings.Cut(string(raw), "|")
+  if !ok {
+    return pageCursor{}, errors.New("malformed cursor")
+  }
+  var c pageCursor
+  c.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt)
+  if err != nil {
+    return pageCursor{}, err
+  }
+  c.ID, err = uuid.Parse(id)
+  if err != nil {
+    return pageCursor{}, err
+  }
+  return c, nil
+}
+
+type bookmarksResponse struct {
+  Chirps     []chirpResponse `json:"chirps"`
+  NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+type collectionResponse struct {
+  ID        uuid.UUID `json:"id"`
+  CreatedAt time.Time `json:"created_at"`
+  UpdatedAt time.Time `json:"updated_at"`
+  Name      string    `json:"name"`
+}
+
+func newCollectionResponse(c database.BookmarkCollection) collectionResponse {
+  return collectionResponse{
+    ID:        c.ID,
+    CreatedAt: c.CreatedAt,
+    UpdatedAt: c.UpdatedAt,
+    Name:      c.Name,
+  }
+}
+
+// validateCollectionName returns the trimmed name, or a message for the user
+// if it can't be used.
+func validateCollectionName(name string) (string, string) {
+  name = strings.TrimSpace(name)
+  if name == "" {
+    return "", "Collection name can't be empty"
+  }
+  if utf8.RuneCountInString(name) > maxCollectionNameLength {
+    return "", fmt.Sprintf("Collection name can be at most %d characters", maxCollectionNameLength)
+  }
+  return name, ""
+}
+
+// handleAddBookmark bookmarks a chirp, optionally into one of the caller's
+// collections. Bookmarking a chirp again moves it to the given collection.
+func (cfg *apiConfig) handleAddBookmark(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  var params struct {
+    ChirpID      uuid.UUID  `json:"chirp_id"`
+    CollectionID *uuid.UUID `json:"collection_id"`
+  }
+  err = json.NewDecoder(r.Body).Decode(&params)
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+    return
+  }
+
+  chirp, err := cfg.db.GetOneChirp(context.Background(), params.ChirpID)
+  if errors.Is(err, sql.ErrNoRows) {
+    respondWithError(w, http.StatusNotFound, "Chirp not found", nil)
+    return
+  }
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching chirp", err)
+    return
+  }
+  blocked, err := cfg.isBlockedBy(context.Background(), uuid.NullUUID{UUID: user.ID, Valid: true}, chirp.UserID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching chirp", err)
+    return
+  }
+  if blocked {
+    respondWithError(w, http.StatusNotFound, "Chirp not found", nil)
+    return
+  }
+
+  var collectionID uuid.NullUUID
+  if params.CollectionID != nil {
+    _, err := cfg.db.GetBookmarkCollection(context.Background(), database.GetBookmarkCollectionParams{
+      ID:     *params.CollectionID,
+      UserID: user.ID,
+    })
+    if errors.Is(err, sql.ErrNoRows) {
+      respondWithError(w, http.StatusNotFound, "Collection not found", nil)
+      return
+    }
+    if err != nil {
+      respondWithError(w, http.StatusInternalServerError, "Error fetching collection", err)
+      return
+    }
+    collectionID = uuid.NullUUID{UUID: *params.CollectionID, Valid: true}
+  }
+
+  err = cfg.db.UpsertBookmark(context.Background(), database.UpsertBookmarkParams{
+    UserID:       user.ID,
+    ChirpID:      chirp.ID,
+    CollectionID: collectionID,
+    CreatedAt:    time.Now(),
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error saving bookmark", err)
+    return
+  }
+
+  w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleRemoveBookmark(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+  chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+    return
+  }
+
+  deleted, err := cfg.db.DeleteBookmark(context.Background(), database.DeleteBookmarkParams{
+    UserID:  user.ID,
+    ChirpID: chirpID,
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error removing bookmark", err)
+    return
+  }
+  if deleted == 0 {
+    respondWithError(w, http.StatusNotFound, "Bookmark not found", nil)
+    return
+  }
+
+  w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListBookmarks pages through the caller's bookmarks, newest first.
+// Chirps that have since been deleted, or whose author is blocked in either
+// direction, are left out.
+func (cfg *apiConfig) handleListBookmarks(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  limit := defaultBookmarksLimit
+  if s := r.URL.Query().Get("limit"); s != "" {
+    limit, err = strconv.Atoi(s)
+    if err != nil || limit <= 0 || limit > maxBookmarksLimit {
+      respondWithError(w, http.StatusBadRequest, "Invalid limit", err)
+      return
+    }
+  }
+  params := database.ListBookmarksParams{
+    UserID: user.ID,
+    // one extra row tells us whether there is another page
+    RowLimit: int32(limit + 1),
+  }
+  if s := r.URL.Query().Get("collection_id"); s != "" {
+    id, err := uuid.Parse(s)
+    if err != nil {
+      respondWithError(w, http.StatusBadRequest, "Invalid collection ID", err)
+      return
+    }
+    params.CollectionID = uuid.NullUUID{UUID: id, Valid: true}
+  }
+  if s := r.URL.Query().Get("cursor"); s != "" {
+    cursor, err := parsePageCursor(s)
+    if err != nil {
+      respondWithError(w, http.StatusBadRequest, "Invalid cursor", err)
+      return
+    }
+    params.BeforeCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+    params.BeforeChirpID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+  }
+
+  rows, err := cfg.db.ListBookmarks(context.Background(), params)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error listing bookmarks", err)
+    return
+  }
+
+  var response bookmarksResponse
+  if len(rows) > limit {
+    rows = rows[:limit]
+    last := rows[limit-1]
+    response.NextCursor = pageCursor{CreatedAt: last.BookmarkedAt, ID: last.ID}.String()
+  }
+  chirps := make([]database.Chirp, 0, len(rows))
+  for _, b := range rows {
+    chirps = append(chirps, database.Chirp{
+      ID:        b.ID,
+      CreatedAt: b.CreatedAt,
+      UpdatedAt: b.UpdatedAt,
+      Body:      b.Body,
+      UserID:    b.UserID,
+      QuoteOf:   b.QuoteOf,
+      HeldAt:    b.HeldAt,
+    })
+  }
+  response.Chirps, err = cfg.chirpResponses(context.Background(), chirps, uuid.NullUUID{UUID: user.ID, Valid: true})
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error listing bookmarks", err)
+    return
+  }
+  respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handleCreateCollection(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  var params struct {
+    Name string `json:"name"`
+  }
+  err = json.NewDecoder(r.Body).Decode(&params)
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+    return
+  }
+  name, msg := validateCollectionName(params.Name)
+  if msg != "" {
+    respondWithError(w, http.StatusBadRequest, msg, nil)
+    return
+  }
+
+  collection, err := cfg.db.CreateBookmarkCollection(context.Background(), database.CreateBookmarkCollectionParams{
+    ID:        uuid.New(),
+    CreatedAt: time.Now(),
+    UserID:    user.ID,
+    Name:      name,
+  })
+  if isUniqueViolation(err) {
+    respondWithError(w, http.StatusConflict, "You already have a collection with that name", nil)
+    return
+  }
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error creating collection", err)
+    return
+  }
+
+  respondWithJSON(w, http.StatusCreated, newCollectionResponse(collection))
+}
+
+func (cfg *apiConfig) handleListCollections(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  collections, err := cfg.db.ListBookmarkCollections(context.Background(), user.ID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error listing collections", err)
+    return
+  }
+
+  response := make([]collectionResponse, 0, len(collections))
+  for _, c := range collections {
+    response = append(response, newCollectionResponse(c))
+  }
+  respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handleRenameCollection(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+  collectionID, err := uuid.Parse(r.PathValue("collectionID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid collection ID", err)
+    return
+  }
+
+  var params struct {
+    Name string `json:"name"`
+  }
+  err = json.NewDecoder(r.Body).Decode(&params)
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+    return
+  }
+  name, msg := validateCollectionName(params.Name)
+  if msg != "" {
+    respondWithError(w, http.StatusBadRequest, msg, nil)
+    return
+  }
+
+  collection, err := cfg.db.RenameBookmarkCollection(context.Background(), database.RenameBookmarkCollectionParams{
+    ID:     collectionID,
+    UserID: user.ID,
+    Name:   name,
+  })
+  if errors.Is(err, sql.ErrNoRows) {
+    respondWithError(w, http.StatusNotFound, "Collection not found", nil)
+    return
+  }
+  if isUniqueViolation(err) {
+    respondWithError(w, http.StatusConflict, "You already have a collection with that name", nil)
+    return
+  }
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error renaming collection", err)
+    return
+  }
+
+  respondWithJSON(w, http.StatusOK, newCollectionResponse(collection))
+}
+
+// handleDeleteCollection removes a collection. Its bookmarks are kept and go
+// back to being uncollected.
+func (cfg *apiConfig) handleDeleteCollection(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+  collectionID, err := uuid.Parse(r.PathValue("collectionID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid collection ID", err)
+    return
+  }
+
+  deleted, err := cfg.db.DeleteBookmarkCollection(context.Background(), database.DeleteBookmarkCollectionParams{
+    ID:     collectionID,
+    UserID: user.ID,
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error deleting collection", err)
+    return
+  }
+  if deleted == 0 {
+    respondWithError(w, http.StatusNotFound, "Collection not found", nil)
+    return
+  }
+
+  w.WriteHeader(http.StatusNoContent)
+}
@@ -1,8 +1,11 @@
 package main 
 
 import (
+  "chirpy/internal/activitypub"
   "chirpy/internal/database"
   "chirpy/internal/auth"
+  "chirpy/internal/contentfilter"
+  "chirpy/internal/webhooks"
   "time"
   "log"
   "fmt"
@@ -11,23 +14,179 @@ import (
   "net/http"
   "encoding/json"
   "context"
+  "unicode/utf8"
   "github.com/google/uuid"
   _ "github.com/lib/pq"
 )
 
 type Chirp struct {
-	Body   string `json:"body"`
-	UserID string `json:"user_id"`
+	Body    string      `json:"body"`
+	UserID  string      `json:"user_id"`
+	Poll    *pollParams `json:"poll"`
+	QuoteOf *uuid.UUID  `json:"quote_of"`
 }
 
-func (cfg *apiConfig) handleCreateChirp(w http.ResponseWriter, r *http.Request) {
-  var chirp Chirp
+// chirpResponse is a chirp as the API returns it: the stored chirp plus
+// what is attached to it.
+type chirpResponse struct {
+  database.Chirp
+  // shadows Chirp.DeletedAt; deleted chirps are never rendered
+  DeletedAt *time.Time `json:"deleted_at,omitempty"`
+  // shadows Chirp.HeldAt; only the author sees held chirps
+  HeldAt *time.Time      `json:"held_at,omitempty"`
+  Pinned bool            `json:"pinned,omitempty"`
+  Poll   *pollResponse   `json:"poll,omitempty"`
+  Quoted *quotedChirp    `json:"quoted,omitempty"`
+  Filter *filterResponse `json:"filter,omitempty"`
+}
 
-  const maxChirpLength = 140 
-  if len(chirp.Body) > maxChirpLength {
-    respondWithError(w, http.StatusBadRequest, "Chirp is too long", nil)
-    return 
+// filterResponse tells authors which content filter rules their chirp
+// tripped.
+type filterResponse struct {
+  Action  string                `json:"action"`
+  Matches []contentfilter.Match `json:"matches"`
+}
+
+func newFilterResponse(result contentfilter.Result) *filterResponse {
+  if len(result.Matches) == 0 {
+    return nil
+  }
+  return &filterResponse{
+    Action:  result.Action,
+    Matches: result.Matches,
+  }
+}
+
+// chirpResponses renders chirps for viewer, who may be anonymous. Chirps
+// the viewer isn't allowed to see are left out.
+func (cfg *apiConfig) chirpResponses(ctx context.Context, chirps []database.Chirp, viewer uuid.NullUUID) ([]chirpResponse, error) {
+  chirps, err := cfg.visibleChirps(ctx, chirps, viewer)
+  if err != nil {
+    return nil, err
+  }
+  ids := make([]uuid.UUID, 0, len(chirps))
+  for _, c := range chirps {
+    ids = append(ids, c.ID)
+  }
+  polls, err := cfg.pollsFor(ctx, ids, viewer)
+  if err != nil {
+    return nil, err
+  }
+  quoted, err := cfg.quotedChirpsFor(ctx, chirps, viewer)
+  if err != nil {
+    return nil, err
+  }
+
+  response := make([]chirpResponse, 0, len(chirps))
+  for _, c := range chirps {
+    resp := chirpResponse{
+      Chirp: c,
+      Poll:  polls[c.ID],
+    }
+    if c.HeldAt.Valid {
+      resp.HeldAt = &c.HeldAt.Time
+    }
+    if c.QuoteOf.Valid {
+      resp.Quoted = quoted[c.ID]
+    }
+    response = append(response, resp)
+  }
+  return response, nil
+}
+
+// chirpRejection is a chirp body that failed validation. The message is
+// meant for the author. ruleID is set when a content filter rule rejected
+// the chirp.
+type chirpRejection struct {
+  msg    string
+  ruleID uuid.UUID
+}
+
+func (e chirpRejection) Error() string {
+  return e.msg
+}
+
+// filterText runs text through the content filter. Text a rule rejects comes
+// back as a chirpRejection; otherwise the result holds the text with masked
+// words replaced and whether it should be held for review.
+func (cfg *apiConfig) filterText(text string) (contentfilter.Result, error) {
+  result := cfg.contentFilter.Check(text)
+  if result.Action == contentfilter.ActionReject {
+    return result, chirpRejection{msg: "Chirp contains content that isn't allowed", ruleID: result.RuleID}
+  }
+  return result, nil
+}
+
+// validateChirp checks a chirp body against the author's plan and the
+// content filter. Every path that creates a chirp goes through here.
+func (cfg *apiConfig) validateChirp(ctx context.Context, userID uuid.UUID, body string) (contentfilter.Result, error) {
+  ent, err := cfg.entitlementsFor(ctx, userID)
+  if err != nil {
+    return contentfilter.Result{}, fmt.Errorf("error fetching entitlements: %v", err)
   }
+  if utf8.RuneCountInString(body) > ent.MaxChirpLength {
+    return contentfilter.Result{}, chirpRejection{msg: "Chirp is too long"}
+  }
+  return cfg.filterText(body)
+}
+
+// chirpHold is why a chirp is held for review: the content filter rule that
+// held it, its spam score, or both.
+type chirpHold struct {
+  ruleID    uuid.NullUUID
+  spamScore sql.NullInt32
+}
+
+func (h chirpHold) held() bool {
+  return h.ruleID.Valid || h.spamScore.Valid
+}
+
+// holdChirp hides a chirp from everyone but its author and opens a
+// moderation case for it.
+func holdChirp(ctx context.Context, qtx *database.Queries, chirp database.Chirp, hold chirpHold) (database.Chirp, error) {
+  now := time.Now()
+  chirp.HeldAt = sql.NullTime{Time: now, Valid: true}
+  err := qtx.HoldChirp(ctx, database.HoldChirpParams{
+    ID:     chirp.ID,
+    HeldAt: chirp.HeldAt,
+  })
+  if err != nil {
+    return database.Chirp{}, fmt.Errorf("error holding chirp: %v", err)
+  }
+  _, err = qtx.OpenModerationCase(ctx, database.OpenModerationCaseParams{
+    ID:           uuid.New(),
+    CreatedAt:    now,
+    TargetKey:    "chirp:" + chirp.ID.String(),
+    UserID:       chirp.UserID,
+    ChirpID:      uuid.NullUUID{UUID: chirp.ID, Valid: true},
+    FilterRuleID: hold.ruleID,
+    SpamScore:    hold.spamScore,
+  })
+  if err != nil {
+    return database.Chirp{}, fmt.Errorf("error opening moderation case: %v", err)
+  }
+  return chirp, nil
+}
+
+// respondWithChirpError reports an error from validateChirp.
+func respondWithChirpError(w http.ResponseWriter, err error) {
+  var rejection chirpRejection
+  if errors.As(err, &rejection) {
+    if rejection.ruleID != uuid.Nil {
+      respondWithJSON(w, http.StatusBadRequest, map[string]string{
+        "error":   rejection.msg,
+        "rule_id": rejection.ruleID.String(),
+      })
+      return
+    }
+    respondWithError(w, http.StatusBadRequest, rejection.msg, nil)
+    return
+  }
+  respondWithError(w, http.StatusInternalServerError, "Error validating chirp", err)
+}
+
+func (cfg *apiConfig) handleCreateChirp(w http.ResponseWriter, r *http.Request) {
+  var chirp Chirp
 
   token, err := auth.GetBearerToken(r.Header)
   if err != nil {
@@ -36,7 +195,7 @@ func (cfg *apiConfig) handleCreateChirp(w http.ResponseWriter, r *http.Request)
     return
   }
 
-  userID, err := auth.ValidateJWT(token, cfg.SecretKey)
+  userID, err := cfg.validateAccessToken(token)
   if err != nil {
     fmt.Printf("ValidateJWT error: %v\n", err) // Debug log
     w.WriteHeader(http.StatusUnauthorized)
@@ -50,22 +209,125 @@ func (cfg *apiConfig) handleCreateChirp(w http.ResponseWriter, r *http.Request)
     w.WriteHeader(http.StatusInternalServerError)
     return
   }
-  cleanBodyMessage := cleanChirpBody(chirp.Body)
+
+  filtered, err := cfg.validateChirp(context.Background(), userID, chirp.Body)
+  if err != nil {
+    respondWithChirpError(w, err)
+    return
+  }
+  if chirp.Poll != nil {
+    if msg := chirp.Poll.validate(time.Now()); msg != "" {
+      respondWithError(w, http.StatusBadRequest, msg, nil)
+      return
+    }
+    // poll options are filtered like the body, and a hold on any of them
+    // holds the whole chirp
+    for i, option := range chirp.Poll.Options {
+      result, err := cfg.filterText(option)
+      if err != nil {
+        respondWithChirpError(w, err)
+        return
+      }
+      chirp.Poll.Options[i] = result.Text
+      filtered.Matches = append(filtered.Matches, result.Matches...)
+      if result.Action == contentfilter.ActionHold && filtered.Action != contentfilter.ActionHold {
+        filtered.Action = result.Action
+        filtered.RuleID = result.RuleID
+      }
+    }
+  }
+  var quoted database.Chirp
+  if chirp.QuoteOf != nil {
+    var msg string
+    quoted, msg, err = cfg.checkQuotable(context.Background(), userID, *chirp.QuoteOf)
+    if err != nil {
+      respondWithError(w, http.StatusInternalServerError, "Error fetching quoted chirp", err)
+      return
+    }
+    if msg != "" {
+      respondWithError(w, http.StatusBadRequest, msg, nil)
+      return
+    }
+  }
+
+  var hold chirpHold
+  if filtered.Action == contentfilter.ActionHold {
+    hold.ruleID = uuid.NullUUID{UUID: filtered.RuleID, Valid: true}
+  }
+  err = cfg.checkSpam(context.Background(), userID, filtered.Text, &hold)
+  if err != nil {
+    respondWithChirpError(w, err)
+    return
+  }
+
+  tx, err := cfg.sqlDB.BeginTx(context.Background(), nil)
+  if err != nil {
+    w.WriteHeader(http.StatusInternalServerError)
+    return
+  }
+  defer tx.Rollback()
+  qtx := cfg.db.WithTx(tx)
+
   //user_id := uuid.MustParse(chirp.UserID)
   postParams := database.CreateChirpParams {
     ID:  uuid.New(),
     CreatedAt:  time.Now(),
     UpdatedAt:  time.Now(),
-    Body:       cleanBodyMessage,
+    Body:       filtered.Text,
     UserID:     userID, 
   } 
-  post, err := cfg.db.CreateChirp(context.Background(), postParams)
+  if chirp.QuoteOf != nil {
+    postParams.QuoteOf = uuid.NullUUID{UUID: *chirp.QuoteOf, Valid: true}
+  }
+  post, err := qtx.CreateChirp(context.Background(), postParams)
+  if err != nil {
+    w.WriteHeader(http.StatusInternalServerError)
+    return
+  }
+  if chirp.QuoteOf != nil {
+    err = createQuoteSnapshot(context.Background(), qtx, post.ID, quoted)
+    if err != nil {
+      respondWithError(w, http.StatusInternalServerError, "Error creating chirp", err)
+      return
+    }
+  }
+  if chirp.Poll != nil {
+    err = createPoll(context.Background(), qtx, post.ID, *chirp.Poll)
+    if err != nil {
+      respondWithError(w, http.StatusInternalServerError, "Error creating poll", err)
+      return
+    }
+  }
+  if hold.held() {
+    post, err = holdChirp(context.Background(), qtx, post, hold)
+    if err != nil {
+      respondWithError(w, http.StatusInternalServerError, "Error creating chirp", err)
+      return
+    }
+  }
+  err = tx.Commit()
   if err != nil {
     w.WriteHeader(http.StatusInternalServerError)
     return
   }
 
-  data, err := json.Marshal(post)
+  // held chirps are announced if and when a moderator releases them
+  if !post.HeldAt.Valid {
+    cfg.publishEvent(context.Background(), webhooks.Event{
+      Type:   webhooks.EventChirpCreated,
+      UserID: userID,
+      Data:   chirpResponse{Chirp: post},
+    })
+    cfg.federateChirp(context.Background(), post, activitypub.TypeCreate)
+  }
+
+  response, err := cfg.chirpResponses(context.Background(), []database.Chirp{post}, uuid.NullUUID{UUID: userID, Valid: true})
+  if err != nil {
+    w.WriteHeader(http.StatusInternalServerError)
+    return
+  }
+  response[0].Filter = newFilterResponse(filtered)
+  data, err := json.Marshal(response[0])
   if err != nil {
     w.WriteHeader(http.StatusInternalServerError)
     return 
@@ -77,6 +339,15 @@ func (cfg *apiConfig) handleCreateChirp(w http.ResponseWriter, r *http.Request)
   return
 }
 	
+// chirpsByAuthors returns the chirps written by any of authors, oldest first
+// unless sortVal is "desc".
+func (cfg *apiConfig) chirpsByAuthors(ctx context.Context, authors []uuid.UUID, sortVal string) ([]database.Chirp, error) {
+  if sortVal == "desc" {
+    return cfg.db.GetChirpsByAuthorsDesc(ctx, authors)
+  }
+  return cfg.db.GetChirpsByAuthors(ctx, authors)
+}
+
 func (cfg *apiConfig) handleGetChirps(w http.ResponseWriter, r *http.Request) {
   if r.Method != http.MethodGet {
     http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -91,16 +362,18 @@ func (cfg *apiConfig) handleGetChirps(w http.ResponseWriter, r *http.Request) {
   var chirps []database.Chirp
   var err error
 
+  var pinned []database.Chirp
   if authorID != "" {
-    if sortVal == "desc"{
-      chirps, err = cfg.db.GetChirpsByAuthorDesc(context.Background(), uID)
-      if err != nil {
-        http.Error(w, "Internal server errror:", http.StatusInternalServerError)
-      }
-    } else {
-      chirps, err = cfg.db.GetChirpsByAuthor(context.Background(), uID)
+    chirps, err = cfg.chirpsByAuthors(context.Background(), []uuid.UUID{uID}, sortVal)
+    if err != nil {
+      http.Error(w, "Internal server errror:", http.StatusInternalServerError)
+      return
+    }
+    if r.URL.Query().Get("include_pinned") == "true" {
+      pinned, err = cfg.db.GetPinnedChirps(context.Background(), uID)
       if err != nil {
-        http.Error(w, "Internal server errror:", http.StatusInternalServerError)
+        http.Error(w, "Internal server error:", http.StatusInternalServerError)
+        return
       }
     }
   } else {
@@ -119,11 +392,17 @@ func (cfg *apiConfig) handleGetChirps(w http.ResponseWriter, r *http.Request) {
     }
   }
 
+  response, err := cfg.chirpResponsesWithPinned(context.Background(), pinned, chirps, cfg.viewerID(r))
+  if err != nil {
+    http.Error(w, "Internal server error:", http.StatusInternalServerError)
+    return
+  }
+
   w.Header().Set("Content-Type", "application/json; charset=utf-8")
   w.WriteHeader(http.StatusOK)
 
   encoder := json.NewEncoder(w)
-  err = encoder.Encode(chirps)
+  err = encoder.Encode(response)
   if err != nil {
     log.Printf("Error encoding response: %w", err)
     return
@@ -153,35 +432,47 @@ func (cfg *apiConfig) handleGetOneChirp(w http.ResponseWriter, r *http.Request)
     return
   }
 
+  response, err := cfg.chirpResponses(context.Background(), []database.Chirp{chirp}, cfg.viewerID(r))
+  if err != nil {
+    http.Error(w, "Internal server error", http.StatusInternalServerError)
+    return
+  }
+  if len(response) == 0 {
+    w.WriteHeader(http.StatusNotFound)
+    return
+  }
+
   w.Header().Set("Content-Type", "application/json; charset=utf-8")
   w.WriteHeader(http.StatusOK)
 
   encoder := json.NewEncoder(w)
-  err = encoder.Encode(chirp)
+  err = encoder.Encode(response[0])
   if err != nil {
     log.Printf("Error encoding response: %v", err)
     return
   }
 }
 
-func (cfg *apiConfig) handleDeleteOneChirp(w http.ResponseWriter, r *http.Request) {
+// ownChirp fetches the chirp named by the chirpID path value and checks
+// that the caller wrote it. If not, it has already responded.
+func (cfg *apiConfig) ownChirp(w http.ResponseWriter, r *http.Request) (database.Chirp, bool) {
   chirpId := r.PathValue("chirpID")
   parsedID, err := uuid.Parse(chirpId)
   if err != nil {
     w.WriteHeader(http.StatusBadRequest) // 400 for invalid ID format
-    return
+    return database.Chirp{}, false
   }
 
   token, err := auth.GetBearerToken(r.Header)
   if err != nil {
     w.WriteHeader(http.StatusUnauthorized) // 401 for missing or invalid token
-    return
+    return database.Chirp{}, false
   }
 
-  userID, err := auth.ValidateJWT(token, cfg.SecretKey)
+  userID, err := cfg.validateAccessToken(token)
   if err != nil {
     w.WriteHeader(http.StatusUnauthorized) // 401 for failed validation
-    return
+    return database.Chirp{}, false
   }
 
   // Fetch chirp details to verify ownership
@@ -189,20 +480,29 @@ func (cfg *apiConfig) handleDeleteOneChirp(w http.ResponseWriter, r *http.Reques
   if err != nil {
     if errors.Is(err, sql.ErrNoRows) {
       w.WriteHeader(http.StatusNotFound) // 404 if chirp not found
-      return
+      return database.Chirp{}, false
     }
     http.Error(w, "Internal server error", http.StatusInternalServerError)
-    return
+    return database.Chirp{}, false
   }
 
   if chirp.UserID != userID {
     w.WriteHeader(http.StatusForbidden) // 403 if user is not the author
+    return database.Chirp{}, false
+  }
+  return chirp, true
+}
+
+func (cfg *apiConfig) handleDeleteOneChirp(w http.ResponseWriter, r *http.Request) {
+  chirp, ok := cfg.ownChirp(w, r)
+  if !ok {
     return
   }
+  userID := chirp.UserID
 
   // Proceed with deletion if authorized
-  err = cfg.db.DeleteOneChirp(context.Background(), database.DeleteOneChirpParams{
-    ID: parsedID,
+  err := cfg.db.DeleteOneChirp(context.Background(), database.DeleteOneChirpParams{
+    ID: chirp.ID,
     UserID: userID,
   })
 
@@ -212,5 +512,98 @@ func (cfg *apiConfig) handleDeleteOneChirp(w http.ResponseWriter, r *http.Reques
     return
   }
 
+  cfg.publishEvent(context.Background(), webhooks.Event{
+    Type:   webhooks.EventChirpDeleted,
+    UserID: userID,
+    Data:   map[string]uuid.UUID{"id": chirp.ID, "user_id": chirp.UserID},
+  })
+  cfg.federateChirp(context.Background(), chirp, activitypub.TypeDelete)
+
   w.WriteHeader(http.StatusNoContent)
 }
+
+// handleUpdateChirp lets authors edit a chirp's body when their plan allows
+// editing.
+func (cfg *apiConfig) handleUpdateChirp(w http.ResponseWriter, r *http.Request) {
+  chirp, ok := cfg.ownChirp(w, r)
+  if !ok {
+    return
+  }
+  userID := chirp.UserID
+
+  ent, err := cfg.entitlementsFor(context.Background(), userID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching entitlements", err)
+    return
+  }
+  if !ent.CanEditChirps {
+    respondWithError(w, http.StatusForbidden, "Editing chirps requires Chirpy Red", nil)
+    return
+  }
+
+  var params Chirp
+  err = json.NewDecoder(r.Body).Decode(&params)
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+    return
+  }
+  filtered, err := cfg.validateChirp(context.Background(), userID, params.Body)
+  if err != nil {
+    respondWithChirpError(w, err)
+    return
+  }
+  var hold chirpHold
+  if filtered.Action == contentfilter.ActionHold {
+    hold.ruleID = uuid.NullUUID{UUID: filtered.RuleID, Valid: true}
+  }
+  err = cfg.checkSpam(context.Background(), userID, filtered.Text, &hold)
+  if err != nil {
+    respondWithChirpError(w, err)
+    return
+  }
+
+  tx, err := cfg.sqlDB.BeginTx(context.Background(), nil)
+  if err != nil {
+    http.Error(w, "Internal server error", http.StatusInternalServerError)
+    return
+  }
+  defer tx.Rollback()
+  qtx := cfg.db.WithTx(tx)
+
+  updated, err := qtx.UpdateChirpBody(context.Background(), database.UpdateChirpBodyParams{
+    ID:   chirp.ID,
+    Body: filtered.Text,
+  })
+  if err != nil {
+    http.Error(w, "Internal server error", http.StatusInternalServerError)
+    return
+  }
+  if hold.held() && !updated.HeldAt.Valid {
+    updated, err = holdChirp(context.Background(), qtx, updated, hold)
+    if err != nil {
+      http.Error(w, "Internal server error", http.StatusInternalServerError)
+      return
+    }
+  }
+  err = tx.Commit()
+  if err != nil {
+    http.Error(w, "Internal server error", http.StatusInternalServerError)
+    return
+  }
+
+  // an edit that gets the chirp held takes it back from other servers
+  switch {
+  case !updated.HeldAt.Valid:
+    cfg.federateChirp(context.Background(), updated, activitypub.TypeUpdate)
+  case !chirp.HeldAt.Valid:
+    cfg.federateChirp(context.Background(), updated, activitypub.TypeDelete)
+  }
+
+  response, err := cfg.chirpResponses(context.Background(), []database.Chirp{updated}, uuid.NullUUID{UUID: userID, Valid: true})
+  if err != nil {
+    http.Error(w, "Internal server error", http.StatusInternalServerError)
+    return
+  }
+  response[0].Filter = newFilterResponse(filtered)
+  respondWithJSON(w, http.StatusOK, response[0])
+}
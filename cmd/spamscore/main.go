@@ -0,0 +1,95 @@
+// Command spamscore scores a corpus of chirps with the spam heuristics, for
+// tuning the thresholds. Each line is either a fixture,
+//
+//   {"expect": "hold", "input": {...}}
+//
+// or a "spam score:" line from the server log, in which case the verdict the
+// server reached is what's expected. Lines whose verdict differs are marked
+// with a "!".
+//
+//   go run ./cmd/spamscore -hold 50 -reject 100 cmd/spamscore/corpus.jsonl
+package main
+
+import (
+  "bufio"
+  "encoding/json"
+  "flag"
+  "fmt"
+  "io"
+  "log"
+  "os"
+  "strings"
+  "chirpy/internal/spam"
+)
+
+type fixture struct {
+  Expect string      `json:"expect"`
+  Input  spam.Input  `json:"input"`
+  Result spam.Result `json:"result"`
+}
+
+func main() {
+  config := spam.DefaultConfig
+  flag.IntVar(&config.HoldThreshold, "hold", config.HoldThreshold, "score at which chirps are held")
+  flag.IntVar(&config.RejectThreshold, "reject", config.RejectThreshold, "score at which chirps are rejected")
+  flag.Parse()
+
+  var in io.Reader = os.Stdin
+  if flag.NArg() > 0 {
+    f, err := os.Open(flag.Arg(0))
+    if err != nil {
+      log.Fatal(err)
+    }
+    defer f.Close()
+    in = f
+  }
+
+  counts := map[string]int{}
+  mismatches := 0
+  scanner := bufio.NewScanner(in)
+  scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+  for n := 1; scanner.Scan(); n++ {
+    line := strings.TrimSpace(scanner.Text())
+    if i := strings.Index(line, "spam score: "); i >= 0 {
+      line = line[i+len("spam score: "):]
+    }
+    if line == "" || strings.HasPrefix(line, "#") {
+      continue
+    }
+    var f fixture
+    err := json.Unmarshal([]byte(line), &f)
+    if err != nil {
+      log.Printf("line %d: %v", n, err)
+      continue
+    }
+    if f.Expect == "" {
+      f.Expect = f.Result.Verdict
+    }
+
+    result := config.Score(f.Input)
+    counts[result.Verdict]++
+    mark := " "
+    if f.Expect != "" && f.Expect != result.Verdict {
+      mark = "!"
+      mismatches++
+    }
+    var signals []string
+    for _, s := range result.Signals {
+      signals = append(signals, fmt.Sprintf("%s=%d (%s)", s.Name, s.Score, s.Detail))
+    }
+    fmt.Printf("%s %4d %-6s %-6s %q %s\n", mark, result.Score, result.Verdict, f.Expect, truncate(f.Input.Body, 40), strings.Join(signals, ", "))
+  }
+  if err := scanner.Err(); err != nil {
+    log.Fatal(err)
+  }
+
+  fmt.Printf("\nallow %d, hold %d, reject %d, %d not as expected\n", counts[spam.VerdictAllow], counts[spam.VerdictHold], counts[spam.VerdictReject], mismatches)
+}
+
+func truncate(s string, n int) string {
+  r := []rune(s)
+  if len(r) <= n {
+    return s
+  }
+  return string(r[:n]) + "…"
+}
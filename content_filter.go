@@ -0,0 +1,248 @@
+package main
+
+import (
+  "context"
+  "database/sql"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "log"
+  "net/http"
+  "strings"
+  "time"
+  "unicode/utf8"
+  "chirpy/internal/contentfilter"
+  "chirpy/internal/database"
+  "github.com/google/uuid"
+)
+
+const (
+  maxFilterPatternLength = 500
+  maxTestFilterRules     = 100
+)
+
+type filterRuleParams struct {
+  Kind    string `json:"kind"`
+  Pattern string `json:"pattern"`
+  Action  string `json:"action"`
+  Enabled *bool  `json:"enabled"`
+}
+
+// validate returns a message for the admin if the rule can't be saved, or
+// "" if it can. Rules are enabled unless they say otherwise.
+func (p *filterRuleParams) validate() string {
+  p.Pattern = strings.TrimSpace(p.Pattern)
+  if p.Enabled == nil {
+    enabled := true
+    p.Enabled = &enabled
+  }
+  if utf8.RuneCountInString(p.Pattern) > maxFilterPatternLength {
+    return fmt.Sprintf("Patterns can be at most %d characters", maxFilterPatternLength)
+  }
+  err := contentfilter.Compile(p.rule(uuid.Nil))
+  if err != nil {
+    return "Invalid rule: " + err.Error()
+  }
+  return ""
+}
+
+func (p filterRuleParams) rule(id uuid.UUID) contentfilter.Rule {
+  return contentfilter.Rule{
+    ID:      id,
+    Kind:    p.Kind,
+    Pattern: p.Pattern,
+    Action:  p.Action,
+  }
+}
+
+type filterRuleResponse struct {
+  ID        uuid.UUID `json:"id"`
+  CreatedAt time.Time `json:"created_at"`
+  UpdatedAt time.Time `json:"updated_at"`
+  Kind      string    `json:"kind"`
+  Pattern   string    `json:"pattern"`
+  Action    string    `json:"action"`
+  Enabled   bool      `json:"enabled"`
+}
+
+func newFilterRuleResponse(r database.ContentFilterRule) filterRuleResponse {
+  return filterRuleResponse{
+    ID:        r.ID,
+    CreatedAt: r.CreatedAt,
+    UpdatedAt: r.UpdatedAt,
+    Kind:      r.Kind,
+    Pattern:   r.Pattern,
+    Action:    r.Action,
+    Enabled:   r.Enabled,
+  }
+}
+
+// reloadContentFilter applies a rule change on this instance right away.
+// Other instances pick it up on their next periodic reload, as does this one
+// if the reload fails.
+func (cfg *apiConfig) reloadContentFilter() {
+  err := cfg.contentFilter.Reload(context.Background())
+  if err != nil {
+    log.Printf("%v", err)
+  }
+}
+
+func (cfg *apiConfig) handleListFilterRules(w http.ResponseWriter, r *http.Request) {
+  rules, err := cfg.db.ListFilterRules(context.Background())
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error listing rules", err)
+    return
+  }
+
+  response := make([]filterRuleResponse, 0, len(rules))
+  for _, rule := range rules {
+    response = append(response, newFilterRuleResponse(rule))
+  }
+  respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handleCreateFilterRule(w http.ResponseWriter, r *http.Request) {
+  var params filterRuleParams
+  err := json.NewDecoder(r.Body).Decode(&params)
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+    return
+  }
+  if msg := params.validate(); msg != "" {
+    respondWithError(w, http.StatusBadRequest, msg, nil)
+    return
+  }
+
+  rule, err := cfg.db.CreateFilterRule(context.Background(), database.CreateFilterRuleParams{
+    ID:        uuid.New(),
+    CreatedAt: time.Now(),
+    Kind:      params.Kind,
+    Pattern:   params.Pattern,
+    Action:    params.Action,
+    Enabled:   *params.Enabled,
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error creating rule", err)
+    return
+  }
+  cfg.reloadContentFilter()
+
+  respondWithJSON(w, http.StatusCreated, newFilterRuleResponse(rule))
+}
+
+// handleUpdateFilterRule replaces a rule. Chirps it already masked, held or
+// rejected are left as they are.
+func (cfg *apiConfig) handleUpdateFilterRule(w http.ResponseWriter, r *http.Request) {
+  ruleID, err := uuid.Parse(r.PathValue("ruleID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid rule ID", err)
+    return
+  }
+  var params filterRuleParams
+  err = json.NewDecoder(r.Body).Decode(&params)
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+    return
+  }
+  if msg := params.validate(); msg != "" {
+    respondWithError(w, http.StatusBadRequest, msg, nil)
+    return
+  }
+
+  rule, err := cfg.db.UpdateFilterRule(context.Background(), database.UpdateFilterRuleParams{
+    ID:      ruleID,
+    Kind:    params.Kind,
+    Pattern: params.Pattern,
+    Action:  params.Action,
+    Enabled: *params.Enabled,
+  })
+  if errors.Is(err, sql.ErrNoRows) {
+    respondWithError(w, http.StatusNotFound, "Rule not found", nil)
+    return
+  }
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error updating rule", err)
+    return
+  }
+  cfg.reloadContentFilter()
+
+  respondWithJSON(w, http.StatusOK, newFilterRuleResponse(rule))
+}
+
+func (cfg *apiConfig) handleDeleteFilterRule(w http.ResponseWriter, r *http.Request) {
+  ruleID, err := uuid.Parse(r.PathValue("ruleID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid rule ID", err)
+    return
+  }
+
+  deleted, err := cfg.db.DeleteFilterRule(context.Background(), ruleID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error deleting rule", err)
+    return
+  }
+  if deleted == 0 {
+    respondWithError(w, http.StatusNotFound, "Rule not found", nil)
+    return
+  }
+  cfg.reloadContentFilter()
+
+  w.WriteHeader(http.StatusNoContent)
+}
+
+// handleTestContentFilter shows what the filter makes of some text, either
+// with the live rules or with rules given in the request, so a rule can be
+// tried out before it is saved.
+func (cfg *apiConfig) handleTestContentFilter(w http.ResponseWriter, r *http.Request) {
+  var params struct {
+    Text  string             `json:"text"`
+    Rules []filterRuleParams `json:"rules"`
+  }
+  err := json.NewDecoder(r.Body).Decode(&params)
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+    return
+  }
+
+  result := cfg.contentFilter.Check(params.Text)
+  if len(params.Rules) > maxTestFilterRules {
+    respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Test at most %d rules at a time", maxTestFilterRules), nil)
+    return
+  }
+  if params.Rules != nil {
+    rules := make([]contentfilter.Rule, 0, len(params.Rules))
+    for i, p := range params.Rules {
+      if msg := p.validate(); msg != "" {
+        respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Rule %d: %s", i, msg), nil)
+        return
+      }
+      // made-up IDs so matches can be told apart; they are the rules'
+      // positions in the request
+      rules = append(rules, p.rule(uuid.UUID{15: byte(i)}))
+    }
+    result, err = contentfilter.CheckWith(rules, params.Text)
+    if err != nil {
+      respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+      return
+    }
+  }
+
+  type response struct {
+    Text    string                `json:"text"`
+    Action  string                `json:"action,omitempty"`
+    RuleID  *uuid.UUID            `json:"rule_id,omitempty"`
+    Matches []contentfilter.Match `json:"matches"`
+  }
+  resp := response{
+    Text:    result.Text,
+    Action:  result.Action,
+    Matches: result.Matches,
+  }
+  if resp.Matches == nil {
+    resp.Matches = []contentfilter.Match{}
+  }
+  if result.Action != "" {
+    resp.RuleID = &result.RuleID
+  }
+  respondWithJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+  "context"
+  "crypto/rand"
+  "database/sql"
+  "encoding/hex"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+  "testing"
+  "time"
+  "chirpy/internal/auth"
+  "chirpy/internal/contentfilter"
+  "chirpy/internal/database"
+  "chirpy/internal/entitlements"
+  "chirpy/internal/jobs"
+  "chirpy/internal/spam"
+  "github.com/google/uuid"
+)
+
+const testSecret = "test-secret"
+
+// testConfig returns an apiConfig on a fresh copy of the schema, made by
+// running every migration in a schema of its own. It needs TEST_DB_URL to
+// point at a Postgres database the test may create schemas in.
+func testConfig(t *testing.T) *apiConfig {
+  t.Helper()
+  url := os.Getenv("TEST_DB_URL")
+  if url == "" {
+    t.Skip("TEST_DB_URL not set")
+  }
+  db, err := sql.Open("postgres", url)
+  if err != nil {
+    t.Fatal(err)
+  }
+  t.Cleanup(func() { db.Close() })
+  // search_path is per connection
+  db.SetMaxOpenConns(1)
+
+  suffix := make([]byte, 4)
+  rand.Read(suffix)
+  schema := "chirpy_test_" + hex.EncodeToString(suffix)
+  stmts := []string{"CREATE SCHEMA " + schema, "SET search_path TO " + schema}
+  migrations, err := filepath.Glob("sql/schema/*.sql")
+  if err != nil {
+    t.Fatal(err)
+  }
+  sort.Strings(migrations)
+  for _, path := range migrations {
+    migration, err := os.ReadFile(path)
+    if err != nil {
+      t.Fatal(err)
+    }
+    up, _, _ := strings.Cut(string(migration), "-- +goose Down")
+    stmts = append(stmts, up)
+  }
+  for _, stmt := range stmts {
+    _, err := db.Exec(stmt)
+    if err != nil {
+      t.Fatal(err)
+    }
+  }
+  t.Cleanup(func() { db.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+  queries := database.New(db)
+  return &apiConfig{
+    db:            queries,
+    sqlDB:         db,
+    SecretKey:     testSecret,
+    BaseURL:       "https://chirpy.test",
+    jobs:          jobs.New(queries),
+    contentFilter: contentfilter.New(queries),
+    spam:          spam.DefaultConfig,
+  }
+}
+
+// testUser creates an account and returns it with an access token for it.
+func testUser(t *testing.T, cfg *apiConfig) (database.User, string) {
+  t.Helper()
+  now := time.Now()
+  id := uuid.New()
+  user, err := cfg.db.CreateUser(context.Background(), database.CreateUserParams{
+    ID:             id,
+    CreatedAt:      now,
+    UpdatedAt:      now,
+    Email:          id.String() + "@example.com",
+    HashedPassword: "unused",
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  token, err := auth.MakeJWT(user.ID, testSecret)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return user, token
+}
+
+// subscribe puts userID on an active Chirpy Red subscription.
+func subscribe(t *testing.T, cfg *apiConfig, userID uuid.UUID) {
+  t.Helper()
+  now := time.Now()
+  _, err := cfg.db.UpsertSubscription(context.Background(), database.UpsertSubscriptionParams{
+    ID:               uuid.New(),
+    CreatedAt:        now,
+    UpdatedAt:        now,
+    UserID:           userID,
+    Plan:             entitlements.PlanChirpyRed,
+    Status:           subscriptionActive,
+    CurrentPeriodEnd: now.Add(30 * 24 * time.Hour),
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+}
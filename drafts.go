@@ -0,0 +1,504 @@
+package main
+
+import (
+  "context"
+  "database/sql"
+  "encoding/json"
+  "errors"
+  "log"
+  "net/http"
+  "time"
+  "chirpy/internal/activitypub"
+  "chirpy/internal/contentfilter"
+  "chirpy/internal/database"
+  "chirpy/internal/jobs"
+  "chirpy/internal/webhooks"
+  "github.com/google/uuid"
+)
+
+const (
+  draftStatusDraft     = "draft"
+  draftStatusScheduled = "scheduled"
+  // a scheduled chirp that didn't pass validation, or whose author was
+  // banned or suspended, when it came to publish it
+  draftStatusFailed = "failed"
+
+  jobPublishChirp = "chirp.publish"
+
+  maxScheduleAhead = 365 * 24 * time.Hour
+)
+
+var errDraftNotFound = errors.New("draft not found")
+
+type draftResponse struct {
+  ID        uuid.UUID  `json:"id"`
+  CreatedAt time.Time  `json:"created_at"`
+  UpdatedAt time.Time  `json:"updated_at"`
+  Body      string     `json:"body"`
+  Status    string     `json:"status"`
+  PublishAt *time.Time `json:"publish_at"`
+  LastError string     `json:"last_error,omitempty"`
+}
+
+func newDraftResponse(d database.ChirpDraft) draftResponse {
+  resp := draftResponse{
+    ID:        d.ID,
+    CreatedAt: d.CreatedAt,
+    UpdatedAt: d.UpdatedAt,
+    Body:      d.Body,
+    Status:    d.Status,
+    LastError: d.LastError.String,
+  }
+  if d.PublishAt.Valid {
+    resp.PublishAt = &d.PublishAt.Time
+  }
+  return resp
+}
+
+type publishChirpArgs struct {
+  DraftID   uuid.UUID `json:"draft_id"`
+  PublishAt time.Time `json:"publish_at"`
+}
+
+// publishDraft turns a draft into a chirp, running it through the same
+// account checks, validation and spam scoring as a new chirp, and deletes
+// the draft. The draft row is locked for the whole time, so it is published
+// at most once; ready is called on the locked row and can refuse with
+// errDraftNotFound.
+func (cfg *apiConfig) publishDraft(ctx context.Context, draftID uuid.UUID, ready func(database.ChirpDraft) bool) (database.Chirp, error) {
+  tx, err := cfg.sqlDB.BeginTx(ctx, nil)
+  if err != nil {
+    return database.Chirp{}, err
+  }
+  defer tx.Rollback()
+  qtx := cfg.db.WithTx(tx)
+
+  draft, err := qtx.LockDraft(ctx, draftID)
+  if errors.Is(err, sql.ErrNoRows) {
+    return database.Chirp{}, errDraftNotFound
+  }
+  if err != nil {
+    return database.Chirp{}, err
+  }
+  if !ready(draft) {
+    return database.Chirp{}, errDraftNotFound
+  }
+  // a ban or suspension also stops chirps scheduled before it
+  author, err := qtx.GetUserById(ctx, draft.UserID)
+  if err != nil {
+    return database.Chirp{}, err
+  }
+  if msg := accountRestriction(author, time.Now()); msg != "" {
+    return database.Chirp{}, chirpRejection{msg: msg}
+  }
+
+  filtered, err := cfg.validateChirp(ctx, draft.UserID, draft.Body)
+  if err != nil {
+    return database.Chirp{}, err
+  }
+  var hold chirpHold
+  if filtered.Action == contentfilter.ActionHold {
+    hold.ruleID = uuid.NullUUID{UUID: filtered.RuleID, Valid: true}
+  }
+  err = cfg.checkSpam(ctx, draft.UserID, filtered.Text, &hold)
+  if err != nil {
+    return database.Chirp{}, err
+  }
+  now := time.Now()
+  chirp, err := qtx.CreateChirp(ctx, database.CreateChirpParams{
+    ID:        uuid.New(),
+    CreatedAt: now,
+    UpdatedAt: now,
+    Body:      filtered.Text,
+    UserID:    draft.UserID,
+  })
+  if err != nil {
+    return database.Chirp{}, err
+  }
+  if hold.held() {
+    chirp, err = holdChirp(ctx, qtx, chirp, hold)
+    if err != nil {
+      return database.Chirp{}, err
+    }
+  }
+  _, err = qtx.DeleteDraft(ctx, database.DeleteDraftParams{
+    ID:     draft.ID,
+    UserID: draft.UserID,
+  })
+  if err != nil {
+    return database.Chirp{}, err
+  }
+  err = tx.Commit()
+  if err != nil {
+    return database.Chirp{}, err
+  }
+
+  // subscribers hear about the chirp now, not when it was scheduled, and
+  // about held chirps only once they are released
+  if !chirp.HeldAt.Valid {
+    cfg.publishEvent(ctx, webhooks.Event{
+      Type:   webhooks.EventChirpCreated,
+      UserID: chirp.UserID,
+      Data:   chirpResponse{Chirp: chirp},
+    })
+    cfg.federateChirp(ctx, chirp, activitypub.TypeCreate)
+  }
+  return chirp, nil
+}
+
+// handlePublishChirpJob publishes a scheduled chirp. Jobs left over from
+// before a draft was unscheduled or moved to another time do nothing.
+func (cfg *apiConfig) handlePublishChirpJob(ctx context.Context, job jobs.Job[publishChirpArgs]) error {
+  _, err := cfg.publishDraft(ctx, job.Args.DraftID, func(d database.ChirpDraft) bool {
+    return d.Status == draftStatusScheduled && d.PublishAt.Valid && d.PublishAt.Time.Unix() == job.Args.PublishAt.Unix()
+  })
+  if errors.Is(err, errDraftNotFound) {
+    return nil
+  }
+
+  var rejection chirpRejection
+  if errors.As(err, &rejection) {
+    log.Printf("Scheduled chirp %s failed validation: %v", job.Args.DraftID, err)
+    return cfg.db.FailDraft(ctx, database.FailDraftParams{
+      ID:        job.Args.DraftID,
+      LastError: sql.NullString{String: rejection.msg, Valid: true},
+    })
+  }
+  return err
+}
+
+// parsePublishAt checks that a requested publish time is in the future and
+// that the user's plan allows scheduling.
+func (cfg *apiConfig) parsePublishAt(ctx context.Context, userID uuid.UUID, publishAt time.Time) (time.Time, string, error) {
+  ent, err := cfg.entitlementsFor(ctx, userID)
+  if err != nil {
+    return time.Time{}, "", err
+  }
+  if !ent.CanScheduleChirps {
+    return time.Time{}, "Scheduling chirps requires Chirpy Red", nil
+  }
+
+  publishAt = publishAt.Local().Truncate(time.Second)
+  now := time.Now()
+  if !publishAt.After(now) {
+    return time.Time{}, "publish_at must be in the future", nil
+  }
+  if publishAt.After(now.Add(maxScheduleAhead)) {
+    return time.Time{}, "publish_at is too far in the future", nil
+  }
+  return publishAt, "", nil
+}
+
+// scheduleDraft sets the draft's publish time and queues the job that
+// publishes it, in one transaction.
+func (cfg *apiConfig) scheduleDraft(ctx context.Context, draftID, userID uuid.UUID, publishAt time.Time) (database.ChirpDraft, error) {
+  tx, err := cfg.sqlDB.BeginTx(ctx, nil)
+  if err != nil {
+    return database.ChirpDraft{}, err
+  }
+  defer tx.Rollback()
+  qtx := cfg.db.WithTx(tx)
+
+  draft, err := qtx.ScheduleDraft(ctx, database.ScheduleDraftParams{
+    ID:        draftID,
+    UserID:    userID,
+    PublishAt: sql.NullTime{Time: publishAt, Valid: true},
+  })
+  if err != nil {
+    return database.ChirpDraft{}, err
+  }
+  _, err = jobs.EnqueueWith(ctx, qtx, jobPublishChirp, publishChirpArgs{
+    DraftID:   draft.ID,
+    PublishAt: publishAt,
+  }, jobs.RunAt(publishAt))
+  if err != nil {
+    return database.ChirpDraft{}, err
+  }
+  return draft, tx.Commit()
+}
+
+func (cfg *apiConfig) handleCreateDraft(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  var params struct {
+    Body      string     `json:"body"`
+    PublishAt *time.Time `json:"publish_at"`
+  }
+  err = json.NewDecoder(r.Body).Decode(&params)
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+    return
+  }
+
+  // drafts are stored as written and only masked when they are published
+  _, err = cfg.validateChirp(context.Background(), user.ID, params.Body)
+  if err != nil {
+    respondWithChirpError(w, err)
+    return
+  }
+  var publishAt time.Time
+  if params.PublishAt != nil {
+    var msg string
+    publishAt, msg, err = cfg.parsePublishAt(context.Background(), user.ID, *params.PublishAt)
+    if err != nil {
+      respondWithError(w, http.StatusInternalServerError, "Error fetching entitlements", err)
+      return
+    }
+    if msg != "" {
+      respondWithError(w, http.StatusBadRequest, msg, nil)
+      return
+    }
+  }
+
+  draft, err := cfg.db.CreateDraft(context.Background(), database.CreateDraftParams{
+    ID:        uuid.New(),
+    CreatedAt: time.Now(),
+    UserID:    user.ID,
+    Body:      params.Body,
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error creating draft", err)
+    return
+  }
+  if params.PublishAt != nil {
+    draft, err = cfg.scheduleDraft(context.Background(), draft.ID, user.ID, publishAt)
+    if err != nil {
+      respondWithError(w, http.StatusInternalServerError, "Error scheduling draft", err)
+      return
+    }
+  }
+
+  respondWithJSON(w, http.StatusCreated, newDraftResponse(draft))
+}
+
+func (cfg *apiConfig) handleListDrafts(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  params := database.ListDraftsParams{UserID: user.ID}
+  if status := r.URL.Query().Get("status"); status != "" {
+    params.Status = sql.NullString{String: status, Valid: true}
+  }
+  drafts, err := cfg.db.ListDrafts(context.Background(), params)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error listing drafts", err)
+    return
+  }
+
+  response := make([]draftResponse, 0, len(drafts))
+  for _, d := range drafts {
+    response = append(response, newDraftResponse(d))
+  }
+  respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handleGetDraft(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+  draftID, err := uuid.Parse(r.PathValue("draftID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid draft ID", err)
+    return
+  }
+
+  draft, err := cfg.db.GetDraft(context.Background(), database.GetDraftParams{
+    ID:     draftID,
+    UserID: user.ID,
+  })
+  if err != nil {
+    if errors.Is(err, sql.ErrNoRows) {
+      respondWithError(w, http.StatusNotFound, "Draft not found", nil)
+      return
+    }
+    respondWithError(w, http.StatusInternalServerError, "Error fetching draft", err)
+    return
+  }
+
+  respondWithJSON(w, http.StatusOK, newDraftResponse(draft))
+}
+
+func (cfg *apiConfig) handleUpdateDraft(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+  draftID, err := uuid.Parse(r.PathValue("draftID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid draft ID", err)
+    return
+  }
+
+  var params struct {
+    Body string `json:"body"`
+  }
+  err = json.NewDecoder(r.Body).Decode(&params)
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+    return
+  }
+  _, err = cfg.validateChirp(context.Background(), user.ID, params.Body)
+  if err != nil {
+    respondWithChirpError(w, err)
+    return
+  }
+
+  draft, err := cfg.db.UpdateDraftBody(context.Background(), database.UpdateDraftBodyParams{
+    ID:     draftID,
+    UserID: user.ID,
+    Body:   params.Body,
+  })
+  if err != nil {
+    if errors.Is(err, sql.ErrNoRows) {
+      respondWithError(w, http.StatusNotFound, "Draft not found", nil)
+      return
+    }
+    respondWithError(w, http.StatusInternalServerError, "Error updating draft", err)
+    return
+  }
+
+  respondWithJSON(w, http.StatusOK, newDraftResponse(draft))
+}
+
+func (cfg *apiConfig) handleDeleteDraft(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+  draftID, err := uuid.Parse(r.PathValue("draftID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid draft ID", err)
+    return
+  }
+
+  deleted, err := cfg.db.DeleteDraft(context.Background(), database.DeleteDraftParams{
+    ID:     draftID,
+    UserID: user.ID,
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error deleting draft", err)
+    return
+  }
+  if deleted == 0 {
+    respondWithError(w, http.StatusNotFound, "Draft not found", nil)
+    return
+  }
+
+  w.WriteHeader(http.StatusNoContent)
+}
+
+// handleScheduleDraft sets or moves the time a draft is published.
+func (cfg *apiConfig) handleScheduleDraft(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+  draftID, err := uuid.Parse(r.PathValue("draftID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid draft ID", err)
+    return
+  }
+
+  var params struct {
+    PublishAt time.Time `json:"publish_at"`
+  }
+  err = json.NewDecoder(r.Body).Decode(&params)
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+    return
+  }
+  publishAt, msg, err := cfg.parsePublishAt(context.Background(), user.ID, params.PublishAt)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching entitlements", err)
+    return
+  }
+  if msg != "" {
+    respondWithError(w, http.StatusBadRequest, msg, nil)
+    return
+  }
+
+  draft, err := cfg.scheduleDraft(context.Background(), draftID, user.ID, publishAt)
+  if err != nil {
+    if errors.Is(err, sql.ErrNoRows) {
+      respondWithError(w, http.StatusNotFound, "Draft not found", nil)
+      return
+    }
+    respondWithError(w, http.StatusInternalServerError, "Error scheduling draft", err)
+    return
+  }
+
+  respondWithJSON(w, http.StatusOK, newDraftResponse(draft))
+}
+
+// handleUnscheduleDraft turns a scheduled chirp back into a plain draft.
+func (cfg *apiConfig) handleUnscheduleDraft(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+  draftID, err := uuid.Parse(r.PathValue("draftID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid draft ID", err)
+    return
+  }
+
+  draft, err := cfg.db.UnscheduleDraft(context.Background(), database.UnscheduleDraftParams{
+    ID:     draftID,
+    UserID: user.ID,
+  })
+  if err != nil {
+    if errors.Is(err, sql.ErrNoRows) {
+      respondWithError(w, http.StatusNotFound, "Scheduled draft not found", nil)
+      return
+    }
+    respondWithError(w, http.StatusInternalServerError, "Error unscheduling draft", err)
+    return
+  }
+
+  respondWithJSON(w, http.StatusOK, newDraftResponse(draft))
+}
+
+// handlePublishDraft publishes a draft right away.
+func (cfg *apiConfig) handlePublishDraft(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+  draftID, err := uuid.Parse(r.PathValue("draftID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid draft ID", err)
+    return
+  }
+
+  chirp, err := cfg.publishDraft(context.Background(), draftID, func(d database.ChirpDraft) bool {
+    return d.UserID == user.ID
+  })
+  if err != nil {
+    if errors.Is(err, errDraftNotFound) {
+      respondWithError(w, http.StatusNotFound, "Draft not found", nil)
+      return
+    }
+    respondWithChirpError(w, err)
+    return
+  }
+
+  resp := chirpResponse{Chirp: chirp}
+  if chirp.HeldAt.Valid {
+    resp.HeldAt = &chirp.HeldAt.Time
+  }
+  respondWithJSON(w, http.StatusCreated, resp)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+  "context"
+  "database/sql"
+  "testing"
+  "time"
+  "chirpy/internal/database"
+  "chirpy/internal/jobs"
+  "github.com/google/uuid"
+)
+
+func TestScheduledChirpOfRestrictedAuthorIsNotPublished(t *testing.T) {
+  tests := []struct {
+    name   string
+    status database.SetAccountStatusParams
+  }{
+    {"banned", database.SetAccountStatusParams{AccountStatus: accountBanned}},
+    {"suspended", database.SetAccountStatusParams{
+      AccountStatus:  accountSuspended,
+      SuspendedUntil: sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true},
+    }},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      cfg := testConfig(t)
+      ctx := context.Background()
+      user, _ := testUser(t, cfg)
+      draft := testDraft(t, cfg, user.ID, "see you tomorrow")
+      publishAt := time.Now().Add(time.Hour).Truncate(time.Second)
+      _, err := cfg.db.ScheduleDraft(ctx, database.ScheduleDraftParams{
+        ID:        draft.ID,
+        UserID:    user.ID,
+        PublishAt: sql.NullTime{Time: publishAt, Valid: true},
+      })
+      if err != nil {
+        t.Fatal(err)
+      }
+      tt.status.ID = user.ID
+      err = cfg.db.SetAccountStatus(ctx, tt.status)
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      err = cfg.handlePublishChirpJob(ctx, jobs.Job[publishChirpArgs]{
+        Args: publishChirpArgs{DraftID: draft.ID, PublishAt: publishAt},
+      })
+      if err != nil {
+        t.Fatal(err)
+      }
+      after, err := cfg.db.GetDraft(ctx, database.GetDraftParams{ID: draft.ID, UserID: user.ID})
+      if err != nil {
+        t.Fatalf("the draft is gone: %v", err)
+      }
+      if after.Status != draftStatusFailed {
+        t.Errorf("status = %q, want %q", after.Status, draftStatusFailed)
+      }
+      chirps, err := cfg.db.GetChirpsByAuthorsDesc(ctx, []uuid.UUID{user.ID})
+      if err != nil {
+        t.Fatal(err)
+      }
+      if len(chirps) != 0 {
+        t.Errorf("%d chirps published", len(chirps))
+      }
+    })
+  }
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+  "context"
+  "database/sql"
+  "errors"
+  "net/http"
+  "time"
+  "chirpy/internal/entitlements"
+  "github.com/google/uuid"
+)
+
+// entitlementsFor resolves what a user may do from their subscription. A
+// subscription only counts while it is active or past due and inside the
+// paid period.
+func (cfg *apiConfig) entitlementsFor(ctx context.Context, userID uuid.UUID) (entitlements.Entitlements, error) {
+  sub, err := cfg.db.GetSubscriptionByUser(ctx, userID)
+  if errors.Is(err, sql.ErrNoRows) {
+    return entitlements.Free(), nil
+  }
+  if err != nil {
+    return entitlements.Entitlements{}, err
+  }
+
+  if !subscriptionEntitled(sub.Status, sub.CurrentPeriodEnd, time.Now()) {
+    return entitlements.Free(), nil
+  }
+  return entitlements.ForPlan(sub.Plan), nil
+}
+
+func (cfg *apiConfig) handleGetEntitlements(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  ent, err := cfg.entitlementsFor(context.Background(), user.ID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching entitlements", err)
+    return
+  }
+
+  respondWithJSON(w, http.StatusOK, ent)
+}
@@ -0,0 +1,701 @@
+package main
+
+import (
+  "archive/zip"
+  "context"
+  "database/sql"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "log"
+  "math"
+  "net/http"
+  "net/url"
+  "os"
+  "path/filepath"
+  "time"
+  "chirpy/internal/auth"
+  "chirpy/internal/database"
+  "chirpy/internal/jobs"
+  "github.com/google/uuid"
+)
+
+const (
+  exportStatusPending = "pending"
+  exportStatusReady   = "ready"
+
+  jobBuildExport     = "export.build"
+  jobPruneExports    = "exports.prune"
+  jobSendExportReady = "email.export_ready"
+
+  // how long a finished archive can be downloaded before it is deleted
+  exportRetention = 7 * 24 * time.Hour
+)
+
+// exportReadme goes at the top of every archive so people know what they
+// are looking at without reading our API docs.
+const exportReadme = `This archive holds the data Chirpy keeps about your account, as of when
+it was built. Every file is JSON.
+
+profile.json       your account
+chirps.json        the chirps you have posted and not deleted
+drafts.json        your drafts and scheduled chirps
+bookmarks.json     the chirps you bookmarked, and your collections
+lists.json         the lists you own, with their members, and the lists
+                   you subscribe to
+blocks.json        the accounts you have blocked
+follows.json       the accounts on other servers you follow, and those
+                   that follow you
+likes.json         likes and boosts of your chirps from other servers
+sessions.json      your login sessions; the tokens themselves are left out
+audit.json         security events on your account
+subscription.json  your Chirpy Red subscription, if you have one
+webhooks.json      your webhook endpoints; signing secrets are left out
+
+Chirpy doesn't have uploaded media, so there is none to include.
+`
+
+type buildExportArgs struct {
+  ExportID uuid.UUID `json:"export_id"`
+}
+
+type dataExportResponse struct {
+  ID          uuid.UUID  `json:"id"`
+  CreatedAt   time.Time  `json:"created_at"`
+  Status      string     `json:"status"`
+  CompletedAt *time.Time `json:"completed_at,omitempty"`
+  ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+  SizeBytes   int64      `json:"size_bytes,omitempty"`
+  DownloadURL string     `json:"download_url,omitempty"`
+}
+
+func (cfg *apiConfig) newDataExportResponse(e database.DataExport) dataExportResponse {
+  resp := dataExportResponse{
+    ID:        e.ID,
+    CreatedAt: e.CreatedAt,
+    Status:    e.Status,
+    SizeBytes: e.SizeBytes.Int64,
+  }
+  if e.CompletedAt.Valid {
+    resp.CompletedAt = &e.CompletedAt.Time
+  }
+  if e.ExpiresAt.Valid {
+    resp.ExpiresAt = &e.ExpiresAt.Time
+  }
+  if e.Status == exportStatusReady && e.ExpiresAt.Time.After(time.Now()) {
+    resp.DownloadURL = cfg.exportDownloadURL(e)
+  }
+  return resp
+}
+
+// exportDownloadURL is a link to the archive that works without logging in
+// until the export expires, so it can be sent by email.
+func (cfg *apiConfig) exportDownloadURL(e database.DataExport) string {
+  expires, signature := auth.SignExpiring(e.ID.String(), e.ExpiresAt.Time, cfg.SecretKey)
+  query := url.Values{}
+  query.Set("expires", expires)
+  query.Set("signature", signature)
+  return cfg.BaseURL + "/api/exports/" + e.ID.String() + "/download?" + query.Encode()
+}
+
+// loadExportDir is where archives are written, EXPORT_DIR or a directory
+// under the system's temporary one. Every instance that serves downloads
+// needs to see the same directory.
+func loadExportDir() string {
+  if dir := os.Getenv("EXPORT_DIR"); dir != "" {
+    return dir
+  }
+  return filepath.Join(os.TempDir(), "chirpy-exports")
+}
+
+func (cfg *apiConfig) exportPath(id uuid.UUID) string {
+  return filepath.Join(cfg.exportDir, id.String()+".zip")
+}
+
+// handleRequestDataExport starts building an archive of the caller's data.
+// It is emailed to them when it's ready. Only one export is built at a time
+// per user.
+func (cfg *apiConfig) handleRequestDataExport(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  ctx := context.Background()
+  tx, err := cfg.sqlDB.BeginTx(ctx, nil)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error requesting export", err)
+    return
+  }
+  defer tx.Rollback()
+  qtx := cfg.db.WithTx(tx)
+
+  export, err := qtx.CreateDataExport(ctx, database.CreateDataExportParams{
+    ID:        uuid.New(),
+    CreatedAt: time.Now(),
+    UserID:    user.ID,
+  })
+  if isUniqueViolation(err) {
+    respondWithError(w, http.StatusConflict, "An export is already being prepared", nil)
+    return
+  }
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error requesting export", err)
+    return
+  }
+  _, err = jobs.EnqueueWith(ctx, qtx, jobBuildExport, buildExportArgs{ExportID: export.ID}, jobs.MaxAttempts(3))
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error requesting export", err)
+    return
+  }
+  err = recordAudit(ctx, qtx, r, auditEntry{
+    Type:    auditDataExportRequested,
+    ActorID: auditUser(user.ID),
+    UserID:  auditUser(user.ID),
+    Details: map[string]any{"export_id": export.ID},
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error requesting export", err)
+    return
+  }
+  err = tx.Commit()
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error requesting export", err)
+    return
+  }
+
+  respondWithJSON(w, http.StatusAccepted, cfg.newDataExportResponse(export))
+}
+
+// handleListDataExports lists the caller's recent exports, with download
+// links for the ones that are ready.
+func (cfg *apiConfig) handleListDataExports(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  exports, err := cfg.db.ListDataExportsByUser(context.Background(), user.ID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error listing exports", err)
+    return
+  }
+
+  response := make([]dataExportResponse, 0, len(exports))
+  for _, e := range exports {
+    response = append(response, cfg.newDataExportResponse(e))
+  }
+  respondWithJSON(w, http.StatusOK, response)
+}
+
+// handleDownloadDataExport serves an archive to whoever has a signed link
+// to it; the signature stands in for logging in.
+func (cfg *apiConfig) handleDownloadDataExport(w http.ResponseWriter, r *http.Request) {
+  exportID, err := uuid.Parse(r.PathValue("exportID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid export ID", err)
+    return
+  }
+  query := r.URL.Query()
+  err = auth.VerifyExpiring(exportID.String(), query.Get("expires"), query.Get("signature"), cfg.SecretKey, time.Now())
+  if err != nil {
+    respondWithError(w, http.StatusForbidden, "Invalid or expired link", err)
+    return
+  }
+
+  export, err := cfg.db.GetDataExport(context.Background(), exportID)
+  if errors.Is(err, sql.ErrNoRows) {
+    respondWithError(w, http.StatusNotFound, "Export not found", nil)
+    return
+  }
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching export", err)
+    return
+  }
+  if export.Status != exportStatusReady {
+    respondWithError(w, http.StatusGone, "Export is no longer available", nil)
+    return
+  }
+  f, err := os.Open(cfg.exportPath(export.ID))
+  if errors.Is(err, os.ErrNotExist) {
+    respondWithError(w, http.StatusGone, "Export is no longer available", err)
+    return
+  }
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error opening export", err)
+    return
+  }
+  defer f.Close()
+
+  name := "chirpy-export-" + export.CompletedAt.Time.Format("2006-01-02") + ".zip"
+  w.Header().Set("Content-Type", "application/zip")
+  w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+  w.Header().Set("Cache-Control", "private, no-store")
+  http.ServeContent(w, r, name, export.CompletedAt.Time, f)
+}
+
+// handleBuildExportJob writes the archive for an export and emails its
+// owner a link to it. The export is marked failed once the job runs out of
+// attempts.
+func (cfg *apiConfig) handleBuildExportJob(ctx context.Context, job jobs.Job[buildExportArgs]) error {
+  export, err := cfg.db.GetDataExport(ctx, job.Args.ExportID)
+  if errors.Is(err, sql.ErrNoRows) {
+    return nil
+  }
+  if err != nil {
+    return err
+  }
+  if export.Status != exportStatusPending {
+    return nil
+  }
+
+  path := cfg.exportPath(export.ID)
+  size, err := cfg.writeDataExport(ctx, export.UserID, path)
+  if err != nil {
+    if job.Attempt >= job.MaxAttempts {
+      failErr := cfg.db.FailDataExport(ctx, database.FailDataExportParams{
+        ID:          export.ID,
+        CompletedAt: sql.NullTime{Time: time.Now(), Valid: true},
+        LastError:   sql.NullString{String: err.Error(), Valid: true},
+      })
+      if failErr != nil {
+        log.Printf("Error marking export %s failed: %v", export.ID, failErr)
+      }
+    }
+    return err
+  }
+
+  now := time.Now()
+  export.Status = exportStatusReady
+  export.CompletedAt = sql.NullTime{Time: now, Valid: true}
+  export.ExpiresAt = sql.NullTime{Time: now.Add(exportRetention), Valid: true}
+  updated, err := cfg.db.CompleteDataExport(ctx, database.CompleteDataExportParams{
+    ID:          export.ID,
+    CompletedAt: export.CompletedAt,
+    ExpiresAt:   export.ExpiresAt,
+    SizeBytes:   sql.NullInt64{Int64: size, Valid: true},
+  })
+  if err != nil {
+    os.Remove(path)
+    return err
+  }
+  if updated == 0 {
+    // another worker got there first
+    os.Remove(path)
+    return nil
+  }
+
+  // the link is still listed under /api/me/exports if the email fails
+  _, err = cfg.jobs.Enqueue(ctx, jobSendExportReady, exportReadyArgs{ExportID: export.ID}, jobs.MaxAttempts(5))
+  if err != nil {
+    log.Printf("Error queueing email for export %s: %v", export.ID, err)
+  }
+  return nil
+}
+
+type exportReadyArgs struct {
+  ExportID uuid.UUID `json:"export_id"`
+}
+
+// handleSendExportReadyJob emails the download link for a finished export.
+// The link is made here rather than when the job is queued, since anyone
+// holding it can download the archive and job payloads are kept for days.
+func (cfg *apiConfig) handleSendExportReadyJob(ctx context.Context, job jobs.Job[exportReadyArgs]) error {
+  export, err := cfg.db.GetDataExport(ctx, job.Args.ExportID)
+  if errors.Is(err, sql.ErrNoRows) {
+    return nil
+  }
+  if err != nil {
+    return fmt.Errorf("error fetching export: %v", err)
+  }
+  if export.Status != exportStatusReady || !export.ExpiresAt.Valid || !export.ExpiresAt.Time.After(time.Now()) {
+    return nil
+  }
+  user, err := cfg.db.GetUserById(ctx, export.UserID)
+  if errors.Is(err, sql.ErrNoRows) {
+    return nil
+  }
+  if err != nil {
+    return fmt.Errorf("error fetching owner of export: %v", err)
+  }
+  body := "The export of your Chirpy data is ready. Download it within the next " +
+    "7 days from:\n\n" + cfg.exportDownloadURL(export) + "\n\n" +
+    "Anyone with this link can download your data, so don't share it.\n"
+  return cfg.mailer.Send(ctx, user.Email, "Your Chirpy data export is ready", body)
+}
+
+// exportProfile is the account without its password hash.
+type exportProfile struct {
+  ID             uuid.UUID  `json:"id"`
+  CreatedAt      time.Time  `json:"created_at"`
+  UpdatedAt      time.Time  `json:"updated_at"`
+  Email          string     `json:"email"`
+  IsChirpyRed    bool       `json:"is_chirpy_red"`
+  Role           string     `json:"role"`
+  AccountStatus  string     `json:"account_status"`
+  SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+}
+
+type exportBookmark struct {
+  ChirpID      uuid.UUID `json:"chirp_id"`
+  AuthorID     uuid.UUID `json:"author_id"`
+  Body         string    `json:"body"`
+  BookmarkedAt time.Time `json:"bookmarked_at"`
+}
+
+type exportCollection struct {
+  collectionResponse
+  ChirpIDs []uuid.UUID `json:"chirp_ids"`
+}
+
+type exportList struct {
+  listResponse
+  Members []listMemberResponse `json:"members"`
+}
+
+type exportFollows struct {
+  Following []followingResponse `json:"following"`
+  Followers []followingResponse `json:"followers"`
+}
+
+// exportReaction is a like or boost of one of the user's chirps, by an
+// account on another server.
+type exportReaction struct {
+  ChirpID   uuid.UUID `json:"chirp_id"`
+  Type      string    `json:"type"`
+  ActorID   string    `json:"actor_id"`
+  CreatedAt time.Time `json:"created_at"`
+}
+
+type exportSession struct {
+  CreatedAt time.Time  `json:"created_at"`
+  UpdatedAt time.Time  `json:"updated_at"`
+  ExpiresAt time.Time  `json:"expires_at"`
+  RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// writeDataExport writes userID's archive to path and returns its size. It
+// is written under another name first so a half-written archive is never
+// served.
+func (cfg *apiConfig) writeDataExport(ctx context.Context, userID uuid.UUID, path string) (int64, error) {
+  err := os.MkdirAll(filepath.Dir(path), 0o700)
+  if err != nil {
+    return 0, fmt.Errorf("error creating export directory: %v", err)
+  }
+  tmp := path + ".tmp"
+  f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+  if err != nil {
+    return 0, fmt.Errorf("error creating export: %v", err)
+  }
+  defer os.Remove(tmp)
+  defer f.Close()
+
+  zw := zip.NewWriter(f)
+  err = cfg.writeExportFiles(ctx, zw, userID)
+  if err != nil {
+    return 0, err
+  }
+  err = zw.Close()
+  if err != nil {
+    return 0, fmt.Errorf("error writing export: %v", err)
+  }
+  info, err := f.Stat()
+  if err != nil {
+    return 0, fmt.Errorf("error writing export: %v", err)
+  }
+  err = f.Close()
+  if err != nil {
+    return 0, fmt.Errorf("error writing export: %v", err)
+  }
+  err = os.Rename(tmp, path)
+  if err != nil {
+    return 0, fmt.Errorf("error saving export: %v", err)
+  }
+  return info.Size(), nil
+}
+
+func addExportFile(zw *zip.Writer, name string, v any) error {
+  w, err := zw.Create(name)
+  if err != nil {
+    return fmt.Errorf("error adding %s to export: %v", name, err)
+  }
+  enc := json.NewEncoder(w)
+  enc.SetIndent("", "  ")
+  err = enc.Encode(v)
+  if err != nil {
+    return fmt.Errorf("error adding %s to export: %v", name, err)
+  }
+  return nil
+}
+
+// writeExportFiles gathers everything stored about userID into zw.
+func (cfg *apiConfig) writeExportFiles(ctx context.Context, zw *zip.Writer, userID uuid.UUID) error {
+  readme, err := zw.Create("README.txt")
+  if err != nil {
+    return fmt.Errorf("error adding README.txt to export: %v", err)
+  }
+  _, err = readme.Write([]byte(exportReadme))
+  if err != nil {
+    return fmt.Errorf("error adding README.txt to export: %v", err)
+  }
+
+  user, err := cfg.db.GetUserById(ctx, userID)
+  if err != nil {
+    return fmt.Errorf("error fetching user: %v", err)
+  }
+  profile := exportProfile{
+    ID:            user.ID,
+    CreatedAt:     user.CreatedAt,
+    UpdatedAt:     user.UpdatedAt,
+    Email:         user.Email,
+    IsChirpyRed:   user.IsChirpyRed,
+    Role:          user.Role,
+    AccountStatus: user.AccountStatus,
+  }
+  if user.SuspendedUntil.Valid {
+    profile.SuspendedUntil = &user.SuspendedUntil.Time
+  }
+  err = addExportFile(zw, "profile.json", profile)
+  if err != nil {
+    return err
+  }
+
+  chirps, err := cfg.db.GetChirpsByAuthors(ctx, []uuid.UUID{userID})
+  if err != nil {
+    return fmt.Errorf("error fetching chirps: %v", err)
+  }
+  // rendered as the author sees them, held chirps included
+  chirpsResp, err := cfg.chirpResponses(ctx, chirps, auditUser(userID))
+  if err != nil {
+    return fmt.Errorf("error rendering chirps: %v", err)
+  }
+  err = addExportFile(zw, "chirps.json", chirpsResp)
+  if err != nil {
+    return err
+  }
+
+  drafts, err := cfg.db.ListDrafts(ctx, database.ListDraftsParams{UserID: userID})
+  if err != nil {
+    return fmt.Errorf("error fetching drafts: %v", err)
+  }
+  draftsResp := make([]draftResponse, 0, len(drafts))
+  for _, d := range drafts {
+    draftsResp = append(draftsResp, newDraftResponse(d))
+  }
+  err = addExportFile(zw, "drafts.json", draftsResp)
+  if err != nil {
+    return err
+  }
+
+  bookmarks, err := cfg.db.ListBookmarks(ctx, database.ListBookmarksParams{UserID: userID, RowLimit: math.MaxInt32})
+  if err != nil {
+    return fmt.Errorf("error fetching bookmarks: %v", err)
+  }
+  bookmarksResp := make([]exportBookmark, 0, len(bookmarks))
+  for _, b := range bookmarks {
+    bookmarksResp = append(bookmarksResp, exportBookmark{
+      ChirpID:      b.ID,
+      AuthorID:     b.UserID,
+      Body:         b.Body,
+      BookmarkedAt: b.BookmarkedAt,
+    })
+  }
+  collections, err := cfg.db.ListBookmarkCollections(ctx, userID)
+  if err != nil {
+    return fmt.Errorf("error fetching collections: %v", err)
+  }
+  collectionsResp := make([]exportCollection, 0, len(collections))
+  for _, c := range collections {
+    rows, err := cfg.db.ListBookmarks(ctx, database.ListBookmarksParams{
+      UserID:       userID,
+      CollectionID: uuid.NullUUID{UUID: c.ID, Valid: true},
+      RowLimit:     math.MaxInt32,
+    })
+    if err != nil {
+      return fmt.Errorf("error fetching collection: %v", err)
+    }
+    resp := exportCollection{collectionResponse: newCollectionResponse(c), ChirpIDs: []uuid.UUID{}}
+    for _, row := range rows {
+      resp.ChirpIDs = append(resp.ChirpIDs, row.ID)
+    }
+    collectionsResp = append(collectionsResp, resp)
+  }
+  err = addExportFile(zw, "bookmarks.json", map[string]any{
+    "bookmarks":   bookmarksResp,
+    "collections": collectionsResp,
+  })
+  if err != nil {
+    return err
+  }
+
+  owned, err := cfg.db.ListListsByOwner(ctx, userID)
+  if err != nil {
+    return fmt.Errorf("error fetching lists: %v", err)
+  }
+  ownedResp := make([]exportList, 0, len(owned))
+  for _, l := range owned {
+    members, err := cfg.db.ListListMembers(ctx, l.ID)
+    if err != nil {
+      return fmt.Errorf("error fetching list members: %v", err)
+    }
+    resp := exportList{listResponse: newListResponse(l), Members: make([]listMemberResponse, 0, len(members))}
+    for _, m := range members {
+      resp.Members = append(resp.Members, listMemberResponse{UserID: m.UserID, AddedAt: m.CreatedAt})
+    }
+    ownedResp = append(ownedResp, resp)
+  }
+  subscribed, err := cfg.db.ListSubscribedLists(ctx, userID)
+  if err != nil {
+    return fmt.Errorf("error fetching list subscriptions: %v", err)
+  }
+  err = addExportFile(zw, "lists.json", map[string]any{
+    "owned":      ownedResp,
+    "subscribed": newListResponses(subscribed),
+  })
+  if err != nil {
+    return err
+  }
+
+  blocks, err := cfg.db.ListBlocks(ctx, userID)
+  if err != nil {
+    return fmt.Errorf("error fetching blocks: %v", err)
+  }
+  blocksResp := make([]blockResponse, 0, len(blocks))
+  for _, b := range blocks {
+    blocksResp = append(blocksResp, blockResponse{UserID: b.BlockedID, CreatedAt: b.CreatedAt})
+  }
+  err = addExportFile(zw, "blocks.json", blocksResp)
+  if err != nil {
+    return err
+  }
+
+  following, err := cfg.db.ListFollowing(ctx, userID)
+  if err != nil {
+    return fmt.Errorf("error fetching follows: %v", err)
+  }
+  followers, err := cfg.db.ListFollowers(ctx, userID)
+  if err != nil {
+    return fmt.Errorf("error fetching followers: %v", err)
+  }
+  follows := exportFollows{
+    Following: make([]followingResponse, 0, len(following)),
+    Followers: make([]followingResponse, 0, len(followers)),
+  }
+  for _, f := range following {
+    follows.Following = append(follows.Following, followingResponse{
+      UserID:    f.UserID,
+      Account:   f.Username + "@" + f.Domain,
+      ActorID:   f.ActorID,
+      CreatedAt: f.CreatedAt,
+      Accepted:  f.AcceptedAt.Valid,
+    })
+  }
+  for _, f := range followers {
+    follows.Followers = append(follows.Followers, followingResponse{
+      UserID:    f.UserID,
+      Account:   f.Username + "@" + f.Domain,
+      ActorID:   f.ActorID,
+      CreatedAt: f.CreatedAt,
+      Accepted:  f.AcceptedAt.Valid,
+    })
+  }
+  err = addExportFile(zw, "follows.json", follows)
+  if err != nil {
+    return err
+  }
+
+  reactions, err := cfg.db.ListReactionsToUserChirps(ctx, userID)
+  if err != nil {
+    return fmt.Errorf("error fetching likes: %v", err)
+  }
+  reactionsResp := make([]exportReaction, 0, len(reactions))
+  for _, r := range reactions {
+    reactionsResp = append(reactionsResp, exportReaction{
+      ChirpID:   r.ChirpID,
+      Type:      r.Type,
+      ActorID:   r.ActorID,
+      CreatedAt: r.CreatedAt,
+    })
+  }
+  err = addExportFile(zw, "likes.json", reactionsResp)
+  if err != nil {
+    return err
+  }
+
+  tokens, err := cfg.db.ListRefreshTokensByUser(ctx, userID)
+  if err != nil {
+    return fmt.Errorf("error fetching sessions: %v", err)
+  }
+  sessions := make([]exportSession, 0, len(tokens))
+  for _, t := range tokens {
+    s := exportSession{CreatedAt: t.CreatedAt, UpdatedAt: t.UpdatedAt, ExpiresAt: t.ExpiresAt}
+    if t.RevokedAt.Valid {
+      s.RevokedAt = &t.RevokedAt.Time
+    }
+    sessions = append(sessions, s)
+  }
+  err = addExportFile(zw, "sessions.json", sessions)
+  if err != nil {
+    return err
+  }
+
+  events, err := cfg.db.ListAuditEvents(ctx, database.ListAuditEventsParams{
+    EventTypes: securityLogEvents,
+    UserID:     auditUser(userID),
+    RowLimit:   maxAuditExportRows,
+  })
+  if err != nil {
+    return fmt.Errorf("error fetching audit events: %v", err)
+  }
+  eventsResp := make([]auditEventResponse, 0, len(events))
+  for _, e := range events {
+    eventsResp = append(eventsResp, newAuditEventResponse(e))
+  }
+  err = addExportFile(zw, "audit.json", eventsResp)
+  if err != nil {
+    return err
+  }
+
+  var subscription *database.Subscription
+  sub, err := cfg.db.GetSubscriptionByUser(ctx, userID)
+  if err == nil {
+    subscription = &sub
+  } else if !errors.Is(err, sql.ErrNoRows) {
+    return fmt.Errorf("error fetching subscription: %v", err)
+  }
+  err = addExportFile(zw, "subscription.json", subscription)
+  if err != nil {
+    return err
+  }
+
+  endpoints, err := cfg.db.ListWebhookEndpointsByUser(ctx, userID)
+  if err != nil {
+    return fmt.Errorf("error fetching webhook endpoints: %v", err)
+  }
+  endpointsResp := make([]webhookEndpointResponse, 0, len(endpoints))
+  for _, e := range endpoints {
+    endpointsResp = append(endpointsResp, newWebhookEndpointResponse(e))
+  }
+  return addExportFile(zw, "webhooks.json", endpointsResp)
+}
+
+// pruneDataExports deletes the archives of exports whose links have
+// expired.
+func (cfg *apiConfig) pruneDataExports(ctx context.Context) error {
+  ids, err := cfg.db.ExpireDataExports(ctx, sql.NullTime{Time: time.Now(), Valid: true})
+  if err != nil {
+    return fmt.Errorf("error expiring exports: %v", err)
+  }
+  for _, id := range ids {
+    err := os.Remove(cfg.exportPath(id))
+    if err != nil && !errors.Is(err, os.ErrNotExist) {
+      log.Printf("Error deleting export %s: %v", id, err)
+    }
+  }
+  if len(ids) > 0 {
+    log.Printf("Deleted %d expired exports", len(ids))
+  }
+  return nil
+}
@@ -0,0 +1,561 @@
+package main
+
+import (
+  "context"
+  "database/sql"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "log"
+  "net/http"
+  "net/url"
+  "strconv"
+  "strings"
+  "time"
+  "chirpy/internal/activitypub"
+  "chirpy/internal/database"
+  "chirpy/internal/jobs"
+  "github.com/google/uuid"
+)
+
+const (
+  jobFederate        = "activitypub.fanout"
+  jobDeliverActivity = "activitypub.deliver"
+
+  // outboxLength is how many of the latest chirps an outbox lists
+  outboxLength = 20
+)
+
+// federateArgs is an activity by UserID to deliver to their followers and
+// to the remote actors in Recipients.
+type federateArgs struct {
+  UserID     uuid.UUID       `json:"user_id"`
+  Activity   json.RawMessage `json:"activity"`
+  Recipients []uuid.UUID     `json:"recipients,omitempty"`
+}
+
+type deliverActivityArgs struct {
+  UserID   uuid.UUID       `json:"user_id"`
+  Inbox    string          `json:"inbox"`
+  Activity json.RawMessage `json:"activity"`
+}
+
+func (cfg *apiConfig) actorURL(userID uuid.UUID) string {
+  return cfg.BaseURL + "/ap/users/" + userID.String()
+}
+
+func (cfg *apiConfig) noteURL(chirpID uuid.UUID) string {
+  return cfg.BaseURL + "/ap/chirps/" + chirpID.String()
+}
+
+// localDomain is the domain in local accounts' handles.
+func (cfg *apiConfig) localDomain() string {
+  u, err := url.Parse(cfg.BaseURL)
+  if err != nil {
+    return ""
+  }
+  return strings.ToLower(u.Host)
+}
+
+// localID is the ID at the end of one of this server's ActivityPub IRIs,
+// such as an actor or note, under prefix.
+func (cfg *apiConfig) localID(iri, prefix string) (uuid.UUID, bool) {
+  rest, ok := strings.CutPrefix(iri, cfg.BaseURL+prefix)
+  if !ok {
+    return uuid.Nil, false
+  }
+  id, err := uuid.Parse(rest)
+  return id, err == nil
+}
+
+// localActor returns the local account behind an actor. Remote actors'
+// shadow users, the placeholder that owns deleted accounts' replies and
+// banned accounts have none.
+func (cfg *apiConfig) localActor(ctx context.Context, userID uuid.UUID) (database.User, error) {
+  if userID == deletedUserID {
+    return database.User{}, sql.ErrNoRows
+  }
+  user, err := cfg.db.GetUserById(ctx, userID)
+  if err != nil {
+    return database.User{}, err
+  }
+  if user.AccountStatus == accountBanned {
+    return database.User{}, sql.ErrNoRows
+  }
+  _, err = cfg.db.GetRemoteActor(ctx, userID)
+  if err == nil {
+    return database.User{}, sql.ErrNoRows
+  }
+  if !errors.Is(err, sql.ErrNoRows) {
+    return database.User{}, err
+  }
+  return user, nil
+}
+
+// actorKey returns the key userID signs their activities with, making one
+// the first time.
+func (cfg *apiConfig) actorKey(ctx context.Context, userID uuid.UUID) (database.ActorKey, error) {
+  key, err := cfg.db.GetActorKey(ctx, userID)
+  if !errors.Is(err, sql.ErrNoRows) {
+    return key, err
+  }
+  privatePEM, publicPEM, err := activitypub.GenerateKey()
+  if err != nil {
+    return database.ActorKey{}, fmt.Errorf("error generating actor key: %v", err)
+  }
+  err = cfg.db.CreateActorKey(ctx, database.CreateActorKeyParams{
+    UserID:        userID,
+    CreatedAt:     time.Now(),
+    PublicKeyPem:  publicPEM,
+    PrivateKeyPem: privatePEM,
+  })
+  if err != nil {
+    return database.ActorKey{}, err
+  }
+  // another request may have made one first, and its key is the one kept
+  return cfg.db.GetActorKey(ctx, userID)
+}
+
+func (cfg *apiConfig) actorSigner(ctx context.Context, userID uuid.UUID) (activitypub.Signer, error) {
+  key, err := cfg.actorKey(ctx, userID)
+  if err != nil {
+    return activitypub.Signer{}, err
+  }
+  private, err := activitypub.ParsePrivateKey(key.PrivateKeyPem)
+  if err != nil {
+    return activitypub.Signer{}, err
+  }
+  return activitypub.Signer{KeyID: cfg.actorURL(userID) + "#main-key", Key: private}, nil
+}
+
+// respondWithActivity is respondWithJSON for documents other servers read,
+// which they expect in their own media types.
+func respondWithActivity(w http.ResponseWriter, contentType string, payload any) {
+  dat, err := json.Marshal(payload)
+  if err != nil {
+    log.Printf("Error marshalling JSON: %s", err)
+    w.WriteHeader(http.StatusInternalServerError)
+    return
+  }
+  w.Header().Set("Content-Type", contentType)
+  w.WriteHeader(http.StatusOK)
+  w.Write(dat)
+}
+
+// handleWebFinger finds the actor for a local account. Accounts have no
+// usernames, so their handle is their ID: acct:<user ID>@<domain>.
+func (cfg *apiConfig) handleWebFinger(w http.ResponseWriter, r *http.Request) {
+  resource := r.URL.Query().Get("resource")
+  if resource == "" {
+    respondWithError(w, http.StatusBadRequest, "Missing resource", nil)
+    return
+  }
+  userID, ok := cfg.localID(resource, "/ap/users/")
+  if !ok && strings.HasPrefix(resource, "acct:") {
+    username, domain, isAccount := activitypub.SplitAccount(resource)
+    id, err := uuid.Parse(username)
+    userID, ok = id, isAccount && err == nil && domain == cfg.localDomain()
+  }
+  if !ok {
+    respondWithError(w, http.StatusNotFound, "Account not found", nil)
+    return
+  }
+
+  user, err := cfg.localActor(context.Background(), userID)
+  if errors.Is(err, sql.ErrNoRows) {
+    respondWithError(w, http.StatusNotFound, "Account not found", nil)
+    return
+  }
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching account", err)
+    return
+  }
+
+  actor := cfg.actorURL(user.ID)
+  respondWithActivity(w, "application/jrd+json", activitypub.WebFinger{
+    Subject: "acct:" + user.ID.String() + "@" + cfg.localDomain(),
+    Aliases: []string{actor},
+    Links: []activitypub.Link{
+      {Rel: "self", Type: activitypub.ContentType, Href: actor},
+    },
+  })
+}
+
+// pathActor returns the local account named by the request's userID path
+// value, responding with an error if there is none.
+func (cfg *apiConfig) pathActor(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+  userID, err := uuid.Parse(r.PathValue("userID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+    return database.User{}, false
+  }
+  user, err := cfg.localActor(context.Background(), userID)
+  if errors.Is(err, sql.ErrNoRows) {
+    respondWithError(w, http.StatusNotFound, "Account not found", nil)
+    return database.User{}, false
+  }
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching account", err)
+    return database.User{}, false
+  }
+  return user, true
+}
+
+func (cfg *apiConfig) handleGetActor(w http.ResponseWriter, r *http.Request) {
+  user, ok := cfg.pathActor(w, r)
+  if !ok {
+    return
+  }
+  key, err := cfg.actorKey(context.Background(), user.ID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching account", err)
+    return
+  }
+
+  actor := cfg.actorURL(user.ID)
+  published := user.CreatedAt.UTC()
+  respondWithActivity(w, activitypub.ContentType, activitypub.Actor{
+    Context:           activitypub.Context,
+    ID:                actor,
+    Type:              activitypub.TypePerson,
+    PreferredUsername: user.ID.String(),
+    URL:               cfg.BaseURL + "/api/users/" + user.ID.String(),
+    Inbox:             actor + "/inbox",
+    Outbox:            actor + "/outbox",
+    Followers:         actor + "/followers",
+    Following:         actor + "/following",
+    Published:         &published,
+    PublicKey: activitypub.PublicKey{
+      ID:           actor + "#main-key",
+      Owner:        actor,
+      PublicKeyPem: key.PublicKeyPem,
+    },
+    Endpoints: &activitypub.Endpoints{SharedInbox: cfg.BaseURL + "/ap/inbox"},
+  })
+}
+
+// handleGetOutbox lists the Create activities for an account's latest
+// chirps, as anyone not logged in sees them.
+func (cfg *apiConfig) handleGetOutbox(w http.ResponseWriter, r *http.Request) {
+  user, ok := cfg.pathActor(w, r)
+  if !ok {
+    return
+  }
+  ctx := context.Background()
+  chirps, err := cfg.db.GetChirpsByAuthorsDesc(ctx, []uuid.UUID{user.ID})
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching outbox", err)
+    return
+  }
+  chirps, err = cfg.visibleChirps(ctx, chirps, uuid.NullUUID{})
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching outbox", err)
+    return
+  }
+
+  total := len(chirps)
+  if len(chirps) > outboxLength {
+    chirps = chirps[:outboxLength]
+  }
+  items := make([]any, 0, len(chirps))
+  for _, c := range chirps {
+    note, _, err := cfg.chirpNote(ctx, c)
+    if err != nil {
+      respondWithError(w, http.StatusInternalServerError, "Error fetching outbox", err)
+      return
+    }
+    create, err := createActivity(note)
+    if err != nil {
+      respondWithError(w, http.StatusInternalServerError, "Error fetching outbox", err)
+      return
+    }
+    create.Context = nil
+    items = append(items, create)
+  }
+
+  respondWithActivity(w, activitypub.ContentType, activitypub.Collection{
+    Context:      activitypub.Context,
+    ID:           cfg.actorURL(user.ID) + "/outbox",
+    Type:         "OrderedCollection",
+    TotalItems:   int64(total),
+    OrderedItems: items,
+  })
+}
+
+// handleGetFollowers and handleGetFollowing say how many accounts there
+// are, but not who they are.
+func (cfg *apiConfig) handleGetFollowers(w http.ResponseWriter, r *http.Request) {
+  user, ok := cfg.pathActor(w, r)
+  if !ok {
+    return
+  }
+  count, err := cfg.db.CountFollowers(context.Background(), user.ID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching followers", err)
+    return
+  }
+  respondWithActivity(w, activitypub.ContentType, activitypub.Collection{
+    Context:    activitypub.Context,
+    ID:         cfg.actorURL(user.ID) + "/followers",
+    Type:       "OrderedCollection",
+    TotalItems: count,
+  })
+}
+
+func (cfg *apiConfig) handleGetFollowing(w http.ResponseWriter, r *http.Request) {
+  user, ok := cfg.pathActor(w, r)
+  if !ok {
+    return
+  }
+  count, err := cfg.db.CountFollowing(context.Background(), user.ID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching following", err)
+    return
+  }
+  respondWithActivity(w, activitypub.ContentType, activitypub.Collection{
+    Context:    activitypub.Context,
+    ID:         cfg.actorURL(user.ID) + "/following",
+    Type:       "OrderedCollection",
+    TotalItems: count,
+  })
+}
+
+// handleGetNote serves a local chirp as a note, with how many times remote
+// actors have liked and boosted it.
+func (cfg *apiConfig) handleGetNote(w http.ResponseWriter, r *http.Request) {
+  chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+    return
+  }
+  ctx := context.Background()
+  chirp, err := cfg.db.GetOneChirp(ctx, chirpID)
+  if errors.Is(err, sql.ErrNoRows) {
+    respondWithError(w, http.StatusNotFound, "Chirp not found", nil)
+    return
+  }
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching chirp", err)
+    return
+  }
+  // remote chirps are served by their own servers
+  _, err = cfg.localActor(ctx, chirp.UserID)
+  if errors.Is(err, sql.ErrNoRows) {
+    respondWithError(w, http.StatusNotFound, "Chirp not found", nil)
+    return
+  }
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching chirp", err)
+    return
+  }
+  visible, err := cfg.visibleChirps(ctx, []database.Chirp{chirp}, uuid.NullUUID{})
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching chirp", err)
+    return
+  }
+  if len(visible) == 0 {
+    respondWithError(w, http.StatusNotFound, "Chirp not found", nil)
+    return
+  }
+
+  note, _, err := cfg.chirpNote(ctx, chirp)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching chirp", err)
+    return
+  }
+  reactions, err := cfg.db.CountChirpReactions(ctx, chirp.ID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching chirp", err)
+    return
+  }
+  note.Context = activitypub.Context
+  note.Likes = &activitypub.Collection{Type: "Collection", TotalItems: int64(reactions.Likes)}
+  note.Shares = &activitypub.Collection{Type: "Collection", TotalItems: int64(reactions.Announces)}
+  respondWithActivity(w, activitypub.ContentType, note)
+}
+
+// chirpNote is a local chirp as a public note. A quote is a reply to the
+// quoted chirp; when that is a remote actor's, the actor is returned as a
+// recipient, since their server should hear about it.
+func (cfg *apiConfig) chirpNote(ctx context.Context, chirp database.Chirp) (activitypub.Note, []uuid.UUID, error) {
+  actor := cfg.actorURL(chirp.UserID)
+  note := activitypub.Note{
+    ID:           cfg.noteURL(chirp.ID),
+    Type:         activitypub.TypeNote,
+    AttributedTo: activitypub.IRI(actor),
+    Content:      activitypub.ContentHTML(chirp.Body),
+    Published:    chirp.CreatedAt.UTC(),
+    URL:          cfg.BaseURL + "/api/chirps/" + chirp.ID.String(),
+    To:           activitypub.IRIs{activitypub.Public},
+    Cc:           activitypub.IRIs{actor + "/followers"},
+  }
+  if !chirp.QuoteOf.Valid {
+    return note, nil, nil
+  }
+
+  quoted, err := cfg.db.GetOneChirp(ctx, chirp.QuoteOf.UUID)
+  if errors.Is(err, sql.ErrNoRows) {
+    return note, nil, nil
+  }
+  if err != nil {
+    return activitypub.Note{}, nil, err
+  }
+  objectID, err := cfg.db.GetRemoteChirpObjectID(ctx, quoted.ID)
+  if errors.Is(err, sql.ErrNoRows) {
+    note.InReplyTo = activitypub.IRI(cfg.noteURL(quoted.ID))
+    return note, nil, nil
+  }
+  if err != nil {
+    return activitypub.Note{}, nil, err
+  }
+  note.InReplyTo = activitypub.IRI(objectID)
+  remote, err := cfg.db.GetRemoteActor(ctx, quoted.UserID)
+  if err != nil {
+    return activitypub.Note{}, nil, err
+  }
+  note.Cc = append(note.Cc, remote.ActorID)
+  return note, []uuid.UUID{remote.UserID}, nil
+}
+
+func createActivity(note activitypub.Note) (activitypub.Activity, error) {
+  create, err := activitypub.NewActivity(note.ID+"/activity", activitypub.TypeCreate, string(note.AttributedTo), note)
+  if err != nil {
+    return activitypub.Activity{}, err
+  }
+  create.To, create.Cc = note.To, note.Cc
+  create.Published = &note.Published
+  return create, nil
+}
+
+// federateChirp tells the followers of a chirp's author that it was
+// created, edited or deleted. Like publishEvent, failing to queue it never
+// fails the request that caused it.
+func (cfg *apiConfig) federateChirp(ctx context.Context, chirp database.Chirp, typ string) {
+  err := cfg.queueChirpActivity(ctx, chirp, typ)
+  if err != nil {
+    log.Printf("Error federating %s of chirp %s: %v", typ, chirp.ID, err)
+  }
+}
+
+func (cfg *apiConfig) queueChirpActivity(ctx context.Context, chirp database.Chirp, typ string) error {
+  if chirp.HeldAt.Valid && typ != activitypub.TypeDelete {
+    return nil
+  }
+  // remote chirps are federated by their own servers, and shadow-banned
+  // accounts' chirps aren't shown to anyone else
+  _, err := cfg.localActor(ctx, chirp.UserID)
+  if errors.Is(err, sql.ErrNoRows) {
+    return nil
+  }
+  if err != nil {
+    return err
+  }
+  banned, err := cfg.shadowBannedAmong(ctx, []uuid.UUID{chirp.UserID})
+  if err != nil {
+    return err
+  }
+  if banned[chirp.UserID] {
+    return nil
+  }
+
+  note, recipients, err := cfg.chirpNote(ctx, chirp)
+  if err != nil {
+    return err
+  }
+  var activity activitypub.Activity
+  switch typ {
+  case activitypub.TypeCreate:
+    activity, err = createActivity(note)
+  case activitypub.TypeUpdate:
+    updated := chirp.UpdatedAt.UTC()
+    note.Updated = &updated
+    activity, err = activitypub.NewActivity(note.ID+"#updates/"+strconv.FormatInt(updated.Unix(), 10), typ, string(note.AttributedTo), note)
+    activity.To, activity.Cc = note.To, note.Cc
+  case activitypub.TypeDelete:
+    activity, err = activitypub.NewActivity(note.ID+"#delete", typ, string(note.AttributedTo), activitypub.Tombstone{
+      ID:   note.ID,
+      Type: activitypub.TypeTombstone,
+    })
+    activity.To, activity.Cc = note.To, note.Cc
+  default:
+    return fmt.Errorf("can't federate a %s", typ)
+  }
+  if err != nil {
+    return err
+  }
+
+  data, err := json.Marshal(activity)
+  if err != nil {
+    return err
+  }
+  _, err = cfg.jobs.Enqueue(ctx, jobFederate, federateArgs{
+    UserID:     chirp.UserID,
+    Activity:   data,
+    Recipients: recipients,
+  }, jobs.MaxAttempts(5))
+  return err
+}
+
+// handleFederateJob fans an activity out into one delivery per inbox. Many
+// followers on one server share an inbox, and get a single delivery.
+func (cfg *apiConfig) handleFederateJob(ctx context.Context, job jobs.Job[federateArgs]) error {
+  inboxes, err := cfg.db.ListFollowerInboxes(ctx, job.Args.UserID)
+  if err != nil {
+    return fmt.Errorf("error listing follower inboxes: %v", err)
+  }
+  if len(job.Args.Recipients) > 0 {
+    more, err := cfg.db.ListRemoteInboxes(ctx, job.Args.Recipients)
+    if err != nil {
+      return fmt.Errorf("error listing recipient inboxes: %v", err)
+    }
+    inboxes = append(inboxes, more...)
+  }
+
+  seen := map[string]bool{}
+  for _, inbox := range inboxes {
+    if seen[inbox] {
+      continue
+    }
+    seen[inbox] = true
+    // a fan-out that is retried doesn't deliver twice to the inboxes it
+    // already got to
+    _, err := cfg.jobs.Enqueue(ctx, jobDeliverActivity, deliverActivityArgs{
+      UserID:   job.Args.UserID,
+      Inbox:    inbox,
+      Activity: job.Args.Activity,
+    }, jobs.MaxAttempts(8), jobs.UniqueKey(job.ID.String()+" "+inbox))
+    if err != nil {
+      return fmt.Errorf("error queueing delivery: %v", err)
+    }
+  }
+  return nil
+}
+
+// queueDelivery queues one activity by userID for one inbox.
+func (cfg *apiConfig) queueDelivery(ctx context.Context, userID uuid.UUID, inbox string, activity activitypub.Activity) error {
+  data, err := json.Marshal(activity)
+  if err != nil {
+    return err
+  }
+  _, err = cfg.jobs.Enqueue(ctx, jobDeliverActivity, deliverActivityArgs{
+    UserID:   userID,
+    Inbox:    inbox,
+    Activity: data,
+  }, jobs.MaxAttempts(8))
+  return err
+}
+
+// handleDeliverActivityJob signs an activity as its actor and posts it to
+// an inbox. Servers that turn it away for good aren't tried again.
+func (cfg *apiConfig) handleDeliverActivityJob(ctx context.Context, job jobs.Job[deliverActivityArgs]) error {
+  signer, err := cfg.actorSigner(ctx, job.Args.UserID)
+  if err != nil {
+    return fmt.Errorf("error loading actor key: %v", err)
+  }
+  err = cfg.federation.Deliver(ctx, job.Args.Inbox, job.Args.Activity, signer)
+  var status *activitypub.StatusError
+  if errors.As(err, &status) && status.Permanent() {
+    return jobs.Permanent(err)
+  }
+  return err
+}
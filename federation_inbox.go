@@ -0,0 +1,519 @@
+package main
+
+import (
+  "context"
+  "database/sql"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io"
+  "log"
+  "net/http"
+  "net/url"
+  "path"
+  "strings"
+  "time"
+  "chirpy/internal/activitypub"
+  "chirpy/internal/contentfilter"
+  "chirpy/internal/database"
+  "chirpy/internal/twitterarchive"
+  "chirpy/internal/webhooks"
+  "github.com/google/uuid"
+)
+
+// maxInboxBody caps the activities other servers can deliver.
+const maxInboxBody = 1 << 20
+
+var (
+  errInvalidActivity  = errors.New("invalid activity")
+  errInvalidSignature = errors.New("invalid signature")
+)
+
+// handleInbox takes activities delivered by other servers, to one actor's
+// inbox or the shared one. Every delivery must be signed by the actor it
+// is from. Activities that don't concern anything here are accepted and
+// ignored, as other servers expect.
+func (cfg *apiConfig) handleInbox(w http.ResponseWriter, r *http.Request) {
+  body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxInboxBody))
+  if err != nil {
+    respondWithError(w, http.StatusRequestEntityTooLarge, "Activity is too large", err)
+    return
+  }
+  var activity activitypub.Activity
+  err = json.Unmarshal(body, &activity)
+  if err != nil || activity.ID == "" || activity.Type == "" || activity.Actor == "" {
+    respondWithError(w, http.StatusBadRequest, "Invalid activity", err)
+    return
+  }
+
+  ctx := context.Background()
+  // servers tell everyone they ever talked to when an account is deleted,
+  // and its key can't be fetched any more to check
+  if activity.Type == activitypub.TypeDelete && activity.ObjectID() == string(activity.Actor) {
+    _, err := cfg.db.GetRemoteActorByActorID(ctx, string(activity.Actor))
+    if errors.Is(err, sql.ErrNoRows) {
+      w.WriteHeader(http.StatusAccepted)
+      return
+    }
+    if err != nil {
+      respondWithError(w, http.StatusInternalServerError, "Error receiving activity", err)
+      return
+    }
+  }
+
+  sender, err := cfg.inboxSender(ctx, r, body)
+  if errors.Is(err, errInvalidSignature) {
+    respondWithError(w, http.StatusUnauthorized, "Invalid signature", err)
+    return
+  }
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error receiving activity", err)
+    return
+  }
+  if string(activity.Actor) != sender.ActorID {
+    respondWithError(w, http.StatusUnauthorized, "Activity isn't by the signer", nil)
+    return
+  }
+
+  err = cfg.receiveActivity(ctx, sender, activity)
+  if errors.Is(err, errInvalidActivity) {
+    respondWithError(w, http.StatusBadRequest, "Invalid activity", err)
+    return
+  }
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error receiving activity", err)
+    return
+  }
+  w.WriteHeader(http.StatusAccepted)
+}
+
+// inboxSender checks a delivery's HTTP signature and returns the remote
+// actor that made it. Actors are fetched the first time they are seen, and
+// again when their stored key doesn't verify, in case it was rotated.
+func (cfg *apiConfig) inboxSender(ctx context.Context, r *http.Request, body []byte) (database.RemoteActor, error) {
+  sig, err := activitypub.ParseSignature(r, body, time.Now())
+  if err != nil {
+    return database.RemoteActor{}, fmt.Errorf("%w: %v", errInvalidSignature, err)
+  }
+  sender, err := cfg.db.GetRemoteActorByKeyID(ctx, sig.KeyID)
+  if err == nil && verifySender(sig, sender) == nil {
+    return sender, nil
+  }
+  if err != nil && !errors.Is(err, sql.ErrNoRows) {
+    return database.RemoteActor{}, err
+  }
+
+  actor, err := cfg.federation.FetchKeyOwner(ctx, sig.KeyID)
+  if err != nil {
+    return database.RemoteActor{}, fmt.Errorf("%w: %v", errInvalidSignature, err)
+  }
+  sender, err = cfg.storeRemoteActor(ctx, actor)
+  if err != nil {
+    return database.RemoteActor{}, err
+  }
+  err = verifySender(sig, sender)
+  if err != nil {
+    return database.RemoteActor{}, fmt.Errorf("%w: %v", errInvalidSignature, err)
+  }
+  return sender, nil
+}
+
+func verifySender(sig *activitypub.Signature, sender database.RemoteActor) error {
+  key, err := activitypub.ParsePublicKey(sender.PublicKeyPem)
+  if err != nil {
+    return err
+  }
+  return sig.Verify(key)
+}
+
+// resolveRemoteActor returns the remote actor with the given id, fetching
+// it if it hasn't been seen before.
+func (cfg *apiConfig) resolveRemoteActor(ctx context.Context, actorID string) (database.RemoteActor, error) {
+  remote, err := cfg.db.GetRemoteActorByActorID(ctx, actorID)
+  if !errors.Is(err, sql.ErrNoRows) {
+    return remote, err
+  }
+  actor, err := cfg.federation.FetchActor(ctx, actorID)
+  if err != nil {
+    return database.RemoteActor{}, err
+  }
+  return cfg.storeRemoteActor(ctx, actor)
+}
+
+// storeRemoteActor saves a fetched actor, updating it if it is already
+// known and otherwise giving it a shadow user. Shadow users have no
+// password and an email address nobody can receive at, so they can't log
+// in.
+func (cfg *apiConfig) storeRemoteActor(ctx context.Context, actor activitypub.Actor) (database.RemoteActor, error) {
+  u, err := url.Parse(actor.ID)
+  if err != nil {
+    return database.RemoteActor{}, fmt.Errorf("%w: %v", errInvalidSignature, err)
+  }
+  domain := strings.ToLower(u.Host)
+  if domain == cfg.localDomain() {
+    return database.RemoteActor{}, fmt.Errorf("%w: %s is a local actor", errInvalidSignature, actor.ID)
+  }
+  username := actor.PreferredUsername
+  if username == "" {
+    username = path.Base(u.Path)
+  }
+  sharedInbox := sql.NullString{String: actor.SharedInbox(), Valid: actor.SharedInbox() != ""}
+
+  existing, err := cfg.db.GetRemoteActorByActorID(ctx, actor.ID)
+  if err == nil {
+    return cfg.db.UpdateRemoteActor(ctx, database.UpdateRemoteActorParams{
+      UserID:       existing.UserID,
+      Username:     username,
+      Inbox:        actor.Inbox,
+      SharedInbox:  sharedInbox,
+      KeyID:        actor.PublicKey.ID,
+      PublicKeyPem: actor.PublicKey.PublicKeyPem,
+    })
+  }
+  if !errors.Is(err, sql.ErrNoRows) {
+    return database.RemoteActor{}, err
+  }
+
+  tx, err := cfg.sqlDB.BeginTx(ctx, nil)
+  if err != nil {
+    return database.RemoteActor{}, err
+  }
+  defer tx.Rollback()
+  qtx := cfg.db.WithTx(tx)
+
+  now := time.Now()
+  userID := uuid.New()
+  _, err = qtx.CreateShadowUser(ctx, database.CreateShadowUserParams{
+    ID:        userID,
+    CreatedAt: now,
+    Email:     userID.String() + "@remote.invalid",
+  })
+  if err != nil {
+    return database.RemoteActor{}, fmt.Errorf("error creating shadow user: %v", err)
+  }
+  remote, err := qtx.CreateRemoteActor(ctx, database.CreateRemoteActorParams{
+    UserID:       userID,
+    CreatedAt:    now,
+    ActorID:      actor.ID,
+    Username:     username,
+    Domain:       domain,
+    Inbox:        actor.Inbox,
+    SharedInbox:  sharedInbox,
+    KeyID:        actor.PublicKey.ID,
+    PublicKeyPem: actor.PublicKey.PublicKeyPem,
+  })
+  if isUniqueViolation(err) {
+    // another delivery from the same actor got here first
+    return cfg.db.GetRemoteActorByActorID(ctx, actor.ID)
+  }
+  if err != nil {
+    return database.RemoteActor{}, fmt.Errorf("error creating remote actor: %v", err)
+  }
+  err = tx.Commit()
+  if err != nil {
+    return database.RemoteActor{}, err
+  }
+  return remote, nil
+}
+
+func (cfg *apiConfig) receiveActivity(ctx context.Context, sender database.RemoteActor, activity activitypub.Activity) error {
+  switch activity.Type {
+  case activitypub.TypeFollow:
+    return cfg.receiveFollow(ctx, sender, activity)
+  case activitypub.TypeAccept:
+    _, err := cfg.db.AcceptFollow(ctx, database.AcceptFollowParams{
+      ActivityID: activity.ObjectID(),
+      FolloweeID: sender.UserID,
+      AcceptedAt: sql.NullTime{Time: time.Now(), Valid: true},
+    })
+    return err
+  case activitypub.TypeReject:
+    _, err := cfg.db.DeleteFollowByActivity(ctx, database.DeleteFollowByActivityParams{
+      ActivityID: activity.ObjectID(),
+      UserID:     sender.UserID,
+    })
+    return err
+  case activitypub.TypeUndo:
+    return cfg.receiveUndo(ctx, sender, activity)
+  case activitypub.TypeLike, activitypub.TypeAnnounce:
+    return cfg.receiveReaction(ctx, sender, activity)
+  case activitypub.TypeCreate:
+    return cfg.receiveNote(ctx, sender, activity)
+  case activitypub.TypeDelete:
+    return cfg.receiveDelete(ctx, sender, activity)
+  case activitypub.TypeUpdate:
+    if activity.ObjectType() != activitypub.TypePerson || activity.ObjectID() != sender.ActorID {
+      return nil
+    }
+    actor, err := cfg.federation.FetchActor(ctx, sender.ActorID)
+    if err != nil {
+      log.Printf("Error refreshing actor %s: %v", sender.ActorID, err)
+      return nil
+    }
+    _, err = cfg.storeRemoteActor(ctx, actor)
+    return err
+  }
+  return nil
+}
+
+// receiveFollow accepts a remote actor following a local account, unless
+// the account blocks them. Accounts don't approve followers by hand.
+func (cfg *apiConfig) receiveFollow(ctx context.Context, sender database.RemoteActor, follow activitypub.Activity) error {
+  userID, ok := cfg.localID(follow.ObjectID(), "/ap/users/")
+  if !ok {
+    return nil
+  }
+  user, err := cfg.localActor(ctx, userID)
+  if errors.Is(err, sql.ErrNoRows) {
+    return nil
+  }
+  if err != nil {
+    return err
+  }
+  blocked, err := cfg.isBlockedBy(ctx, uuid.NullUUID{UUID: sender.UserID, Valid: true}, user.ID)
+  if err != nil {
+    return err
+  }
+
+  now := time.Now()
+  answer := activitypub.TypeAccept
+  if blocked {
+    answer = activitypub.TypeReject
+  } else {
+    _, err = cfg.db.CreateFollow(ctx, database.CreateFollowParams{
+      FollowerID: sender.UserID,
+      FolloweeID: user.ID,
+      CreatedAt:  now,
+      ActivityID: follow.ID,
+      AcceptedAt: sql.NullTime{Time: now, Valid: true},
+    })
+    if err != nil {
+      return fmt.Errorf("error creating follow: %v", err)
+    }
+  }
+
+  actor := cfg.actorURL(user.ID)
+  follow.Context = nil
+  response, err := activitypub.NewActivity(actor+"#"+strings.ToLower(answer)+"s/"+uuid.NewString(), answer, actor, follow)
+  if err != nil {
+    return err
+  }
+  response.To = activitypub.IRIs{sender.ActorID}
+  err = cfg.queueDelivery(ctx, user.ID, sender.Inbox, response)
+  if err != nil {
+    return fmt.Errorf("error queueing %s: %v", answer, err)
+  }
+
+  if !blocked {
+    cfg.publishEvent(ctx, webhooks.Event{
+      Type:   webhooks.EventFollowCreated,
+      UserID: user.ID,
+      Data: map[string]any{
+        "follower_id": sender.UserID,
+        "account":     sender.Username + "@" + sender.Domain,
+      },
+    })
+  }
+  return nil
+}
+
+// receiveUndo takes back a follow, like or boost. Only the actor that made
+// one can undo it.
+func (cfg *apiConfig) receiveUndo(ctx context.Context, sender database.RemoteActor, undo activitypub.Activity) error {
+  objectID := undo.ObjectID()
+  typ := undo.ObjectType()
+  if typ == "" || typ == activitypub.TypeFollow {
+    _, err := cfg.db.DeleteFollowByActivity(ctx, database.DeleteFollowByActivityParams{
+      ActivityID: objectID,
+      UserID:     sender.UserID,
+    })
+    if err != nil {
+      return err
+    }
+  }
+  if typ == "" || typ == activitypub.TypeLike || typ == activitypub.TypeAnnounce {
+    _, err := cfg.db.DeleteChirpReaction(ctx, database.DeleteChirpReactionParams{
+      ActivityID: objectID,
+      UserID:     sender.UserID,
+    })
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// receiveReaction records a remote actor liking or boosting a local chirp,
+// if it is one they can see: not deleted, held or shadow-banned, and by an
+// author who hasn't blocked them.
+func (cfg *apiConfig) receiveReaction(ctx context.Context, sender database.RemoteActor, activity activitypub.Activity) error {
+  chirpID, ok := cfg.localID(activity.ObjectID(), "/ap/chirps/")
+  if !ok {
+    return nil
+  }
+  _, ok, err := cfg.visibleChirp(ctx, chirpID, uuid.NullUUID{UUID: sender.UserID, Valid: true})
+  if err != nil || !ok {
+    return err
+  }
+  return cfg.db.CreateChirpReaction(ctx, database.CreateChirpReactionParams{
+    ActivityID: activity.ID,
+    CreatedAt:  time.Now(),
+    ChirpID:    chirpID,
+    UserID:     sender.UserID,
+    Type:       activity.Type,
+  })
+}
+
+// receiveNote stores a remote actor's public note as a chirp by their
+// shadow user, if it replies to a chirp already here or someone here
+// follows them. It goes through the same validation and content filter as
+// any other chirp; a note that is too long is cut short rather than lost.
+// Spam scoring is left to the server it came from.
+func (cfg *apiConfig) receiveNote(ctx context.Context, sender database.RemoteActor, create activitypub.Activity) error {
+  if create.ObjectType() != activitypub.TypeNote {
+    return nil
+  }
+  var note activitypub.Note
+  err := create.DecodeObject(&note)
+  if err != nil {
+    return fmt.Errorf("%w: %v", errInvalidActivity, err)
+  }
+  if note.ID == "" || string(note.AttributedTo) != sender.ActorID {
+    return fmt.Errorf("%w: note isn't by the sender", errInvalidActivity)
+  }
+  if !note.To.Contains(activitypub.Public) && !note.Cc.Contains(activitypub.Public) {
+    return nil
+  }
+  _, err = cfg.db.GetRemoteChirpID(ctx, note.ID)
+  if err == nil {
+    return nil
+  }
+  if !errors.Is(err, sql.ErrNoRows) {
+    return err
+  }
+
+  var quoteOf uuid.NullUUID
+  if note.InReplyTo != "" {
+    if id, ok := cfg.localID(string(note.InReplyTo), "/ap/chirps/"); ok {
+      quoteOf = uuid.NullUUID{UUID: id, Valid: true}
+    } else if id, err := cfg.db.GetRemoteChirpID(ctx, string(note.InReplyTo)); err == nil {
+      quoteOf = uuid.NullUUID{UUID: id, Valid: true}
+    } else if !errors.Is(err, sql.ErrNoRows) {
+      return err
+    }
+  }
+  var quoted database.Chirp
+  if quoteOf.Valid {
+    var msg string
+    quoted, msg, err = cfg.checkQuotable(ctx, sender.UserID, quoteOf.UUID)
+    if err != nil {
+      return err
+    }
+    if msg != "" {
+      return nil
+    }
+  } else {
+    followed, err := cfg.db.IsFollowed(ctx, sender.UserID)
+    if err != nil {
+      return err
+    }
+    if !followed {
+      return nil
+    }
+  }
+
+  body := activitypub.ContentText(note.Content)
+  if body == "" {
+    return nil
+  }
+  ent, err := cfg.entitlementsFor(ctx, sender.UserID)
+  if err != nil {
+    return fmt.Errorf("error fetching entitlements: %v", err)
+  }
+  body = twitterarchive.Truncate(body, ent.MaxChirpLength)
+  filtered, err := cfg.validateChirp(ctx, sender.UserID, body)
+  var rejection chirpRejection
+  if errors.As(err, &rejection) {
+    return nil
+  }
+  if err != nil {
+    return err
+  }
+
+  now := time.Now()
+  createdAt := note.Published.Local()
+  if createdAt.IsZero() || createdAt.After(now) {
+    createdAt = now
+  }
+
+  tx, err := cfg.sqlDB.BeginTx(ctx, nil)
+  if err != nil {
+    return err
+  }
+  defer tx.Rollback()
+  qtx := cfg.db.WithTx(tx)
+
+  chirp, err := qtx.CreateChirp(ctx, database.CreateChirpParams{
+    ID:        uuid.New(),
+    CreatedAt: createdAt,
+    UpdatedAt: now,
+    Body:      filtered.Text,
+    UserID:    sender.UserID,
+    QuoteOf:   quoteOf,
+  })
+  if err != nil {
+    return fmt.Errorf("error creating chirp: %v", err)
+  }
+  err = qtx.CreateRemoteChirp(ctx, database.CreateRemoteChirpParams{
+    ObjectID: note.ID,
+    ChirpID:  chirp.ID,
+  })
+  if isUniqueViolation(err) {
+    // delivered to more than one inbox at once
+    return nil
+  }
+  if err != nil {
+    return fmt.Errorf("error creating remote chirp: %v", err)
+  }
+  if quoteOf.Valid {
+    err = createQuoteSnapshot(ctx, qtx, chirp.ID, quoted)
+    if err != nil {
+      return err
+    }
+  }
+  if filtered.Action == contentfilter.ActionHold {
+    _, err = holdChirp(ctx, qtx, chirp, chirpHold{ruleID: uuid.NullUUID{UUID: filtered.RuleID, Valid: true}})
+    if err != nil {
+      return err
+    }
+  }
+  return tx.Commit()
+}
+
+// receiveDelete deletes a remote actor, along with everything of theirs,
+// or one of their notes.
+func (cfg *apiConfig) receiveDelete(ctx context.Context, sender database.RemoteActor, activity activitypub.Activity) error {
+  objectID := activity.ObjectID()
+  if objectID == sender.ActorID {
+    return cfg.db.DeleteUser(ctx, sender.UserID)
+  }
+  chirpID, err := cfg.db.GetRemoteChirpID(ctx, objectID)
+  if errors.Is(err, sql.ErrNoRows) {
+    return nil
+  }
+  if err != nil {
+    return err
+  }
+  chirp, err := cfg.db.GetOneChirp(ctx, chirpID)
+  if errors.Is(err, sql.ErrNoRows) {
+    return nil
+  }
+  if err != nil {
+    return err
+  }
+  if chirp.UserID != sender.UserID {
+    return nil
+  }
+  _, err = cfg.db.RemoveChirp(ctx, chirp.ID)
+  return err
+}
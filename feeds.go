@@ -0,0 +1,212 @@
+package main
+
+import (
+  "bytes"
+  "context"
+  "crypto/sha256"
+  "encoding/hex"
+  "net/http"
+  "net/url"
+  "strconv"
+  "strings"
+  "time"
+  "unicode"
+  "unicode/utf8"
+  "chirpy/internal/database"
+  "chirpy/internal/feed"
+  "github.com/google/uuid"
+)
+
+const (
+  feedAtom = "atom"
+  feedRSS  = "rss"
+
+  // feedLength is how many of the latest chirps a feed holds
+  feedLength = 50
+  // feedMaxAge is how long clients and proxies may cache a feed
+  feedMaxAge = 5 * time.Minute
+
+  maxHashtagLength = 100
+)
+
+func (cfg *apiConfig) handleUserAtomFeed(w http.ResponseWriter, r *http.Request) {
+  cfg.serveUserFeed(w, r, feedAtom)
+}
+
+func (cfg *apiConfig) handleUserRSSFeed(w http.ResponseWriter, r *http.Request) {
+  cfg.serveUserFeed(w, r, feedRSS)
+}
+
+func (cfg *apiConfig) handleHashtagAtomFeed(w http.ResponseWriter, r *http.Request) {
+  cfg.serveHashtagFeed(w, r, feedAtom)
+}
+
+func (cfg *apiConfig) handleHashtagRSSFeed(w http.ResponseWriter, r *http.Request) {
+  cfg.serveHashtagFeed(w, r, feedRSS)
+}
+
+// serveUserFeed serves a user's latest chirps as they appear to someone
+// who isn't logged in, which is how feed readers fetch them.
+func (cfg *apiConfig) serveUserFeed(w http.ResponseWriter, r *http.Request, format string) {
+  userID, err := uuid.Parse(r.PathValue("userID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+    return
+  }
+  ctx := context.Background()
+  user, err := cfg.db.GetUserById(ctx, userID)
+  if err != nil {
+    respondWithError(w, http.StatusNotFound, "User not found", nil)
+    return
+  }
+
+  chirps, err := cfg.db.GetLatestChirpsByAuthor(ctx, database.GetLatestChirpsByAuthorParams{
+    UserID:   user.ID,
+    RowLimit: feedLength,
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching feed", err)
+    return
+  }
+  chirps, err = cfg.visibleChirps(ctx, chirps, uuid.NullUUID{})
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching feed", err)
+    return
+  }
+  author := cfg.feedAuthor(user.ID)
+  f := feed.Feed{
+    ID:          "urn:uuid:" + user.ID.String(),
+    Title:       "Chirps by " + user.ID.String(),
+    Description: "The latest chirps by " + user.ID.String() + " on Chirpy",
+    Link:        author.URI,
+    SelfLink:    cfg.BaseURL + r.URL.Path,
+    Author:      author,
+    Entries:     cfg.feedEntries(chirps, false),
+  }
+  // an account without chirps was last updated when it was created
+  f.Updated = feedUpdated(chirps, user.CreatedAt)
+  serveFeed(w, r, f, format)
+}
+
+// serveHashtagFeed serves the latest chirps mentioning #tag, in any case.
+func (cfg *apiConfig) serveHashtagFeed(w http.ResponseWriter, r *http.Request, format string) {
+  tag := strings.ToLower(r.PathValue("tag"))
+  if !validHashtag(tag) {
+    respondWithError(w, http.StatusBadRequest, "Invalid hashtag", nil)
+    return
+  }
+  ctx := context.Background()
+  chirps, err := cfg.db.GetChirpsByHashtagDesc(ctx, database.GetChirpsByHashtagDescParams{
+    Tag:      tag,
+    RowLimit: feedLength,
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching feed", err)
+    return
+  }
+  chirps, err = cfg.visibleChirps(ctx, chirps, uuid.NullUUID{})
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching feed", err)
+    return
+  }
+
+  f := feed.Feed{
+    ID:          cfg.BaseURL + "/hashtags/" + url.PathEscape(tag),
+    Title:       "#" + tag,
+    Description: "The latest chirps tagged #" + tag + " on Chirpy",
+    Link:        cfg.BaseURL,
+    SelfLink:    cfg.BaseURL + r.URL.Path,
+    Entries:     cfg.feedEntries(chirps, true),
+    // a tag nobody has used has no date of its own, so an empty feed is
+    // dated to the start of its cache lifetime
+    Updated: feedUpdated(chirps, time.Now().Truncate(feedMaxAge)),
+  }
+  serveFeed(w, r, f, format)
+}
+
+// validHashtag reports whether tag can follow a "#": letters, digits and
+// underscores. Nothing else can get into the pattern the chirps are
+// matched with.
+func validHashtag(tag string) bool {
+  if tag == "" || utf8.RuneCountInString(tag) > maxHashtagLength {
+    return false
+  }
+  for _, r := range tag {
+    if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+      return false
+    }
+  }
+  return true
+}
+
+func (cfg *apiConfig) feedAuthor(userID uuid.UUID) *feed.Person {
+  return &feed.Person{
+    Name: userID.String(),
+    URI:  cfg.BaseURL + "/api/users/" + userID.String(),
+  }
+}
+
+// feedEntries turns chirps into feed entries, each with its author when
+// they don't all share the feed's.
+func (cfg *apiConfig) feedEntries(chirps []database.Chirp, withAuthors bool) []feed.Entry {
+  entries := make([]feed.Entry, 0, len(chirps))
+  for _, c := range chirps {
+    entry := feed.Entry{
+      ID:        "urn:uuid:" + c.ID.String(),
+      Title:     feed.Title(c.Body),
+      Link:      cfg.BaseURL + "/api/chirps/" + c.ID.String(),
+      Content:   c.Body,
+      Published: c.CreatedAt,
+      Updated:   c.UpdatedAt,
+    }
+    if withAuthors {
+      entry.Author = cfg.feedAuthor(c.UserID)
+    }
+    entries = append(entries, entry)
+  }
+  return entries
+}
+
+// feedUpdated is when the most recently changed chirp was, or since when a
+// feed without any has been empty.
+func feedUpdated(chirps []database.Chirp, empty time.Time) time.Time {
+  updated := empty
+  for _, c := range chirps {
+    if c.UpdatedAt.After(updated) {
+      updated = c.UpdatedAt
+    }
+  }
+  return updated
+}
+
+// serveFeed writes f in format. The ETag is a hash of the document and
+// Last-Modified is the feed's updated time, left out when there are no
+// entries, and http.ServeContent answers If-None-Match and
+// If-Modified-Since with 304 Not Modified.
+func serveFeed(w http.ResponseWriter, r *http.Request, f feed.Feed, format string) {
+  var body []byte
+  var mediaType string
+  var err error
+  switch format {
+  case feedAtom:
+    body, err = f.Atom()
+    mediaType = feed.AtomMediaType
+  case feedRSS:
+    body, err = f.RSS()
+    mediaType = feed.RSSMediaType
+  }
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error generating feed", err)
+    return
+  }
+
+  sum := sha256.Sum256(body)
+  w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
+  w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+  w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(feedMaxAge.Seconds())))
+  modified := f.Updated
+  if len(f.Entries) == 0 {
+    modified = time.Time{}
+  }
+  http.ServeContent(w, r, "", modified, bytes.NewReader(body))
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+  "net/http"
+  "net/http/httptest"
+  "testing"
+  "time"
+  "chirpy/internal/feed"
+)
+
+func serveTestFeed(f feed.Feed, format string, header http.Header) *httptest.ResponseRecorder {
+  req := httptest.NewRequest(http.MethodGet, "/feed", nil)
+  for name, values := range header {
+    req.Header[name] = values
+  }
+  w := httptest.NewRecorder()
+  serveFeed(w, req, f, format)
+  return w
+}
+
+func TestServeFeedCachingHeaders(t *testing.T) {
+  updated := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+  f := feed.Feed{
+    ID:      "urn:uuid:3311741c-680c-4546-99f3-fc9efac2036c",
+    Title:   "Chirps",
+    Updated: updated,
+    Entries: []feed.Entry{{ID: "urn:chirp:1", Content: "hello", Published: updated, Updated: updated}},
+  }
+  empty := f
+  empty.Entries = nil
+
+  tests := []struct {
+    name         string
+    feed         feed.Feed
+    format       string
+    contentType  string
+    lastModified string
+  }{
+    {"atom", f, feedAtom, feed.AtomMediaType, "Sat, 01 Jun 2024 12:00:00 GMT"},
+    {"rss", f, feedRSS, feed.RSSMediaType, "Sat, 01 Jun 2024 12:00:00 GMT"},
+    {"empty atom", empty, feedAtom, feed.AtomMediaType, ""},
+    {"empty rss", empty, feedRSS, feed.RSSMediaType, ""},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      w := serveTestFeed(tt.feed, tt.format, nil)
+      if w.Code != http.StatusOK {
+        t.Fatalf("status = %d", w.Code)
+      }
+      if got := w.Header().Get("Content-Type"); got != tt.contentType+"; charset=utf-8" {
+        t.Errorf("Content-Type = %q", got)
+      }
+      if got := w.Header().Get("Last-Modified"); got != tt.lastModified {
+        t.Errorf("Last-Modified = %q, want %q", got, tt.lastModified)
+      }
+      if got := w.Header().Get("Cache-Control"); got != "public, max-age=300" {
+        t.Errorf("Cache-Control = %q", got)
+      }
+      etag := w.Header().Get("ETag")
+      if etag == "" {
+        t.Fatal("no ETag")
+      }
+
+      again := serveTestFeed(tt.feed, tt.format, nil)
+      if got := again.Header().Get("ETag"); got != etag {
+        t.Errorf("ETag changed between requests: %s, then %s", etag, got)
+      }
+      notModified := serveTestFeed(tt.feed, tt.format, http.Header{"If-None-Match": {etag}})
+      if notModified.Code != http.StatusNotModified {
+        t.Errorf("If-None-Match: status = %d, want 304", notModified.Code)
+      }
+    })
+  }
+}
+
+func TestServeFeedETagFollowsContent(t *testing.T) {
+  updated := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+  f := feed.Feed{
+    ID:      "urn:uuid:3311741c-680c-4546-99f3-fc9efac2036c",
+    Title:   "Chirps",
+    Updated: updated,
+    Entries: []feed.Entry{{ID: "urn:chirp:1", Content: "hello", Published: updated, Updated: updated}},
+  }
+  etag := serveTestFeed(f, feedAtom, nil).Header().Get("ETag")
+
+  f.Entries[0].Content = "hello, edited"
+  changed := serveTestFeed(f, feedAtom, http.Header{"If-None-Match": {etag}})
+  if changed.Code != http.StatusOK {
+    t.Errorf("status = %d after the content changed, want 200", changed.Code)
+  }
+  if changed.Header().Get("ETag") == etag {
+    t.Error("ETag didn't change with the content")
+  }
+
+  since := serveTestFeed(f, feedAtom, http.Header{"If-Modified-Since": {updated.Format(http.TimeFormat)}})
+  if since.Code != http.StatusNotModified {
+    t.Errorf("If-Modified-Since: status = %d, want 304", since.Code)
+  }
+}
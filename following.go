@@ -0,0 +1,170 @@
+package main
+
+import (
+  "context"
+  "database/sql"
+  "encoding/json"
+  "errors"
+  "net/http"
+  "time"
+  "chirpy/internal/activitypub"
+  "chirpy/internal/database"
+  "github.com/google/uuid"
+)
+
+type followingResponse struct {
+  UserID    uuid.UUID `json:"user_id"`
+  Account   string    `json:"account"`
+  ActorID   string    `json:"actor_id"`
+  CreatedAt time.Time `json:"created_at"`
+  Accepted  bool      `json:"accepted"`
+}
+
+// handleFollowRemote follows an account on another server by its handle,
+// such as "alice@example.social". The follow is pending until their server
+// accepts it; from then on their public posts arrive as chirps.
+func (cfg *apiConfig) handleFollowRemote(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  var params struct {
+    Account string `json:"account"`
+  }
+  err = json.NewDecoder(r.Body).Decode(&params)
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+    return
+  }
+  if _, _, ok := activitypub.SplitAccount(params.Account); !ok {
+    respondWithError(w, http.StatusBadRequest, "Account must look like user@example.social", nil)
+    return
+  }
+
+  ctx := context.Background()
+  actorID, err := cfg.federation.Lookup(ctx, params.Account)
+  if err != nil {
+    respondWithError(w, http.StatusNotFound, "Account not found", err)
+    return
+  }
+  remote, err := cfg.resolveRemoteActor(ctx, actorID)
+  if err != nil {
+    respondWithError(w, http.StatusBadGateway, "Couldn't fetch account", err)
+    return
+  }
+
+  actor := cfg.actorURL(user.ID)
+  follow, err := cfg.db.CreateFollow(ctx, database.CreateFollowParams{
+    FollowerID: user.ID,
+    FolloweeID: remote.UserID,
+    CreatedAt:  time.Now(),
+    ActivityID: actor + "#follows/" + uuid.NewString(),
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error following account", err)
+    return
+  }
+  activity, err := activitypub.NewActivity(follow.ActivityID, activitypub.TypeFollow, actor, remote.ActorID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error following account", err)
+    return
+  }
+  activity.To = activitypub.IRIs{remote.ActorID}
+  err = cfg.queueDelivery(ctx, user.ID, remote.Inbox, activity)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error following account", err)
+    return
+  }
+
+  respondWithJSON(w, http.StatusAccepted, followingResponse{
+    UserID:    remote.UserID,
+    Account:   remote.Username + "@" + remote.Domain,
+    ActorID:   remote.ActorID,
+    CreatedAt: follow.CreatedAt,
+    Accepted:  follow.AcceptedAt.Valid,
+  })
+}
+
+func (cfg *apiConfig) handleListFollowing(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+  rows, err := cfg.db.ListFollowing(context.Background(), user.ID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error listing following", err)
+    return
+  }
+  response := make([]followingResponse, 0, len(rows))
+  for _, row := range rows {
+    response = append(response, followingResponse{
+      UserID:    row.UserID,
+      Account:   row.Username + "@" + row.Domain,
+      ActorID:   row.ActorID,
+      CreatedAt: row.CreatedAt,
+      Accepted:  row.AcceptedAt.Valid,
+    })
+  }
+  respondWithJSON(w, http.StatusOK, response)
+}
+
+// handleUnfollowRemote stops following a remote account, telling its
+// server by undoing the Follow.
+func (cfg *apiConfig) handleUnfollowRemote(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+  followeeID, err := uuid.Parse(r.PathValue("userID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+    return
+  }
+
+  ctx := context.Background()
+  remote, err := cfg.db.GetRemoteActor(ctx, followeeID)
+  if errors.Is(err, sql.ErrNoRows) {
+    respondWithError(w, http.StatusNotFound, "Not following that account", nil)
+    return
+  }
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error unfollowing account", err)
+    return
+  }
+  followID, err := cfg.db.DeleteFollow(ctx, database.DeleteFollowParams{
+    FollowerID: user.ID,
+    FolloweeID: remote.UserID,
+  })
+  if errors.Is(err, sql.ErrNoRows) {
+    respondWithError(w, http.StatusNotFound, "Not following that account", nil)
+    return
+  }
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error unfollowing account", err)
+    return
+  }
+
+  actor := cfg.actorURL(user.ID)
+  follow, err := activitypub.NewActivity(followID, activitypub.TypeFollow, actor, remote.ActorID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error unfollowing account", err)
+    return
+  }
+  follow.Context = nil
+  undo, err := activitypub.NewActivity(followID+"/undo", activitypub.TypeUndo, actor, follow)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error unfollowing account", err)
+    return
+  }
+  undo.To = activitypub.IRIs{remote.ActorID}
+  err = cfg.queueDelivery(ctx, user.ID, remote.Inbox, undo)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error unfollowing account", err)
+    return
+  }
+  w.WriteHeader(http.StatusNoContent)
+}
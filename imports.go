@@ -0,0 +1,548 @@
+package main
+
+import (
+  "archive/zip"
+  "context"
+  "database/sql"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io"
+  "log"
+  "net/http"
+  "os"
+  "path/filepath"
+  "strconv"
+  "time"
+  "unicode/utf8"
+  "chirpy/internal/contentfilter"
+  "chirpy/internal/database"
+  "chirpy/internal/jobs"
+  "chirpy/internal/twitterarchive"
+  "github.com/google/uuid"
+)
+
+const (
+  importSourceTwitter = "twitter"
+
+  importStatusPending   = "pending"
+  importStatusRunning   = "running"
+  importStatusSucceeded = "succeeded"
+  importStatusFailed    = "failed"
+
+  // what to do with posts longer than the user's plan allows
+  longPostsSplit    = "split"
+  longPostsTruncate = "truncate"
+
+  jobImportChirps = "chirps.import"
+
+  // progress is saved, and chirps committed, this many posts at a time
+  importBatchSize = 100
+  // archives with media attached can be large; only tweets.js is read
+  maxImportArchiveSize = 4 << 30
+)
+
+type importChirpsArgs struct {
+  ImportID uuid.UUID `json:"import_id"`
+}
+
+// importStats counts what happened to the posts an import has processed.
+// Imported and Rejected count posts; Chirps counts the chirps they became,
+// which is more than Imported when long posts are split.
+type importStats struct {
+  Imported   int `json:"imported"`
+  Chirps     int `json:"chirps"`
+  Retweets   int `json:"skipped_retweets"`
+  Duplicates int `json:"skipped_duplicates"`
+  Empty      int `json:"skipped_empty"`
+  Rejected   int `json:"rejected"`
+  Held       int `json:"held"`
+  Truncated  int `json:"truncated"`
+  Split      int `json:"split"`
+}
+
+type chirpImportResponse struct {
+  ID          uuid.UUID   `json:"id"`
+  CreatedAt   time.Time   `json:"created_at"`
+  UpdatedAt   time.Time   `json:"updated_at"`
+  Source      string      `json:"source"`
+  Status      string      `json:"status"`
+  DryRun      bool        `json:"dry_run"`
+  LongPosts   string      `json:"long_posts"`
+  Total       int32       `json:"total"`
+  Processed   int32       `json:"processed"`
+  // percent of the archive processed so far
+  Progress    int         `json:"progress"`
+  Stats       importStats `json:"stats"`
+  CompletedAt *time.Time  `json:"completed_at,omitempty"`
+  LastError   string      `json:"last_error,omitempty"`
+}
+
+func newChirpImportResponse(i database.ChirpImport) chirpImportResponse {
+  resp := chirpImportResponse{
+    ID:        i.ID,
+    CreatedAt: i.CreatedAt,
+    UpdatedAt: i.UpdatedAt,
+    Source:    i.Source,
+    Status:    i.Status,
+    DryRun:    i.DryRun,
+    LongPosts: i.LongPosts,
+    Total:     i.Total,
+    Processed: i.Processed,
+    LastError: i.LastError.String,
+  }
+  json.Unmarshal(i.Stats, &resp.Stats)
+  if i.Total > 0 {
+    resp.Progress = int(i.Processed * 100 / i.Total)
+  }
+  if i.Status == importStatusSucceeded {
+    resp.Progress = 100
+  }
+  if i.CompletedAt.Valid {
+    resp.CompletedAt = &i.CompletedAt.Time
+  }
+  return resp
+}
+
+// loadImportDir is where uploaded archives wait for their import job,
+// IMPORT_DIR or a directory under the system's temporary one. Every
+// instance running jobs needs to see the same directory.
+func loadImportDir() string {
+  if dir := os.Getenv("IMPORT_DIR"); dir != "" {
+    return dir
+  }
+  return filepath.Join(os.TempDir(), "chirpy-imports")
+}
+
+func (cfg *apiConfig) importPath(id uuid.UUID) string {
+  return filepath.Join(cfg.importDir, id.String()+".zip")
+}
+
+// saveImportArchive streams the "archive" field of a multipart upload to
+// path.
+func saveImportArchive(r *http.Request, path string) error {
+  mr, err := r.MultipartReader()
+  if err != nil {
+    return err
+  }
+  for {
+    part, err := mr.NextPart()
+    if err == io.EOF {
+      return errors.New("no archive field in upload")
+    }
+    if err != nil {
+      return err
+    }
+    if part.FormName() != "archive" {
+      continue
+    }
+
+    err = os.MkdirAll(filepath.Dir(path), 0o700)
+    if err != nil {
+      return err
+    }
+    f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+    if err != nil {
+      return err
+    }
+    _, err = io.Copy(f, part)
+    if err != nil {
+      f.Close()
+      return err
+    }
+    return f.Close()
+  }
+}
+
+// checkImportArchive makes sure the upload is a ZIP with tweets in it before
+// a job is queued for it.
+func checkImportArchive(path string) error {
+  zr, err := zip.OpenReader(path)
+  if err != nil {
+    return err
+  }
+  defer zr.Close()
+  _, err = twitterarchive.Files(&zr.Reader)
+  return err
+}
+
+// handleCreateChirpImport takes a Twitter archive ZIP, uploaded as the
+// "archive" field of a multipart form, and queues it to be imported as
+// chirps. ?dry_run=true only counts what would be imported, and
+// ?long_posts=split (the default) or truncate says what to do with tweets
+// longer than the user's plan allows.
+func (cfg *apiConfig) handleCreateChirpImport(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  query := r.URL.Query()
+  dryRun := false
+  if s := query.Get("dry_run"); s != "" {
+    dryRun, err = strconv.ParseBool(s)
+    if err != nil {
+      respondWithError(w, http.StatusBadRequest, "Invalid dry_run", err)
+      return
+    }
+  }
+  longPosts := longPostsSplit
+  if s := query.Get("long_posts"); s != "" {
+    if s != longPostsSplit && s != longPostsTruncate {
+      respondWithError(w, http.StatusBadRequest, "long_posts must be split or truncate", nil)
+      return
+    }
+    longPosts = s
+  }
+
+  importID := uuid.New()
+  path := cfg.importPath(importID)
+  r.Body = http.MaxBytesReader(w, r.Body, maxImportArchiveSize)
+  err = saveImportArchive(r, path)
+  if err != nil {
+    os.Remove(path)
+    var tooLarge *http.MaxBytesError
+    if errors.As(err, &tooLarge) {
+      respondWithError(w, http.StatusRequestEntityTooLarge, "Archive is too large", err)
+      return
+    }
+    respondWithError(w, http.StatusBadRequest, "Upload the archive as the archive field of a multipart form", err)
+    return
+  }
+  err = checkImportArchive(path)
+  if err != nil {
+    os.Remove(path)
+    respondWithError(w, http.StatusBadRequest, "Not a Twitter archive", err)
+    return
+  }
+
+  ctx := context.Background()
+  tx, err := cfg.sqlDB.BeginTx(ctx, nil)
+  if err != nil {
+    os.Remove(path)
+    respondWithError(w, http.StatusInternalServerError, "Error starting import", err)
+    return
+  }
+  defer tx.Rollback()
+  qtx := cfg.db.WithTx(tx)
+
+  imp, err := qtx.CreateChirpImport(ctx, database.CreateChirpImportParams{
+    ID:        importID,
+    CreatedAt: time.Now(),
+    UserID:    user.ID,
+    Source:    importSourceTwitter,
+    DryRun:    dryRun,
+    LongPosts: longPosts,
+  })
+  if isUniqueViolation(err) {
+    os.Remove(path)
+    respondWithError(w, http.StatusConflict, "An import is already running", nil)
+    return
+  }
+  if err != nil {
+    os.Remove(path)
+    respondWithError(w, http.StatusInternalServerError, "Error starting import", err)
+    return
+  }
+  _, err = jobs.EnqueueWith(ctx, qtx, jobImportChirps, importChirpsArgs{ImportID: imp.ID}, jobs.MaxAttempts(5))
+  if err != nil {
+    os.Remove(path)
+    respondWithError(w, http.StatusInternalServerError, "Error starting import", err)
+    return
+  }
+  err = tx.Commit()
+  if err != nil {
+    os.Remove(path)
+    respondWithError(w, http.StatusInternalServerError, "Error starting import", err)
+    return
+  }
+
+  respondWithJSON(w, http.StatusAccepted, newChirpImportResponse(imp))
+}
+
+func (cfg *apiConfig) handleListChirpImports(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  imports, err := cfg.db.ListChirpImportsByUser(context.Background(), user.ID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error listing imports", err)
+    return
+  }
+
+  response := make([]chirpImportResponse, 0, len(imports))
+  for _, i := range imports {
+    response = append(response, newChirpImportResponse(i))
+  }
+  respondWithJSON(w, http.StatusOK, response)
+}
+
+// handleGetChirpImport reports an import's progress.
+func (cfg *apiConfig) handleGetChirpImport(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+  importID, err := uuid.Parse(r.PathValue("importID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid import ID", err)
+    return
+  }
+
+  imp, err := cfg.db.GetChirpImport(context.Background(), importID)
+  if errors.Is(err, sql.ErrNoRows) || (err == nil && imp.UserID != user.ID) {
+    respondWithError(w, http.StatusNotFound, "Import not found", nil)
+    return
+  }
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching import", err)
+    return
+  }
+
+  respondWithJSON(w, http.StatusOK, newChirpImportResponse(imp))
+}
+
+// handleImportChirpsJob runs an import. Progress is committed a batch at a
+// time, so a retried job carries on where the last attempt stopped. The
+// archive is deleted once the import succeeds or gives up.
+func (cfg *apiConfig) handleImportChirpsJob(ctx context.Context, job jobs.Job[importChirpsArgs]) error {
+  path := cfg.importPath(job.Args.ImportID)
+  imp, err := cfg.db.GetChirpImport(ctx, job.Args.ImportID)
+  if errors.Is(err, sql.ErrNoRows) {
+    // the account was deleted
+    os.Remove(path)
+    return nil
+  }
+  if err != nil {
+    return err
+  }
+  if imp.Status != importStatusPending && imp.Status != importStatusRunning {
+    return nil
+  }
+
+  tweets, err := twitterarchive.ReadFile(path)
+  if err != nil {
+    cfg.finishChirpImport(ctx, imp.ID, err)
+    return jobs.Permanent(err)
+  }
+  err = cfg.runChirpImport(ctx, imp, tweets)
+  if err != nil {
+    if job.Attempt >= job.MaxAttempts {
+      cfg.finishChirpImport(ctx, imp.ID, err)
+    }
+    return err
+  }
+  cfg.finishChirpImport(ctx, imp.ID, nil)
+  return nil
+}
+
+// finishChirpImport marks an import succeeded, or failed with err, and
+// deletes its archive.
+func (cfg *apiConfig) finishChirpImport(ctx context.Context, id uuid.UUID, err error) {
+  params := database.FinishChirpImportParams{
+    ID:          id,
+    Status:      importStatusSucceeded,
+    CompletedAt: sql.NullTime{Time: time.Now(), Valid: true},
+  }
+  if err != nil {
+    params.Status = importStatusFailed
+    params.LastError = sql.NullString{String: err.Error(), Valid: true}
+  }
+  dbErr := cfg.db.FinishChirpImport(ctx, params)
+  if dbErr != nil {
+    log.Printf("Error finishing import %s: %v", id, dbErr)
+  }
+  os.Remove(cfg.importPath(id))
+}
+
+// runChirpImport works through tweets from where imp got to.
+func (cfg *apiConfig) runChirpImport(ctx context.Context, imp database.ChirpImport, tweets []twitterarchive.Tweet) error {
+  err := cfg.db.StartChirpImport(ctx, database.StartChirpImportParams{
+    ID:    imp.ID,
+    Total: int32(len(tweets)),
+  })
+  if err != nil {
+    return fmt.Errorf("error starting import: %v", err)
+  }
+  ent, err := cfg.entitlementsFor(ctx, imp.UserID)
+  if err != nil {
+    return fmt.Errorf("error fetching entitlements: %v", err)
+  }
+  imported, err := cfg.db.ListImportedPostIDs(ctx, database.ListImportedPostIDsParams{
+    UserID: imp.UserID,
+    Source: imp.Source,
+  })
+  if err != nil {
+    return fmt.Errorf("error listing imported posts: %v", err)
+  }
+  seen := make(map[string]bool, len(imported))
+  for _, id := range imported {
+    seen[id] = true
+  }
+
+  var stats importStats
+  json.Unmarshal(imp.Stats, &stats)
+  for start := int(imp.Processed); start < len(tweets); start += importBatchSize {
+    end := min(start+importBatchSize, len(tweets))
+    err := cfg.importChirpBatch(ctx, imp, tweets[start:end], ent.MaxChirpLength, seen, &stats, int32(end))
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// importChirpBatch imports batch and saves the progress along with it, in
+// one transaction. stats and seen are only updated once it commits.
+func (cfg *apiConfig) importChirpBatch(ctx context.Context, imp database.ChirpImport, batch []twitterarchive.Tweet, maxLength int, seen map[string]bool, stats *importStats, processed int32) error {
+  tx, err := cfg.sqlDB.BeginTx(ctx, nil)
+  if err != nil {
+    return err
+  }
+  defer tx.Rollback()
+  qtx := cfg.db.WithTx(tx)
+
+  next := *stats
+  var added []string
+  for _, tweet := range batch {
+    switch {
+    case tweet.Retweet:
+      next.Retweets++
+      continue
+    case seen[tweet.ID]:
+      next.Duplicates++
+      continue
+    case tweet.Text == "":
+      // tweets that were only media
+      next.Empty++
+      continue
+    }
+
+    pieces := []string{tweet.Text}
+    if utf8.RuneCountInString(tweet.Text) > maxLength {
+      if imp.LongPosts == longPostsTruncate {
+        pieces = []string{twitterarchive.Truncate(tweet.Text, maxLength)}
+        next.Truncated++
+      } else {
+        pieces = twitterarchive.Split(tweet.Text, maxLength)
+        next.Split++
+      }
+    }
+
+    // a tweet is imported whole or not at all
+    results := make([]contentfilter.Result, 0, len(pieces))
+    var rejection chirpRejection
+    for _, piece := range pieces {
+      result, err := cfg.validateChirp(ctx, imp.UserID, piece)
+      if errors.As(err, &rejection) {
+        break
+      }
+      if err != nil {
+        return err
+      }
+      results = append(results, result)
+    }
+    if len(results) < len(pieces) {
+      next.Rejected++
+      continue
+    }
+
+    if !imp.DryRun {
+      created, held, err := importTweet(ctx, qtx, imp, tweet, results)
+      if err != nil {
+        return err
+      }
+      if !created {
+        next.Duplicates++
+        continue
+      }
+      if held {
+        next.Held++
+      }
+      added = append(added, tweet.ID)
+    }
+    next.Imported++
+    next.Chirps += len(pieces)
+  }
+
+  statsJSON, err := json.Marshal(next)
+  if err != nil {
+    return err
+  }
+  err = qtx.UpdateChirpImportProgress(ctx, database.UpdateChirpImportProgressParams{
+    ID:        imp.ID,
+    Processed: processed,
+    Stats:     statsJSON,
+  })
+  if err != nil {
+    return fmt.Errorf("error saving import progress: %v", err)
+  }
+  err = tx.Commit()
+  if err != nil {
+    return err
+  }
+
+  *stats = next
+  for _, id := range added {
+    seen[id] = true
+  }
+  return nil
+}
+
+// importTweet creates the chirps for one tweet, dated when it was tweeted.
+// The pieces of a split tweet are a millisecond apart so they stay in
+// order. Subscribers aren't told about imported chirps, which would flood
+// them with old posts. It reports whether the tweet was imported, rather
+// than found to be imported already, and whether the content filter held
+// any of its chirps.
+func importTweet(ctx context.Context, qtx *database.Queries, imp database.ChirpImport, tweet twitterarchive.Tweet, results []contentfilter.Result) (bool, bool, error) {
+  firstID := uuid.New()
+  recorded, err := qtx.RecordImportedPost(ctx, database.RecordImportedPostParams{
+    UserID:    imp.UserID,
+    Source:    imp.Source,
+    SourceID:  tweet.ID,
+    ChirpID:   firstID,
+    CreatedAt: time.Now(),
+  })
+  if err != nil {
+    return false, false, fmt.Errorf("error recording imported post: %v", err)
+  }
+  if recorded == 0 {
+    // another import got to it first
+    return false, false, nil
+  }
+
+  held := false
+  for i, result := range results {
+    id := firstID
+    if i > 0 {
+      id = uuid.New()
+    }
+    createdAt := tweet.CreatedAt.Add(time.Duration(i) * time.Millisecond)
+    chirp, err := qtx.CreateChirp(ctx, database.CreateChirpParams{
+      ID:        id,
+      CreatedAt: createdAt,
+      UpdatedAt: createdAt,
+      Body:      result.Text,
+      UserID:    imp.UserID,
+    })
+    if err != nil {
+      return false, false, fmt.Errorf("error creating chirp: %v", err)
+    }
+    if result.Action == contentfilter.ActionHold {
+      _, err = holdChirp(ctx, qtx, chirp, chirpHold{
+        ruleID: uuid.NullUUID{UUID: result.RuleID, Valid: true},
+      })
+      if err != nil {
+        return false, false, err
+      }
+      held = true
+    }
+  }
+  return true, held, nil
+}
@@ -0,0 +1,258 @@
+package activitypub
+
+import (
+  "encoding/json"
+  "errors"
+  "strings"
+  "time"
+)
+
+const (
+  // ContentType is what ActivityPub documents are served and delivered as.
+  ContentType = "application/activity+json"
+  // accept asks for an ActivityPub document in either of the media types
+  // servers use for them.
+  accept = `application/activity+json, application/ld+json; profile="https://www.w3.org/ns/activitystreams"`
+
+  // Public addresses an activity to everyone.
+  Public = "https://www.w3.org/ns/activitystreams#Public"
+
+  activityStreamsContext = "https://www.w3.org/ns/activitystreams"
+  securityContext        = "https://w3id.org/security/v1"
+)
+
+// Context is the @context for documents that carry a public key, which is
+// every document Chirpy serves or sends.
+var Context = []string{activityStreamsContext, securityContext}
+
+const (
+  TypeCreate   = "Create"
+  TypeUpdate   = "Update"
+  TypeDelete   = "Delete"
+  TypeFollow   = "Follow"
+  TypeAccept   = "Accept"
+  TypeReject   = "Reject"
+  TypeUndo     = "Undo"
+  TypeLike     = "Like"
+  TypeAnnounce = "Announce"
+
+  TypePerson    = "Person"
+  TypeNote      = "Note"
+  TypeTombstone = "Tombstone"
+)
+
+// IRI is a reference to another object. Servers write them either as the
+// object's id or as the object itself; only the id is kept.
+type IRI string
+
+func (i *IRI) UnmarshalJSON(data []byte) error {
+  id, err := decodeID(data)
+  *i = IRI(id)
+  return err
+}
+
+// IRIs is an audience such as to or cc, which may be a single IRI or a list
+// of them.
+type IRIs []string
+
+func (is *IRIs) UnmarshalJSON(data []byte) error {
+  var list []json.RawMessage
+  if err := json.Unmarshal(data, &list); err != nil {
+    list = []json.RawMessage{data}
+  }
+  *is = nil
+  for _, item := range list {
+    id, err := decodeID(item)
+    if err != nil {
+      return err
+    }
+    *is = append(*is, id)
+  }
+  return nil
+}
+
+func decodeID(data []byte) (string, error) {
+  var id string
+  if err := json.Unmarshal(data, &id); err == nil {
+    return id, nil
+  }
+  var object struct {
+    ID string `json:"id"`
+  }
+  if err := json.Unmarshal(data, &object); err != nil {
+    return "", errors.New("expected an IRI or an object with an id")
+  }
+  return object.ID, nil
+}
+
+// Contains reports whether iri is in the audience.
+func (is IRIs) Contains(iri string) bool {
+  for _, i := range is {
+    if i == iri {
+      return true
+    }
+  }
+  return false
+}
+
+type PublicKey struct {
+  ID           string `json:"id"`
+  Owner        string `json:"owner"`
+  PublicKeyPem string `json:"publicKeyPem"`
+}
+
+type Endpoints struct {
+  SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+type Actor struct {
+  Context                   any        `json:"@context,omitempty"`
+  ID                        string     `json:"id"`
+  Type                      string     `json:"type"`
+  PreferredUsername         string     `json:"preferredUsername"`
+  Name                      string     `json:"name,omitempty"`
+  Summary                   string     `json:"summary,omitempty"`
+  URL                       string     `json:"url,omitempty"`
+  Inbox                     string     `json:"inbox"`
+  Outbox                    string     `json:"outbox,omitempty"`
+  Followers                 string     `json:"followers,omitempty"`
+  Following                 string     `json:"following,omitempty"`
+  ManuallyApprovesFollowers bool       `json:"manuallyApprovesFollowers"`
+  Published                 *time.Time `json:"published,omitempty"`
+  PublicKey                 PublicKey  `json:"publicKey"`
+  Endpoints                 *Endpoints `json:"endpoints,omitempty"`
+}
+
+// SharedInbox is where activities for several of the actor's server's
+// users can be delivered at once, or the actor's own inbox.
+func (a Actor) SharedInbox() string {
+  if a.Endpoints != nil && a.Endpoints.SharedInbox != "" {
+    return a.Endpoints.SharedInbox
+  }
+  return a.Inbox
+}
+
+// Collection is a followers, following or outbox collection. Chirpy only
+// says how many items its followers and following collections hold.
+type Collection struct {
+  Context      any    `json:"@context,omitempty"`
+  ID           string `json:"id,omitempty"`
+  Type         string `json:"type"`
+  TotalItems   int64  `json:"totalItems"`
+  OrderedItems []any  `json:"orderedItems,omitempty"`
+}
+
+// Note is a chirp. Content is HTML.
+type Note struct {
+  Context      any         `json:"@context,omitempty"`
+  ID           string      `json:"id"`
+  Type         string      `json:"type"`
+  AttributedTo IRI         `json:"attributedTo"`
+  Content      string      `json:"content"`
+  InReplyTo    IRI         `json:"inReplyTo,omitempty"`
+  Published    time.Time   `json:"published"`
+  Updated      *time.Time  `json:"updated,omitempty"`
+  URL          string      `json:"url,omitempty"`
+  To           IRIs        `json:"to"`
+  Cc           IRIs        `json:"cc,omitempty"`
+  Likes        *Collection `json:"likes,omitempty"`
+  Shares       *Collection `json:"shares,omitempty"`
+}
+
+// Tombstone stands in for a deleted note.
+type Tombstone struct {
+  ID   string `json:"id"`
+  Type string `json:"type"`
+}
+
+// Activity is an activity with its object left encoded, as the object can
+// be an IRI or any type of object.
+type Activity struct {
+  Context   any             `json:"@context,omitempty"`
+  ID        string          `json:"id"`
+  Type      string          `json:"type"`
+  Actor     IRI             `json:"actor"`
+  Object    json.RawMessage `json:"object"`
+  To        IRIs            `json:"to,omitempty"`
+  Cc        IRIs            `json:"cc,omitempty"`
+  Published *time.Time      `json:"published,omitempty"`
+}
+
+// NewActivity returns an activity of type typ by actor about object, which
+// may be an IRI string or an object to embed.
+func NewActivity(id, typ, actor string, object any) (Activity, error) {
+  data, err := json.Marshal(object)
+  if err != nil {
+    return Activity{}, err
+  }
+  return Activity{
+    Context: Context,
+    ID:      id,
+    Type:    typ,
+    Actor:   IRI(actor),
+    Object:  data,
+  }, nil
+}
+
+// ObjectID is the id of the activity's object, whether it was embedded or
+// referred to.
+func (a Activity) ObjectID() string {
+  id, _ := decodeID(a.Object)
+  return id
+}
+
+// ObjectType is the type of an embedded object, or "" for a reference.
+func (a Activity) ObjectType() string {
+  var object struct {
+    Type string `json:"type"`
+  }
+  if json.Unmarshal(a.Object, &object) != nil {
+    return ""
+  }
+  return object.Type
+}
+
+// DecodeObject decodes an embedded object into v.
+func (a Activity) DecodeObject(v any) error {
+  return json.Unmarshal(a.Object, v)
+}
+
+// Link is a link in a WebFinger document.
+type Link struct {
+  Rel  string `json:"rel"`
+  Type string `json:"type,omitempty"`
+  Href string `json:"href,omitempty"`
+}
+
+// WebFinger is a JSON Resource Descriptor (RFC 7033).
+type WebFinger struct {
+  Subject string   `json:"subject"`
+  Aliases []string `json:"aliases,omitempty"`
+  Links   []Link   `json:"links"`
+}
+
+// ActorLink is the link to the ActivityPub actor for the subject, if any.
+func (wf WebFinger) ActorLink() string {
+  for _, l := range wf.Links {
+    if l.Rel == "self" && isActivityType(l.Type) {
+      return l.Href
+    }
+  }
+  return ""
+}
+
+func isActivityType(mediaType string) bool {
+  return mediaType == ContentType ||
+    strings.HasPrefix(mediaType, "application/ld+json") && strings.Contains(mediaType, activityStreamsContext)
+}
+
+// SplitAccount splits an account handle such as "@alice@example.social" or
+// "acct:alice@example.social" into its username and domain.
+func SplitAccount(account string) (username, domain string, ok bool) {
+  account = strings.TrimPrefix(strings.TrimPrefix(account, "acct:"), "@")
+  username, domain, ok = strings.Cut(account, "@")
+  if !ok || username == "" || domain == "" || strings.ContainsAny(domain, "/?#@") {
+    return "", "", false
+  }
+  return username, strings.ToLower(domain), true
+}
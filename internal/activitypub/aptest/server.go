@@ -0,0 +1,331 @@
+// Package aptest runs a fake remote ActivityPub server in-process, so
+// federation can be exercised end to end without another instance or the
+// network.
+package aptest
+
+import (
+  "context"
+  "encoding/json"
+  "fmt"
+  "io"
+  "net/http"
+  "net/http/httptest"
+  "strconv"
+  "sync"
+  "time"
+  "chirpy/internal/activitypub"
+)
+
+// Server is a remote instance with actors that can follow, post, like and
+// announce, and inboxes that record what is delivered to them. Deliveries
+// are only recorded when their HTTP signature checks out against the
+// sender's key, fetched the way a real server would.
+//
+// Server speaks https with a self-signed certificate: hand Client() to the
+// instance under test as its federation client so it trusts it.
+type Server struct {
+  *httptest.Server
+  // Domain is the host and port accounts on the server are at.
+  Domain string
+
+  client *activitypub.Client
+
+  mu       sync.Mutex
+  actors   map[string]*Actor
+  received []activitypub.Activity
+  nextID   int
+}
+
+// NewServer starts a fake remote instance. client is what it uses to reach
+// the instance under test, both to fetch its actors' keys and to deliver
+// activities to it.
+func NewServer(client *http.Client) *Server {
+  s := &Server{
+    client: activitypub.NewClient(client),
+    actors: map[string]*Actor{},
+  }
+  mux := http.NewServeMux()
+  mux.HandleFunc("GET /.well-known/webfinger", s.handleWebFinger)
+  mux.HandleFunc("GET /users/{username}", s.handleActor)
+  mux.HandleFunc("POST /users/{username}/inbox", s.handleInbox)
+  mux.HandleFunc("POST /inbox", s.handleInbox)
+  s.Server = httptest.NewTLSServer(mux)
+  s.Domain = s.Listener.Addr().String()
+  return s
+}
+
+// Actor is an account on the fake server.
+type Actor struct {
+  Username string
+  ID       string
+  Inbox    string
+
+  server    *Server
+  signer    activitypub.Signer
+  publicPEM string
+}
+
+// AddActor creates an account on the server.
+func (s *Server) AddActor(username string) (*Actor, error) {
+  privatePEM, publicPEM, err := activitypub.GenerateKey()
+  if err != nil {
+    return nil, err
+  }
+  key, err := activitypub.ParsePrivateKey(privatePEM)
+  if err != nil {
+    return nil, err
+  }
+  id := s.URL + "/users/" + username
+  a := &Actor{
+    Username:  username,
+    ID:        id,
+    Inbox:     id + "/inbox",
+    server:    s,
+    signer:    activitypub.Signer{KeyID: id + "#main-key", Key: key},
+    publicPEM: publicPEM,
+  }
+  s.mu.Lock()
+  s.actors[username] = a
+  s.mu.Unlock()
+  return a, nil
+}
+
+// Account is the actor's handle, for looking it up through WebFinger.
+func (a *Actor) Account() string {
+  return a.Username + "@" + a.server.Domain
+}
+
+func (a *Actor) document() activitypub.Actor {
+  return activitypub.Actor{
+    Context:           activitypub.Context,
+    ID:                a.ID,
+    Type:              activitypub.TypePerson,
+    PreferredUsername: a.Username,
+    Inbox:             a.Inbox,
+    Outbox:            a.ID + "/outbox",
+    Followers:         a.ID + "/followers",
+    PublicKey: activitypub.PublicKey{
+      ID:           a.signer.KeyID,
+      Owner:        a.ID,
+      PublicKeyPem: a.publicPEM,
+    },
+    Endpoints: &activitypub.Endpoints{SharedInbox: a.server.URL + "/inbox"},
+  }
+}
+
+func (s *Server) actor(username string) *Actor {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  return s.actors[username]
+}
+
+func (s *Server) newID(kind string) string {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.nextID++
+  return s.URL + "/" + kind + "/" + strconv.Itoa(s.nextID)
+}
+
+func (s *Server) handleWebFinger(w http.ResponseWriter, r *http.Request) {
+  username, domain, ok := activitypub.SplitAccount(r.URL.Query().Get("resource"))
+  a := s.actor(username)
+  if !ok || domain != s.Domain || a == nil {
+    http.NotFound(w, r)
+    return
+  }
+  writeJSON(w, "application/jrd+json", activitypub.WebFinger{
+    Subject: "acct:" + a.Account(),
+    Links: []activitypub.Link{
+      {Rel: "self", Type: activitypub.ContentType, Href: a.ID},
+    },
+  })
+}
+
+func (s *Server) handleActor(w http.ResponseWriter, r *http.Request) {
+  a := s.actor(r.PathValue("username"))
+  if a == nil {
+    http.NotFound(w, r)
+    return
+  }
+  writeJSON(w, activitypub.ContentType, a.document())
+}
+
+func (s *Server) handleInbox(w http.ResponseWriter, r *http.Request) {
+  body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+  if err != nil {
+    http.Error(w, err.Error(), http.StatusBadRequest)
+    return
+  }
+  sig, err := activitypub.ParseSignature(r, body, time.Now())
+  if err != nil {
+    http.Error(w, err.Error(), http.StatusUnauthorized)
+    return
+  }
+  sender, err := s.client.FetchKeyOwner(r.Context(), sig.KeyID)
+  if err != nil {
+    http.Error(w, err.Error(), http.StatusUnauthorized)
+    return
+  }
+  key, err := activitypub.ParsePublicKey(sender.PublicKey.PublicKeyPem)
+  if err != nil {
+    http.Error(w, err.Error(), http.StatusUnauthorized)
+    return
+  }
+  err = sig.Verify(key)
+  if err != nil {
+    http.Error(w, "invalid signature", http.StatusUnauthorized)
+    return
+  }
+
+  var activity activitypub.Activity
+  err = json.Unmarshal(body, &activity)
+  if err != nil {
+    http.Error(w, err.Error(), http.StatusBadRequest)
+    return
+  }
+  if string(activity.Actor) != sender.ID {
+    http.Error(w, "activity isn't by the signer", http.StatusUnauthorized)
+    return
+  }
+  s.mu.Lock()
+  s.received = append(s.received, activity)
+  s.mu.Unlock()
+  w.WriteHeader(http.StatusAccepted)
+}
+
+// Received returns every activity delivered to the server so far.
+func (s *Server) Received() []activitypub.Activity {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  return append([]activitypub.Activity(nil), s.received...)
+}
+
+// WaitFor waits for an activity of type typ to be delivered, for deliveries
+// that go through a job queue.
+func (s *Server) WaitFor(ctx context.Context, typ string) (activitypub.Activity, error) {
+  ticker := time.NewTicker(10 * time.Millisecond)
+  defer ticker.Stop()
+  for {
+    for _, a := range s.Received() {
+      if a.Type == typ {
+        return a, nil
+      }
+    }
+    select {
+    case <-ctx.Done():
+      return activitypub.Activity{}, fmt.Errorf("no %s activity was delivered: %v", typ, ctx.Err())
+    case <-ticker.C:
+    }
+  }
+}
+
+// Send delivers an activity by a to inbox, signed with a's key.
+func (a *Actor) Send(ctx context.Context, inbox string, activity activitypub.Activity) error {
+  body, err := json.Marshal(activity)
+  if err != nil {
+    return err
+  }
+  return a.server.client.Deliver(ctx, inbox, body, a.signer)
+}
+
+// newActivity makes an activity by a, addressed publicly.
+func (a *Actor) newActivity(typ string, object any) (activitypub.Activity, error) {
+  activity, err := activitypub.NewActivity(a.server.newID("activities"), typ, a.ID, object)
+  if err != nil {
+    return activitypub.Activity{}, err
+  }
+  activity.To = activitypub.IRIs{activitypub.Public}
+  activity.Cc = activitypub.IRIs{a.ID + "/followers"}
+  return activity, nil
+}
+
+// Follow sends a Follow for the actor at target to its inbox.
+func (a *Actor) Follow(ctx context.Context, target string) (activitypub.Activity, error) {
+  actor, err := a.server.client.FetchActor(ctx, target)
+  if err != nil {
+    return activitypub.Activity{}, err
+  }
+  follow, err := activitypub.NewActivity(a.server.newID("follows"), activitypub.TypeFollow, a.ID, target)
+  if err != nil {
+    return activitypub.Activity{}, err
+  }
+  return follow, a.Send(ctx, actor.Inbox, follow)
+}
+
+// Accept accepts a follow request that was delivered to a.
+func (a *Actor) Accept(ctx context.Context, follow activitypub.Activity) error {
+  follower, err := a.server.client.FetchActor(ctx, string(follow.Actor))
+  if err != nil {
+    return err
+  }
+  accept, err := activitypub.NewActivity(a.server.newID("accepts"), activitypub.TypeAccept, a.ID, follow)
+  if err != nil {
+    return err
+  }
+  return a.Send(ctx, follower.Inbox, accept)
+}
+
+// Post sends a public note to inbox, in reply to inReplyTo unless that is
+// "". It returns the Create activity, whose object is the note.
+func (a *Actor) Post(ctx context.Context, inbox, text, inReplyTo string) (activitypub.Activity, error) {
+  note := activitypub.Note{
+    ID:           a.server.newID("notes"),
+    Type:         activitypub.TypeNote,
+    AttributedTo: activitypub.IRI(a.ID),
+    Content:      activitypub.ContentHTML(text),
+    InReplyTo:    activitypub.IRI(inReplyTo),
+    Published:    time.Now().UTC(),
+    To:           activitypub.IRIs{activitypub.Public},
+    Cc:           activitypub.IRIs{a.ID + "/followers"},
+  }
+  create, err := a.newActivity(activitypub.TypeCreate, note)
+  if err != nil {
+    return activitypub.Activity{}, err
+  }
+  return create, a.Send(ctx, inbox, create)
+}
+
+// Like likes the object at objectID, telling inbox.
+func (a *Actor) Like(ctx context.Context, inbox, objectID string) (activitypub.Activity, error) {
+  like, err := a.newActivity(activitypub.TypeLike, objectID)
+  if err != nil {
+    return activitypub.Activity{}, err
+  }
+  return like, a.Send(ctx, inbox, like)
+}
+
+// Announce boosts the object at objectID, telling inbox.
+func (a *Actor) Announce(ctx context.Context, inbox, objectID string) (activitypub.Activity, error) {
+  announce, err := a.newActivity(activitypub.TypeAnnounce, objectID)
+  if err != nil {
+    return activitypub.Activity{}, err
+  }
+  return announce, a.Send(ctx, inbox, announce)
+}
+
+// Undo takes back one of a's earlier activities, such as a Follow or Like.
+func (a *Actor) Undo(ctx context.Context, inbox string, activity activitypub.Activity) error {
+  undo, err := activitypub.NewActivity(activity.ID+"/undo", activitypub.TypeUndo, a.ID, activity)
+  if err != nil {
+    return err
+  }
+  return a.Send(ctx, inbox, undo)
+}
+
+// Delete deletes one of a's notes, or a itself when objectID is a.ID.
+func (a *Actor) Delete(ctx context.Context, inbox, objectID string) error {
+  var object any = activitypub.Tombstone{ID: objectID, Type: activitypub.TypeTombstone}
+  if objectID == a.ID {
+    object = a.ID
+  }
+  del, err := a.newActivity(activitypub.TypeDelete, object)
+  if err != nil {
+    return err
+  }
+  return a.Send(ctx, inbox, del)
+}
+
+func writeJSON(w http.ResponseWriter, contentType string, v any) {
+  w.Header().Set("Content-Type", contentType)
+  json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,155 @@
+package aptest_test
+
+import (
+  "context"
+  "crypto/tls"
+  "errors"
+  "net/http"
+  "testing"
+  "time"
+  "chirpy/internal/activitypub"
+  "chirpy/internal/activitypub/aptest"
+)
+
+// newServers starts two fake instances that can reach each other.
+func newServers(t *testing.T) (*aptest.Server, *aptest.Server) {
+  t.Helper()
+  // both servers use httptest's self-signed certificate
+  client := &http.Client{
+    Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+    Timeout:   5 * time.Second,
+  }
+  a := aptest.NewServer(client)
+  t.Cleanup(a.Close)
+  b := aptest.NewServer(client)
+  t.Cleanup(b.Close)
+  return a, b
+}
+
+func newActor(t *testing.T, s *aptest.Server, username string) *aptest.Actor {
+  t.Helper()
+  actor, err := s.AddActor(username)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return actor
+}
+
+func waitFor(t *testing.T, s *aptest.Server, typ string) activitypub.Activity {
+  t.Helper()
+  ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+  defer cancel()
+  activity, err := s.WaitFor(ctx, typ)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return activity
+}
+
+func TestFollowAccept(t *testing.T) {
+  a, b := newServers(t)
+  alice := newActor(t, a, "alice")
+  bob := newActor(t, b, "bob")
+  ctx := context.Background()
+
+  client := activitypub.NewClient(a.Client())
+  id, err := client.Lookup(ctx, bob.Account())
+  if err != nil {
+    t.Fatalf("Lookup: %v", err)
+  }
+  if id != bob.ID {
+    t.Fatalf("Lookup = %q, want %q", id, bob.ID)
+  }
+
+  follow, err := alice.Follow(ctx, bob.ID)
+  if err != nil {
+    t.Fatalf("Follow: %v", err)
+  }
+  received := waitFor(t, b, activitypub.TypeFollow)
+  if received.ID != follow.ID || string(received.Actor) != alice.ID || received.ObjectID() != bob.ID {
+    t.Fatalf("bob received %+v, want alice's follow %s", received, follow.ID)
+  }
+
+  err = bob.Accept(ctx, received)
+  if err != nil {
+    t.Fatalf("Accept: %v", err)
+  }
+  accept := waitFor(t, a, activitypub.TypeAccept)
+  if string(accept.Actor) != bob.ID || accept.ObjectID() != follow.ID {
+    t.Errorf("alice received %+v, want bob accepting %s", accept, follow.ID)
+  }
+}
+
+func TestDelete(t *testing.T) {
+  a, b := newServers(t)
+  alice := newActor(t, a, "alice")
+  bob := newActor(t, b, "bob")
+  ctx := context.Background()
+
+  create, err := alice.Post(ctx, bob.Inbox, "hello", "")
+  if err != nil {
+    t.Fatalf("Post: %v", err)
+  }
+  noteID := create.ObjectID()
+  err = alice.Delete(ctx, bob.Inbox, noteID)
+  if err != nil {
+    t.Fatalf("Delete: %v", err)
+  }
+  del := waitFor(t, b, activitypub.TypeDelete)
+  if string(del.Actor) != alice.ID || del.ObjectID() != noteID || del.ObjectType() != activitypub.TypeTombstone {
+    t.Errorf("bob received %+v, want a tombstone for %s", del, noteID)
+  }
+
+  err = alice.Delete(ctx, bob.Inbox, alice.ID)
+  if err != nil {
+    t.Fatalf("Delete actor: %v", err)
+  }
+  var deletedActor bool
+  for _, activity := range b.Received() {
+    if activity.Type == activitypub.TypeDelete && activity.ObjectID() == alice.ID {
+      deletedActor = true
+    }
+  }
+  if !deletedActor {
+    t.Error("bob didn't receive the Delete for alice's account")
+  }
+}
+
+func TestRejectsActorNotMatchingKey(t *testing.T) {
+  a, b := newServers(t)
+  alice := newActor(t, a, "alice")
+  mallory := newActor(t, a, "mallory")
+  bob := newActor(t, b, "bob")
+  ctx := context.Background()
+
+  // signed by mallory, but claiming to be alice
+  forged, err := activitypub.NewActivity(a.URL+"/follows/forged", activitypub.TypeFollow, alice.ID, bob.ID)
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = mallory.Send(ctx, bob.Inbox, forged)
+  var status *activitypub.StatusError
+  if !errors.As(err, &status) || status.StatusCode != http.StatusUnauthorized {
+    t.Fatalf("Send = %v, want a 401", err)
+  }
+  if len(b.Received()) != 0 {
+    t.Errorf("bob received %+v, want nothing", b.Received())
+  }
+}
+
+func TestRejectsUnsignedDelivery(t *testing.T) {
+  _, b := newServers(t)
+  bob := newActor(t, b, "bob")
+
+  resp, err := b.Client().Post(bob.Inbox, activitypub.ContentType, nil)
+  if err != nil {
+    t.Fatal(err)
+  }
+  resp.Body.Close()
+  if resp.StatusCode != http.StatusUnauthorized {
+    t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+  }
+  if len(b.Received()) != 0 {
+    t.Errorf("bob received %+v, want nothing", b.Received())
+  }
+}
@@ -0,0 +1,172 @@
+package activitypub
+
+import (
+  "bytes"
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io"
+  "net/http"
+  "net/url"
+  "strings"
+  "time"
+)
+
+// maxDocumentSize caps the documents read from other servers.
+const maxDocumentSize = 1 << 20
+
+// StatusError is a response from another server that wasn't a success.
+type StatusError struct {
+  URL        string
+  StatusCode int
+}
+
+func (e *StatusError) Error() string {
+  return fmt.Sprintf("%s responded with %d", e.URL, e.StatusCode)
+}
+
+// Permanent reports whether the server turned the request away for good,
+// so trying again won't help.
+func (e *StatusError) Permanent() bool {
+  return e.StatusCode >= 400 && e.StatusCode < 500 &&
+    e.StatusCode != http.StatusRequestTimeout && e.StatusCode != http.StatusTooManyRequests
+}
+
+// Client fetches documents from other servers and delivers activities to
+// them. It only talks https.
+type Client struct {
+  http *http.Client
+}
+
+func NewClient(c *http.Client) *Client {
+  return &Client{http: c}
+}
+
+// Lookup finds the actor behind an account handle such as
+// "alice@example.social" through WebFinger and returns its id.
+func (c *Client) Lookup(ctx context.Context, account string) (string, error) {
+  username, domain, ok := SplitAccount(account)
+  if !ok {
+    return "", fmt.Errorf("invalid account %q", account)
+  }
+  resource := "acct:" + username + "@" + domain
+  var wf WebFinger
+  err := c.get(ctx, "https://"+domain+"/.well-known/webfinger?resource="+url.QueryEscape(resource), "application/jrd+json, application/json", &wf)
+  if err != nil {
+    return "", err
+  }
+  id := wf.ActorLink()
+  if id == "" {
+    return "", fmt.Errorf("%s has no ActivityPub actor", resource)
+  }
+  return id, nil
+}
+
+// FetchActor fetches the actor at id, making sure the document is the one
+// that was asked for and that its key is its own.
+func (c *Client) FetchActor(ctx context.Context, id string) (Actor, error) {
+  var actor Actor
+  err := c.get(ctx, id, accept, &actor)
+  if err != nil {
+    return Actor{}, err
+  }
+  if actor.ID != id {
+    return Actor{}, fmt.Errorf("%s returned actor %s", id, actor.ID)
+  }
+  if actor.Inbox == "" || actor.PublicKey.PublicKeyPem == "" || actor.PublicKey.Owner != actor.ID {
+    return Actor{}, fmt.Errorf("%s is not a usable actor", id)
+  }
+  return actor, nil
+}
+
+// FetchKeyOwner fetches the actor that a signature's keyId belongs to.
+// Most servers make the key a fragment of the actor's document; others
+// serve the key on its own, pointing at its owner.
+func (c *Client) FetchKeyOwner(ctx context.Context, keyID string) (Actor, error) {
+  docID, _, _ := strings.Cut(keyID, "#")
+  var doc struct {
+    Actor
+    Owner string `json:"owner"`
+  }
+  err := c.get(ctx, docID, accept, &doc)
+  if err != nil {
+    return Actor{}, err
+  }
+  ownerID := doc.ID
+  if doc.Owner != "" {
+    ownerID = doc.Owner
+  }
+  actor, err := c.FetchActor(ctx, ownerID)
+  if err != nil {
+    return Actor{}, err
+  }
+  if actor.PublicKey.ID != keyID {
+    return Actor{}, fmt.Errorf("key %s doesn't belong to %s", keyID, actor.ID)
+  }
+  return actor, nil
+}
+
+// Deliver posts an activity to an inbox, signed by signer.
+func (c *Client) Deliver(ctx context.Context, inbox string, activity []byte, signer Signer) error {
+  if err := checkURL(inbox); err != nil {
+    return err
+  }
+  req, err := http.NewRequestWithContext(ctx, http.MethodPost, inbox, bytes.NewReader(activity))
+  if err != nil {
+    return err
+  }
+  req.Header.Set("Content-Type", ContentType)
+  req.Header.Set("Accept", accept)
+  err = signer.Sign(req, activity, time.Now())
+  if err != nil {
+    return err
+  }
+
+  resp, err := c.http.Do(req)
+  if err != nil {
+    return err
+  }
+  defer resp.Body.Close()
+  io.Copy(io.Discard, io.LimitReader(resp.Body, maxDocumentSize))
+  if resp.StatusCode/100 != 2 {
+    return &StatusError{URL: inbox, StatusCode: resp.StatusCode}
+  }
+  return nil
+}
+
+func (c *Client) get(ctx context.Context, rawURL, accept string, v any) error {
+  if err := checkURL(rawURL); err != nil {
+    return err
+  }
+  req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+  if err != nil {
+    return err
+  }
+  req.Header.Set("Accept", accept)
+
+  resp, err := c.http.Do(req)
+  if err != nil {
+    return err
+  }
+  defer resp.Body.Close()
+  if resp.StatusCode/100 != 2 {
+    return &StatusError{URL: rawURL, StatusCode: resp.StatusCode}
+  }
+  err = json.NewDecoder(io.LimitReader(resp.Body, maxDocumentSize)).Decode(v)
+  if err != nil {
+    return fmt.Errorf("error decoding %s: %v", rawURL, err)
+  }
+  return nil
+}
+
+func checkURL(rawURL string) error {
+  u, err := url.Parse(rawURL)
+  if err != nil {
+    return err
+  }
+  if u.Scheme != "https" || u.Host == "" {
+    return errors.New("not an https URL: " + rawURL)
+  }
+  return nil
+}
@@ -0,0 +1,28 @@
+package activitypub
+
+import (
+  "html"
+  "regexp"
+  "strings"
+)
+
+// ContentHTML turns plain text into the HTML a note's content is expected
+// in: one paragraph, with line breaks kept.
+func ContentHTML(text string) string {
+  escaped := html.EscapeString(text)
+  return "<p>" + strings.ReplaceAll(escaped, "\n", "<br>") + "</p>"
+}
+
+var (
+  lineBreaks = regexp.MustCompile(`(?i)<br\s*/?>|</p>\s*<p[^>]*>`)
+  tags       = regexp.MustCompile(`<[^>]*>`)
+)
+
+// ContentText turns a note's HTML content back into plain text: breaks and
+// paragraphs become new lines, other markup is dropped and entities are
+// decoded. Links keep their text, which Mastodon writes as the URL.
+func ContentText(content string) string {
+  text := lineBreaks.ReplaceAllString(content, "\n")
+  text = tags.ReplaceAllString(text, "")
+  return strings.TrimSpace(html.UnescapeString(text))
+}
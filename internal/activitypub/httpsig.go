@@ -0,0 +1,179 @@
+package activitypub
+
+import (
+  "crypto"
+  "crypto/rand"
+  "crypto/rsa"
+  "crypto/sha256"
+  "encoding/base64"
+  "errors"
+  "fmt"
+  "net/http"
+  "strings"
+  "time"
+)
+
+// Requests are signed the way Mastodon signs them: draft-cavage-http-
+// signatures-12 with rsa-sha256 over the request target, host and date, and
+// a SHA-256 Digest header for the body.
+
+const (
+  // signatures older than maxSignatureAge, or dated more than
+  // maxClockSkew in the future, are turned away; these are Mastodon's limits
+  maxSignatureAge = 12 * time.Hour
+  maxClockSkew    = time.Hour
+)
+
+// Signer signs requests as one actor, with the key their KeyID refers to.
+type Signer struct {
+  KeyID string
+  Key   *rsa.PrivateKey
+}
+
+// Sign sets req's Date header, its Digest header when there is a body, and
+// the Signature header covering them.
+func (s Signer) Sign(req *http.Request, body []byte, now time.Time) error {
+  req.Header.Set("Date", now.UTC().Format(http.TimeFormat))
+  headers := []string{"(request-target)", "host", "date"}
+  if body != nil {
+    req.Header.Set("Digest", digest(body))
+    headers = append(headers, "digest")
+  }
+  signed, err := signingString(req, headers)
+  if err != nil {
+    return err
+  }
+  hashed := sha256.Sum256([]byte(signed))
+  sig, err := rsa.SignPKCS1v15(rand.Reader, s.Key, crypto.SHA256, hashed[:])
+  if err != nil {
+    return err
+  }
+  req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+    s.KeyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(sig)))
+  return nil
+}
+
+func digest(body []byte) string {
+  sum := sha256.Sum256(body)
+  return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func signingString(req *http.Request, headers []string) (string, error) {
+  lines := make([]string, 0, len(headers))
+  for _, h := range headers {
+    var value string
+    switch h {
+    case "(request-target)":
+      value = strings.ToLower(req.Method) + " " + req.URL.RequestURI()
+    case "host":
+      value = req.Host
+      if value == "" {
+        value = req.URL.Host
+      }
+    default:
+      values := req.Header.Values(h)
+      if len(values) == 0 {
+        return "", fmt.Errorf("signed header %s is missing", h)
+      }
+      value = strings.Join(values, ", ")
+    }
+    lines = append(lines, h+": "+value)
+  }
+  return strings.Join(lines, "\n"), nil
+}
+
+// Signature is the parsed Signature header of an incoming request.
+type Signature struct {
+  KeyID     string
+  signed    string
+  signature []byte
+}
+
+// ParseSignature reads the Signature header of an incoming request and
+// checks everything that doesn't need the signer's key: that the signature
+// covers the request target, host and date, and the digest when there is a
+// body; that the date is recent; and that the digest matches body.
+func ParseSignature(req *http.Request, body []byte, now time.Time) (*Signature, error) {
+  header := req.Header.Get("Signature")
+  if header == "" {
+    return nil, errors.New("request is not signed")
+  }
+  params := map[string]string{}
+  for _, part := range strings.Split(header, ",") {
+    key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+    if !ok {
+      return nil, errors.New("malformed Signature header")
+    }
+    params[key] = strings.Trim(value, `"`)
+  }
+
+  switch params["algorithm"] {
+  case "", "rsa-sha256", "hs2019":
+  default:
+    return nil, fmt.Errorf("unsupported signature algorithm %q", params["algorithm"])
+  }
+  if params["keyId"] == "" || params["signature"] == "" {
+    return nil, errors.New("malformed Signature header")
+  }
+  sig, err := base64.StdEncoding.DecodeString(params["signature"])
+  if err != nil {
+    return nil, fmt.Errorf("malformed signature: %v", err)
+  }
+
+  headers := strings.Fields(strings.ToLower(params["headers"]))
+  if len(headers) == 0 {
+    headers = []string{"date"}
+  }
+  required := []string{"(request-target)", "host", "date"}
+  if len(body) > 0 {
+    required = append(required, "digest")
+  }
+  for _, h := range required {
+    if !contains(headers, h) {
+      return nil, fmt.Errorf("signature doesn't cover %s", h)
+    }
+  }
+
+  date, err := http.ParseTime(req.Header.Get("Date"))
+  if err != nil {
+    return nil, fmt.Errorf("invalid Date header: %v", err)
+  }
+  if now.Sub(date) > maxSignatureAge || date.Sub(now) > maxClockSkew {
+    return nil, errors.New("signature has expired")
+  }
+  if len(body) > 0 && !digestMatches(req.Header.Get("Digest"), body) {
+    return nil, errors.New("digest doesn't match body")
+  }
+
+  signed, err := signingString(req, headers)
+  if err != nil {
+    return nil, err
+  }
+  return &Signature{KeyID: params["keyId"], signed: signed, signature: sig}, nil
+}
+
+// Verify checks the signature against the signer's public key.
+func (s *Signature) Verify(key *rsa.PublicKey) error {
+  hashed := sha256.Sum256([]byte(s.signed))
+  return rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], s.signature)
+}
+
+func digestMatches(header string, body []byte) bool {
+  want := strings.TrimPrefix(digest(body), "SHA-256=")
+  for _, part := range strings.Split(header, ",") {
+    alg, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+    if ok && strings.EqualFold(alg, "SHA-256") && value == want {
+      return true
+    }
+  }
+  return false
+}
+
+func contains(list []string, s string) bool {
+  for _, item := range list {
+    if item == s {
+      return true
+    }
+  }
+  return false
+}
@@ -0,0 +1,140 @@
+package activitypub
+
+import (
+  "net/http"
+  "strings"
+  "testing"
+  "time"
+)
+
+func newTestSigner(t *testing.T, keyID string) (Signer, string) {
+  t.Helper()
+  privatePEM, publicPEM, err := GenerateKey()
+  if err != nil {
+    t.Fatal(err)
+  }
+  key, err := ParsePrivateKey(privatePEM)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return Signer{KeyID: keyID, Key: key}, publicPEM
+}
+
+func newSignedRequest(t *testing.T, signer Signer, body string, now time.Time) *http.Request {
+  t.Helper()
+  req, err := http.NewRequest(http.MethodPost, "https://example.social/users/bob/inbox", strings.NewReader(body))
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = signer.Sign(req, []byte(body), now)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return req
+}
+
+func TestSignatureVerifies(t *testing.T) {
+  signer, publicPEM := newTestSigner(t, "https://remote.example/users/alice#main-key")
+  key, err := ParsePublicKey(publicPEM)
+  if err != nil {
+    t.Fatal(err)
+  }
+  now := time.Now()
+  body := `{"type":"Follow"}`
+  req := newSignedRequest(t, signer, body, now)
+
+  sig, err := ParseSignature(req, []byte(body), now)
+  if err != nil {
+    t.Fatalf("ParseSignature: %v", err)
+  }
+  if sig.KeyID != signer.KeyID {
+    t.Errorf("KeyID = %q, want %q", sig.KeyID, signer.KeyID)
+  }
+  if err := sig.Verify(key); err != nil {
+    t.Errorf("Verify: %v", err)
+  }
+}
+
+func TestSignatureRejectsOtherKey(t *testing.T) {
+  signer, _ := newTestSigner(t, "https://remote.example/users/alice#main-key")
+  _, otherPEM := newTestSigner(t, "https://remote.example/users/mallory#main-key")
+  other, err := ParsePublicKey(otherPEM)
+  if err != nil {
+    t.Fatal(err)
+  }
+  now := time.Now()
+  body := `{"type":"Follow"}`
+  req := newSignedRequest(t, signer, body, now)
+
+  sig, err := ParseSignature(req, []byte(body), now)
+  if err != nil {
+    t.Fatalf("ParseSignature: %v", err)
+  }
+  if err := sig.Verify(other); err == nil {
+    t.Error("Verify accepted a signature checked against someone else's key")
+  }
+}
+
+func TestParseSignatureRejects(t *testing.T) {
+  signer, _ := newTestSigner(t, "https://remote.example/users/alice#main-key")
+  now := time.Now()
+  body := `{"type":"Follow"}`
+
+  tests := []struct {
+    name   string
+    modify func(req *http.Request) (*http.Request, string, time.Time)
+  }{
+    {"unsigned", func(req *http.Request) (*http.Request, string, time.Time) {
+      req.Header.Del("Signature")
+      return req, body, now
+    }},
+    {"tampered body", func(req *http.Request) (*http.Request, string, time.Time) {
+      return req, `{"type":"Delete"}`, now
+    }},
+    {"expired", func(req *http.Request) (*http.Request, string, time.Time) {
+      return req, body, now.Add(maxSignatureAge + time.Minute)
+    }},
+    {"from the future", func(req *http.Request) (*http.Request, string, time.Time) {
+      return req, body, now.Add(-maxClockSkew - time.Minute)
+    }},
+    {"digest not signed", func(req *http.Request) (*http.Request, string, time.Time) {
+      sig := req.Header.Get("Signature")
+      req.Header.Set("Signature", strings.Replace(sig, ` digest"`, `"`, 1))
+      return req, body, now
+    }},
+    {"unsupported algorithm", func(req *http.Request) (*http.Request, string, time.Time) {
+      sig := req.Header.Get("Signature")
+      req.Header.Set("Signature", strings.Replace(sig, "rsa-sha256", "hmac-sha256", 1))
+      return req, body, now
+    }},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      req, got, at := tt.modify(newSignedRequest(t, signer, body, now))
+      _, err := ParseSignature(req, []byte(got), at)
+      if err == nil {
+        t.Error("ParseSignature accepted the request")
+      }
+    })
+  }
+}
+
+func TestSignatureRejectsChangedTarget(t *testing.T) {
+  signer, publicPEM := newTestSigner(t, "https://remote.example/users/alice#main-key")
+  key, err := ParsePublicKey(publicPEM)
+  if err != nil {
+    t.Fatal(err)
+  }
+  now := time.Now()
+  body := `{"type":"Follow"}`
+  req := newSignedRequest(t, signer, body, now)
+  req.URL.Path = "/users/carol/inbox"
+
+  sig, err := ParseSignature(req, []byte(body), now)
+  if err != nil {
+    t.Fatalf("ParseSignature: %v", err)
+  }
+  if err := sig.Verify(key); err == nil {
+    t.Error("Verify accepted a signature made for another inbox")
+  }
+}
@@ -0,0 +1,73 @@
+package activitypub
+
+import (
+  "crypto/rand"
+  "crypto/rsa"
+  "crypto/x509"
+  "encoding/pem"
+  "errors"
+)
+
+// keyBits is the size of the RSA keys actors sign with, which is what
+// Mastodon uses.
+const keyBits = 2048
+
+// GenerateKey makes a key pair for an actor, PEM-encoded: the private key as
+// PKCS #8 and the public key as PKIX, the form publicKeyPem is expected in.
+func GenerateKey() (privatePEM, publicPEM string, err error) {
+  key, err := rsa.GenerateKey(rand.Reader, keyBits)
+  if err != nil {
+    return "", "", err
+  }
+  private, err := x509.MarshalPKCS8PrivateKey(key)
+  if err != nil {
+    return "", "", err
+  }
+  public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+  if err != nil {
+    return "", "", err
+  }
+  privatePEM = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: private}))
+  publicPEM = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}))
+  return privatePEM, publicPEM, nil
+}
+
+func ParsePrivateKey(data string) (*rsa.PrivateKey, error) {
+  block, _ := pem.Decode([]byte(data))
+  if block == nil {
+    return nil, errors.New("no PEM block in private key")
+  }
+  if block.Type == "RSA PRIVATE KEY" {
+    return x509.ParsePKCS1PrivateKey(block.Bytes)
+  }
+  key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+  if err != nil {
+    return nil, err
+  }
+  rsaKey, ok := key.(*rsa.PrivateKey)
+  if !ok {
+    return nil, errors.New("private key is not an RSA key")
+  }
+  return rsaKey, nil
+}
+
+// ParsePublicKey reads a publicKeyPem, which servers write as either PKIX
+// or PKCS #1.
+func ParsePublicKey(data string) (*rsa.PublicKey, error) {
+  block, _ := pem.Decode([]byte(data))
+  if block == nil {
+    return nil, errors.New("no PEM block in public key")
+  }
+  if block.Type == "RSA PUBLIC KEY" {
+    return x509.ParsePKCS1PublicKey(block.Bytes)
+  }
+  key, err := x509.ParsePKIXPublicKey(block.Bytes)
+  if err != nil {
+    return nil, err
+  }
+  rsaKey, ok := key.(*rsa.PublicKey)
+  if !ok {
+    return nil, errors.New("public key is not an RSA key")
+  }
+  return rsaKey, nil
+}
@@ -1,7 +1,6 @@
 package auth
 
 import (
-  "golang.org/x/crypto/bcrypt"
   "fmt"
   "time"
   "errors"
@@ -13,22 +12,6 @@ import (
   "github.com/golang-jwt/jwt/v5"
 )
 
-func HashPassword(password string) (string, error) {
-  hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-  if err != nil {
-    return "", fmt.Errorf("Error encrypting the password: %v", err)
-  }
-  hashedPass := string(hash)
-  return hashedPass, nil 
-}
-
-func CheckPasswordHash(password, hash string) error {
-  err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-  if err != nil {
-    return fmt.Errorf("Passwords don't match")
-  }
-  return nil
-}
 // MakeJWT generates a signed JWT for the given userID with a specified expiration time.
 func MakeJWT(userID uuid.UUID, tokenSecret string) (string, error) {
 	// Validate userID
@@ -0,0 +1,221 @@
+package auth
+
+import (
+  "crypto/rand"
+  "crypto/subtle"
+  "encoding/base64"
+  "errors"
+  "fmt"
+  "strings"
+  "golang.org/x/crypto/argon2"
+  "golang.org/x/crypto/bcrypt"
+)
+
+var errPasswordMismatch = errors.New("Passwords don't match")
+
+// Hasher produces and verifies password hashes in PHC string format
+// ($<id>$<params>$<salt>$<hash>).
+type Hasher interface {
+  // Matches reports whether encoded was produced by this algorithm.
+  Matches(encoded string) bool
+  Hash(password string) (string, error)
+  Verify(password, encoded string) error
+  // NeedsRehash reports whether encoded was produced with parameters other
+  // than the hasher's current ones.
+  NeedsRehash(encoded string) bool
+}
+
+// Argon2idParams are the Argon2id cost parameters. Memory is in KiB.
+type Argon2idParams struct {
+  Memory      uint32
+  Iterations  uint32
+  Parallelism uint8
+  SaltLength  uint32
+  KeyLength   uint32
+}
+
+// DefaultArgon2idParams follows the OWASP recommendation of 19 MiB of memory
+// and two passes.
+var DefaultArgon2idParams = Argon2idParams{
+  Memory:      19 * 1024,
+  Iterations:  2,
+  Parallelism: 1,
+  SaltLength:  16,
+  KeyLength:   32,
+}
+
+type Argon2idHasher struct {
+  Params Argon2idParams
+}
+
+func (h Argon2idHasher) Matches(encoded string) bool {
+  return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+  p := h.Params
+  salt := make([]byte, p.SaltLength)
+  _, err := rand.Read(salt)
+  if err != nil {
+    return "", fmt.Errorf("Error generating salt: %v", err)
+  }
+
+  key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+  return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+    argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+    base64.RawStdEncoding.EncodeToString(salt),
+    base64.RawStdEncoding.EncodeToString(key),
+  ), nil
+}
+
+func (h Argon2idHasher) Verify(password, encoded string) error {
+  p, salt, key, err := decodeArgon2id(encoded)
+  if err != nil {
+    return err
+  }
+
+  other := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(key)))
+  if subtle.ConstantTimeCompare(key, other) != 1 {
+    return errPasswordMismatch
+  }
+  return nil
+}
+
+func (h Argon2idHasher) NeedsRehash(encoded string) bool {
+  p, salt, key, err := decodeArgon2id(encoded)
+  if err != nil {
+    return true
+  }
+  return p.Memory != h.Params.Memory ||
+    p.Iterations != h.Params.Iterations ||
+    p.Parallelism != h.Params.Parallelism ||
+    uint32(len(salt)) != h.Params.SaltLength ||
+    uint32(len(key)) != h.Params.KeyLength
+}
+
+func decodeArgon2id(encoded string) (Argon2idParams, []byte, []byte, error) {
+  var p Argon2idParams
+
+  // "", "argon2id", "v=19", "m=...,t=...,p=...", salt, hash
+  parts := strings.Split(encoded, "$")
+  if len(parts) != 6 || parts[1] != "argon2id" {
+    return p, nil, nil, errors.New("invalid argon2id hash")
+  }
+
+  var version int
+  _, err := fmt.Sscanf(parts[2], "v=%d", &version)
+  if err != nil || version != argon2.Version {
+    return p, nil, nil, errors.New("unsupported argon2 version")
+  }
+
+  _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism)
+  if err != nil {
+    return p, nil, nil, fmt.Errorf("invalid argon2id parameters: %v", err)
+  }
+  // argon2.IDKey panics on zero parallelism, and a hash with no memory or
+  // iterations was never made by Hash
+  if p.Memory == 0 || p.Iterations == 0 || p.Parallelism == 0 {
+    return p, nil, nil, errors.New("invalid argon2id parameters: m, t and p must be positive")
+  }
+
+  salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+  if err != nil {
+    return p, nil, nil, fmt.Errorf("invalid argon2id salt: %v", err)
+  }
+  key, err := base64.RawStdEncoding.DecodeString(parts[5])
+  if err != nil {
+    return p, nil, nil, fmt.Errorf("invalid argon2id hash: %v", err)
+  }
+  if len(salt) == 0 || len(key) == 0 {
+    return p, nil, nil, errors.New("invalid argon2id hash: empty salt or key")
+  }
+
+  p.SaltLength = uint32(len(salt))
+  p.KeyLength = uint32(len(key))
+  return p, salt, key, nil
+}
+
+// BcryptHasher handles the $2a$/$2b$/$2y$ hashes created before Argon2id
+// became the default. bcrypt only looks at the first 72 bytes of a password.
+type BcryptHasher struct {
+  Cost int
+}
+
+func (h BcryptHasher) Matches(encoded string) bool {
+  return strings.HasPrefix(encoded, "$2a$") ||
+    strings.HasPrefix(encoded, "$2b$") ||
+    strings.HasPrefix(encoded, "$2y$")
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+  hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+  if err != nil {
+    return "", fmt.Errorf("Error encrypting the password: %v", err)
+  }
+  return string(hash), nil
+}
+
+func (h BcryptHasher) Verify(password, encoded string) error {
+  err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+  if err != nil {
+    return errPasswordMismatch
+  }
+  return nil
+}
+
+func (h BcryptHasher) NeedsRehash(encoded string) bool {
+  cost, err := bcrypt.Cost([]byte(encoded))
+  return err != nil || cost != h.Cost
+}
+
+// PasswordHashing hashes new passwords with Current and verifies hashes made
+// by Current or any of the Legacy hashers.
+type PasswordHashing struct {
+  Current Hasher
+  Legacy  []Hasher
+}
+
+var passwords = PasswordHashing{
+  Current: Argon2idHasher{Params: DefaultArgon2idParams},
+  Legacy:  []Hasher{BcryptHasher{Cost: bcrypt.DefaultCost}},
+}
+
+// SetPasswordHashing replaces the hashers used by HashPassword,
+// CheckPasswordHash and NeedsRehash.
+func SetPasswordHashing(p PasswordHashing) {
+  passwords = p
+}
+
+func (p PasswordHashing) hasherFor(encoded string) (Hasher, bool) {
+  if p.Current.Matches(encoded) {
+    return p.Current, true
+  }
+  for _, h := range p.Legacy {
+    if h.Matches(encoded) {
+      return h, true
+    }
+  }
+  return nil, false
+}
+
+func HashPassword(password string) (string, error) {
+  return passwords.Current.Hash(password)
+}
+
+func CheckPasswordHash(password, hash string) error {
+  h, ok := passwords.hasherFor(hash)
+  if !ok {
+    return errors.New("unrecognized password hash format")
+  }
+  return h.Verify(password, hash)
+}
+
+// NeedsRehash reports whether hash should be replaced with a fresh one from
+// the current hasher, either because it uses another algorithm or because the
+// cost parameters have changed.
+func NeedsRehash(hash string) bool {
+  if !passwords.Current.Matches(hash) {
+    return true
+  }
+  return passwords.Current.NeedsRehash(hash)
+}
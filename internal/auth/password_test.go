@@ -0,0 +1,82 @@
+package auth
+
+import (
+  "strings"
+  "testing"
+)
+
+// testArgon2idParams keep the tests fast; the format is what's under test.
+var testArgon2idParams = Argon2idParams{
+  Memory:      64,
+  Iterations:  1,
+  Parallelism: 1,
+  SaltLength:  16,
+  KeyLength:   32,
+}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+  h := Argon2idHasher{Params: testArgon2idParams}
+  encoded, err := h.Hash("correct horse")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if !h.Matches(encoded) {
+    t.Errorf("Matches(%q) = false", encoded)
+  }
+  if err := h.Verify("correct horse", encoded); err != nil {
+    t.Errorf("Verify with the right password: %v", err)
+  }
+  if err := h.Verify("wrong horse", encoded); err == nil {
+    t.Error("Verify accepted the wrong password")
+  }
+  if h.NeedsRehash(encoded) {
+    t.Error("NeedsRehash is true for a hash made with the current parameters")
+  }
+  stronger := Argon2idHasher{Params: testArgon2idParams}
+  stronger.Params.Iterations++
+  if !stronger.NeedsRehash(encoded) {
+    t.Error("NeedsRehash is false after the parameters changed")
+  }
+}
+
+// TestArgon2idRejectsBadHashes feeds Verify hashes that were corrupted or
+// edited by hand. None of them may panic.
+func TestArgon2idRejectsBadHashes(t *testing.T) {
+  h := Argon2idHasher{Params: testArgon2idParams}
+  valid, err := h.Hash("correct horse")
+  if err != nil {
+    t.Fatal(err)
+  }
+  parts := strings.Split(valid, "$")
+  with := func(i int, value string) string {
+    edited := append([]string(nil), parts...)
+    edited[i] = value
+    return strings.Join(edited, "$")
+  }
+
+  tests := []struct {
+    name    string
+    encoded string
+  }{
+    {"empty", ""},
+    {"too few parts", "$argon2id$v=19$m=64,t=1,p=1$c2FsdA"},
+    {"other version", with(2, "v=16")},
+    {"garbled parameters", with(3, "m=64,t=one,p=1")},
+    {"zero memory", with(3, "m=0,t=1,p=1")},
+    {"zero iterations", with(3, "m=64,t=0,p=1")},
+    {"zero parallelism", with(3, "m=64,t=1,p=0")},
+    {"empty salt", with(4, "")},
+    {"empty key", with(5, "")},
+    {"salt not base64", with(4, "!!!")},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      if err := h.Verify("correct horse", tt.encoded); err == nil {
+        t.Errorf("Verify(%q) succeeded", tt.encoded)
+      }
+      if !h.NeedsRehash(tt.encoded) {
+        t.Errorf("NeedsRehash(%q) = false", tt.encoded)
+      }
+    })
+  }
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+  "crypto/hmac"
+  "crypto/sha256"
+  "encoding/hex"
+  "errors"
+  "fmt"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// SignWebhook returns a signature header value of the form
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">".
+func SignWebhook(body []byte, secret string, t time.Time) string {
+  ts := strconv.FormatInt(t.Unix(), 10)
+  return "t=" + ts + ",v1=" + webhookMAC(ts, body, secret)
+}
+
+func webhookMAC(ts string, body []byte, secret string) string {
+  mac := hmac.New(sha256.New, []byte(secret))
+  mac.Write([]byte(ts))
+  mac.Write([]byte("."))
+  mac.Write(body)
+  return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks a header produced by SignWebhook. The
+// timestamp must be within tolerance of now, which stops old requests from
+// being replayed, and any of the v1 signatures may match so secrets can be
+// rotated.
+func VerifyWebhookSignature(header string, body []byte, secret string, tolerance time.Duration, now time.Time) error {
+  if header == "" {
+    return errors.New("signature header not present")
+  }
+
+  ts, signatures := parseWebhookSignature(header)
+  if ts == "" || len(signatures) == 0 {
+    return errors.New("malformed signature header")
+  }
+
+  unix, err := strconv.ParseInt(ts, 10, 64)
+  if err != nil {
+    return fmt.Errorf("invalid signature timestamp: %v", err)
+  }
+  age := now.Sub(time.Unix(unix, 0))
+  if age > tolerance || age < -tolerance {
+    return errors.New("signature timestamp outside tolerance")
+  }
+
+  expected := []byte(webhookMAC(ts, body, secret))
+  for _, sig := range signatures {
+    if hmac.Equal(expected, []byte(sig)) {
+      return nil
+    }
+  }
+  return errors.New("signature mismatch")
+}
+
+// WebhookSignatureTimestamp returns the t= part of a signature header,
+// which is signed along with the body, or "" if there is none.
+func WebhookSignatureTimestamp(header string) string {
+  ts, _ := parseWebhookSignature(header)
+  return ts
+}
+
+func parseWebhookSignature(header string) (string, []string) {
+  var ts string
+  var signatures []string
+  for _, part := range strings.Split(header, ",") {
+    key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+    if !ok {
+      continue
+    }
+    switch key {
+    case "t":
+      ts = value
+    case "v1":
+      signatures = append(signatures, value)
+    }
+  }
+  return ts, signatures
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestWebhookSignatureRoundTrip(t *testing.T) {
+  body := []byte(`{"event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`)
+  now := time.Now()
+  header := SignWebhook(body, "secret", now)
+
+  err := VerifyWebhookSignature(header, body, "secret", 5*time.Minute, now.Add(time.Minute))
+  if err != nil {
+    t.Fatalf("VerifyWebhookSignature: %v", err)
+  }
+}
+
+func TestWebhookSignatureRotatedSecret(t *testing.T) {
+  body := []byte(`{}`)
+  now := time.Now()
+  old := SignWebhook(body, "old", now)
+  current := SignWebhook(body, "new", now)
+  _, oldSig, _ := strings.Cut(old, ",")
+  header := current + "," + oldSig
+
+  for _, secret := range []string{"old", "new"} {
+    err := VerifyWebhookSignature(header, body, secret, time.Minute, now)
+    if err != nil {
+      t.Errorf("secret %q: %v", secret, err)
+    }
+  }
+}
+
+func TestWebhookSignatureRejects(t *testing.T) {
+  body := []byte(`{"event":"user.upgraded"}`)
+  now := time.Now()
+  header := SignWebhook(body, "secret", now)
+
+  tests := []struct {
+    name   string
+    header string
+    body   string
+    secret string
+    now    time.Time
+  }{
+    {"missing header", "", string(body), "secret", now},
+    {"malformed header", "garbage", string(body), "secret", now},
+    {"no signature", strings.Split(header, ",")[0], string(body), "secret", now},
+    {"wrong secret", header, string(body), "other", now},
+    {"tampered body", header, `{"event":"user.downgraded"}`, "secret", now},
+    {"too old", header, string(body), "secret", now.Add(10 * time.Minute)},
+    {"from the future", header, string(body), "secret", now.Add(-10 * time.Minute)},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      err := VerifyWebhookSignature(tt.header, []byte(tt.body), tt.secret, 5*time.Minute, tt.now)
+      if err == nil {
+        t.Error("VerifyWebhookSignature accepted the request")
+      }
+    })
+  }
+}
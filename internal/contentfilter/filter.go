@@ -0,0 +1,267 @@
+package contentfilter
+
+import (
+  "context"
+  "fmt"
+  "log"
+  "regexp"
+  "sort"
+  "strings"
+  "sync/atomic"
+  "time"
+  "unicode/utf8"
+  "chirpy/internal/database"
+  "github.com/google/uuid"
+)
+
+const (
+  // KindWord matches a word or phrase on word boundaries, so "fornax"
+  // catches "Fornax!" but not "fornaxes".
+  KindWord = "word"
+  // KindRegex is a Go regular expression, run against the normalized text.
+  KindRegex = "regex"
+)
+
+const (
+  ActionMask   = "mask"
+  ActionHold   = "hold"
+  ActionReject = "reject"
+)
+
+// severity orders actions so the strongest one that fired wins.
+var severity = map[string]int{
+  ActionMask:   1,
+  ActionHold:   2,
+  ActionReject: 3,
+}
+
+// Mask is what masked text is replaced with.
+const Mask = "****"
+
+// ReloadInterval is how often Run picks up rule changes made through other
+// instances.
+const ReloadInterval = 30 * time.Second
+
+// Rule is a rule as stored in content_filter_rules.
+type Rule struct {
+  ID      uuid.UUID
+  Kind    string
+  Pattern string
+  Action  string
+}
+
+type compiledRule struct {
+  Rule
+  // word is the normalized pattern of a word rule, re the compiled pattern
+  // of a regex rule
+  word string
+  re   *regexp.Regexp
+}
+
+// Compile checks that a rule is well formed. Admins go through it before a
+// rule is saved, so Reload only ever skips rules that were edited in the
+// database by hand.
+func Compile(rule Rule) error {
+  _, err := compile(rule)
+  return err
+}
+
+func compile(rule Rule) (compiledRule, error) {
+  c := compiledRule{Rule: rule}
+  if _, ok := severity[rule.Action]; !ok {
+    return c, fmt.Errorf("unknown action %q", rule.Action)
+  }
+  switch rule.Kind {
+  case KindWord:
+    c.word = normalize(strings.TrimSpace(rule.Pattern)).text
+    if c.word == "" {
+      return c, fmt.Errorf("word rules need a word")
+    }
+  case KindRegex:
+    // the text is already lowercase, (?i) just saves admins from having to
+    // remember that
+    re, err := regexp.Compile("(?i)" + rule.Pattern)
+    if err != nil {
+      return c, fmt.Errorf("invalid pattern: %v", err)
+    }
+    if re.MatchString("") {
+      return c, fmt.Errorf("pattern matches empty text")
+    }
+    c.re = re
+  default:
+    return c, fmt.Errorf("unknown kind %q", rule.Kind)
+  }
+  return c, nil
+}
+
+// Match is a rule that fired.
+type Match struct {
+  RuleID uuid.UUID `json:"rule_id"`
+  Action string    `json:"action"`
+}
+
+// Result is what Check made of some text.
+type Result struct {
+  // Text with the matches of mask rules masked
+  Text string
+  // Action is the strongest action among Matches, or "" if nothing fired
+  Action string
+  // RuleID is the rule behind Action
+  RuleID  uuid.UUID
+  Matches []Match
+}
+
+// Filter checks text against the enabled rules in content_filter_rules. The
+// rules are swapped in atomically on Reload, so checks never wait on it.
+type Filter struct {
+  db    *database.Queries
+  rules atomic.Pointer[[]compiledRule]
+}
+
+func New(db *database.Queries) *Filter {
+  f := &Filter{db: db}
+  f.rules.Store(&[]compiledRule{})
+  return f
+}
+
+// Reload loads the enabled rules. A rule that doesn't compile is logged and
+// skipped rather than taking the other rules down with it.
+func (f *Filter) Reload(ctx context.Context) error {
+  rows, err := f.db.ListEnabledFilterRules(ctx)
+  if err != nil {
+    return fmt.Errorf("error loading content filter rules: %v", err)
+  }
+  rules := make([]compiledRule, 0, len(rows))
+  for _, row := range rows {
+    c, err := compile(Rule{
+      ID:      row.ID,
+      Kind:    row.Kind,
+      Pattern: row.Pattern,
+      Action:  row.Action,
+    })
+    if err != nil {
+      log.Printf("Skipping content filter rule %s: %v", row.ID, err)
+      continue
+    }
+    rules = append(rules, c)
+  }
+  f.rules.Store(&rules)
+  return nil
+}
+
+// Run reloads the rules every ReloadInterval until ctx is done.
+func (f *Filter) Run(ctx context.Context) {
+  ticker := time.NewTicker(ReloadInterval)
+  defer ticker.Stop()
+  for {
+    select {
+    case <-ctx.Done():
+      return
+    case <-ticker.C:
+      err := f.Reload(ctx)
+      if err != nil && ctx.Err() == nil {
+        log.Printf("%v", err)
+      }
+    }
+  }
+}
+
+// Check runs text through the current rules.
+func (f *Filter) Check(text string) Result {
+  return check(*f.rules.Load(), text)
+}
+
+// CheckWith runs text through rules alone, for trying out rules before they
+// are saved.
+func CheckWith(rules []Rule, text string) (Result, error) {
+  compiled := make([]compiledRule, 0, len(rules))
+  for _, rule := range rules {
+    c, err := compile(rule)
+    if err != nil {
+      return Result{}, err
+    }
+    compiled = append(compiled, c)
+  }
+  return check(compiled, text), nil
+}
+
+func check(rules []compiledRule, text string) Result {
+  result := Result{Text: text}
+  n := normalize(text)
+
+  // byte ranges of the original text to mask
+  var masked [][2]int
+  for _, rule := range rules {
+    spans := rule.find(n.text)
+    if len(spans) == 0 {
+      continue
+    }
+    result.Matches = append(result.Matches, Match{RuleID: rule.ID, Action: rule.Action})
+    if severity[rule.Action] > severity[result.Action] {
+      result.Action = rule.Action
+      result.RuleID = rule.ID
+    }
+    if rule.Action == ActionMask {
+      for _, s := range spans {
+        from, to := n.span(s[0], s[1])
+        masked = append(masked, [2]int{from, to})
+      }
+    }
+  }
+  if len(masked) > 0 {
+    result.Text = mask(text, masked)
+  }
+  return result
+}
+
+// find returns the byte ranges of text the rule matches.
+func (c compiledRule) find(text string) [][2]int {
+  if c.re != nil {
+    var spans [][2]int
+    for _, loc := range c.re.FindAllStringIndex(text, -1) {
+      spans = append(spans, [2]int{loc[0], loc[1]})
+    }
+    return spans
+  }
+
+  var spans [][2]int
+  for offset := 0; offset < len(text); {
+    i := strings.Index(text[offset:], c.word)
+    if i < 0 {
+      break
+    }
+    from, to := offset+i, offset+i+len(c.word)
+    before, _ := utf8.DecodeLastRuneInString(text[:from])
+    after, _ := utf8.DecodeRuneInString(text[to:])
+    if (from == 0 || !isWordRune(before)) && (to == len(text) || !isWordRune(after)) {
+      spans = append(spans, [2]int{from, to})
+      offset = to
+      continue
+    }
+    _, size := utf8.DecodeRuneInString(text[from:])
+    offset = from + size
+  }
+  return spans
+}
+
+// mask replaces each of spans in text with Mask, merging spans that
+// overlap.
+func mask(text string, spans [][2]int) string {
+  sort.Slice(spans, func(i, j int) bool {
+    return spans[i][0] < spans[j][0]
+  })
+  var b strings.Builder
+  last := 0
+  for _, s := range spans {
+    if s[1] <= last {
+      continue
+    }
+    if s[0] >= last {
+      b.WriteString(text[last:s[0]])
+      b.WriteString(Mask)
+    }
+    last = s[1]
+  }
+  b.WriteString(text[last:])
+  return b.String()
+}
@@ -0,0 +1,100 @@
+package contentfilter
+
+import (
+  "testing"
+  "github.com/google/uuid"
+)
+
+func TestCheckWith(t *testing.T) {
+  maskRule := Rule{ID: uuid.New(), Kind: KindWord, Pattern: "kerfuffle", Action: ActionMask}
+  holdRule := Rule{ID: uuid.New(), Kind: KindRegex, Pattern: `buy\s+now`, Action: ActionHold}
+  rejectRule := Rule{ID: uuid.New(), Kind: KindWord, Pattern: "fornax", Action: ActionReject}
+  rules := []Rule{maskRule, holdRule, rejectRule}
+
+  tests := []struct {
+    name   string
+    text   string
+    want   string
+    action string
+    ruleID uuid.UUID
+  }{
+    {"nothing fires", "a quiet day", "a quiet day", "", uuid.Nil},
+    {"word is masked", "what a kerfuffle!", "what a ****!", ActionMask, maskRule.ID},
+    {"every occurrence is masked", "kerfuffle, kerfuffle", "****, ****", ActionMask, maskRule.ID},
+    {"case is ignored", "KERFUFFLE", "****", ActionMask, maskRule.ID},
+    {"accents are ignored", "Kérfuffle", "****", ActionMask, maskRule.ID},
+    {"fullwidth letters are folded", "ｋｅｒｆｕｆｆｌｅ", "****", ActionMask, maskRule.ID},
+    {"confusables are folded", "k\u0435rfuffle", "****", ActionMask, maskRule.ID},
+    {"zero-width spaces are dropped", "ker\u200bfuffle", "****", ActionMask, maskRule.ID},
+    {"words match on boundaries", "kerfuffles", "kerfuffles", "", uuid.Nil},
+    {"regex", "Buy   NOW while it lasts", "Buy   NOW while it lasts", ActionHold, holdRule.ID},
+    {"strongest action wins", "buy now, kerfuffle, fornax", "buy now, ****, fornax", ActionReject, rejectRule.ID},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      result, err := CheckWith(rules, tt.text)
+      if err != nil {
+        t.Fatal(err)
+      }
+      if result.Text != tt.want {
+        t.Errorf("Text = %q, want %q", result.Text, tt.want)
+      }
+      if result.Action != tt.action || result.RuleID != tt.ruleID {
+        t.Errorf("Action = %q (rule %s), want %q (rule %s)", result.Action, result.RuleID, tt.action, tt.ruleID)
+      }
+    })
+  }
+}
+
+func TestCheckWithReportsEveryMatch(t *testing.T) {
+  rules := []Rule{
+    {ID: uuid.New(), Kind: KindWord, Pattern: "kerfuffle", Action: ActionMask},
+    {ID: uuid.New(), Kind: KindWord, Pattern: "hullabaloo", Action: ActionHold},
+    {ID: uuid.New(), Kind: KindWord, Pattern: "fornax", Action: ActionReject},
+  }
+  result, err := CheckWith(rules, "a kerfuffle and a hullabaloo")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(result.Matches) != 2 || result.Matches[0].RuleID != rules[0].ID || result.Matches[1].RuleID != rules[1].ID {
+    t.Errorf("Matches = %+v, want the first two rules", result.Matches)
+  }
+}
+
+func TestMaskOverlappingRules(t *testing.T) {
+  rules := []Rule{
+    {ID: uuid.New(), Kind: KindWord, Pattern: "big kerfuffle", Action: ActionMask},
+    {ID: uuid.New(), Kind: KindWord, Pattern: "kerfuffle", Action: ActionMask},
+  }
+  result, err := CheckWith(rules, "a big kerfuffle here")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if result.Text != "a **** here" {
+    t.Errorf("Text = %q, want the overlap masked once", result.Text)
+  }
+}
+
+func TestCompile(t *testing.T) {
+  tests := []struct {
+    name  string
+    rule  Rule
+    valid bool
+  }{
+    {"word", Rule{Kind: KindWord, Pattern: "fornax", Action: ActionReject}, true},
+    {"regex", Rule{Kind: KindRegex, Pattern: `\bfor+nax\b`, Action: ActionHold}, true},
+    {"blank word", Rule{Kind: KindWord, Pattern: "  ", Action: ActionMask}, false},
+    {"invalid regex", Rule{Kind: KindRegex, Pattern: "(", Action: ActionMask}, false},
+    {"regex matching empty text", Rule{Kind: KindRegex, Pattern: "x*", Action: ActionMask}, false},
+    {"unknown action", Rule{Kind: KindWord, Pattern: "fornax", Action: "delete"}, false},
+    {"unknown kind", Rule{Kind: "glob", Pattern: "for*", Action: ActionMask}, false},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      err := Compile(tt.rule)
+      if (err == nil) != tt.valid {
+        t.Errorf("Compile = %v, want valid %v", err, tt.valid)
+      }
+    })
+  }
+}
@@ -0,0 +1,98 @@
+package contentfilter
+
+import (
+  "strings"
+  "unicode"
+  "golang.org/x/text/unicode/norm"
+)
+
+// confusables folds the Cyrillic and Greek letters that are commonly passed
+// off as Latin ones. NFKD already takes care of fullwidth and mathematical
+// forms, so only look-alikes from other scripts need listing. Keys are
+// lowercase, since folding runs after lowercasing.
+var confusables = map[rune]rune{
+  // Cyrillic
+  'а': 'a',
+  'в': 'b',
+  'е': 'e',
+  'к': 'k',
+  'м': 'm',
+  'н': 'h',
+  'о': 'o',
+  'р': 'p',
+  'с': 'c',
+  'т': 't',
+  'у': 'y',
+  'х': 'x',
+  'ѕ': 's',
+  'і': 'i',
+  'ј': 'j',
+  'һ': 'h',
+  'ԁ': 'd',
+  'ӏ': 'l',
+  // Greek
+  'α': 'a',
+  'β': 'b',
+  'ε': 'e',
+  'η': 'n',
+  'ι': 'i',
+  'κ': 'k',
+  'ν': 'v',
+  'ο': 'o',
+  'ρ': 'p',
+  'τ': 't',
+  'υ': 'u',
+  'χ': 'x',
+}
+
+// normalized is text folded for matching, with a way back to the original:
+// byte i of text came from orig[start[i]:end[i]].
+type normalized struct {
+  text  string
+  start []int
+  end   []int
+}
+
+// normalize decomposes text (NFKD), drops combining marks and invisible
+// format characters such as zero-width spaces, lowercases it and folds
+// confusables, so "Kérfuffle", "ｋｅｒｆｕｆｆｌｅ" and "kеrfuffle" with a Cyrillic
+// е all read as "kerfuffle".
+func normalize(text string) normalized {
+  var b strings.Builder
+  n := normalized{
+    start: make([]int, 0, len(text)),
+    end:   make([]int, 0, len(text)),
+  }
+  for i, r := range text {
+    size := len(string(r))
+    for _, d := range norm.NFKD.String(string(r)) {
+      if unicode.Is(unicode.Mn, d) || unicode.Is(unicode.Cf, d) {
+        continue
+      }
+      d = unicode.ToLower(d)
+      if folded, ok := confusables[d]; ok {
+        d = folded
+      }
+      before := b.Len()
+      b.WriteRune(d)
+      for j := before; j < b.Len(); j++ {
+        n.start = append(n.start, i)
+        n.end = append(n.end, i+size)
+      }
+    }
+  }
+  n.text = b.String()
+  return n
+}
+
+// span maps the normalized bytes [from, to) back to a byte range of the
+// original text.
+func (n normalized) span(from, to int) (int, int) {
+  return n.start[from], n.end[to-1]
+}
+
+// isWordRune reports whether r is part of a word, for the boundaries of
+// word rules.
+func isWordRune(r rune) bool {
+  return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
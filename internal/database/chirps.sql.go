@@ -7,29 +7,55 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const anonymizeRepliesByAuthor = `-- name: AnonymizeRepliesByAuthor :execrows
+UPDATE chirps
+SET user_id = $1
+WHERE user_id = $2
+  AND quote_of IS NOT NULL
+  AND deleted_at IS NULL
+  AND held_at IS NULL
+`
+
+type AnonymizeRepliesByAuthorParams struct {
+	AnonymousID uuid.UUID `json:"anonymous_id"`
+	UserID      uuid.UUID `json:"user_id"`
+}
+
+func (q *Queries) AnonymizeRepliesByAuthor(ctx context.Context, arg AnonymizeRepliesByAuthorParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, anonymizeRepliesByAuthor, arg.AnonymousID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, quote_of)
 VALUES (
   $1,
   $2,
   $3,
   $4,
-  $5
+  $5,
+  $6
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, quote_of, deleted_at, held_at
 `
 
 type CreateChirpParams struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID        uuid.UUID     `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	Body      string        `json:"body"`
+	UserID    uuid.UUID     `json:"user_id"`
+	QuoteOf   uuid.NullUUID `json:"quote_of"`
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
@@ -39,6 +65,7 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		arg.UpdatedAt,
 		arg.Body,
 		arg.UserID,
+		arg.QuoteOf,
 	)
 	var i Chirp
 	err := row.Scan(
@@ -47,13 +74,17 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.QuoteOf,
+		&i.DeletedAt,
+		&i.HeldAt,
 	)
 	return i, err
 }
 
 const deleteOneChirp = `-- name: DeleteOneChirp :exec
-DELETE FROM chirps 
-WHERE id = $1 AND user_id = $2
+UPDATE chirps
+SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
 `
 
 type DeleteOneChirpParams struct {
@@ -67,7 +98,8 @@ func (q *Queries) DeleteOneChirp(ctx context.Context, arg DeleteOneChirpParams)
 }
 
 const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, quote_of, deleted_at, held_at FROM chirps
+WHERE deleted_at IS NULL
 ORDER BY created_at ASC
 `
 
@@ -86,6 +118,9 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.QuoteOf,
+			&i.DeletedAt,
+			&i.HeldAt,
 		); err != nil {
 			return nil, err
 		}
@@ -101,7 +136,8 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getAllChirpsDesc = `-- name: GetAllChirpsDesc :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps 
+SELECT id, created_at, updated_at, body, user_id, quote_of, deleted_at, held_at FROM chirps 
+WHERE deleted_at IS NULL
 ORDER BY created_at DESC
 `
 
@@ -120,6 +156,9 @@ func (q *Queries) GetAllChirpsDesc(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.QuoteOf,
+			&i.DeletedAt,
+			&i.HeldAt,
 		); err != nil {
 			return nil, err
 		}
@@ -134,13 +173,98 @@ func (q *Queries) GetAllChirpsDesc(ctx context.Context) ([]Chirp, error) {
 	return items, nil
 }
 
-const getChirpsByAuthor = `-- name: GetChirpsByAuthor :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE user_id = $1
+const getChirpsByAuthors = `-- name: GetChirpsByAuthors :many
+SELECT id, created_at, updated_at, body, user_id, quote_of, deleted_at, held_at FROM chirps
+WHERE user_id = ANY($1::uuid[]) AND deleted_at IS NULL
+ORDER BY created_at ASC
+`
+
+func (q *Queries) GetChirpsByAuthors(ctx context.Context, userIds []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByAuthors, pq.Array(userIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.QuoteOf,
+			&i.DeletedAt,
+			&i.HeldAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByAuthorsDesc = `-- name: GetChirpsByAuthorsDesc :many
+SELECT id, created_at, updated_at, body, user_id, quote_of, deleted_at, held_at FROM chirps
+WHERE user_id = ANY($1::uuid[]) AND deleted_at IS NULL
+ORDER BY created_at DESC
+`
+
+func (q *Queries) GetChirpsByAuthorsDesc(ctx context.Context, userIds []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByAuthorsDesc, pq.Array(userIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.QuoteOf,
+			&i.DeletedAt,
+			&i.HeldAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByHashtagDesc = `-- name: GetChirpsByHashtagDesc :many
+SELECT id, created_at, updated_at, body, user_id, quote_of, deleted_at, held_at FROM chirps
+WHERE body ~* ('(^|[^[:alnum:]_])#' || $1::text || '($|[^[:alnum:]_])')
+  AND deleted_at IS NULL
+  AND held_at IS NULL
+ORDER BY created_at DESC
+LIMIT $2
 `
 
-func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByAuthor, userID)
+type GetChirpsByHashtagDescParams struct {
+	Tag      string `json:"tag"`
+	RowLimit int32  `json:"row_limit"`
+}
+
+func (q *Queries) GetChirpsByHashtagDesc(ctx context.Context, arg GetChirpsByHashtagDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByHashtagDesc, arg.Tag, arg.RowLimit)
 	if err != nil {
 		return nil, err
 	}
@@ -154,6 +278,9 @@ func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.QuoteOf,
+			&i.DeletedAt,
+			&i.HeldAt,
 		); err != nil {
 			return nil, err
 		}
@@ -168,14 +295,59 @@ func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Ch
 	return items, nil
 }
 
-const getChirpsByAuthorDesc = `-- name: GetChirpsByAuthorDesc :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps 
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+SELECT id, created_at, updated_at, body, user_id, quote_of, deleted_at, held_at FROM chirps
+WHERE id = ANY($1::uuid[]) AND deleted_at IS NULL
+`
+
+func (q *Queries) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.QuoteOf,
+			&i.DeletedAt,
+			&i.HeldAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLatestChirpsByAuthor = `-- name: GetLatestChirpsByAuthor :many
+SELECT id, created_at, updated_at, body, user_id, quote_of, deleted_at, held_at FROM chirps
 WHERE user_id = $1
+  AND deleted_at IS NULL
+  AND held_at IS NULL
 ORDER BY created_at DESC
+LIMIT $2
 `
 
-func (q *Queries) GetChirpsByAuthorDesc(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByAuthorDesc, userID)
+type GetLatestChirpsByAuthorParams struct {
+	UserID   uuid.UUID `json:"user_id"`
+	RowLimit int32     `json:"row_limit"`
+}
+
+func (q *Queries) GetLatestChirpsByAuthor(ctx context.Context, arg GetLatestChirpsByAuthorParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getLatestChirpsByAuthor, arg.UserID, arg.RowLimit)
 	if err != nil {
 		return nil, err
 	}
@@ -189,6 +361,9 @@ func (q *Queries) GetChirpsByAuthorDesc(ctx context.Context, userID uuid.UUID) (
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.QuoteOf,
+			&i.DeletedAt,
+			&i.HeldAt,
 		); err != nil {
 			return nil, err
 		}
@@ -204,8 +379,8 @@ func (q *Queries) GetChirpsByAuthorDesc(ctx context.Context, userID uuid.UUID) (
 }
 
 const getOneChirp = `-- name: GetOneChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE id = $1
+SELECT id, created_at, updated_at, body, user_id, quote_of, deleted_at, held_at FROM chirps
+WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) GetOneChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -217,6 +392,159 @@ func (q *Queries) GetOneChirp(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.QuoteOf,
+		&i.DeletedAt,
+		&i.HeldAt,
+	)
+	return i, err
+}
+
+const getQuotesOfChirp = `-- name: GetQuotesOfChirp :many
+SELECT id, created_at, updated_at, body, user_id, quote_of, deleted_at, held_at FROM chirps
+WHERE quote_of = $1
+  AND deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE user_blocks.blocker_id = chirps.user_id
+      AND user_blocks.blocked_id = $2
+  )
+ORDER BY created_at DESC
+`
+
+type GetQuotesOfChirpParams struct {
+	QuoteOf  uuid.NullUUID `json:"quote_of"`
+	ViewerID uuid.NullUUID `json:"viewer_id"`
+}
+
+func (q *Queries) GetQuotesOfChirp(ctx context.Context, arg GetQuotesOfChirpParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getQuotesOfChirp, arg.QuoteOf, arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.QuoteOf,
+			&i.DeletedAt,
+			&i.HeldAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSpamCounts = `-- name: GetSpamCounts :one
+SELECT
+  COUNT(*) FILTER (WHERE user_id = $1)::int AS recent_chirps,
+  COUNT(*) FILTER (WHERE user_id = $1 AND lower(body) = lower($2))::int AS duplicates_by_author,
+  COUNT(DISTINCT user_id) FILTER (WHERE user_id <> $1 AND lower(body) = lower($2))::int AS duplicates_by_others
+FROM chirps
+WHERE created_at > $3 AND (user_id = $1 OR lower(body) = lower($2))
+`
+
+type GetSpamCountsParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	Body   string    `json:"body"`
+	Since  time.Time `json:"since"`
+}
+
+type GetSpamCountsRow struct {
+	RecentChirps       int32 `json:"recent_chirps"`
+	DuplicatesByAuthor int32 `json:"duplicates_by_author"`
+	DuplicatesByOthers int32 `json:"duplicates_by_others"`
+}
+
+func (q *Queries) GetSpamCounts(ctx context.Context, arg GetSpamCountsParams) (GetSpamCountsRow, error) {
+	row := q.db.QueryRowContext(ctx, getSpamCounts, arg.UserID, arg.Body, arg.Since)
+	var i GetSpamCountsRow
+	err := row.Scan(
+		&i.RecentChirps,
+		&i.DuplicatesByAuthor,
+		&i.DuplicatesByOthers,
+	)
+	return i, err
+}
+
+const holdChirp = `-- name: HoldChirp :exec
+UPDATE chirps
+SET held_at = $2
+WHERE id = $1
+`
+
+type HoldChirpParams struct {
+	ID     uuid.UUID    `json:"id"`
+	HeldAt sql.NullTime `json:"held_at"`
+}
+
+func (q *Queries) HoldChirp(ctx context.Context, arg HoldChirpParams) error {
+	_, err := q.db.ExecContext(ctx, holdChirp, arg.ID, arg.HeldAt)
+	return err
+}
+
+const releaseChirp = `-- name: ReleaseChirp :exec
+UPDATE chirps
+SET held_at = NULL
+WHERE id = $1
+`
+
+func (q *Queries) ReleaseChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, releaseChirp, id)
+	return err
+}
+
+const removeChirp = `-- name: RemoveChirp :execrows
+UPDATE chirps
+SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) RemoveChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, removeChirp, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, quote_of, deleted_at, held_at
+`
+
+type UpdateChirpBodyParams struct {
+	ID   uuid.UUID `json:"id"`
+	Body string    `json:"body"`
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.QuoteOf,
+		&i.DeletedAt,
+		&i.HeldAt,
 	)
 	return i, err
 }
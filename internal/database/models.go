@@ -6,17 +6,253 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type ActorKey struct {
+	UserID        uuid.UUID `json:"user_id"`
+	CreatedAt     time.Time `json:"created_at"`
+	PublicKeyPem  string    `json:"public_key_pem"`
+	PrivateKeyPem string    `json:"private_key_pem"`
+}
+
+type AuditEvent struct {
+	ID        uuid.UUID       `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	EventType string          `json:"event_type"`
+	ActorID   uuid.NullUUID   `json:"actor_id"`
+	UserID    uuid.NullUUID   `json:"user_id"`
+	Ip        string          `json:"ip"`
+	UserAgent string          `json:"user_agent"`
+	RequestID string          `json:"request_id"`
+	Details   json.RawMessage `json:"details"`
+}
+
+type Bookmark struct {
+	UserID       uuid.UUID     `json:"user_id"`
+	ChirpID      uuid.UUID     `json:"chirp_id"`
+	CollectionID uuid.NullUUID `json:"collection_id"`
+	CreatedAt    time.Time     `json:"created_at"`
+}
+
+type BookmarkCollection struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	Name      string    `json:"name"`
+}
+
 type Chirp struct {
+	ID        uuid.UUID     `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	Body      string        `json:"body"`
+	UserID    uuid.UUID     `json:"user_id"`
+	QuoteOf   uuid.NullUUID `json:"quote_of"`
+	DeletedAt sql.NullTime  `json:"deleted_at"`
+	HeldAt    sql.NullTime  `json:"held_at"`
+}
+
+type ChirpDraft struct {
+	ID        uuid.UUID      `json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	UserID    uuid.UUID      `json:"user_id"`
+	Body      string         `json:"body"`
+	Status    string         `json:"status"`
+	PublishAt sql.NullTime   `json:"publish_at"`
+	LastError sql.NullString `json:"last_error"`
+}
+
+type ChirpImport struct {
+	ID          uuid.UUID       `json:"id"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	UserID      uuid.UUID       `json:"user_id"`
+	Source      string          `json:"source"`
+	Status      string          `json:"status"`
+	DryRun      bool            `json:"dry_run"`
+	LongPosts   string          `json:"long_posts"`
+	Total       int32           `json:"total"`
+	Processed   int32           `json:"processed"`
+	Stats       json.RawMessage `json:"stats"`
+	CompletedAt sql.NullTime    `json:"completed_at"`
+	LastError   sql.NullString  `json:"last_error"`
+}
+
+type ChirpReaction struct {
+	ActivityID string    `json:"activity_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	ChirpID    uuid.UUID `json:"chirp_id"`
+	UserID     uuid.UUID `json:"user_id"`
+	Type       string    `json:"type"`
+}
+
+type ContentFilterRule struct {
 	ID        uuid.UUID `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
+	Kind      string    `json:"kind"`
+	Pattern   string    `json:"pattern"`
+	Action    string    `json:"action"`
+	Enabled   bool      `json:"enabled"`
+}
+
+type DataExport struct {
+	ID          uuid.UUID      `json:"id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UserID      uuid.UUID      `json:"user_id"`
+	Status      string         `json:"status"`
+	CompletedAt sql.NullTime   `json:"completed_at"`
+	ExpiresAt   sql.NullTime   `json:"expires_at"`
+	SizeBytes   sql.NullInt64  `json:"size_bytes"`
+	LastError   sql.NullString `json:"last_error"`
+}
+
+type Follow struct {
+	FollowerID uuid.UUID    `json:"follower_id"`
+	FolloweeID uuid.UUID    `json:"followee_id"`
+	CreatedAt  time.Time    `json:"created_at"`
+	ActivityID string       `json:"activity_id"`
+	AcceptedAt sql.NullTime `json:"accepted_at"`
+}
+
+type ImportedPost struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Source    string    `json:"source"`
+	SourceID  string    `json:"source_id"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Job struct {
+	ID          uuid.UUID       `json:"id"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int32           `json:"attempts"`
+	MaxAttempts int32           `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	LockedUntil sql.NullTime    `json:"locked_until"`
+	LastError   sql.NullString  `json:"last_error"`
+	UniqueKey   sql.NullString  `json:"unique_key"`
+}
+
+type List struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	OwnerID     uuid.UUID `json:"owner_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	IsPrivate   bool      `json:"is_private"`
+}
+
+type ListMember struct {
+	ListID    uuid.UUID `json:"list_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ListSubscription struct {
+	ListID    uuid.UUID `json:"list_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type LoginLockout struct {
+	ID             uuid.UUID      `json:"id"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	Scope          string         `json:"scope"`
+	Subject        string         `json:"subject"`
+	FailedAttempts int32          `json:"failed_attempts"`
+	LastFailedAt   time.Time      `json:"last_failed_at"`
+	LockedUntil    sql.NullTime   `json:"locked_until"`
+	UnlockToken    sql.NullString `json:"unlock_token"`
+}
+
+type ModerationAction struct {
+	ID           uuid.UUID     `json:"id"`
+	CreatedAt    time.Time     `json:"created_at"`
+	ModeratorID  uuid.NullUUID `json:"moderator_id"`
+	CaseID       uuid.NullUUID `json:"case_id"`
+	TargetUserID uuid.UUID     `json:"target_user_id"`
+	ChirpID      uuid.NullUUID `json:"chirp_id"`
+	Action       string        `json:"action"`
+	Note         string        `json:"note"`
+	ExpiresAt    sql.NullTime  `json:"expires_at"`
+}
+
+type ModerationCase struct {
+	ID             uuid.UUID      `json:"id"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	TargetKey      string         `json:"target_key"`
+	UserID         uuid.UUID      `json:"user_id"`
+	ChirpID        uuid.NullUUID  `json:"chirp_id"`
+	Status         string         `json:"status"`
+	ReportCount    int32          `json:"report_count"`
+	LastReportedAt time.Time      `json:"last_reported_at"`
+	ResolvedAt     sql.NullTime   `json:"resolved_at"`
+	Resolution     sql.NullString `json:"resolution"`
+	FilterRuleID   uuid.NullUUID  `json:"filter_rule_id"`
+	SpamScore      sql.NullInt32  `json:"spam_score"`
+}
+
+type PasswordReset struct {
+	TokenHash string       `json:"token_hash"`
+	CreatedAt time.Time    `json:"created_at"`
+	UserID    uuid.UUID    `json:"user_id"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type PinnedChirp struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Poll struct {
+	ChirpID     uuid.UUID    `json:"chirp_id"`
+	CreatedAt   time.Time    `json:"created_at"`
+	ClosesAt    time.Time    `json:"closes_at"`
+	FinalizedAt sql.NullTime `json:"finalized_at"`
+}
+
+type PollOption struct {
+	ChirpID  uuid.UUID `json:"chirp_id"`
+	Position int32     `json:"position"`
+	Text     string    `json:"text"`
+}
+
+type PollVote struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Position  int32     `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type QuoteSnapshot struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	QuotedID  uuid.UUID `json:"quoted_id"`
 	UserID    uuid.UUID `json:"user_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RateLimitBucket struct {
+	Key       string    `json:"key"`
+	Tokens    float64   `json:"tokens"`
+	Allowed   bool      `json:"allowed"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type RefreshToken struct {
@@ -28,11 +264,102 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime `json:"revoked_at"`
 }
 
+type RemoteActor struct {
+	UserID       uuid.UUID      `json:"user_id"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	ActorID      string         `json:"actor_id"`
+	Username     string         `json:"username"`
+	Domain       string         `json:"domain"`
+	Inbox        string         `json:"inbox"`
+	SharedInbox  sql.NullString `json:"shared_inbox"`
+	KeyID        string         `json:"key_id"`
+	PublicKeyPem string         `json:"public_key_pem"`
+}
+
+type RemoteChirp struct {
+	ObjectID string    `json:"object_id"`
+	ChirpID  uuid.UUID `json:"chirp_id"`
+}
+
+type Report struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	CaseID     uuid.UUID `json:"case_id"`
+	ReporterID uuid.UUID `json:"reporter_id"`
+	Reason     string    `json:"reason"`
+	Details    string    `json:"details"`
+}
+
+type Subscription struct {
+	ID                uuid.UUID `json:"id"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+	UserID            uuid.UUID `json:"user_id"`
+	Plan              string    `json:"plan"`
+	Status            string    `json:"status"`
+	CurrentPeriodEnd  time.Time `json:"current_period_end"`
+	CancelAtPeriodEnd bool      `json:"cancel_at_period_end"`
+}
+
 type User struct {
-	ID             uuid.UUID `json:"id"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
-	Email          string    `json:"email"`
-	HashedPassword string    `json:"hashed_password"`
-	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	ID             uuid.UUID    `json:"id"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+	Email          string       `json:"email"`
+	HashedPassword string       `json:"hashed_password"`
+	IsChirpyRed    bool         `json:"is_chirpy_red"`
+	Role           string       `json:"role"`
+	AccountStatus  string       `json:"account_status"`
+	SuspendedUntil sql.NullTime `json:"suspended_until"`
+	DeleteAfter    sql.NullTime `json:"delete_after"`
+}
+
+type UserBlock struct {
+	BlockerID uuid.UUID `json:"blocker_id"`
+	BlockedID uuid.UUID `json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	EndpointID     uuid.UUID       `json:"endpoint_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode sql.NullInt32   `json:"last_status_code"`
+	LastError      sql.NullString  `json:"last_error"`
+	DeliveredAt    sql.NullTime    `json:"delivered_at"`
+}
+
+type WebhookEndpoint struct {
+	ID                  uuid.UUID    `json:"id"`
+	CreatedAt           time.Time    `json:"created_at"`
+	UpdatedAt           time.Time    `json:"updated_at"`
+	UserID              uuid.UUID    `json:"user_id"`
+	Url                 string       `json:"url"`
+	Secret              string       `json:"secret"`
+	Events              []string     `json:"events"`
+	Global              bool         `json:"global"`
+	Enabled             bool         `json:"enabled"`
+	ConsecutiveFailures int32        `json:"consecutive_failures"`
+	DisabledAt          sql.NullTime `json:"disabled_at"`
+}
+
+type WebhookEvent struct {
+	ID             uuid.UUID       `json:"id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	Source         string          `json:"source"`
+	IdempotencyKey string          `json:"idempotency_key"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	LastError      sql.NullString  `json:"last_error"`
+	ProcessedAt    sql.NullTime    `json:"processed_at"`
 }
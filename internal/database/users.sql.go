@@ -7,11 +7,57 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const cancelAccountDeletion = `-- name: CancelAccountDeletion :execrows
+UPDATE users
+SET delete_after = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE users.id = $1 AND delete_after IS NOT NULL
+`
+
+func (q *Queries) CancelAccountDeletion(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, cancelAccountDeletion, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const createShadowUser = `-- name: CreateShadowUser :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+VALUES ($1, $2, $2, $3, '')
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, account_status, suspended_until, delete_after
+`
+
+type CreateShadowUserParams struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Email     string    `json:"email"`
+}
+
+func (q *Queries) CreateShadowUser(ctx context.Context, arg CreateShadowUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, createShadowUser, arg.ID, arg.CreatedAt, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.AccountStatus,
+		&i.SuspendedUntil,
+		&i.DeleteAfter,
+	)
+	return i, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, email, hashed_password)
 VALUES (
@@ -21,7 +67,7 @@ VALUES (
   $4,
   $5
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, account_status, suspended_until, delete_after
 `
 
 type CreateUserParams struct {
@@ -48,10 +94,24 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.AccountStatus,
+		&i.SuspendedUntil,
+		&i.DeleteAfter,
 	)
 	return i, err
 }
 
+const deleteUser = `-- name: DeleteUser :exec
+DELETE FROM users
+WHERE users.id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteUser, id)
+	return err
+}
+
 const deleteUsers = `-- name: DeleteUsers :exec
 DELETE FROM users
 `
@@ -61,8 +121,26 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 	return err
 }
 
+const ensureDeletedUser = `-- name: EnsureDeletedUser :exec
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, account_status)
+VALUES (
+  $1,
+  CURRENT_TIMESTAMP,
+  CURRENT_TIMESTAMP,
+  'deleted@chirpy.invalid',
+  '',
+  'banned'
+)
+ON CONFLICT DO NOTHING
+`
+
+func (q *Queries) EnsureDeletedUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, ensureDeletedUser, id)
+	return err
+}
+
 const getUserById = `-- name: GetUserById :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, account_status, suspended_until, delete_after
 FROM users 
 WHERE id = $1
 `
@@ -77,10 +155,142 @@ func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.AccountStatus,
+		&i.SuspendedUntil,
+		&i.DeleteAfter,
 	)
 	return i, err
 }
 
+const listAccountsDueForDeletion = `-- name: ListAccountsDueForDeletion :many
+SELECT id FROM users
+WHERE delete_after <= $1
+ORDER BY delete_after ASC
+LIMIT 100
+`
+
+func (q *Queries) ListAccountsDueForDeletion(ctx context.Context, deleteAfter sql.NullTime) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, listAccountsDueForDeletion, deleteAfter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listShadowBannedAmong = `-- name: ListShadowBannedAmong :many
+SELECT id FROM users
+WHERE id = ANY($1::uuid[]) AND account_status = 'shadow_banned'
+`
+
+func (q *Queries) ListShadowBannedAmong(ctx context.Context, userIds []uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, listShadowBannedAmong, pq.Array(userIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockAccountDueForDeletion = `-- name: LockAccountDueForDeletion :one
+SELECT id FROM users
+WHERE users.id = $1 AND delete_after <= $2
+FOR UPDATE
+`
+
+type LockAccountDueForDeletionParams struct {
+	ID          uuid.UUID    `json:"id"`
+	DeleteAfter sql.NullTime `json:"delete_after"`
+}
+
+func (q *Queries) LockAccountDueForDeletion(ctx context.Context, arg LockAccountDueForDeletionParams) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, lockAccountDueForDeletion, arg.ID, arg.DeleteAfter)
+	var id uuid.UUID
+	err := row.Scan(&id)
+	return id, err
+}
+
+const scheduleAccountDeletion = `-- name: ScheduleAccountDeletion :one
+UPDATE users
+SET delete_after = COALESCE(delete_after, $1::timestamp), updated_at = CURRENT_TIMESTAMP
+WHERE users.id = $2
+RETURNING delete_after
+`
+
+type ScheduleAccountDeletionParams struct {
+	DeleteAfter time.Time `json:"delete_after"`
+	ID          uuid.UUID `json:"id"`
+}
+
+func (q *Queries) ScheduleAccountDeletion(ctx context.Context, arg ScheduleAccountDeletionParams) (sql.NullTime, error) {
+	row := q.db.QueryRowContext(ctx, scheduleAccountDeletion, arg.DeleteAfter, arg.ID)
+	var deleteAfter sql.NullTime
+	err := row.Scan(&deleteAfter)
+	return deleteAfter, err
+}
+
+const setAccountStatus = `-- name: SetAccountStatus :exec
+UPDATE users
+SET account_status = $2, suspended_until = $3, updated_at = CURRENT_TIMESTAMP
+WHERE users.id = $1
+`
+
+type SetAccountStatusParams struct {
+	ID             uuid.UUID    `json:"id"`
+	AccountStatus  string       `json:"account_status"`
+	SuspendedUntil sql.NullTime `json:"suspended_until"`
+}
+
+func (q *Queries) SetAccountStatus(ctx context.Context, arg SetAccountStatusParams) error {
+	_, err := q.db.ExecContext(ctx, setAccountStatus, arg.ID, arg.AccountStatus, arg.SuspendedUntil)
+	return err
+}
+
+const setChirpyRed = `-- name: SetChirpyRed :exec
+UPDATE users
+SET is_chirpy_red = $2
+WHERE users.id = $1
+`
+
+type SetChirpyRedParams struct {
+	ID          uuid.UUID `json:"id"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+}
+
+func (q *Queries) SetChirpyRed(ctx context.Context, arg SetChirpyRedParams) error {
+	_, err := q.db.ExecContext(ctx, setChirpyRed, arg.ID, arg.IsChirpyRed)
+	return err
+}
+
 const updateUser = `-- name: UpdateUser :exec
 UPDATE users
 SET email = $1 , hashed_password = $2
@@ -98,19 +308,24 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) error {
 	return err
 }
 
-const upgradeToChirpyRed = `-- name: UpgradeToChirpyRed :exec
-UPDATE users 
-SET is_chirpy_red = TRUE
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = CURRENT_TIMESTAMP
 WHERE users.id = $1
 `
 
-func (q *Queries) UpgradeToChirpyRed(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, upgradeToChirpyRed, id)
+type UpdateUserPasswordParams struct {
+	ID             uuid.UUID `json:"id"`
+	HashedPassword string    `json:"hashed_password"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.HashedPassword)
 	return err
 }
 
 const userByEmail = `-- name: UserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red 
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, account_status, suspended_until, delete_after 
 FROM users
 WHERE email = $1
 `
@@ -125,6 +340,10 @@ func (q *Queries) UserByEmail(ctx context.Context, email string) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.AccountStatus,
+		&i.SuspendedUntil,
+		&i.DeleteAfter,
 	)
 	return i, err
 }
@@ -0,0 +1,55 @@
+package entitlements
+
+const (
+  PlanFree      = "free"
+  PlanChirpyRed = "chirpy_red"
+)
+
+const (
+  TierStandard = "standard"
+  TierRed      = "red"
+)
+
+// Entitlements are the perks a plan comes with.
+type Entitlements struct {
+  Plan              string `json:"plan"`
+  MaxChirpLength    int    `json:"max_chirp_length"`
+  CanEditChirps     bool   `json:"can_edit_chirps"`
+  CanScheduleChirps bool   `json:"can_schedule_chirps"`
+  MaxPinnedChirps   int    `json:"max_pinned_chirps"`
+  // which rate limit quotas apply, TierStandard or TierRed
+  RateLimitTier     string `json:"rate_limit_tier"`
+}
+
+var plans = map[string]Entitlements{
+  PlanFree: {
+    Plan:              PlanFree,
+    MaxChirpLength:    140,
+    CanEditChirps:     false,
+    CanScheduleChirps: false,
+    MaxPinnedChirps:   1,
+    RateLimitTier:     TierStandard,
+  },
+  PlanChirpyRed: {
+    Plan:              PlanChirpyRed,
+    MaxChirpLength:    1000,
+    CanEditChirps:     true,
+    CanScheduleChirps: true,
+    MaxPinnedChirps:   5,
+    RateLimitTier:     TierRed,
+  },
+}
+
+// ForPlan returns the entitlements of plan. Unknown plans get the free
+// entitlements.
+func ForPlan(plan string) Entitlements {
+  if e, ok := plans[plan]; ok {
+    return e
+  }
+  return plans[PlanFree]
+}
+
+// Free is what every account gets without a subscription.
+func Free() Entitlements {
+  return plans[PlanFree]
+}
@@ -0,0 +1,101 @@
+package feed
+
+import (
+  "encoding/xml"
+  "time"
+)
+
+const (
+  AtomMediaType = "application/atom+xml"
+  atomNamespace = "http://www.w3.org/2005/Atom"
+)
+
+type atomFeed struct {
+  XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+  ID       string      `xml:"id"`
+  Title    string      `xml:"title"`
+  Subtitle string      `xml:"subtitle,omitempty"`
+  Updated  string      `xml:"updated"`
+  Links    []atomLink  `xml:"link"`
+  Author   *atomPerson `xml:"author"`
+  Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+  Rel  string `xml:"rel,attr"`
+  Type string `xml:"type,attr,omitempty"`
+  Href string `xml:"href,attr"`
+}
+
+type atomPerson struct {
+  Name string `xml:"name"`
+  URI  string `xml:"uri,omitempty"`
+}
+
+type atomText struct {
+  Type string `xml:"type,attr"`
+  Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+  ID        string      `xml:"id"`
+  Title     atomText    `xml:"title"`
+  Links     []atomLink  `xml:"link"`
+  Author    *atomPerson `xml:"author"`
+  Published string      `xml:"published"`
+  Updated   string      `xml:"updated"`
+  Content   atomText    `xml:"content"`
+}
+
+// Atom writes the feed as an Atom 1.0 document (RFC 4287).
+func (f Feed) Atom() ([]byte, error) {
+  doc := atomFeed{
+    ID:       f.ID,
+    Title:    f.Title,
+    Subtitle: f.Description,
+    Updated:  atomTime(f.Updated),
+    Author:   atomAuthor(f.Author),
+  }
+  if f.SelfLink != "" {
+    doc.Links = append(doc.Links, atomLink{Rel: "self", Type: AtomMediaType, Href: f.SelfLink})
+  }
+  if f.Link != "" {
+    doc.Links = append(doc.Links, atomLink{Rel: "alternate", Href: f.Link})
+  }
+  for _, e := range f.Entries {
+    entry := atomEntry{
+      ID:        e.ID,
+      Title:     atomText{Type: "text", Body: e.Title},
+      Author:    atomAuthor(e.Author),
+      Published: atomTime(e.Published),
+      Updated:   atomTime(e.Updated),
+      Content:   atomText{Type: "text", Body: e.Content},
+    }
+    if e.Link != "" {
+      entry.Links = append(entry.Links, atomLink{Rel: "alternate", Href: e.Link})
+    }
+    doc.Entries = append(doc.Entries, entry)
+  }
+  return marshal(doc)
+}
+
+func atomAuthor(p *Person) *atomPerson {
+  if p == nil {
+    return nil
+  }
+  return &atomPerson{Name: p.Name, URI: p.URI}
+}
+
+// atomTime formats t as an RFC 3339 date-time, which is what Atom's date
+// constructs require.
+func atomTime(t time.Time) string {
+  return t.UTC().Format(time.RFC3339)
+}
+
+func marshal(doc any) ([]byte, error) {
+  out, err := xml.MarshalIndent(doc, "", "  ")
+  if err != nil {
+    return nil, err
+  }
+  return append([]byte(xml.Header), append(out, '\n')...), nil
+}
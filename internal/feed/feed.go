@@ -0,0 +1,66 @@
+package feed
+
+import (
+  "strings"
+  "time"
+  "unicode"
+  "unicode/utf8"
+)
+
+// Feed is a syndication feed that can be written out as Atom 1.0 or
+// RSS 2.0. Entries are expected newest first.
+type Feed struct {
+  // ID is the feed's permanent, unique identifier, an IRI.
+  ID          string
+  Title       string
+  Description string
+  // Link is the page the feed is for, and SelfLink where the feed itself
+  // is served.
+  Link     string
+  SelfLink string
+  // Author is the feed's author when every entry has the same one.
+  Author  *Person
+  Updated time.Time
+  Entries []Entry
+}
+
+type Person struct {
+  Name string
+  URI  string
+}
+
+type Entry struct {
+  // ID is the entry's permanent, unique identifier, an IRI.
+  ID      string
+  Title   string
+  Link    string
+  Content string
+  // Author is left out when the feed's author wrote the entry.
+  Author    *Person
+  Published time.Time
+  Updated   time.Time
+}
+
+// titleLength is how many runes of a post Title keeps.
+const titleLength = 80
+
+// Title makes an entry title from the text of a post that has none: its
+// first line, shortened at a word boundary if it is long.
+func Title(text string) string {
+  text = strings.TrimSpace(text)
+  if i := strings.IndexByte(text, '\n'); i >= 0 {
+    text = strings.TrimSpace(text[:i])
+  }
+  if utf8.RuneCountInString(text) <= titleLength {
+    return text
+  }
+  runes := []rune(text)
+  cut := titleLength - 1
+  for i := cut; i > titleLength/2; i-- {
+    if unicode.IsSpace(runes[i]) {
+      cut = i
+      break
+    }
+  }
+  return strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace) + "…"
+}
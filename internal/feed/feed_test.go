@@ -0,0 +1,265 @@
+package feed
+
+import (
+  "encoding/xml"
+  "strings"
+  "testing"
+  "time"
+)
+
+var (
+  published = time.Date(2024, 6, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+  updated   = published.Add(time.Hour)
+)
+
+func testFeed(content string) Feed {
+  return Feed{
+    ID:          "urn:uuid:3311741c-680c-4546-99f3-fc9efac2036c",
+    Title:       "Chirps by ana",
+    Description: "The latest chirps by ana",
+    Link:        "https://chirpy.test/users/ana",
+    SelfLink:    "https://chirpy.test/users/ana/feed.atom",
+    Author:      &Person{Name: "ana", URI: "https://chirpy.test/users/ana"},
+    Updated:     updated,
+    Entries: []Entry{{
+      ID:        "https://chirpy.test/chirps/1",
+      Title:     Title(content),
+      Link:      "https://chirpy.test/chirps/1",
+      Content:   content,
+      Published: published,
+      Updated:   updated,
+    }},
+  }
+}
+
+// parsedLink, parsedAtom and parsedRSS are what a feed reader would see.
+type parsedLink struct {
+  Rel  string `xml:"rel,attr"`
+  Type string `xml:"type,attr"`
+  Href string `xml:"href,attr"`
+}
+
+type parsedAtom struct {
+  XMLName xml.Name     `xml:"http://www.w3.org/2005/Atom feed"`
+  ID      string       `xml:"id"`
+  Updated string       `xml:"updated"`
+  Links   []parsedLink `xml:"link"`
+  Author  struct {
+    Name string `xml:"name"`
+  } `xml:"author"`
+  Entries []struct {
+    ID        string       `xml:"id"`
+    Title     string       `xml:"title"`
+    Links     []parsedLink `xml:"link"`
+    Published string       `xml:"published"`
+    Content   struct {
+      Type string `xml:"type,attr"`
+      Body string `xml:",chardata"`
+    } `xml:"content"`
+  } `xml:"entry"`
+}
+
+type parsedRSS struct {
+  XMLName xml.Name `xml:"rss"`
+  Version string   `xml:"version,attr"`
+  Channel struct {
+    // RSS's link and the atom:link borrowed from Atom have the same local
+    // name, so they are told apart by namespace
+    Links []struct {
+      XMLName xml.Name
+      parsedLink
+      Value string `xml:",chardata"`
+    } `xml:"link"`
+    LastBuildDate string `xml:"lastBuildDate"`
+    Items         []struct {
+      Title       string `xml:"title"`
+      Link        string `xml:"link"`
+      Description string `xml:"description"`
+      GUID        string `xml:"guid"`
+      PubDate     string `xml:"pubDate"`
+    } `xml:"item"`
+  } `xml:"channel"`
+}
+
+// escapeTests are chirp bodies that must come out of a feed reader as they
+// went in, without being read as markup.
+var escapeTests = []struct {
+  name    string
+  content string
+}{
+  {"plain", "hello world"},
+  {"markup", `<script>alert("hi")</script>`},
+  {"entities", "fish & chips &amp; more"},
+  {"cdata end", "]]> <![CDATA[ x"},
+  {"quotes", `she said "it's fine"`},
+}
+
+func TestAtomEscapesContent(t *testing.T) {
+  for _, tt := range escapeTests {
+    t.Run(tt.name, func(t *testing.T) {
+      out, err := testFeed(tt.content).Atom()
+      if err != nil {
+        t.Fatal(err)
+      }
+      if strings.Contains(string(out), "<script>") {
+        t.Errorf("raw markup in the document:\n%s", out)
+      }
+      var doc parsedAtom
+      err = xml.Unmarshal(out, &doc)
+      if err != nil {
+        t.Fatalf("%v\n%s", err, out)
+      }
+      entry := doc.Entries[0]
+      if entry.Content.Body != tt.content || entry.Content.Type != "text" {
+        t.Errorf("content = %q (%s), want %q as text", entry.Content.Body, entry.Content.Type, tt.content)
+      }
+      if entry.Title != tt.content {
+        t.Errorf("title = %q, want %q", entry.Title, tt.content)
+      }
+    })
+  }
+}
+
+func TestRSSEscapesContent(t *testing.T) {
+  for _, tt := range escapeTests {
+    t.Run(tt.name, func(t *testing.T) {
+      out, err := testFeed(tt.content).RSS()
+      if err != nil {
+        t.Fatal(err)
+      }
+      if strings.Contains(string(out), "<script>") {
+        t.Errorf("raw markup in the document:\n%s", out)
+      }
+      var doc parsedRSS
+      err = xml.Unmarshal(out, &doc)
+      if err != nil {
+        t.Fatalf("%v\n%s", err, out)
+      }
+      if got := doc.Channel.Items[0].Description; got != tt.content {
+        t.Errorf("description = %q, want %q", got, tt.content)
+      }
+    })
+  }
+}
+
+func TestAtomLinksAndDates(t *testing.T) {
+  f := testFeed("hello")
+  out, err := f.Atom()
+  if err != nil {
+    t.Fatal(err)
+  }
+  var doc parsedAtom
+  err = xml.Unmarshal(out, &doc)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  want := []parsedLink{
+    {Rel: "self", Type: AtomMediaType, Href: f.SelfLink},
+    {Rel: "alternate", Href: f.Link},
+  }
+  if len(doc.Links) != len(want) {
+    t.Fatalf("links = %+v, want %+v", doc.Links, want)
+  }
+  for i := range want {
+    if doc.Links[i] != want[i] {
+      t.Errorf("link %d = %+v, want %+v", i, doc.Links[i], want[i])
+    }
+  }
+  entryLinks := doc.Entries[0].Links
+  if len(entryLinks) != 1 || entryLinks[0] != (parsedLink{Rel: "alternate", Href: f.Entries[0].Link}) {
+    t.Errorf("entry links = %+v", entryLinks)
+  }
+
+  if doc.ID != f.ID || doc.Author.Name != "ana" {
+    t.Errorf("id = %q, author = %q", doc.ID, doc.Author.Name)
+  }
+  if doc.Updated != "2024-06-01T11:00:00Z" {
+    t.Errorf("updated = %q, want it in UTC", doc.Updated)
+  }
+  if got := doc.Entries[0].Published; got != "2024-06-01T10:00:00Z" {
+    t.Errorf("published = %q, want it in UTC", got)
+  }
+}
+
+func TestAtomLeavesOutMissingLinks(t *testing.T) {
+  f := testFeed("hello")
+  f.SelfLink, f.Link = "", ""
+  f.Entries[0].Link = ""
+  out, err := f.Atom()
+  if err != nil {
+    t.Fatal(err)
+  }
+  var doc parsedAtom
+  err = xml.Unmarshal(out, &doc)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(doc.Links) != 0 || len(doc.Entries[0].Links) != 0 {
+    t.Errorf("links = %+v, entry links = %+v; want none", doc.Links, doc.Entries[0].Links)
+  }
+}
+
+func TestRSSLinksAndDates(t *testing.T) {
+  f := testFeed("hello")
+  out, err := f.RSS()
+  if err != nil {
+    t.Fatal(err)
+  }
+  var doc parsedRSS
+  err = xml.Unmarshal(out, &doc)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if doc.Version != "2.0" {
+    t.Errorf("version = %q", doc.Version)
+  }
+  var link string
+  var self []parsedLink
+  for _, l := range doc.Channel.Links {
+    switch l.XMLName.Space {
+    case "":
+      link = l.Value
+    case atomNamespace:
+      self = append(self, l.parsedLink)
+    }
+  }
+  if link != f.Link {
+    t.Errorf("link = %q, want %q", link, f.Link)
+  }
+  if len(self) != 1 || self[0] != (parsedLink{Rel: "self", Type: RSSMediaType, Href: f.SelfLink}) {
+    t.Errorf("atom:link = %+v, want the self link", self)
+  }
+  item := doc.Channel.Items[0]
+  if item.Link != f.Entries[0].Link || item.GUID != f.Entries[0].ID {
+    t.Errorf("item link = %q, guid = %q", item.Link, item.GUID)
+  }
+  if doc.Channel.LastBuildDate != "Sat, 01 Jun 2024 11:00:00 +0000" {
+    t.Errorf("lastBuildDate = %q", doc.Channel.LastBuildDate)
+  }
+  if item.PubDate != "Sat, 01 Jun 2024 10:00:00 +0000" {
+    t.Errorf("pubDate = %q", item.PubDate)
+  }
+}
+
+func TestTitle(t *testing.T) {
+  long := strings.Repeat("word ", 30)
+  tests := []struct {
+    name string
+    text string
+    want string
+  }{
+    {"short", "  hello world  ", "hello world"},
+    {"first line only", "first line\nsecond line", "first line"},
+    {"cut at a word", long, strings.TrimSpace(strings.Repeat("word ", 16)) + "…"},
+    {"no spaces", strings.Repeat("x", 100), strings.Repeat("x", 79) + "…"},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      if got := Title(tt.text); got != tt.want {
+        t.Errorf("Title = %q, want %q", got, tt.want)
+      }
+    })
+  }
+}
@@ -0,0 +1,76 @@
+package feed
+
+import (
+  "encoding/xml"
+  "time"
+)
+
+const RSSMediaType = "application/rss+xml"
+
+type rssDocument struct {
+  XMLName xml.Name   `xml:"rss"`
+  Version string     `xml:"version,attr"`
+  AtomNS  string     `xml:"xmlns:atom,attr"`
+  Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+  Title         string    `xml:"title"`
+  Link          string    `xml:"link"`
+  Description   string    `xml:"description"`
+  LastBuildDate string    `xml:"lastBuildDate"`
+  SelfLink      *atomLink `xml:"atom:link"`
+  Items         []rssItem `xml:"item"`
+}
+
+type rssGUID struct {
+  IsPermaLink bool   `xml:"isPermaLink,attr"`
+  Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+  Title       string  `xml:"title"`
+  Link        string  `xml:"link,omitempty"`
+  Description string  `xml:"description"`
+  GUID        rssGUID `xml:"guid"`
+  PubDate     string  `xml:"pubDate"`
+}
+
+// RSS writes the feed as an RSS 2.0 document. RSS wants authors as email
+// addresses, so they are left out.
+func (f Feed) RSS() ([]byte, error) {
+  description := f.Description
+  if description == "" {
+    description = f.Title
+  }
+  doc := rssDocument{
+    Version: "2.0",
+    AtomNS:  atomNamespace,
+    Channel: rssChannel{
+      Title:         f.Title,
+      Link:          f.Link,
+      Description:   description,
+      LastBuildDate: rssTime(f.Updated),
+    },
+  }
+  // the channel's self link is borrowed from Atom, as RSS has none
+  if f.SelfLink != "" {
+    doc.Channel.SelfLink = &atomLink{Rel: "self", Type: RSSMediaType, Href: f.SelfLink}
+  }
+  for _, e := range f.Entries {
+    doc.Channel.Items = append(doc.Channel.Items, rssItem{
+      Title:       e.Title,
+      Link:        e.Link,
+      Description: e.Content,
+      GUID:        rssGUID{IsPermaLink: false, Value: e.ID},
+      PubDate:     rssTime(e.Published),
+    })
+  }
+  return marshal(doc)
+}
+
+// rssTime formats t as an RFC 822 date-time with a four-digit year, as
+// RSS 2.0 asks.
+func rssTime(t time.Time) string {
+  return t.UTC().Format(time.RFC1123Z)
+}
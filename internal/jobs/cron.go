@@ -0,0 +1,194 @@
+package jobs
+
+import (
+  "context"
+  "fmt"
+  "log"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// Spec is a parsed schedule: either a fixed interval or the five classic
+// cron fields.
+type Spec struct {
+  every time.Duration
+
+  minute, hour, dom, month, dow uint64
+  // day of month and day of week match if either does when both are
+  // restricted, as in cron
+  domStar, dowStar bool
+}
+
+var specAliases = map[string]string{
+  "@hourly":  "0 * * * *",
+  "@daily":   "0 0 * * *",
+  "@weekly":  "0 0 * * 0",
+  "@monthly": "0 0 1 * *",
+}
+
+// ParseSpec parses "@every <duration>", one of @hourly, @daily, @weekly and
+// @monthly, or "minute hour day-of-month month day-of-week" where each field
+// is *, a number, a range a-b, a step */n or a-b/n, or a comma separated
+// list of those. Times are in UTC.
+func ParseSpec(spec string) (Spec, error) {
+  spec = strings.TrimSpace(spec)
+  if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+    d, err := time.ParseDuration(strings.TrimSpace(rest))
+    if err != nil || d < time.Second {
+      return Spec{}, fmt.Errorf("invalid interval in %q", spec)
+    }
+    return Spec{every: d}, nil
+  }
+  if alias, ok := specAliases[spec]; ok {
+    spec = alias
+  }
+
+  fields := strings.Fields(spec)
+  if len(fields) != 5 {
+    return Spec{}, fmt.Errorf("invalid schedule %q: want 5 fields, got %d", spec, len(fields))
+  }
+  bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+  var sets [5]uint64
+  for i, field := range fields {
+    set, err := parseField(field, bounds[i][0], bounds[i][1])
+    if err != nil {
+      return Spec{}, fmt.Errorf("invalid schedule %q: %v", spec, err)
+    }
+    sets[i] = set
+  }
+  return Spec{
+    minute:  sets[0],
+    hour:    sets[1],
+    dom:     sets[2],
+    month:   sets[3],
+    dow:     sets[4],
+    domStar: fields[2] == "*",
+    dowStar: fields[4] == "*",
+  }, nil
+}
+
+func parseField(field string, min, max int) (uint64, error) {
+  var set uint64
+  for _, part := range strings.Split(field, ",") {
+    rng, stepStr, hasStep := strings.Cut(part, "/")
+    step := 1
+    if hasStep {
+      n, err := strconv.Atoi(stepStr)
+      if err != nil || n < 1 {
+        return 0, fmt.Errorf("bad step in %q", part)
+      }
+      step = n
+    }
+
+    lo, hi := min, max
+    if rng != "*" {
+      a, b, isRange := strings.Cut(rng, "-")
+      var err error
+      lo, err = strconv.Atoi(a)
+      if err != nil {
+        return 0, fmt.Errorf("bad value in %q", part)
+      }
+      hi = lo
+      if isRange {
+        hi, err = strconv.Atoi(b)
+        if err != nil {
+          return 0, fmt.Errorf("bad range in %q", part)
+        }
+      } else if hasStep {
+        hi = max
+      }
+    }
+    if lo < min || hi > max || lo > hi {
+      return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+    }
+    for v := lo; v <= hi; v += step {
+      set |= 1 << uint(v)
+    }
+  }
+  return set, nil
+}
+
+func (s Spec) matchesDay(t time.Time) bool {
+  dom := s.dom&(1<<uint(t.Day())) != 0
+  dow := s.dow&(1<<uint(t.Weekday())) != 0
+  if s.domStar || s.dowStar {
+    return dom && dow
+  }
+  return dom || dow
+}
+
+// Next returns the first time after t that the schedule fires, or the zero
+// time if it never does (e.g. February 30th).
+func (s Spec) Next(t time.Time) time.Time {
+  if s.every > 0 {
+    return t.Truncate(s.every).Add(s.every)
+  }
+
+  t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+  limit := t.AddDate(5, 0, 0)
+  for t.Before(limit) {
+    if s.month&(1<<uint(t.Month())) == 0 {
+      t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+      continue
+    }
+    if !s.matchesDay(t) {
+      t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+      continue
+    }
+    if s.hour&(1<<uint(t.Hour())) == 0 {
+      t = t.Truncate(time.Hour).Add(time.Hour)
+      continue
+    }
+    if s.minute&(1<<uint(t.Minute())) == 0 {
+      t = t.Add(time.Minute)
+      continue
+    }
+    return t
+  }
+  return time.Time{}
+}
+
+type schedule struct {
+  name string
+  spec Spec
+  kind string
+  args any
+}
+
+// Schedule enqueues a kind job with args every time spec fires. Each run is
+// enqueued with a unique key made of name and the run's time, so when
+// several instances share the queue the job still runs once per tick.
+// Schedules must be added before Run.
+func (q *Queue) Schedule(name, spec, kind string, args any) error {
+  parsed, err := ParseSpec(spec)
+  if err != nil {
+    return err
+  }
+  if _, ok := q.handlers[kind]; !ok {
+    return fmt.Errorf("no handler registered for %s", kind)
+  }
+  q.schedules = append(q.schedules, schedule{name, parsed, kind, args})
+  return nil
+}
+
+func (q *Queue) runSchedule(ctx context.Context, s schedule) {
+  for {
+    next := s.spec.Next(q.now())
+    if next.IsZero() {
+      log.Printf("Schedule %s never fires", s.name)
+      return
+    }
+    select {
+    case <-ctx.Done():
+      return
+    case <-time.After(time.Until(next)):
+    }
+
+    key := fmt.Sprintf("cron:%s@%d", s.name, next.Unix())
+    _, err := q.Enqueue(ctx, s.kind, s.args, UniqueKey(key), MaxAttempts(1))
+    if err != nil {
+      log.Printf("Error enqueueing scheduled job %s: %v", s.name, err)
+    }
+  }
+}
@@ -0,0 +1,311 @@
+package jobs
+
+import (
+  "context"
+  "database/sql"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "log"
+  "math"
+  "math/rand/v2"
+  "sync"
+  "time"
+  "chirpy/internal/database"
+  "github.com/google/uuid"
+)
+
+const (
+  StatusPending   = "pending"
+  StatusRunning   = "running"
+  StatusSucceeded = "succeeded"
+  StatusDead      = "dead"
+)
+
+const DefaultMaxAttempts = 10
+
+// finishTimeout bounds recording a job's outcome once its handler returns.
+const finishTimeout = 10 * time.Second
+
+// Job is a claimed job with its payload decoded into Args.
+type Job[T any] struct {
+  ID          uuid.UUID
+  Kind        string
+  Attempt     int32
+  MaxAttempts int32
+  Args        T
+}
+
+type handler func(ctx context.Context, job database.Job) error
+
+// Queue runs jobs stored in the jobs table. Any number of instances can
+// share the table: workers claim jobs with FOR UPDATE SKIP LOCKED, and a
+// claim is a lease, so a job whose worker died is picked up again once
+// Lease has passed.
+type Queue struct {
+  db        *database.Queries
+  now       func() time.Time
+  handlers  map[string]handler
+  schedules []schedule
+
+  Workers      int
+  PollInterval time.Duration
+  // how long a job may run before another worker can claim it again; it
+  // is also the deadline for the handler's context. Only the worker
+  // holding the lease can record the job's outcome.
+  Lease        time.Duration
+}
+
+func New(db *database.Queries) *Queue {
+  return &Queue{
+    db:           db,
+    now:          time.Now,
+    handlers:     map[string]handler{},
+    Workers:      4,
+    PollInterval: time.Second,
+    Lease:        5 * time.Minute,
+  }
+}
+
+// Register sets the handler for kind. Payloads are decoded from JSON into
+// T before fn is called. Handlers must be registered before Run.
+func Register[T any](q *Queue, kind string, fn func(ctx context.Context, job Job[T]) error) {
+  q.handlers[kind] = func(ctx context.Context, row database.Job) error {
+    var args T
+    err := json.Unmarshal(row.Payload, &args)
+    if err != nil {
+      return Permanent(fmt.Errorf("error decoding %s payload: %v", kind, err))
+    }
+    return fn(ctx, Job[T]{
+      ID:          row.ID,
+      Kind:        row.Kind,
+      Attempt:     row.Attempts,
+      MaxAttempts: row.MaxAttempts,
+      Args:        args,
+    })
+  }
+}
+
+type options struct {
+  runAt       time.Time
+  maxAttempts int32
+  uniqueKey   string
+}
+
+type Option func(*options)
+
+// RunAt delays the job until t.
+func RunAt(t time.Time) Option {
+  return func(o *options) { o.runAt = t }
+}
+
+func MaxAttempts(n int32) Option {
+  return func(o *options) { o.maxAttempts = n }
+}
+
+// UniqueKey drops the job if another job was already enqueued with key.
+func UniqueKey(key string) Option {
+  return func(o *options) { o.uniqueKey = key }
+}
+
+// Enqueue adds a job to the queue. It reports false if the job was dropped
+// because of its UniqueKey.
+func (q *Queue) Enqueue(ctx context.Context, kind string, args any, opts ...Option) (bool, error) {
+  return EnqueueWith(ctx, q.db, kind, args, opts...)
+}
+
+// EnqueueWith is Enqueue through db, which may be bound to a transaction
+// so the job is only queued if the transaction commits.
+func EnqueueWith(ctx context.Context, db *database.Queries, kind string, args any, opts ...Option) (bool, error) {
+  now := time.Now()
+  o := options{runAt: now, maxAttempts: DefaultMaxAttempts}
+  for _, opt := range opts {
+    opt(&o)
+  }
+
+  payload, err := json.Marshal(args)
+  if err != nil {
+    return false, fmt.Errorf("error encoding %s payload: %v", kind, err)
+  }
+  n, err := db.EnqueueJob(ctx, database.EnqueueJobParams{
+    ID:          uuid.New(),
+    CreatedAt:   now,
+    Kind:        kind,
+    Payload:     payload,
+    MaxAttempts: o.maxAttempts,
+    RunAt:       o.runAt,
+    UniqueKey:   sql.NullString{String: o.uniqueKey, Valid: o.uniqueKey != ""},
+  })
+  if err != nil {
+    return false, fmt.Errorf("error enqueueing %s job: %v", kind, err)
+  }
+  return n == 1, nil
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying: the job goes straight to the
+// dead state.
+func Permanent(err error) error {
+  return permanentError{err}
+}
+
+type retryError struct {
+  err  error
+  wait time.Duration
+}
+
+func (e retryError) Error() string { return e.err.Error() }
+func (e retryError) Unwrap() error { return e.err }
+
+// RetryAfter overrides the default backoff before the next attempt.
+func RetryAfter(err error, wait time.Duration) error {
+  return retryError{err, wait}
+}
+
+// Backoff returns the default wait before retrying after the given attempt:
+// 10 seconds doubled for every earlier attempt, capped at an hour, with up
+// to 20% jitter so failed jobs don't retry in lockstep.
+func Backoff(attempt int32) time.Duration {
+  wait := float64(10*time.Second) * math.Pow(2, float64(attempt-1))
+  if wait > float64(time.Hour) {
+    wait = float64(time.Hour)
+  }
+  return time.Duration(wait * (1 + 0.2*rand.Float64()))
+}
+
+func (q *Queue) kinds() []string {
+  kinds := make([]string, 0, len(q.handlers))
+  for kind := range q.handlers {
+    kinds = append(kinds, kind)
+  }
+  return kinds
+}
+
+// work claims and runs one job. It reports false if no job was due.
+func (q *Queue) work(ctx context.Context) (bool, error) {
+  now := q.now()
+  job, err := q.db.ClaimJob(ctx, database.ClaimJobParams{
+    LockedUntil: now.Add(q.Lease),
+    Now:         now,
+    Kinds:       q.kinds(),
+  })
+  if errors.Is(err, sql.ErrNoRows) || (err != nil && ctx.Err() != nil) {
+    return false, nil
+  }
+  if err != nil {
+    return false, fmt.Errorf("error claiming job: %v", err)
+  }
+
+  // a job that has started is allowed to finish even if ctx is cancelled
+  // for shutdown; the lease is its deadline
+  runCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), q.Lease)
+  defer cancel()
+  runErr := q.run(runCtx, job)
+
+  // the outcome is recorded apart from the handler's deadline, so a handler
+  // that succeeds just before its lease runs out isn't run again for want
+  // of a context to record it with
+  doneCtx, doneCancel := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+  defer doneCancel()
+  var n int64
+  if runErr == nil {
+    n, err = q.db.CompleteJob(doneCtx, database.CompleteJobParams{
+      ID:       job.ID,
+      Attempts: job.Attempts,
+    })
+    if err != nil {
+      return true, fmt.Errorf("error completing job %s: %v", job.ID, err)
+    }
+    if n == 0 {
+      log.Printf("Job %s (%s) succeeded after its lease ran out; another worker has it now", job.ID, job.Kind)
+    }
+    return true, nil
+  }
+
+  var permanent permanentError
+  lastError := sql.NullString{String: runErr.Error(), Valid: true}
+  if errors.As(runErr, &permanent) || job.Attempts >= job.MaxAttempts {
+    log.Printf("Job %s (%s) is dead after %d attempts: %v", job.ID, job.Kind, job.Attempts, runErr)
+    n, err = q.db.BuryJob(doneCtx, database.BuryJobParams{
+      ID:        job.ID,
+      LastError: lastError,
+      Attempts:  job.Attempts,
+    })
+  } else {
+    wait := Backoff(job.Attempts)
+    var retry retryError
+    if errors.As(runErr, &retry) {
+      wait = retry.wait
+    }
+    n, err = q.db.RetryJob(doneCtx, database.RetryJobParams{
+      ID:        job.ID,
+      RunAt:     q.now().Add(wait),
+      LastError: lastError,
+      Attempts:  job.Attempts,
+    })
+  }
+  if err != nil {
+    return true, fmt.Errorf("error recording failure of job %s: %v", job.ID, err)
+  }
+  if n == 0 {
+    log.Printf("Job %s (%s) failed after its lease ran out; another worker has it now: %v", job.ID, job.Kind, runErr)
+  }
+  return true, nil
+}
+
+// BuryExpired marks dead the jobs whose lease ran out on their last
+// attempt. Workers never claim them again, so without this they would sit
+// in running for good.
+func (q *Queue) BuryExpired(ctx context.Context) (int64, error) {
+  return q.db.BuryExpiredJobs(ctx, q.now())
+}
+
+func (q *Queue) run(ctx context.Context, job database.Job) (err error) {
+  defer func() {
+    if r := recover(); r != nil {
+      err = fmt.Errorf("panic: %v", r)
+    }
+  }()
+  return q.handlers[job.Kind](ctx, job)
+}
+
+// Run starts the workers and the cron scheduler and blocks until ctx is
+// cancelled and every job that was already running has finished.
+func (q *Queue) Run(ctx context.Context) {
+  var wg sync.WaitGroup
+  for i := 0; i < q.Workers; i++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      q.worker(ctx)
+    }()
+  }
+  for _, s := range q.schedules {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      q.runSchedule(ctx, s)
+    }()
+  }
+  wg.Wait()
+}
+
+func (q *Queue) worker(ctx context.Context) {
+  for ctx.Err() == nil {
+    worked, err := q.work(ctx)
+    if err != nil {
+      log.Println(err)
+    }
+    if worked && err == nil {
+      continue
+    }
+    select {
+    case <-ctx.Done():
+    case <-time.After(q.PollInterval):
+    }
+  }
+}
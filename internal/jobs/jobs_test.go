@@ -0,0 +1,266 @@
+package jobs
+
+import (
+  "context"
+  "crypto/rand"
+  "database/sql"
+  "encoding/hex"
+  "errors"
+  "os"
+  "strings"
+  "testing"
+  "time"
+  "chirpy/internal/database"
+  _ "github.com/lib/pq"
+)
+
+func TestBackoff(t *testing.T) {
+  tests := []struct {
+    attempt int32
+    base    time.Duration
+  }{
+    {1, 10 * time.Second},
+    {2, 20 * time.Second},
+    {4, 80 * time.Second},
+    {20, time.Hour},
+  }
+  for _, tt := range tests {
+    for i := 0; i < 20; i++ {
+      got := Backoff(tt.attempt)
+      if got < tt.base || got > tt.base+tt.base/5 {
+        t.Fatalf("Backoff(%d) = %s, want %s plus up to 20%%", tt.attempt, got, tt.base)
+      }
+    }
+  }
+}
+
+// testQueue returns a queue on a fresh jobs table in its own schema, and a
+// pointer to the clock it runs on. It needs TEST_DB_URL to point at a
+// Postgres database the test may create schemas in.
+func testQueue(t *testing.T) (*Queue, *time.Time) {
+  t.Helper()
+  url := os.Getenv("TEST_DB_URL")
+  if url == "" {
+    t.Skip("TEST_DB_URL not set")
+  }
+  db, err := sql.Open("postgres", url)
+  if err != nil {
+    t.Fatal(err)
+  }
+  t.Cleanup(func() { db.Close() })
+  // search_path is per connection
+  db.SetMaxOpenConns(1)
+
+  suffix := make([]byte, 4)
+  rand.Read(suffix)
+  schema := "jobs_test_" + hex.EncodeToString(suffix)
+  migration, err := os.ReadFile("../../sql/schema/013_jobs.sql")
+  if err != nil {
+    t.Fatal(err)
+  }
+  up, _, _ := strings.Cut(string(migration), "-- +goose Down")
+  for _, stmt := range []string{"CREATE SCHEMA " + schema, "SET search_path TO " + schema, up} {
+    _, err := db.Exec(stmt)
+    if err != nil {
+      t.Fatal(err)
+    }
+  }
+  t.Cleanup(func() { db.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+  clock := time.Now().Add(time.Second)
+  q := New(database.New(db))
+  q.now = func() time.Time { return clock }
+  return q, &clock
+}
+
+// onlyJob returns the one job in the table.
+func onlyJob(t *testing.T, q *Queue) database.Job {
+  t.Helper()
+  rows, err := q.db.ListJobs(context.Background(), database.ListJobsParams{RowLimit: 10})
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(rows) != 1 {
+    t.Fatalf("%d jobs, want 1", len(rows))
+  }
+  return rows[0]
+}
+
+func work(t *testing.T, q *Queue) bool {
+  t.Helper()
+  worked, err := q.work(context.Background())
+  if err != nil {
+    t.Fatal(err)
+  }
+  return worked
+}
+
+func TestRetryThenSucceed(t *testing.T) {
+  q, clock := testQueue(t)
+  Register(q, "test.flaky", func(ctx context.Context, job Job[struct{}]) error {
+    if job.Attempt < 3 {
+      return errors.New("boom")
+    }
+    return nil
+  })
+  _, err := q.Enqueue(context.Background(), "test.flaky", struct{}{}, MaxAttempts(3))
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  for attempt := int32(1); attempt <= 2; attempt++ {
+    if !work(t, q) {
+      t.Fatalf("attempt %d: no job was due", attempt)
+    }
+    job := onlyJob(t, q)
+    if job.Status != StatusPending || job.Attempts != attempt || job.LastError.String != "boom" {
+      t.Fatalf("after attempt %d: %+v, want pending with the error", attempt, job)
+    }
+    if !job.RunAt.After(*clock) {
+      t.Fatalf("after attempt %d: retry at %s, want after %s", attempt, job.RunAt, *clock)
+    }
+    if work(t, q) {
+      t.Fatalf("after attempt %d: retried before its backoff", attempt)
+    }
+    *clock = clock.Add(Backoff(attempt) * 2)
+  }
+
+  if !work(t, q) {
+    t.Fatal("attempt 3: no job was due")
+  }
+  job := onlyJob(t, q)
+  if job.Status != StatusSucceeded || job.Attempts != 3 || job.LastError.Valid {
+    t.Fatalf("after attempt 3: %+v, want succeeded", job)
+  }
+}
+
+func TestBuryAfterMaxAttempts(t *testing.T) {
+  q, clock := testQueue(t)
+  Register(q, "test.doomed", func(ctx context.Context, job Job[struct{}]) error {
+    return errors.New("boom")
+  })
+  _, err := q.Enqueue(context.Background(), "test.doomed", struct{}{}, MaxAttempts(2))
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  work(t, q)
+  *clock = clock.Add(time.Hour)
+  work(t, q)
+  job := onlyJob(t, q)
+  if job.Status != StatusDead || job.Attempts != 2 {
+    t.Fatalf("%+v, want dead after 2 attempts", job)
+  }
+  *clock = clock.Add(time.Hour)
+  if work(t, q) {
+    t.Fatal("a dead job was claimed again")
+  }
+}
+
+func TestPermanentErrorBuriesAtOnce(t *testing.T) {
+  q, _ := testQueue(t)
+  Register(q, "test.permanent", func(ctx context.Context, job Job[struct{}]) error {
+    return Permanent(errors.New("bad payload"))
+  })
+  _, err := q.Enqueue(context.Background(), "test.permanent", struct{}{})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  work(t, q)
+  job := onlyJob(t, q)
+  if job.Status != StatusDead || job.Attempts != 1 || job.LastError.String != "bad payload" {
+    t.Fatalf("%+v, want dead after 1 attempt", job)
+  }
+}
+
+func TestUniqueKey(t *testing.T) {
+  q, _ := testQueue(t)
+  Register(q, "test.once", func(ctx context.Context, job Job[struct{}]) error { return nil })
+  for i, want := range []bool{true, false} {
+    queued, err := q.Enqueue(context.Background(), "test.once", struct{}{}, UniqueKey("once"))
+    if err != nil {
+      t.Fatal(err)
+    }
+    if queued != want {
+      t.Errorf("Enqueue %d = %v, want %v", i+1, queued, want)
+    }
+  }
+}
+
+func TestExpiredLeaseCantFinish(t *testing.T) {
+  q, clock := testQueue(t)
+  ctx := context.Background()
+  _, err := q.Enqueue(ctx, "test.slow", struct{}{})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  claim := func() database.Job {
+    job, err := q.db.ClaimJob(ctx, database.ClaimJobParams{
+      LockedUntil: clock.Add(q.Lease),
+      Now:         *clock,
+      Kinds:       []string{"test.slow"},
+    })
+    if err != nil {
+      t.Fatal(err)
+    }
+    return job
+  }
+  first := claim()
+  *clock = clock.Add(q.Lease + time.Second)
+  second := claim()
+  if second.ID != first.ID || second.Attempts != 2 {
+    t.Fatalf("second claim = %+v, want job %s again", second, first.ID)
+  }
+
+  n, err := q.db.CompleteJob(ctx, database.CompleteJobParams{ID: first.ID, Attempts: first.Attempts})
+  if err != nil {
+    t.Fatal(err)
+  }
+  if n != 0 {
+    t.Error("the worker whose lease ran out completed the job")
+  }
+  n, err = q.db.CompleteJob(ctx, database.CompleteJobParams{ID: second.ID, Attempts: second.Attempts})
+  if err != nil {
+    t.Fatal(err)
+  }
+  if n != 1 {
+    t.Error("the worker holding the lease couldn't complete the job")
+  }
+}
+
+func TestExpiredLeaseOnLastAttemptIsBuried(t *testing.T) {
+  q, clock := testQueue(t)
+  ctx := context.Background()
+  _, err := q.Enqueue(ctx, "test.crash", struct{}{}, MaxAttempts(1))
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  claim := database.ClaimJobParams{
+    LockedUntil: clock.Add(q.Lease),
+    Now:         *clock,
+    Kinds:       []string{"test.crash"},
+  }
+  _, err = q.db.ClaimJob(ctx, claim)
+  if err != nil {
+    t.Fatal(err)
+  }
+  // the worker dies without recording anything
+  *clock = clock.Add(q.Lease + time.Second)
+  claim.LockedUntil, claim.Now = clock.Add(q.Lease), *clock
+  _, err = q.db.ClaimJob(ctx, claim)
+  if !errors.Is(err, sql.ErrNoRows) {
+    t.Fatalf("ClaimJob after the last attempt = %v, want no rows", err)
+  }
+
+  n, err := q.BuryExpired(ctx)
+  if err != nil {
+    t.Fatal(err)
+  }
+  job := onlyJob(t, q)
+  if n != 1 || job.Status != StatusDead || job.Attempts != 1 {
+    t.Fatalf("BuryExpired = %d, job %+v; want it dead after 1 attempt", n, job)
+  }
+}
@@ -0,0 +1,48 @@
+package mailer
+
+import (
+  "context"
+  "fmt"
+  "log"
+  "net/smtp"
+  "strings"
+)
+
+// Mailer sends plain-text email messages.
+type Mailer interface {
+  Send(ctx context.Context, to, subject, body string) error
+}
+
+// LogMailer writes messages to the standard logger instead of delivering
+// them. It is used when no SMTP server is configured.
+type LogMailer struct{}
+
+func (LogMailer) Send(ctx context.Context, to, subject, body string) error {
+  log.Printf("mail to=%s subject=%q\n%s", to, subject, body)
+  return nil
+}
+
+// SMTPMailer delivers messages through an SMTP relay.
+type SMTPMailer struct {
+  Addr  string
+  From  string
+  Auth  smtp.Auth
+}
+
+func (m SMTPMailer) Send(ctx context.Context, to, subject, body string) error {
+  if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+    return fmt.Errorf("invalid mail header")
+  }
+
+  msg := "From: " + m.From + "\r\n" +
+    "To: " + to + "\r\n" +
+    "Subject: " + subject + "\r\n" +
+    "Content-Type: text/plain; charset=utf-8\r\n" +
+    "\r\n" + body
+
+  err := smtp.SendMail(m.Addr, m.Auth, m.From, []string{to}, []byte(msg))
+  if err != nil {
+    return fmt.Errorf("error sending mail: %v", err)
+  }
+  return nil
+}
@@ -0,0 +1,173 @@
+package passwordpolicy
+
+import (
+  "bufio"
+  "crypto/sha1"
+  "encoding/hex"
+  "errors"
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+  "strconv"
+  "strings"
+)
+
+// BreachedCorpus answers k-anonymity range queries the same way the Have I
+// Been Pwned API does: given the first five hex characters of a password's
+// SHA-1, it returns the remaining 35 characters of every breached hash with
+// that prefix and how often each was seen.
+type BreachedCorpus interface {
+  Range(prefix string) (map[string]int, error)
+}
+
+// BreachCount reports how many times password appears in the corpus. Only
+// the hash prefix is handed to the corpus.
+func BreachCount(c BreachedCorpus, password string) (int, error) {
+  sum := sha1.Sum([]byte(password))
+  hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+  suffixes, err := c.Range(hash[:5])
+  if err != nil {
+    return 0, fmt.Errorf("error querying breached passwords: %v", err)
+  }
+  return suffixes[hash[5:]], nil
+}
+
+// OpenCorpus opens an offline HIBP dump. A directory is read as one
+// <PREFIX>.txt file per range holding "SUFFIX:COUNT" lines; a file is read as
+// a single dump of "HASH:COUNT" lines sorted by hash.
+func OpenCorpus(path string) (BreachedCorpus, error) {
+  info, err := os.Stat(path)
+  if err != nil {
+    return nil, err
+  }
+  if info.IsDir() {
+    return RangeDir{Dir: path}, nil
+  }
+  return SortedFile{Path: path}, nil
+}
+
+// RangeDir is a directory of per-prefix range files, as written by the
+// official downloader when it is told not to merge them.
+type RangeDir struct {
+  Dir string
+}
+
+func (d RangeDir) Range(prefix string) (map[string]int, error) {
+  prefix = strings.ToUpper(prefix)
+  f, err := os.Open(filepath.Join(d.Dir, prefix+".txt"))
+  if errors.Is(err, os.ErrNotExist) {
+    return map[string]int{}, nil
+  }
+  if err != nil {
+    return nil, err
+  }
+  defer f.Close()
+
+  suffixes := make(map[string]int)
+  scanner := bufio.NewScanner(f)
+  for scanner.Scan() {
+    suffix, count, ok := parseRangeLine(scanner.Text())
+    if ok {
+      suffixes[suffix] = count
+    }
+  }
+  return suffixes, scanner.Err()
+}
+
+// SortedFile is a single sorted dump of full hashes. Ranges are found by
+// binary searching the file, so it never has to be loaded into memory.
+type SortedFile struct {
+  Path string
+}
+
+func (s SortedFile) Range(prefix string) (map[string]int, error) {
+  prefix = strings.ToUpper(prefix)
+  f, err := os.Open(s.Path)
+  if err != nil {
+    return nil, err
+  }
+  defer f.Close()
+
+  info, err := f.Stat()
+  if err != nil {
+    return nil, err
+  }
+
+  // find the first line whose hash sorts at or after prefix
+  lo, hi := int64(0), info.Size()
+  for lo < hi {
+    mid := lo + (hi-lo)/2
+    line, _, err := lineAfter(f, mid)
+    if err != nil && !errors.Is(err, io.EOF) {
+      return nil, err
+    }
+    if errors.Is(err, io.EOF) && line == "" || strings.ToUpper(line) >= prefix {
+      hi = mid
+    } else {
+      lo = mid + 1
+    }
+  }
+
+  _, start, err := lineAfter(f, lo)
+  if err != nil && !errors.Is(err, io.EOF) {
+    return nil, err
+  }
+  _, err = f.Seek(start, io.SeekStart)
+  if err != nil {
+    return nil, err
+  }
+
+  suffixes := make(map[string]int)
+  scanner := bufio.NewScanner(f)
+  for scanner.Scan() {
+    line := strings.ToUpper(scanner.Text())
+    if !strings.HasPrefix(line, prefix) {
+      break
+    }
+    suffix, count, ok := parseRangeLine(line[len(prefix):])
+    if ok {
+      suffixes[suffix] = count
+    }
+  }
+  return suffixes, scanner.Err()
+}
+
+// lineAfter returns the first complete line starting at or after offset,
+// along with the offset it starts at.
+func lineAfter(f *os.File, offset int64) (string, int64, error) {
+  start := offset
+  if offset > 0 {
+    start = offset - 1
+  }
+  _, err := f.Seek(start, io.SeekStart)
+  if err != nil {
+    return "", 0, err
+  }
+
+  r := bufio.NewReader(f)
+  if offset > 0 {
+    // skip the rest of the line offset-1 belongs to
+    skipped, err := r.ReadString('\n')
+    if err != nil {
+      return "", offset, err
+    }
+    start += int64(len(skipped))
+  }
+
+  line, err := r.ReadString('\n')
+  return strings.TrimRight(line, "\r\n"), start, err
+}
+
+func parseRangeLine(line string) (string, int, bool) {
+  suffix, countStr, ok := strings.Cut(strings.TrimSpace(line), ":")
+  if !ok {
+    return "", 0, false
+  }
+  count, err := strconv.Atoi(countStr)
+  if err != nil {
+    return "", 0, false
+  }
+  return strings.ToUpper(suffix), count, true
+}
@@ -0,0 +1,38 @@
+package passwordpolicy
+
+// commonPasswords lists frequently used passwords and words, most common
+// first. A match is only as strong as its rank.
+var commonPasswords = []string{
+  "password", "123456", "123456789", "12345678", "12345", "qwerty", "abc123",
+  "football", "1234567", "monkey", "111111", "letmein", "1234", "1234567890",
+  "dragon", "baseball", "sunshine", "iloveyou", "trustno1", "princess",
+  "adobe123", "123123", "welcome", "login", "admin", "qwerty123", "solo",
+  "1q2w3e4r", "master", "666666", "photoshop", "1qaz2wsx", "qwertyuiop",
+  "ashley", "mustang", "121212", "starwars", "654321", "bailey", "access",
+  "flower", "555555", "passw0rd", "shadow", "lovely", "7777777", "michael",
+  "!@#$%^&*", "jesus", "password1", "superman", "hello", "charlie", "888888",
+  "696969", "hottie", "freedom", "aa123456", "qazwsx", "ninja", "azerty",
+  "loveme", "whatever", "donald", "batman", "zaq1zaq1", "000000", "123qwe",
+  "killer", "jordan", "jennifer", "hunter", "buster", "soccer", "harley",
+  "andrew", "tigger", "robert", "thomas", "hockey", "ranger", "daniel",
+  "klaster", "112233", "george", "computer", "michelle",
+  "jessica", "pepper", "zxcvbnm", "131313", "summer",
+  "winter", "spring", "autumn", "secret", "chirpy", "chirp", "twitter",
+  "cookie", "cheese", "orange", "banana", "purple", "silver", "golden",
+  "matrix", "pokemon", "maggie", "ginger", "joshua", "amanda", "nicole",
+  "corvette", "mercedes", "ferrari", "yankees", "cowboys", "eagles",
+  "dolphins", "lakers", "love", "god", "sex", "money", "test", "guest",
+  "default", "changeme", "letmein1", "welcome1", "password123", "abcdef",
+  "abcd1234", "iloveu", "asdfgh", "asdf", "zxcvb", "qwert", "monday",
+  "friday", "january", "december", "america", "london", "paris", "berlin",
+}
+
+var commonRanks = func() map[string]int {
+  ranks := make(map[string]int, len(commonPasswords))
+  for i, p := range commonPasswords {
+    if _, ok := ranks[p]; !ok {
+      ranks[p] = i + 1
+    }
+  }
+  return ranks
+}()
@@ -0,0 +1,115 @@
+package passwordpolicy
+
+import (
+  "fmt"
+  "strings"
+  "unicode/utf8"
+)
+
+// Violation is a single reason a password was rejected.
+type Violation struct {
+  Code    string `json:"code"`
+  Message string `json:"message"`
+}
+
+const (
+  CodeTooShort   = "too_short"
+  CodeTooLong    = "too_long"
+  CodeTooWeak    = "too_weak"
+  CodeMatchEmail = "matches_email"
+  CodeBreached   = "breached"
+)
+
+// Policy decides whether a password is acceptable.
+type Policy struct {
+  MinLength   int
+  MaxLength   int
+  // lowest acceptable Strength score, from 0 to 4
+  MinStrength int
+  // optional; when nil breached passwords are not screened
+  Breached    BreachedCorpus
+}
+
+var DefaultPolicy = Policy{
+  MinLength:   8,
+  MaxLength:   256,
+  MinStrength: 2,
+}
+
+// Check returns every rule the password breaks, or nil when it is
+// acceptable. email is the address of the account the password is for.
+func (p Policy) Check(password, email string) ([]Violation, error) {
+  var violations []Violation
+
+  length := utf8.RuneCountInString(password)
+  if length < p.MinLength {
+    violations = append(violations, Violation{
+      Code:    CodeTooShort,
+      Message: fmt.Sprintf("Password must be at least %d characters long", p.MinLength),
+    })
+  }
+  if p.MaxLength > 0 && length > p.MaxLength {
+    violations = append(violations, Violation{
+      Code:    CodeTooLong,
+      Message: fmt.Sprintf("Password must be at most %d characters long", p.MaxLength),
+    })
+    // scoring is expensive, and nothing else matters once it is too long
+    return violations, nil
+  }
+
+  if matchesEmail(password, email) {
+    violations = append(violations, Violation{
+      Code:    CodeMatchEmail,
+      Message: "Password must not be the same as your email address",
+    })
+  }
+
+  if password != "" && Strength(password, emailInputs(email)...) < p.MinStrength {
+    violations = append(violations, Violation{
+      Code:    CodeTooWeak,
+      Message: "Password is too easy to guess; try a longer passphrase or fewer common words",
+    })
+  }
+
+  if p.Breached != nil && password != "" {
+    count, err := BreachCount(p.Breached, password)
+    if err != nil {
+      return nil, err
+    }
+    if count > 0 {
+      violations = append(violations, Violation{
+        Code:    CodeBreached,
+        Message: "Password has appeared in a data breach; please choose another one",
+      })
+    }
+  }
+
+  return violations, nil
+}
+
+func matchesEmail(password, email string) bool {
+  if email == "" {
+    return false
+  }
+  password = strings.ToLower(strings.TrimSpace(password))
+  email = strings.ToLower(strings.TrimSpace(email))
+  local, _, _ := strings.Cut(email, "@")
+  return password == email || password == local
+}
+
+// emailInputs are the parts of an email address an attacker would try first.
+func emailInputs(email string) []string {
+  if email == "" {
+    return nil
+  }
+  email = strings.ToLower(email)
+  local, domain, _ := strings.Cut(email, "@")
+  inputs := []string{email, local}
+  inputs = append(inputs, strings.FieldsFunc(local, func(r rune) bool {
+    return r == '.' || r == '_' || r == '-' || r == '+'
+  })...)
+  if name, _, ok := strings.Cut(domain, "."); ok {
+    inputs = append(inputs, name)
+  }
+  return inputs
+}
@@ -0,0 +1,153 @@
+package passwordpolicy
+
+import (
+  "crypto/sha1"
+  "encoding/hex"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+)
+
+func codes(violations []Violation) []string {
+  var out []string
+  for _, v := range violations {
+    out = append(out, v.Code)
+  }
+  return out
+}
+
+func TestCheck(t *testing.T) {
+  tests := []struct {
+    name     string
+    password string
+    email    string
+    want     []string
+  }{
+    {"strong passphrase", "correct horse battery staple", "ana@example.com", nil},
+    {"too short", "x7#", "ana@example.com", []string{CodeTooShort, CodeTooWeak}},
+    {"common password", "password123", "ana@example.com", []string{CodeTooWeak}},
+    {"keyboard run", "qwertyuiop", "ana@example.com", []string{CodeTooWeak}},
+    {"same as email", "ana@example.com", "ana@example.com", []string{CodeMatchEmail, CodeTooWeak}},
+    {"same as local part", "Ana.Lopez", "ana.lopez@example.com", []string{CodeMatchEmail, CodeTooWeak}},
+    {"too long", strings.Repeat("a", 257), "ana@example.com", []string{CodeTooLong}},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      violations, err := DefaultPolicy.Check(tt.password, tt.email)
+      if err != nil {
+        t.Fatal(err)
+      }
+      got := codes(violations)
+      if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+        t.Errorf("Check(%q) = %v, want %v", tt.password, got, tt.want)
+      }
+    })
+  }
+}
+
+// TestCheckLongPasswordIsFast guards against scoring inputs long enough to
+// tie up the server: the estimator is only run on the first
+// maxScoredLength runes.
+func TestCheckLongPasswordIsFast(t *testing.T) {
+  policy := DefaultPolicy
+  policy.MaxLength = 0
+  start := time.Now()
+  _, err := policy.Check(strings.Repeat("ab1!", 10000), "")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if elapsed := time.Since(start); elapsed > 2*time.Second {
+    t.Errorf("Check took %s", elapsed)
+  }
+}
+
+func TestStrengthOrdering(t *testing.T) {
+  weak := Strength("password")
+  strong := Strength("tangerine-viaduct-82-oboe")
+  if weak >= strong {
+    t.Errorf("Strength(password) = %d, want less than a passphrase's %d", weak, strong)
+  }
+  if weak != 0 {
+    t.Errorf("Strength(password) = %d, want 0", weak)
+  }
+  if strong != 4 {
+    t.Errorf("Strength of a passphrase = %d, want 4", strong)
+  }
+}
+
+type mapCorpus map[string]map[string]int
+
+func (c mapCorpus) Range(prefix string) (map[string]int, error) {
+  return c[prefix], nil
+}
+
+func sha1Hex(s string) string {
+  sum := sha1.Sum([]byte(s))
+  return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func TestCheckBreached(t *testing.T) {
+  breached := "tangerine-viaduct-82-oboe"
+  hash := sha1Hex(breached)
+  policy := DefaultPolicy
+  policy.Breached = mapCorpus{hash[:5]: {hash[5:]: 3}}
+
+  violations, err := policy.Check(breached, "")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if got := codes(violations); len(got) != 1 || got[0] != CodeBreached {
+    t.Errorf("Check = %v, want [%s]", got, CodeBreached)
+  }
+
+  violations, err = policy.Check("correct horse battery staple", "")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(violations) != 0 {
+    t.Errorf("Check = %v, want none", codes(violations))
+  }
+}
+
+func TestOpenCorpus(t *testing.T) {
+  hash := sha1Hex("hunter2")
+  other := sha1Hex("letmein")
+
+  dir := t.TempDir()
+  err := os.WriteFile(filepath.Join(dir, hash[:5]+".txt"), []byte(hash[5:]+":42\r\n"), 0o644)
+  if err != nil {
+    t.Fatal(err)
+  }
+  lines := []string{hash + ":42", other + ":7"}
+  if other < hash {
+    lines[0], lines[1] = lines[1], lines[0]
+  }
+  file := filepath.Join(t.TempDir(), "pwned.txt")
+  err = os.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0o644)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  for _, path := range []string{dir, file} {
+    corpus, err := OpenCorpus(path)
+    if err != nil {
+      t.Fatal(err)
+    }
+    count, err := BreachCount(corpus, "hunter2")
+    if err != nil {
+      t.Fatal(err)
+    }
+    if count != 42 {
+      t.Errorf("%s: BreachCount(hunter2) = %d, want 42", path, count)
+    }
+    count, err = BreachCount(corpus, "correct horse battery staple")
+    if err != nil {
+      t.Fatal(err)
+    }
+    if count != 0 {
+      t.Errorf("%s: BreachCount of an unbreached password = %d, want 0", path, count)
+    }
+  }
+}
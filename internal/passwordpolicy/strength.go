@@ -0,0 +1,226 @@
+package passwordpolicy
+
+import (
+  "math"
+  "strings"
+  "unicode"
+)
+
+// Strength estimates how hard password is to guess, in the spirit of zxcvbn.
+// The password is split into the cheapest sequence of patterns (common
+// passwords and words, user inputs, repeats, sequences, keyboard runs, years
+// and brute-forced characters) and the guesses needed for each are
+// multiplied. The result is a score from 0 (too guessable) to 4 (very
+// unguessable) using zxcvbn's thresholds.
+func Strength(password string, userInputs ...string) int {
+  guesses := guessesLog10(password, userInputs)
+  switch {
+  case guesses < 3:
+    return 0
+  case guesses < 6:
+    return 1
+  case guesses < 8:
+    return 2
+  case guesses < 10:
+    return 3
+  default:
+    return 4
+  }
+}
+
+var keyboardRows = []string{
+  "`1234567890-=",
+  "qwertyuiop[]\\",
+  "asdfghjkl;'",
+  "zxcvbnm,./",
+}
+
+var leet = map[rune]rune{
+  '4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '1': 'i',
+  '!': 'i', '|': 'l', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z',
+}
+
+// maxScoredLength caps how much of a password is scored, as zxcvbn does:
+// matching is cubic in the length, and anything longer is strong anyway.
+const maxScoredLength = 100
+
+// guessesLog10 returns log10 of the estimated number of guesses.
+func guessesLog10(password string, userInputs []string) float64 {
+  runes := []rune(password)
+  if len(runes) > maxScoredLength {
+    runes = runes[:maxScoredLength]
+  }
+  n := len(runes)
+  if n == 0 {
+    return 0
+  }
+
+  dictionary := make(map[string]int, len(userInputs))
+  for i, in := range userInputs {
+    if len([]rune(in)) >= 3 {
+      dictionary[strings.ToLower(in)] = i + 1
+    }
+  }
+
+  // best[i] is the cheapest estimate for runes[:i]
+  best := make([]float64, n+1)
+  for i := 1; i <= n; i++ {
+    best[i] = math.Inf(1)
+  }
+
+  for i := 0; i < n; i++ {
+    if math.IsInf(best[i], 1) {
+      continue
+    }
+    relax(best, i+1, best[i]+math.Log10(cardinality(runes[i])))
+
+    for j := i + 3; j <= n; j++ {
+      if g, ok := patternGuessesLog10(runes[i:j], dictionary); ok {
+        relax(best, j, best[i]+g)
+      }
+    }
+  }
+
+  return best[n]
+}
+
+func relax(best []float64, i int, v float64) {
+  if v < best[i] {
+    best[i] = v
+  }
+}
+
+// patternGuessesLog10 returns the cheapest pattern that explains all of
+// token, if any does.
+func patternGuessesLog10(token []rune, userInputs map[string]int) (float64, bool) {
+  g := math.Inf(1)
+  if v, ok := dictionaryGuesses(token, userInputs); ok {
+    g = math.Min(g, v)
+  }
+  if isRepeat(token) {
+    g = math.Min(g, math.Log10(cardinality(token[0])*float64(len(token))))
+  }
+  if isSequence(token) {
+    base := 26.0
+    if token[0] == 'a' || token[0] == '1' || token[0] == 'z' || token[0] == '9' {
+      base = 4
+    }
+    g = math.Min(g, math.Log10(base*float64(len(token))))
+  }
+  if len(token) >= 4 && isKeyboardRun(token) {
+    g = math.Min(g, math.Log10(44*float64(len(token))))
+  }
+  if isYear(token) {
+    g = math.Min(g, math.Log10(130))
+  }
+  return g, !math.IsInf(g, 1)
+}
+
+func dictionaryGuesses(token []rune, userInputs map[string]int) (float64, bool) {
+  lower := strings.ToLower(string(token))
+  plain := unleet(lower)
+
+  rank, ok := userInputs[lower]
+  if !ok {
+    rank, ok = userInputs[plain]
+  }
+  if !ok {
+    rank, ok = commonRanks[lower]
+  }
+  if !ok {
+    rank, ok = commonRanks[plain]
+  }
+  if !ok {
+    return 0, false
+  }
+
+  g := math.Log10(float64(rank))
+  if lower != string(token) {
+    // capitalisation roughly doubles the work for common patterns
+    g += math.Log10(2)
+    if strings.ToUpper(string(token)) != string(token) && !unicode.IsUpper(token[0]) {
+      g += 1
+    }
+  }
+  if plain != lower {
+    g += math.Log10(2)
+  }
+  return g, true
+}
+
+func unleet(s string) string {
+  return strings.Map(func(r rune) rune {
+    if sub, ok := leet[r]; ok {
+      return sub
+    }
+    return r
+  }, s)
+}
+
+func isRepeat(token []rune) bool {
+  for _, r := range token[1:] {
+    if r != token[0] {
+      return false
+    }
+  }
+  return true
+}
+
+func isSequence(token []rune) bool {
+  delta := token[1] - token[0]
+  if delta != 1 && delta != -1 {
+    return false
+  }
+  for i := 2; i < len(token); i++ {
+    if token[i]-token[i-1] != delta {
+      return false
+    }
+  }
+  return true
+}
+
+func isKeyboardRun(token []rune) bool {
+  s := strings.ToLower(string(token))
+  for _, row := range keyboardRows {
+    if strings.Contains(row, s) || strings.Contains(reverse(row), s) {
+      return true
+    }
+  }
+  return false
+}
+
+func isYear(token []rune) bool {
+  if len(token) != 4 {
+    return false
+  }
+  for _, r := range token {
+    if r < '0' || r > '9' {
+      return false
+    }
+  }
+  s := string(token)
+  return s >= "1900" && s <= "2039"
+}
+
+func reverse(s string) string {
+  r := []rune(s)
+  for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+    r[i], r[j] = r[j], r[i]
+  }
+  return string(r)
+}
+
+func cardinality(r rune) float64 {
+  switch {
+  case r >= '0' && r <= '9':
+    return 10
+  case r >= 'a' && r <= 'z':
+    return 26
+  case r >= 'A' && r <= 'Z':
+    return 26
+  case r < 128:
+    return 33
+  default:
+    return 100
+  }
+}
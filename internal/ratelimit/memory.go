@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+  "context"
+  "math"
+  "sync"
+  "time"
+)
+
+type bucket struct {
+  tokens    float64
+  updatedAt time.Time
+  // the time at which the bucket will have refilled completely
+  fullAt    time.Time
+}
+
+// MemoryStore keeps buckets in process memory. Limits are not shared between
+// instances; use PostgresStore for that.
+type MemoryStore struct {
+  mu        sync.Mutex
+  buckets   map[string]*bucket
+  lastSweep time.Time
+  now       func() time.Time
+}
+
+const sweepInterval = time.Minute
+
+func NewMemoryStore() *MemoryStore {
+  return &MemoryStore{
+    buckets: make(map[string]*bucket),
+    now:     time.Now,
+  }
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, p Policy) (Result, error) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+
+  now := s.now()
+  s.sweep(now)
+
+  capacity := float64(p.Limit)
+  b, ok := s.buckets[key]
+  if !ok {
+    b = &bucket{tokens: capacity, updatedAt: now}
+    s.buckets[key] = b
+  }
+
+  elapsed := now.Sub(b.updatedAt).Seconds()
+  b.tokens = math.Min(capacity, b.tokens+elapsed*p.refillRate())
+  b.updatedAt = now
+
+  allowed := b.tokens >= 1
+  if allowed {
+    b.tokens--
+  }
+
+  res := newResult(p, b.tokens, allowed)
+  b.fullAt = now.Add(res.Reset)
+  return res, nil
+}
+
+// sweep drops buckets that have refilled completely, since a fresh bucket
+// behaves the same. Callers must hold s.mu.
+func (s *MemoryStore) sweep(now time.Time) {
+  if now.Sub(s.lastSweep) < sweepInterval {
+    return
+  }
+  s.lastSweep = now
+  for key, b := range s.buckets {
+    if !now.Before(b.fullAt) {
+      delete(s.buckets, key)
+    }
+  }
+}
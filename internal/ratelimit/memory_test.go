@@ -0,0 +1,145 @@
+package ratelimit
+
+import (
+  "context"
+  "net/http"
+  "testing"
+  "time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestStore() (*MemoryStore, *fakeClock) {
+  clock := &fakeClock{t: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+  s := NewMemoryStore()
+  s.now = clock.now
+  return s, clock
+}
+
+func take(t *testing.T, s *MemoryStore, key string, p Policy) Result {
+  t.Helper()
+  res, err := s.Take(context.Background(), key, p)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return res
+}
+
+func TestMemoryStoreBurstThenReject(t *testing.T) {
+  s, _ := newTestStore()
+  p := Policy{Name: "test", Limit: 3, Window: time.Minute}
+
+  for i := 0; i < 3; i++ {
+    res := take(t, s, "k", p)
+    if !res.Allowed || res.Remaining != 2-i {
+      t.Fatalf("request %d = %+v, want allowed with %d remaining", i+1, res, 2-i)
+    }
+  }
+  res := take(t, s, "k", p)
+  if res.Allowed {
+    t.Fatalf("request 4 = %+v, want rejected", res)
+  }
+  // a token comes back every 20 seconds
+  if res.RetryAfter != 20*time.Second {
+    t.Errorf("RetryAfter = %s, want 20s", res.RetryAfter)
+  }
+}
+
+func TestMemoryStoreRefills(t *testing.T) {
+  s, clock := newTestStore()
+  p := Policy{Name: "test", Limit: 3, Window: time.Minute}
+  for i := 0; i < 3; i++ {
+    take(t, s, "k", p)
+  }
+
+  clock.advance(20 * time.Second)
+  if res := take(t, s, "k", p); !res.Allowed {
+    t.Fatalf("after one refill = %+v, want allowed", res)
+  }
+  if res := take(t, s, "k", p); res.Allowed {
+    t.Fatalf("second request after one refill = %+v, want rejected", res)
+  }
+
+  // never more than Limit, however long the bucket sits
+  clock.advance(time.Hour)
+  for i := 0; i < 3; i++ {
+    if res := take(t, s, "k", p); !res.Allowed {
+      t.Fatalf("request %d after an hour = %+v, want allowed", i+1, res)
+    }
+  }
+  if res := take(t, s, "k", p); res.Allowed {
+    t.Fatalf("request 4 after an hour = %+v, want rejected", res)
+  }
+}
+
+func TestMemoryStoreKeysAreSeparate(t *testing.T) {
+  s, _ := newTestStore()
+  p := Policy{Name: "test", Limit: 1, Window: time.Minute}
+
+  if res := take(t, s, "a", p); !res.Allowed {
+    t.Fatalf("a = %+v, want allowed", res)
+  }
+  if res := take(t, s, "b", p); !res.Allowed {
+    t.Fatalf("b = %+v, want allowed", res)
+  }
+  if res := take(t, s, "a", p); res.Allowed {
+    t.Fatalf("a again = %+v, want rejected", res)
+  }
+}
+
+func TestMemoryStoreSweepsFullBuckets(t *testing.T) {
+  s, clock := newTestStore()
+  p := Policy{Name: "test", Limit: 2, Window: time.Minute}
+  take(t, s, "a", p)
+
+  clock.advance(2 * time.Minute)
+  take(t, s, "b", p)
+  if _, ok := s.buckets["a"]; ok {
+    t.Error("bucket a is still kept after refilling")
+  }
+  if _, ok := s.buckets["b"]; !ok {
+    t.Error("bucket b was swept while in use")
+  }
+}
+
+func TestParsePolicy(t *testing.T) {
+  p, err := ParsePolicy("login", "5/1m")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if p != (Policy{Name: "login", Limit: 5, Window: time.Minute}) {
+    t.Errorf("ParsePolicy = %+v", p)
+  }
+
+  for _, spec := range []string{"", "5", "0/1m", "-1/1m", "x/1m", "5/0s", "5/soon"} {
+    if _, err := ParsePolicy("login", spec); err == nil {
+      t.Errorf("ParsePolicy(%q) succeeded", spec)
+    }
+  }
+}
+
+func TestWriteHeaders(t *testing.T) {
+  s, _ := newTestStore()
+  p := Policy{Name: "test", Limit: 1, Window: time.Minute}
+  take(t, s, "k", p)
+  res := take(t, s, "k", p)
+
+  h := http.Header{}
+  res.WriteHeaders(p, h)
+  want := map[string]string{
+    "RateLimit-Limit":     "1",
+    "RateLimit-Remaining": "0",
+    "RateLimit-Reset":     "60",
+    "RateLimit-Policy":    "1;w=60",
+    "Retry-After":         "60",
+  }
+  for name, value := range want {
+    if got := h.Get(name); got != value {
+      t.Errorf("%s = %q, want %q", name, got, value)
+    }
+  }
+}
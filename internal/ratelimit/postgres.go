@@ -0,0 +1,36 @@
+package ratelimit
+
+import (
+  "context"
+  "fmt"
+  "time"
+  "chirpy/internal/database"
+)
+
+// PostgresStore keeps buckets in the rate_limit_buckets table so that limits
+// hold across every instance sharing the database. Refills go by the
+// database's clock rather than the instances'.
+type PostgresStore struct {
+  db *database.Queries
+}
+
+func NewPostgresStore(db *database.Queries) *PostgresStore {
+  return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, p Policy) (Result, error) {
+  row, err := s.db.TakeRateLimitToken(ctx, database.TakeRateLimitTokenParams{
+    Key:        key,
+    Capacity:   float64(p.Limit),
+    RefillRate: p.refillRate(),
+  })
+  if err != nil {
+    return Result{}, fmt.Errorf("error taking rate limit token: %v", err)
+  }
+  return newResult(p, row.Tokens, row.Allowed), nil
+}
+
+// Prune deletes buckets that have not been touched since before.
+func (s *PostgresStore) Prune(ctx context.Context, before time.Time) error {
+  return s.db.DeleteStaleRateLimitBuckets(ctx, before)
+}
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+  "context"
+  "fmt"
+  "math"
+  "net/http"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// Policy allows Limit requests per Window. Requests are metered with a token
+// bucket, so a client may burst up to Limit requests at once and then earns
+// tokens back at Limit/Window per second.
+type Policy struct {
+  Name   string
+  Limit  int
+  Window time.Duration
+}
+
+func (p Policy) refillRate() float64 {
+  return float64(p.Limit) / p.Window.Seconds()
+}
+
+// ParsePolicy reads a policy written as "<limit>/<window>", e.g. "30/1m".
+func ParsePolicy(name, spec string) (Policy, error) {
+  limitStr, windowStr, ok := strings.Cut(spec, "/")
+  if !ok {
+    return Policy{}, fmt.Errorf("invalid rate limit %q: expected <limit>/<window>", spec)
+  }
+
+  limit, err := strconv.Atoi(strings.TrimSpace(limitStr))
+  if err != nil || limit <= 0 {
+    return Policy{}, fmt.Errorf("invalid rate limit %q: limit must be a positive integer", spec)
+  }
+
+  window, err := time.ParseDuration(strings.TrimSpace(windowStr))
+  if err != nil || window <= 0 {
+    return Policy{}, fmt.Errorf("invalid rate limit %q: window must be a positive duration", spec)
+  }
+
+  return Policy{Name: name, Limit: limit, Window: window}, nil
+}
+
+// Result describes the state of a bucket after a request was counted.
+type Result struct {
+  Allowed    bool
+  Limit      int
+  Remaining  int
+  // time until the bucket is full again
+  Reset      time.Duration
+  // time until the next request would be allowed; zero when Allowed
+  RetryAfter time.Duration
+}
+
+func newResult(p Policy, tokens float64, allowed bool) Result {
+  rate := p.refillRate()
+  res := Result{
+    Allowed:   allowed,
+    Limit:     p.Limit,
+    Remaining: int(math.Max(0, math.Floor(tokens))),
+    Reset:     time.Duration((float64(p.Limit) - tokens) / rate * float64(time.Second)),
+  }
+  if !allowed {
+    res.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+  }
+  return res
+}
+
+// WriteHeaders sets the RateLimit-* headers, and Retry-After when the
+// request was rejected.
+func (r Result) WriteHeaders(p Policy, h http.Header) {
+  h.Set("RateLimit-Limit", strconv.Itoa(r.Limit))
+  h.Set("RateLimit-Remaining", strconv.Itoa(r.Remaining))
+  h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(r.Reset)))
+  h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", p.Limit, ceilSeconds(p.Window)))
+  if !r.Allowed {
+    h.Set("Retry-After", strconv.Itoa(ceilSeconds(r.RetryAfter)))
+  }
+}
+
+func ceilSeconds(d time.Duration) int {
+  return int(math.Ceil(d.Seconds()))
+}
+
+// Store keeps token buckets and takes one token from the bucket for key on
+// every call.
+type Store interface {
+  Take(ctx context.Context, key string, p Policy) (Result, error)
+}
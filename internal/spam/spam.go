@@ -0,0 +1,157 @@
+package spam
+
+import (
+  "fmt"
+  "regexp"
+  "strings"
+  "time"
+  "unicode/utf8"
+)
+
+const (
+  VerdictAllow  = "allow"
+  VerdictHold   = "hold"
+  VerdictReject = "reject"
+)
+
+// Config holds the thresholds a chirp's score is compared against. A score
+// at or above HoldThreshold queues the chirp for moderation, and one at or
+// above RejectThreshold turns it away.
+type Config struct {
+  HoldThreshold   int
+  RejectThreshold int
+  // Window is how far back recent chirps and duplicates are counted.
+  Window time.Duration
+  // NewAccountAge is how old an account has to be before posting quickly
+  // stops counting against it.
+  NewAccountAge time.Duration
+}
+
+var DefaultConfig = Config{
+  HoldThreshold:   50,
+  RejectThreshold: 100,
+  Window:          10 * time.Minute,
+  NewAccountAge:   24 * time.Hour,
+}
+
+// Input is everything the scorer knows about a chirp as it is posted. The
+// caller gathers it, so scoring needs no database and logged inputs can be
+// scored again while tuning.
+type Input struct {
+  Body             string    `json:"body"`
+  PostedAt         time.Time `json:"posted_at"`
+  AccountCreatedAt time.Time `json:"account_created_at"`
+  // chirps the author posted within Window
+  RecentChirps int `json:"recent_chirps"`
+  // chirps with the same body within Window, by the author and by how many
+  // other accounts
+  DuplicatesByAuthor int `json:"duplicates_by_author"`
+  DuplicatesByOthers int `json:"duplicates_by_others"`
+}
+
+// Signal is one heuristic that added to a chirp's score.
+type Signal struct {
+  Name   string `json:"name"`
+  Score  int    `json:"score"`
+  Detail string `json:"detail"`
+}
+
+type Result struct {
+  Score   int      `json:"score"`
+  Verdict string   `json:"verdict"`
+  Signals []Signal `json:"signals"`
+}
+
+// minDuplicateLength keeps short replies like "same" or "lol" from counting
+// as a flood when lots of people post them.
+const minDuplicateLength = 10
+
+var (
+  linkPattern    = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+  mentionPattern = regexp.MustCompile(`@([A-Za-z0-9_]+)`)
+)
+
+// Score runs every heuristic over in and compares the total against the
+// thresholds.
+func (c Config) Score(in Input) Result {
+  var signals []Signal
+  add := func(name string, score int, detail string) {
+    if score > 0 {
+      signals = append(signals, Signal{Name: name, Score: score, Detail: detail})
+    }
+  }
+
+  add("duplicate_body", c.duplicateScore(in), fmt.Sprintf("%d by author, %d other accounts", in.DuplicatesByAuthor, in.DuplicatesByOthers))
+  links := linkPattern.FindAllString(in.Body, -1)
+  add("links", linkScore(in.Body, links), fmt.Sprintf("%d links", len(links)))
+  add("new_account_velocity", c.velocityScore(in), fmt.Sprintf("%d chirps in %s, account %s old", in.RecentChirps, c.Window, in.PostedAt.Sub(in.AccountCreatedAt).Round(time.Minute)))
+  mentions, repeats := countMentions(in.Body)
+  add("mentions", mentionScore(mentions, repeats), fmt.Sprintf("%d accounts mentioned, %d repeats", mentions, repeats))
+
+  result := Result{Verdict: VerdictAllow, Signals: signals}
+  for _, s := range signals {
+    result.Score += s.Score
+  }
+  switch {
+  case result.Score >= c.RejectThreshold:
+    result.Verdict = VerdictReject
+  case result.Score >= c.HoldThreshold:
+    result.Verdict = VerdictHold
+  }
+  return result
+}
+
+// duplicateScore scores the same body posted over and over, by the author
+// or across accounts.
+func (c Config) duplicateScore(in Input) int {
+  if utf8.RuneCountInString(strings.TrimSpace(in.Body)) < minDuplicateLength {
+    return 0
+  }
+  return min(30*in.DuplicatesByAuthor, 90) + min(10*in.DuplicatesByOthers, 60)
+}
+
+// linkScore lets one link through and scores each one after it, plus posts
+// that are mostly links.
+func linkScore(body string, links []string) int {
+  if len(links) == 0 {
+    return 0
+  }
+  score := 15 * (len(links) - 1)
+  linkLength := 0
+  for _, l := range links {
+    linkLength += len(l)
+  }
+  if linkLength*2 > len(strings.TrimSpace(body)) {
+    score += 20
+  }
+  return score
+}
+
+// velocityScore scores new accounts posting faster than people do.
+func (c Config) velocityScore(in Input) int {
+  if in.PostedAt.Sub(in.AccountCreatedAt) >= c.NewAccountAge || in.RecentChirps < 5 {
+    return 0
+  }
+  return min(10*(in.RecentChirps-4), 60)
+}
+
+// countMentions returns how many accounts body mentions and how many
+// mentions repeat one already made.
+func countMentions(body string) (int, int) {
+  seen := map[string]bool{}
+  repeats := 0
+  for _, m := range mentionPattern.FindAllStringSubmatch(body, -1) {
+    name := strings.ToLower(m[1])
+    if seen[name] {
+      repeats++
+    }
+    seen[name] = true
+  }
+  return len(seen), repeats
+}
+
+// mentionScore lets a few mentions through and scores each one after them,
+// and pinging the same account twice.
+func mentionScore(mentions, repeats int) int {
+  return 10*max(mentions-3, 0) + 15*repeats
+}
@@ -0,0 +1,68 @@
+package spam
+
+import (
+  "bufio"
+  "encoding/json"
+  "os"
+  "strings"
+  "testing"
+)
+
+// TestCorpus scores the fixtures cmd/spamscore tunes against, so a change to
+// a heuristic that flips one of their verdicts fails here first.
+func TestCorpus(t *testing.T) {
+  f, err := os.Open("../../cmd/spamscore/corpus.jsonl")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer f.Close()
+
+  scanner := bufio.NewScanner(f)
+  fixtures := 0
+  for n := 1; scanner.Scan(); n++ {
+    line := strings.TrimSpace(scanner.Text())
+    if line == "" || strings.HasPrefix(line, "#") {
+      continue
+    }
+    var fixture struct {
+      Expect string `json:"expect"`
+      Input  Input  `json:"input"`
+    }
+    err := json.Unmarshal([]byte(line), &fixture)
+    if err != nil {
+      t.Fatalf("line %d: %v", n, err)
+    }
+    fixtures++
+
+    result := DefaultConfig.Score(fixture.Input)
+    if result.Verdict != fixture.Expect {
+      t.Errorf("line %d: %q scored %d (%s), want %s; signals %+v", n, fixture.Input.Body, result.Score, result.Verdict, fixture.Expect, result.Signals)
+    }
+  }
+  if err := scanner.Err(); err != nil {
+    t.Fatal(err)
+  }
+  if fixtures == 0 {
+    t.Fatal("corpus has no fixtures")
+  }
+}
+
+func TestScoreThresholds(t *testing.T) {
+  config := Config{HoldThreshold: 20, RejectThreshold: 40}
+  tests := []struct {
+    mentions string
+    want     string
+  }{
+    {"@a @b @c", VerdictAllow},
+    // a mention past the third and a repeat: 10 + 15
+    {"@a @b @c @d @a", VerdictHold},
+    // three past the third and two repeats: 30 + 30
+    {"@a @b @c @d @e @f @a @b", VerdictReject},
+  }
+  for _, tt := range tests {
+    result := config.Score(Input{Body: tt.mentions})
+    if result.Verdict != tt.want {
+      t.Errorf("Score(%q) = %d (%s), want %s", tt.mentions, result.Score, result.Verdict, tt.want)
+    }
+  }
+}
@@ -0,0 +1,195 @@
+package twitterarchive
+
+import (
+  "archive/zip"
+  "bytes"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "html"
+  "io"
+  "regexp"
+  "sort"
+  "strings"
+  "time"
+)
+
+// ErrNoTweets means the ZIP isn't a Twitter archive, or one without tweets.
+var ErrNoTweets = errors.New("no tweets.js found in archive")
+
+// Tweet is a tweet as it is imported: its text has had HTML entities
+// decoded and t.co links swapped back for the links that were posted.
+type Tweet struct {
+  ID        string
+  CreatedAt time.Time
+  Text      string
+  Retweet   bool
+}
+
+// tweetFiles matches data/tweets.js and the data/tweets-partN.js files large
+// archives are split into, and tweet.js from older archives. Lookalikes such
+// as deleted-tweets.js and tweet-headers.js don't hold the tweets' text.
+var tweetFiles = regexp.MustCompile(`(^|/)tweets?(-part\d+)?\.js$`)
+
+// createdAtLayout is how tweets.js writes created_at, for example
+// "Wed Oct 10 20:19:24 +0000 2018".
+const createdAtLayout = time.RubyDate
+
+// maxTweetsFileSize keeps one tweets.js from using up the worker's memory;
+// the biggest accounts' files run to a few hundred megabytes.
+const maxTweetsFileSize = 1 << 30
+
+// Files returns the archive's tweet files, or ErrNoTweets.
+func Files(zr *zip.Reader) ([]*zip.File, error) {
+  var files []*zip.File
+  for _, f := range zr.File {
+    if tweetFiles.MatchString(f.Name) {
+      files = append(files, f)
+    }
+  }
+  if len(files) == 0 {
+    return nil, ErrNoTweets
+  }
+  return files, nil
+}
+
+// ReadFile reads every tweet in the archive at path, oldest first.
+func ReadFile(path string) ([]Tweet, error) {
+  zr, err := zip.OpenReader(path)
+  if err != nil {
+    return nil, fmt.Errorf("error opening archive: %v", err)
+  }
+  defer zr.Close()
+  return Read(&zr.Reader)
+}
+
+// Read reads every tweet in zr, oldest first.
+func Read(zr *zip.Reader) ([]Tweet, error) {
+  files, err := Files(zr)
+  if err != nil {
+    return nil, err
+  }
+  var tweets []Tweet
+  for _, f := range files {
+    if f.UncompressedSize64 > maxTweetsFileSize {
+      return nil, fmt.Errorf("%s is too large", f.Name)
+    }
+    rc, err := f.Open()
+    if err != nil {
+      return nil, fmt.Errorf("error opening %s: %v", f.Name, err)
+    }
+    data, err := io.ReadAll(rc)
+    rc.Close()
+    if err != nil {
+      return nil, fmt.Errorf("error reading %s: %v", f.Name, err)
+    }
+    parsed, err := Parse(data)
+    if err != nil {
+      return nil, fmt.Errorf("error parsing %s: %v", f.Name, err)
+    }
+    tweets = append(tweets, parsed...)
+  }
+  sort.SliceStable(tweets, func(i, j int) bool {
+    return tweets[i].CreatedAt.Before(tweets[j].CreatedAt)
+  })
+  return tweets, nil
+}
+
+type rawURL struct {
+  URL         string `json:"url"`
+  ExpandedURL string `json:"expanded_url"`
+}
+
+type rawTweet struct {
+  ID        string `json:"id_str"`
+  CreatedAt string `json:"created_at"`
+  FullText  string `json:"full_text"`
+  Text      string `json:"text"`
+  Retweeted bool   `json:"retweeted"`
+  Entities  struct {
+    URLs  []rawURL `json:"urls"`
+    Media []rawURL `json:"media"`
+  } `json:"entities"`
+}
+
+// Parse reads the contents of a tweets.js file. It is a script assigning a
+// JSON array to a global, such as "window.YTD.tweets.part0 = [...]", whose
+// entries are either {"tweet": {...}} or, in older archives, the tweet
+// itself.
+func Parse(data []byte) ([]Tweet, error) {
+  start := bytes.IndexByte(data, '[')
+  if start < 0 {
+    return nil, errors.New("no tweet array")
+  }
+  var entries []json.RawMessage
+  err := json.Unmarshal(data[start:], &entries)
+  if err != nil {
+    return nil, err
+  }
+
+  tweets := make([]Tweet, 0, len(entries))
+  for i, entry := range entries {
+    var wrapped struct {
+      Tweet *rawTweet `json:"tweet"`
+    }
+    err := json.Unmarshal(entry, &wrapped)
+    if err != nil {
+      return nil, fmt.Errorf("tweet %d: %v", i, err)
+    }
+    raw := wrapped.Tweet
+    if raw == nil {
+      raw = &rawTweet{}
+      err = json.Unmarshal(entry, raw)
+      if err != nil {
+        return nil, fmt.Errorf("tweet %d: %v", i, err)
+      }
+    }
+    tweet, err := raw.convert()
+    if err != nil {
+      return nil, fmt.Errorf("tweet %d: %v", i, err)
+    }
+    tweets = append(tweets, tweet)
+  }
+  return tweets, nil
+}
+
+func (raw rawTweet) convert() (Tweet, error) {
+  if raw.ID == "" {
+    return Tweet{}, errors.New("missing id_str")
+  }
+  createdAt, err := time.Parse(createdAtLayout, raw.CreatedAt)
+  if err != nil {
+    return Tweet{}, fmt.Errorf("invalid created_at: %v", err)
+  }
+  text := raw.FullText
+  if text == "" {
+    text = raw.Text
+  }
+
+  // links go back to what was posted, and links to attached media, which
+  // isn't imported, are dropped
+  replacements := []string{}
+  for _, u := range raw.Entities.URLs {
+    if u.URL != "" && u.ExpandedURL != "" {
+      replacements = append(replacements, u.URL, u.ExpandedURL)
+    }
+  }
+  for _, m := range raw.Entities.Media {
+    if m.URL != "" {
+      replacements = append(replacements, m.URL, "")
+    }
+  }
+  if len(replacements) > 0 {
+    text = strings.NewReplacer(replacements...).Replace(text)
+  }
+  text = strings.TrimSpace(html.UnescapeString(text))
+
+  return Tweet{
+    ID:        raw.ID,
+    CreatedAt: createdAt.Local(),
+    Text:      text,
+    // archives rarely set retweeted on the user's own retweets, so the
+    // "RT @" prefix they are stored with is what gives them away
+    Retweet: raw.Retweeted || strings.HasPrefix(raw.FullText, "RT @"),
+  }, nil
+}
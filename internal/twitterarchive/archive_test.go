@@ -0,0 +1,200 @@
+package twitterarchive
+
+import (
+  "archive/zip"
+  "bytes"
+  "errors"
+  "strings"
+  "testing"
+  "time"
+  "unicode/utf8"
+)
+
+const tweetsJS = `window.YTD.tweets.part0 = [
+  {
+    "tweet" : {
+      "id_str" : "2",
+      "created_at" : "Thu Oct 11 08:00:00 +0000 2018",
+      "full_text" : "Reading https://t.co/abc &amp; thinking &lt;3 https://t.co/pic",
+      "entities" : {
+        "urls" : [ { "url" : "https://t.co/abc", "expanded_url" : "https://example.com/post" } ],
+        "media" : [ { "url" : "https://t.co/pic", "expanded_url" : "https://twitter.com/ana/status/2/photo/1" } ]
+      }
+    }
+  },
+  {
+    "tweet" : {
+      "id_str" : "3",
+      "created_at" : "Fri Oct 12 09:30:00 +0000 2018",
+      "full_text" : "RT @bob: something worth sharing"
+    }
+  }
+]`
+
+// olderTweetJS is the tweet.js of older archives, whose entries aren't
+// wrapped in {"tweet": ...}.
+const olderTweetJS = `window.YTD.tweet.part0 = [ {
+  "id_str" : "1",
+  "created_at" : "Wed Oct 10 20:19:24 +0000 2018",
+  "text" : "  hello world  ",
+  "retweeted" : false
+} ]`
+
+func TestParse(t *testing.T) {
+  tweets, err := Parse([]byte(tweetsJS))
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(tweets) != 2 {
+    t.Fatalf("%d tweets, want 2", len(tweets))
+  }
+
+  first := tweets[0]
+  if first.ID != "2" {
+    t.Errorf("ID = %q", first.ID)
+  }
+  if want := "Reading https://example.com/post & thinking <3"; first.Text != want {
+    t.Errorf("Text = %q, want %q", first.Text, want)
+  }
+  if want := time.Date(2018, 10, 11, 8, 0, 0, 0, time.UTC); !first.CreatedAt.Equal(want) {
+    t.Errorf("CreatedAt = %s, want %s", first.CreatedAt, want)
+  }
+  if first.CreatedAt.Location() != time.Local {
+    t.Errorf("CreatedAt is in %s, want local time", first.CreatedAt.Location())
+  }
+  if first.Retweet {
+    t.Error("an original tweet was taken for a retweet")
+  }
+  if !tweets[1].Retweet {
+    t.Error("an RT @ tweet wasn't taken for a retweet")
+  }
+}
+
+func TestParseOlderArchive(t *testing.T) {
+  tweets, err := Parse([]byte(olderTweetJS))
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(tweets) != 1 || tweets[0].ID != "1" || tweets[0].Text != "hello world" {
+    t.Errorf("tweets = %+v", tweets)
+  }
+}
+
+func TestParseRejects(t *testing.T) {
+  tests := []struct {
+    name string
+    data string
+  }{
+    {"no array", "window.YTD.tweets.part0 = {}"},
+    {"not JSON", "window.YTD.tweets.part0 = [ nope ]"},
+    {"missing id", `[ { "tweet" : { "created_at" : "Wed Oct 10 20:19:24 +0000 2018", "full_text" : "hi" } } ]`},
+    {"bad date", `[ { "tweet" : { "id_str" : "1", "created_at" : "yesterday", "full_text" : "hi" } } ]`},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      _, err := Parse([]byte(tt.data))
+      if err == nil {
+        t.Error("Parse succeeded")
+      }
+    })
+  }
+}
+
+func testArchive(t *testing.T, files map[string]string) *zip.Reader {
+  t.Helper()
+  var buf bytes.Buffer
+  zw := zip.NewWriter(&buf)
+  for name, content := range files {
+    w, err := zw.Create(name)
+    if err != nil {
+      t.Fatal(err)
+    }
+    w.Write([]byte(content))
+  }
+  err := zw.Close()
+  if err != nil {
+    t.Fatal(err)
+  }
+  zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+  if err != nil {
+    t.Fatal(err)
+  }
+  return zr
+}
+
+func TestRead(t *testing.T) {
+  zr := testArchive(t, map[string]string{
+    "data/tweets.js":         tweetsJS,
+    "data/tweets-part1.js":   olderTweetJS,
+    "data/deleted-tweets.js": `[ { "tweet" : { "id_str" : "99", "created_at" : "Wed Oct 10 20:19:24 +0000 2018", "full_text" : "deleted" } } ]`,
+    "data/tweet-headers.js":  `[]`,
+    "assets/js/something.js": `[]`,
+  })
+  tweets, err := Read(zr)
+  if err != nil {
+    t.Fatal(err)
+  }
+  var ids []string
+  for _, tweet := range tweets {
+    ids = append(ids, tweet.ID)
+  }
+  if got := strings.Join(ids, ","); got != "1,2,3" {
+    t.Errorf("tweet IDs = %s, want 1,2,3 oldest first", got)
+  }
+}
+
+func TestReadWithoutTweets(t *testing.T) {
+  zr := testArchive(t, map[string]string{"data/account.js": `[]`})
+  _, err := Read(zr)
+  if !errors.Is(err, ErrNoTweets) {
+    t.Errorf("Read = %v, want %v", err, ErrNoTweets)
+  }
+}
+
+func TestTruncate(t *testing.T) {
+  tests := []struct {
+    name string
+    text string
+    max  int
+    want string
+  }{
+    {"fits", "hello world", 11, "hello world"},
+    {"cut at a word", "hello wonderful world", 18, "hello wonderful…"},
+    {"word cut when the space is early", "hello wonderful world", 12, "hello wonde…"},
+    {"cut inside a long word", "supercalifragilistic", 10, "supercali…"},
+    {"counts runes, not bytes", "héllo wörld ünd mehr", 12, "héllo wörld…"},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      got := Truncate(tt.text, tt.max)
+      if got != tt.want {
+        t.Errorf("Truncate(%q, %d) = %q, want %q", tt.text, tt.max, got, tt.want)
+      }
+      if n := utf8.RuneCountInString(got); n > tt.max {
+        t.Errorf("Truncate(%q, %d) is %d runes long", tt.text, tt.max, n)
+      }
+    })
+  }
+}
+
+func TestSplit(t *testing.T) {
+  tests := []struct {
+    name string
+    text string
+    max  int
+    want []string
+  }{
+    {"fits", "  hello  ", 10, []string{"hello"}},
+    {"empty", "", 10, []string{""}},
+    {"at words", "one two three four", 9, []string{"one two", "three", "four"}},
+    {"inside a long word", "abcdefghijkl", 5, []string{"abcde", "fghij", "kl"}},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      got := Split(tt.text, tt.max)
+      if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+        t.Errorf("Split(%q, %d) = %q, want %q", tt.text, tt.max, got, tt.want)
+      }
+    })
+  }
+}
@@ -0,0 +1,50 @@
+package twitterarchive
+
+import (
+  "strings"
+  "unicode"
+  "unicode/utf8"
+)
+
+// Ellipsis ends text that Truncate shortened.
+const Ellipsis = "…"
+
+// Truncate shortens text to at most max runes, cutting at a word boundary
+// where there is one in the second half and marking the cut with Ellipsis.
+func Truncate(text string, max int) string {
+  if utf8.RuneCountInString(text) <= max {
+    return text
+  }
+  runes := []rune(text)
+  cut := breakPoint(runes, max-1)
+  return strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace) + Ellipsis
+}
+
+// Split breaks text into pieces of at most max runes, at word boundaries
+// where it can, to be posted one after another.
+func Split(text string, max int) []string {
+  runes := []rune(strings.TrimSpace(text))
+  var pieces []string
+  for len(runes) > max {
+    cut := breakPoint(runes, max)
+    piece := strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace)
+    pieces = append(pieces, piece)
+    runes = []rune(strings.TrimLeftFunc(string(runes[cut:]), unicode.IsSpace))
+  }
+  if len(runes) > 0 || len(pieces) == 0 {
+    pieces = append(pieces, string(runes))
+  }
+  return pieces
+}
+
+// breakPoint is where to cut runes so the first part holds at most max
+// runes: after the last space that fits, unless that would leave the part
+// less than half full, in which case the word is cut instead.
+func breakPoint(runes []rune, max int) int {
+  for i := max; i > max/2; i-- {
+    if unicode.IsSpace(runes[i]) {
+      return i
+    }
+  }
+  return max
+}
@@ -0,0 +1,48 @@
+package webhooks
+
+import (
+  "errors"
+  "net"
+  "net/http"
+  "syscall"
+  "time"
+)
+
+var errPrivateAddress = errors.New("webhook endpoints may not resolve to private addresses")
+
+// NewClient returns an HTTP client for delivering webhooks that refuses to
+// connect to loopback, private and link-local addresses, so endpoints can't
+// be pointed at Chirpy's own network.
+func NewClient() *http.Client {
+  dialer := &net.Dialer{
+    Timeout: 10 * time.Second,
+    Control: func(network, address string, c syscall.RawConn) error {
+      host, _, err := net.SplitHostPort(address)
+      if err != nil {
+        return err
+      }
+      ip := net.ParseIP(host)
+      if ip == nil || !publicIP(ip) {
+        return errPrivateAddress
+      }
+      return nil
+    },
+  }
+
+  transport := http.DefaultTransport.(*http.Transport).Clone()
+  transport.DialContext = dialer.DialContext
+  transport.Proxy = nil
+
+  return &http.Client{
+    Transport: transport,
+    Timeout:   15 * time.Second,
+    CheckRedirect: func(req *http.Request, via []*http.Request) error {
+      return http.ErrUseLastResponse
+    },
+  }
+}
+
+func publicIP(ip net.IP) bool {
+  return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+    ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast())
+}
@@ -0,0 +1,276 @@
+package webhooks
+
+import (
+  "bytes"
+  "context"
+  "database/sql"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io"
+  "log"
+  "math"
+  "net/http"
+  "time"
+  "chirpy/internal/auth"
+  "chirpy/internal/database"
+  "chirpy/internal/jobs"
+  "github.com/google/uuid"
+)
+
+const (
+  EventChirpCreated  = "chirp.created"
+  EventChirpDeleted  = "chirp.deleted"
+  EventFollowCreated = "follow.created"
+  EventUserUpgraded  = "user.upgraded"
+)
+
+// EventTypes lists every event an endpoint can subscribe to.
+// EventFollowCreated is published when an account on another server
+// follows one here.
+var EventTypes = []string{
+  EventChirpCreated,
+  EventChirpDeleted,
+  EventFollowCreated,
+  EventUserUpgraded,
+}
+
+func ValidEvent(event string) bool {
+  for _, e := range EventTypes {
+    if e == event {
+      return true
+    }
+  }
+  return false
+}
+
+const (
+  StatusPending   = "pending"
+  StatusDelivered = "delivered"
+  StatusDead      = "dead"
+)
+
+// Event is something that happened to UserID's account. It is delivered to
+// that user's endpoints and to every global endpoint subscribed to Type.
+type Event struct {
+  Type   string
+  UserID uuid.UUID
+  Data   any
+}
+
+type envelope struct {
+  ID        uuid.UUID `json:"id"`
+  Type      string    `json:"type"`
+  CreatedAt time.Time `json:"created_at"`
+  Data      any       `json:"data"`
+}
+
+// KindDeliver is the job that makes one attempt at a webhook delivery.
+const KindDeliver = "webhook.deliver"
+
+type deliverArgs struct {
+  DeliveryID uuid.UUID `json:"delivery_id"`
+}
+
+// Dispatcher records events in webhook_deliveries and sends them, signed,
+// to the subscribed endpoints through the job queue. Failed deliveries are
+// retried with exponential backoff until MaxAttempts, and an endpoint is
+// disabled once DisableAfter attempts in a row have failed.
+type Dispatcher struct {
+  db     *database.Queries
+  queue  *jobs.Queue
+  client *http.Client
+  now    func() time.Time
+
+  MaxAttempts  int32
+  DisableAfter int32
+  BaseBackoff  time.Duration
+  MaxBackoff   time.Duration
+}
+
+// NewDispatcher registers the delivery job on queue.
+func NewDispatcher(db *database.Queries, queue *jobs.Queue, client *http.Client) *Dispatcher {
+  d := &Dispatcher{
+    db:           db,
+    queue:        queue,
+    client:       client,
+    now:          time.Now,
+    MaxAttempts:  10,
+    DisableAfter: 20,
+    BaseBackoff:  30 * time.Second,
+    MaxBackoff:   6 * time.Hour,
+  }
+  jobs.Register(queue, KindDeliver, d.handleDeliver)
+  return d
+}
+
+// Publish queues one delivery per subscribed endpoint.
+func (d *Dispatcher) Publish(ctx context.Context, e Event) error {
+  endpoints, err := d.db.ListWebhookEndpointsForEvent(ctx, database.ListWebhookEndpointsForEventParams{
+    Event:  e.Type,
+    UserID: e.UserID,
+  })
+  if err != nil {
+    return fmt.Errorf("error listing webhook endpoints: %v", err)
+  }
+
+  now := d.now()
+  for _, endpoint := range endpoints {
+    id := uuid.New()
+    payload, err := json.Marshal(envelope{
+      ID:        id,
+      Type:      e.Type,
+      CreatedAt: now,
+      Data:      e.Data,
+    })
+    if err != nil {
+      return fmt.Errorf("error encoding webhook payload: %v", err)
+    }
+
+    err = d.db.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+      ID:         id,
+      CreatedAt:  now,
+      EndpointID: endpoint.ID,
+      Event:      e.Type,
+      Payload:    payload,
+    })
+    if err != nil {
+      return fmt.Errorf("error queueing webhook delivery: %v", err)
+    }
+    err = d.enqueue(ctx, id)
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+func (d *Dispatcher) enqueue(ctx context.Context, deliveryID uuid.UUID) error {
+  _, err := d.queue.Enqueue(ctx, KindDeliver, deliverArgs{DeliveryID: deliveryID}, jobs.MaxAttempts(d.MaxAttempts))
+  return err
+}
+
+// Resume queues the deliveries an endpoint missed while it was disabled.
+func (d *Dispatcher) Resume(ctx context.Context, endpointID uuid.UUID) error {
+  deliveries, err := d.db.ListPendingWebhookDeliveries(ctx, endpointID)
+  if err != nil {
+    return fmt.Errorf("error listing pending webhook deliveries: %v", err)
+  }
+  for _, delivery := range deliveries {
+    err := d.enqueue(ctx, delivery.ID)
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+func (d *Dispatcher) handleDeliver(ctx context.Context, job jobs.Job[deliverArgs]) error {
+  delivery, err := d.db.GetWebhookDelivery(ctx, job.Args.DeliveryID)
+  if errors.Is(err, sql.ErrNoRows) {
+    // the endpoint was deleted
+    return nil
+  }
+  if err != nil {
+    return err
+  }
+  if delivery.Status != StatusPending {
+    return nil
+  }
+  endpoint, err := d.db.GetWebhookEndpoint(ctx, delivery.EndpointID)
+  if err != nil {
+    return err
+  }
+  if !endpoint.Enabled {
+    // left pending; Resume queues it again when the endpoint is re-enabled
+    return nil
+  }
+
+  err = d.Deliver(ctx, endpoint, delivery)
+  if err != nil {
+    return jobs.RetryAfter(err, d.backoff(delivery.Attempts+1))
+  }
+  return nil
+}
+
+// Deliver makes one attempt at a delivery and records the outcome.
+func (d *Dispatcher) Deliver(ctx context.Context, endpoint database.WebhookEndpoint, delivery database.WebhookDelivery) error {
+  statusCode, sendErr := d.send(ctx, endpoint, delivery)
+  if sendErr == nil {
+    err := d.db.MarkWebhookDeliverySucceeded(ctx, database.MarkWebhookDeliverySucceededParams{
+      ID:             delivery.ID,
+      LastStatusCode: sql.NullInt32{Int32: int32(statusCode), Valid: true},
+      DeliveredAt:    sql.NullTime{Time: d.now(), Valid: true},
+    })
+    if err != nil {
+      return err
+    }
+    return d.db.RecordWebhookEndpointSuccess(ctx, endpoint.ID)
+  }
+
+  status := StatusPending
+  if delivery.Attempts+1 >= d.MaxAttempts {
+    status = StatusDead
+  }
+  err := d.db.MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+    ID:             delivery.ID,
+    Status:         status,
+    NextAttemptAt:  d.now().Add(d.backoff(delivery.Attempts + 1)),
+    LastStatusCode: sql.NullInt32{Int32: int32(statusCode), Valid: statusCode != 0},
+    LastError:      sql.NullString{String: sendErr.Error(), Valid: true},
+  })
+  if err != nil {
+    return err
+  }
+
+  enabled, err := d.db.RecordWebhookEndpointFailure(ctx, database.RecordWebhookEndpointFailureParams{
+    DisableAfter: d.DisableAfter,
+    ID:           endpoint.ID,
+  })
+  if err != nil {
+    return err
+  }
+  if !enabled && endpoint.Enabled {
+    log.Printf("Disabled webhook endpoint %s after %d consecutive failures", endpoint.ID, d.DisableAfter)
+  }
+  if status == StatusDead {
+    return jobs.Permanent(sendErr)
+  }
+  return sendErr
+}
+func (d *Dispatcher) send(ctx context.Context, endpoint database.WebhookEndpoint, delivery database.WebhookDelivery) (int, error) {
+  ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+  defer cancel()
+
+  req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.Url, bytes.NewReader(delivery.Payload))
+  if err != nil {
+    return 0, err
+  }
+  req.Header.Set("Content-Type", "application/json")
+  req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+  req.Header.Set("Chirpy-Event", delivery.Event)
+  req.Header.Set("Chirpy-Delivery", delivery.ID.String())
+  req.Header.Set("Chirpy-Signature", auth.SignWebhook(delivery.Payload, endpoint.Secret, d.now()))
+
+  resp, err := d.client.Do(req)
+  if err != nil {
+    return 0, err
+  }
+  defer resp.Body.Close()
+  io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+  if resp.StatusCode < 200 || resp.StatusCode > 299 {
+    return resp.StatusCode, fmt.Errorf("endpoint responded with %s", resp.Status)
+  }
+  return resp.StatusCode, nil
+}
+
+// backoff returns the wait before the given attempt: BaseBackoff doubled for
+// every earlier attempt, capped at MaxBackoff.
+func (d *Dispatcher) backoff(attempt int32) time.Duration {
+  wait := float64(d.BaseBackoff) * math.Pow(2, float64(attempt-1))
+  if wait > float64(d.MaxBackoff) {
+    return d.MaxBackoff
+  }
+  return time.Duration(wait)
+}
@@ -0,0 +1,157 @@
+package webhooks
+
+import (
+  "context"
+  "errors"
+  "io"
+  "net/http"
+  "net/http/httptest"
+  "testing"
+  "time"
+  "chirpy/internal/auth"
+  "chirpy/internal/database"
+  "github.com/google/uuid"
+)
+
+// receiver is an httptest endpoint that checks deliveries the way an
+// integrator would.
+type receiver struct {
+  *httptest.Server
+  secret string
+  status int
+
+  deliveries chan *http.Request
+  bodies     chan []byte
+}
+
+func newReceiver(t *testing.T, secret string, status int) *receiver {
+  t.Helper()
+  r := &receiver{
+    secret:     secret,
+    status:     status,
+    deliveries: make(chan *http.Request, 10),
+    bodies:     make(chan []byte, 10),
+  }
+  r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+    body, err := io.ReadAll(req.Body)
+    if err != nil {
+      http.Error(w, err.Error(), http.StatusBadRequest)
+      return
+    }
+    err = auth.VerifyWebhookSignature(req.Header.Get("Chirpy-Signature"), body, r.secret, 5*time.Minute, time.Now())
+    if err != nil {
+      http.Error(w, err.Error(), http.StatusUnauthorized)
+      return
+    }
+    r.deliveries <- req
+    r.bodies <- body
+    w.WriteHeader(r.status)
+  }))
+  t.Cleanup(r.Close)
+  return r
+}
+
+func newTestDispatcher(client *http.Client) *Dispatcher {
+  return &Dispatcher{
+    client:       client,
+    now:          time.Now,
+    MaxAttempts:  10,
+    DisableAfter: 20,
+    BaseBackoff:  30 * time.Second,
+    MaxBackoff:   6 * time.Hour,
+  }
+}
+
+func testDelivery() database.WebhookDelivery {
+  return database.WebhookDelivery{
+    ID:      uuid.New(),
+    Event:   EventChirpCreated,
+    Payload: []byte(`{"type":"chirp.created","data":{"body":"hello"}}`),
+  }
+}
+
+func TestSendSignsDelivery(t *testing.T) {
+  r := newReceiver(t, "whsec", http.StatusNoContent)
+  d := newTestDispatcher(r.Client())
+  endpoint := database.WebhookEndpoint{ID: uuid.New(), Url: r.URL, Secret: "whsec", Enabled: true}
+  delivery := testDelivery()
+
+  status, err := d.send(context.Background(), endpoint, delivery)
+  if err != nil {
+    t.Fatalf("send: %v", err)
+  }
+  if status != http.StatusNoContent {
+    t.Errorf("status = %d, want %d", status, http.StatusNoContent)
+  }
+
+  req := <-r.deliveries
+  body := <-r.bodies
+  if string(body) != string(delivery.Payload) {
+    t.Errorf("body = %s, want %s", body, delivery.Payload)
+  }
+  if got := req.Header.Get("Chirpy-Event"); got != EventChirpCreated {
+    t.Errorf("Chirpy-Event = %q, want %q", got, EventChirpCreated)
+  }
+  if got := req.Header.Get("Chirpy-Delivery"); got != delivery.ID.String() {
+    t.Errorf("Chirpy-Delivery = %q, want %q", got, delivery.ID)
+  }
+  if got := req.Header.Get("Content-Type"); got != "application/json" {
+    t.Errorf("Content-Type = %q", got)
+  }
+}
+
+func TestSendWrongSecretIsRejected(t *testing.T) {
+  r := newReceiver(t, "whsec", http.StatusNoContent)
+  d := newTestDispatcher(r.Client())
+  endpoint := database.WebhookEndpoint{ID: uuid.New(), Url: r.URL, Secret: "stale", Enabled: true}
+
+  status, err := d.send(context.Background(), endpoint, testDelivery())
+  if err == nil || status != http.StatusUnauthorized {
+    t.Errorf("send = %d, %v; want a 401 error", status, err)
+  }
+}
+
+func TestSendFailingEndpoint(t *testing.T) {
+  r := newReceiver(t, "whsec", http.StatusServiceUnavailable)
+  d := newTestDispatcher(r.Client())
+  endpoint := database.WebhookEndpoint{ID: uuid.New(), Url: r.URL, Secret: "whsec", Enabled: true}
+
+  status, err := d.send(context.Background(), endpoint, testDelivery())
+  if err == nil || status != http.StatusServiceUnavailable {
+    t.Errorf("send = %d, %v; want a 503 error", status, err)
+  }
+}
+
+func TestBackoff(t *testing.T) {
+  d := newTestDispatcher(nil)
+  tests := []struct {
+    attempt int32
+    want    time.Duration
+  }{
+    {1, 30 * time.Second},
+    {2, time.Minute},
+    {3, 2 * time.Minute},
+    {10, 4*time.Hour + 16*time.Minute},
+    {11, 6 * time.Hour},
+    {50, 6 * time.Hour},
+  }
+  for _, tt := range tests {
+    if got := d.backoff(tt.attempt); got != tt.want {
+      t.Errorf("backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+    }
+  }
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+  r := newReceiver(t, "whsec", http.StatusNoContent)
+  d := newTestDispatcher(NewClient())
+  endpoint := database.WebhookEndpoint{ID: uuid.New(), Url: r.URL, Secret: "whsec", Enabled: true}
+
+  _, err := d.send(context.Background(), endpoint, testDelivery())
+  if !errors.Is(err, errPrivateAddress) {
+    t.Errorf("send to %s = %v, want %v", r.URL, err, errPrivateAddress)
+  }
+  if len(r.deliveries) != 0 {
+    t.Error("the loopback endpoint received a delivery")
+  }
+}
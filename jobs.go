@@ -0,0 +1,193 @@
+package main
+
+import (
+  "context"
+  "database/sql"
+  "fmt"
+  "log"
+  "net/http"
+  "strconv"
+  "time"
+  "chirpy/internal/database"
+  "chirpy/internal/jobs"
+  "chirpy/internal/ratelimit"
+  "github.com/google/uuid"
+)
+
+const (
+  jobSendEmail           = "email.send"
+  jobCleanRefreshTokens  = "refresh_tokens.clean"
+  jobExpireSubscriptions = "subscriptions.expire"
+  jobPruneRateLimits     = "rate_limits.prune"
+  jobPruneJobs           = "jobs.prune"
+
+  // how long finished jobs are kept around for inspection
+  finishedJobRetention = 7 * 24 * time.Hour
+)
+
+type emailArgs struct {
+  To      string `json:"to"`
+  Subject string `json:"subject"`
+  Body    string `json:"body"`
+}
+
+type noArgs struct{}
+
+type cronJob struct {
+  name, spec, kind string
+}
+
+// registerJobs sets up the handlers and cron schedules for the background
+// work the API hands off to cfg.jobs.
+func (cfg *apiConfig) registerJobs() error {
+  jobs.Register(cfg.jobs, jobSendEmail, func(ctx context.Context, job jobs.Job[emailArgs]) error {
+    return cfg.mailer.Send(ctx, job.Args.To, job.Args.Subject, job.Args.Body)
+  })
+  jobs.Register(cfg.jobs, jobCleanRefreshTokens, func(ctx context.Context, job jobs.Job[noArgs]) error {
+    n, err := cfg.db.DeleteExpiredRefreshTokens(ctx, time.Now())
+    if err != nil {
+      return fmt.Errorf("error deleting expired refresh tokens: %v", err)
+    }
+    if n > 0 {
+      log.Printf("Deleted %d expired refresh tokens", n)
+    }
+    return nil
+  })
+  jobs.Register(cfg.jobs, jobExpireSubscriptions, func(ctx context.Context, job jobs.Job[noArgs]) error {
+    return cfg.expireLapsedSubscriptions(ctx)
+  })
+  jobs.Register(cfg.jobs, jobSendPasswordReset, cfg.handleSendPasswordResetJob)
+  jobs.Register(cfg.jobs, jobSendUnlockEmail, cfg.handleSendUnlockEmailJob)
+  jobs.Register(cfg.jobs, jobSendExportReady, cfg.handleSendExportReadyJob)
+  jobs.Register(cfg.jobs, jobPublishChirp, cfg.handlePublishChirpJob)
+  jobs.Register(cfg.jobs, jobFinalizePoll, cfg.handleFinalizePollJob)
+  jobs.Register(cfg.jobs, jobBuildExport, cfg.handleBuildExportJob)
+  jobs.Register(cfg.jobs, jobImportChirps, cfg.handleImportChirpsJob)
+  jobs.Register(cfg.jobs, jobFederate, cfg.handleFederateJob)
+  jobs.Register(cfg.jobs, jobDeliverActivity, cfg.handleDeliverActivityJob)
+  jobs.Register(cfg.jobs, jobPruneExports, func(ctx context.Context, job jobs.Job[noArgs]) error {
+    return cfg.pruneDataExports(ctx)
+  })
+  jobs.Register(cfg.jobs, jobPurgeAccounts, func(ctx context.Context, job jobs.Job[noArgs]) error {
+    return cfg.purgeDeletedAccounts(ctx)
+  })
+  jobs.Register(cfg.jobs, jobPruneJobs, func(ctx context.Context, job jobs.Job[noArgs]) error {
+    buried, err := cfg.jobs.BuryExpired(ctx)
+    if err != nil {
+      return err
+    }
+    if buried > 0 {
+      log.Printf("Buried %d jobs whose lease ran out on their last attempt", buried)
+    }
+    _, err = cfg.db.DeleteFinishedJobs(ctx, time.Now().Add(-finishedJobRetention))
+    return err
+  })
+
+  schedules := []cronJob{
+    {"clean-refresh-tokens", "@hourly", jobCleanRefreshTokens},
+    {"expire-subscriptions", "@every 5m", jobExpireSubscriptions},
+    {"prune-jobs", "30 3 * * *", jobPruneJobs},
+    {"prune-exports", "@hourly", jobPruneExports},
+    {"purge-accounts", "@hourly", jobPurgeAccounts},
+  }
+
+  // buckets only need pruning when they live in the database
+  if store, ok := cfg.rateLimitStore.(*ratelimit.PostgresStore); ok {
+    jobs.Register(cfg.jobs, jobPruneRateLimits, func(ctx context.Context, job jobs.Job[noArgs]) error {
+      return store.Prune(ctx, time.Now().Add(-24*time.Hour))
+    })
+    schedules = append(schedules, cronJob{"prune-rate-limits", "@hourly", jobPruneRateLimits})
+  }
+
+  for _, s := range schedules {
+    err := cfg.jobs.Schedule(s.name, s.spec, s.kind, noArgs{})
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// sendEmail queues an email so a slow or unavailable mail server doesn't hold
+// up the request; it is retried if sending fails. The body is kept in the
+// jobs table, so emails carrying tokens or signed links have their own jobs
+// that make them when they are sent.
+func (cfg *apiConfig) sendEmail(ctx context.Context, to, subject, body string) error {
+  _, err := cfg.jobs.Enqueue(ctx, jobSendEmail, emailArgs{
+    To:      to,
+    Subject: subject,
+    Body:    body,
+  }, jobs.MaxAttempts(5))
+  return err
+}
+
+// jobResponse leaves out the payload, since emails carry account details.
+type jobResponse struct {
+  ID          uuid.UUID `json:"id"`
+  CreatedAt   time.Time `json:"created_at"`
+  UpdatedAt   time.Time `json:"updated_at"`
+  Kind        string    `json:"kind"`
+  Status      string    `json:"status"`
+  Attempts    int32     `json:"attempts"`
+  MaxAttempts int32     `json:"max_attempts"`
+  RunAt       time.Time `json:"run_at"`
+  LastError   string    `json:"last_error,omitempty"`
+}
+
+func (cfg *apiConfig) handleListJobs(w http.ResponseWriter, r *http.Request) {
+  params := database.ListJobsParams{RowLimit: 50}
+  if status := r.URL.Query().Get("status"); status != "" {
+    params.Status = sql.NullString{String: status, Valid: true}
+  }
+  if limit := r.URL.Query().Get("limit"); limit != "" {
+    n, err := strconv.Atoi(limit)
+    if err != nil || n <= 0 || n > 500 {
+      respondWithError(w, http.StatusBadRequest, "Invalid limit", err)
+      return
+    }
+    params.RowLimit = int32(n)
+  }
+
+  rows, err := cfg.db.ListJobs(context.Background(), params)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error listing jobs", err)
+    return
+  }
+
+  response := make([]jobResponse, 0, len(rows))
+  for _, j := range rows {
+    response = append(response, jobResponse{
+      ID:          j.ID,
+      CreatedAt:   j.CreatedAt,
+      UpdatedAt:   j.UpdatedAt,
+      Kind:        j.Kind,
+      Status:      j.Status,
+      Attempts:    j.Attempts,
+      MaxAttempts: j.MaxAttempts,
+      RunAt:       j.RunAt,
+      LastError:   j.LastError.String,
+    })
+  }
+  respondWithJSON(w, http.StatusOK, response)
+}
+
+// handleRetryJob gives a dead job a fresh set of attempts.
+func (cfg *apiConfig) handleRetryJob(w http.ResponseWriter, r *http.Request) {
+  jobID, err := uuid.Parse(r.PathValue("jobID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid job ID", err)
+    return
+  }
+
+  updated, err := cfg.db.RequeueDeadJob(context.Background(), jobID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error retrying job", err)
+    return
+  }
+  if updated == 0 {
+    respondWithError(w, http.StatusNotFound, "Dead job not found", nil)
+    return
+  }
+
+  w.WriteHeader(http.StatusAccepted)
+}
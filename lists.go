@@ -0,0 +1,511 @@
+package main
+
+import (
+  "context"
+  "database/sql"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net/http"
+  "strings"
+  "time"
+  "unicode/utf8"
+  "chirpy/internal/database"
+  "github.com/google/uuid"
+)
+
+const (
+  maxListNameLength        = 25
+  maxListDescriptionLength = 100
+  maxListMembers           = 500
+)
+
+var errListNotFound = errors.New("list not found")
+
+type listParams struct {
+  Name        string `json:"name"`
+  Description string `json:"description"`
+  Private     bool   `json:"private"`
+}
+
+// validate trims the name and description, and returns a message for the
+// user if they can't be used.
+func (p *listParams) validate() string {
+  p.Name = strings.TrimSpace(p.Name)
+  p.Description = strings.TrimSpace(p.Description)
+  if p.Name == "" {
+    return "List name can't be empty"
+  }
+  if utf8.RuneCountInString(p.Name) > maxListNameLength {
+    return fmt.Sprintf("List name can be at most %d characters", maxListNameLength)
+  }
+  if utf8.RuneCountInString(p.Description) > maxListDescriptionLength {
+    return fmt.Sprintf("List description can be at most %d characters", maxListDescriptionLength)
+  }
+  return ""
+}
+
+type listResponse struct {
+  ID          uuid.UUID `json:"id"`
+  CreatedAt   time.Time `json:"created_at"`
+  UpdatedAt   time.Time `json:"updated_at"`
+  OwnerID     uuid.UUID `json:"owner_id"`
+  Name        string    `json:"name"`
+  Description string    `json:"description"`
+  Private     bool      `json:"private"`
+}
+
+func newListResponse(l database.List) listResponse {
+  return listResponse{
+    ID:          l.ID,
+    CreatedAt:   l.CreatedAt,
+    UpdatedAt:   l.UpdatedAt,
+    OwnerID:     l.OwnerID,
+    Name:        l.Name,
+    Description: l.Description,
+    Private:     l.IsPrivate,
+  }
+}
+
+func newListResponses(lists []database.List) []listResponse {
+  response := make([]listResponse, 0, len(lists))
+  for _, l := range lists {
+    response = append(response, newListResponse(l))
+  }
+  return response
+}
+
+// visibleList fetches a list viewer is allowed to see. Private lists only
+// exist for their owner, and a list whose owner blocked viewer looks the
+// same as one that doesn't exist.
+func (cfg *apiConfig) visibleList(ctx context.Context, listID uuid.UUID, viewer uuid.NullUUID) (database.List, error) {
+  list, err := cfg.db.GetList(ctx, listID)
+  if errors.Is(err, sql.ErrNoRows) {
+    return database.List{}, errListNotFound
+  }
+  if err != nil {
+    return database.List{}, fmt.Errorf("error fetching list: %v", err)
+  }
+  if viewer.Valid && viewer.UUID == list.OwnerID {
+    return list, nil
+  }
+  if list.IsPrivate {
+    return database.List{}, errListNotFound
+  }
+  blocked, err := cfg.isBlockedBy(ctx, viewer, list.OwnerID)
+  if err != nil {
+    return database.List{}, err
+  }
+  if blocked {
+    return database.List{}, errListNotFound
+  }
+  return list, nil
+}
+
+// respondWithListError maps errors from visibleList to a response.
+func respondWithListError(w http.ResponseWriter, err error) {
+  if errors.Is(err, errListNotFound) {
+    respondWithError(w, http.StatusNotFound, "List not found", nil)
+    return
+  }
+  respondWithError(w, http.StatusInternalServerError, "Error fetching list", err)
+}
+
+// ownedList parses the listID path value and fetches it if user owns it.
+func (cfg *apiConfig) ownedList(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (database.List, bool) {
+  listID, err := uuid.Parse(r.PathValue("listID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid list ID", err)
+    return database.List{}, false
+  }
+  list, err := cfg.db.GetList(context.Background(), listID)
+  if errors.Is(err, sql.ErrNoRows) || (err == nil && list.OwnerID != userID) {
+    respondWithError(w, http.StatusNotFound, "List not found", nil)
+    return database.List{}, false
+  }
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching list", err)
+    return database.List{}, false
+  }
+  return list, true
+}
+
+func (cfg *apiConfig) handleCreateList(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  var params listParams
+  err = json.NewDecoder(r.Body).Decode(&params)
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+    return
+  }
+  if msg := params.validate(); msg != "" {
+    respondWithError(w, http.StatusBadRequest, msg, nil)
+    return
+  }
+
+  list, err := cfg.db.CreateList(context.Background(), database.CreateListParams{
+    ID:          uuid.New(),
+    CreatedAt:   time.Now(),
+    OwnerID:     user.ID,
+    Name:        params.Name,
+    Description: params.Description,
+    IsPrivate:   params.Private,
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error creating list", err)
+    return
+  }
+
+  respondWithJSON(w, http.StatusCreated, newListResponse(list))
+}
+
+// handleListLists lists the lists the caller owns.
+func (cfg *apiConfig) handleListLists(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  lists, err := cfg.db.ListListsByOwner(context.Background(), user.ID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error listing lists", err)
+    return
+  }
+  respondWithJSON(w, http.StatusOK, newListResponses(lists))
+}
+
+func (cfg *apiConfig) handleGetList(w http.ResponseWriter, r *http.Request) {
+  listID, err := uuid.Parse(r.PathValue("listID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid list ID", err)
+    return
+  }
+
+  list, err := cfg.visibleList(context.Background(), listID, cfg.viewerID(r))
+  if err != nil {
+    respondWithListError(w, err)
+    return
+  }
+  respondWithJSON(w, http.StatusOK, newListResponse(list))
+}
+
+func (cfg *apiConfig) handleUpdateList(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+  list, ok := cfg.ownedList(w, r, user.ID)
+  if !ok {
+    return
+  }
+
+  var params listParams
+  err = json.NewDecoder(r.Body).Decode(&params)
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+    return
+  }
+  if msg := params.validate(); msg != "" {
+    respondWithError(w, http.StatusBadRequest, msg, nil)
+    return
+  }
+
+  list, err = cfg.db.UpdateList(context.Background(), database.UpdateListParams{
+    ID:          list.ID,
+    OwnerID:     user.ID,
+    Name:        params.Name,
+    Description: params.Description,
+    IsPrivate:   params.Private,
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error updating list", err)
+    return
+  }
+
+  respondWithJSON(w, http.StatusOK, newListResponse(list))
+}
+
+func (cfg *apiConfig) handleDeleteList(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+  listID, err := uuid.Parse(r.PathValue("listID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid list ID", err)
+    return
+  }
+
+  deleted, err := cfg.db.DeleteList(context.Background(), database.DeleteListParams{
+    ID:      listID,
+    OwnerID: user.ID,
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error deleting list", err)
+    return
+  }
+  if deleted == 0 {
+    respondWithError(w, http.StatusNotFound, "List not found", nil)
+    return
+  }
+
+  w.WriteHeader(http.StatusNoContent)
+}
+
+type listMemberResponse struct {
+  UserID  uuid.UUID `json:"user_id"`
+  AddedAt time.Time `json:"added_at"`
+}
+
+func (cfg *apiConfig) handleListListMembers(w http.ResponseWriter, r *http.Request) {
+  listID, err := uuid.Parse(r.PathValue("listID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid list ID", err)
+    return
+  }
+  list, err := cfg.visibleList(context.Background(), listID, cfg.viewerID(r))
+  if err != nil {
+    respondWithListError(w, err)
+    return
+  }
+
+  members, err := cfg.db.ListListMembers(context.Background(), list.ID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error listing members", err)
+    return
+  }
+
+  response := make([]listMemberResponse, 0, len(members))
+  for _, m := range members {
+    response = append(response, listMemberResponse{
+      UserID:  m.UserID,
+      AddedAt: m.CreatedAt,
+    })
+  }
+  respondWithJSON(w, http.StatusOK, response)
+}
+
+// handleAddListMember adds an account to one of the caller's lists. Accounts
+// that have blocked the caller can't be added.
+func (cfg *apiConfig) handleAddListMember(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+  list, ok := cfg.ownedList(w, r, user.ID)
+  if !ok {
+    return
+  }
+
+  var params struct {
+    UserID uuid.UUID `json:"user_id"`
+  }
+  err = json.NewDecoder(r.Body).Decode(&params)
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+    return
+  }
+  _, err = cfg.db.GetUserById(context.Background(), params.UserID)
+  if err != nil {
+    respondWithError(w, http.StatusNotFound, "User not found", nil)
+    return
+  }
+  blocked, err := cfg.isBlockedBy(context.Background(), uuid.NullUUID{UUID: user.ID, Valid: true}, params.UserID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error adding member", err)
+    return
+  }
+  if blocked {
+    respondWithError(w, http.StatusForbidden, "You can't add this user to a list", nil)
+    return
+  }
+
+  count, err := cfg.db.CountListMembers(context.Background(), list.ID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error adding member", err)
+    return
+  }
+  if count >= maxListMembers {
+    respondWithError(w, http.StatusConflict, fmt.Sprintf("Lists can have at most %d members", maxListMembers), nil)
+    return
+  }
+
+  _, err = cfg.db.AddListMember(context.Background(), database.AddListMemberParams{
+    ListID:    list.ID,
+    UserID:    params.UserID,
+    CreatedAt: time.Now(),
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error adding member", err)
+    return
+  }
+
+  w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleRemoveListMember(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+  list, ok := cfg.ownedList(w, r, user.ID)
+  if !ok {
+    return
+  }
+  memberID, err := uuid.Parse(r.PathValue("userID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+    return
+  }
+
+  removed, err := cfg.db.RemoveListMember(context.Background(), database.RemoveListMemberParams{
+    ListID: list.ID,
+    UserID: memberID,
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error removing member", err)
+    return
+  }
+  if removed == 0 {
+    respondWithError(w, http.StatusNotFound, "Member not found", nil)
+    return
+  }
+
+  w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetListChirps is a list's timeline: the chirps of all its members,
+// in the same shape and order as GET /api/chirps. Members who blocked the
+// caller are left out.
+func (cfg *apiConfig) handleGetListChirps(w http.ResponseWriter, r *http.Request) {
+  listID, err := uuid.Parse(r.PathValue("listID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid list ID", err)
+    return
+  }
+  viewer := cfg.viewerID(r)
+  list, err := cfg.visibleList(context.Background(), listID, viewer)
+  if err != nil {
+    respondWithListError(w, err)
+    return
+  }
+
+  members, err := cfg.db.GetListMemberIDs(context.Background(), list.ID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching list members", err)
+    return
+  }
+  blockers, err := cfg.blockersAmong(context.Background(), viewer, members)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching list members", err)
+    return
+  }
+  authors := make([]uuid.UUID, 0, len(members))
+  for _, id := range members {
+    if !blockers[id] {
+      authors = append(authors, id)
+    }
+  }
+
+  chirps, err := cfg.chirpsByAuthors(context.Background(), authors, r.URL.Query().Get("sort"))
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching chirps", err)
+    return
+  }
+  response, err := cfg.chirpResponses(context.Background(), chirps, viewer)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching chirps", err)
+    return
+  }
+  respondWithJSON(w, http.StatusOK, response)
+}
+
+// handleSubscribeList subscribes the caller to someone else's public list.
+func (cfg *apiConfig) handleSubscribeList(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+  listID, err := uuid.Parse(r.PathValue("listID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid list ID", err)
+    return
+  }
+  list, err := cfg.visibleList(context.Background(), listID, uuid.NullUUID{UUID: user.ID, Valid: true})
+  if err != nil {
+    respondWithListError(w, err)
+    return
+  }
+  if list.OwnerID == user.ID {
+    respondWithError(w, http.StatusBadRequest, "You can't subscribe to your own list", nil)
+    return
+  }
+
+  _, err = cfg.db.SubscribeToList(context.Background(), database.SubscribeToListParams{
+    ListID:    list.ID,
+    UserID:    user.ID,
+    CreatedAt: time.Now(),
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error subscribing to list", err)
+    return
+  }
+
+  w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleUnsubscribeList(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+  listID, err := uuid.Parse(r.PathValue("listID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid list ID", err)
+    return
+  }
+
+  removed, err := cfg.db.UnsubscribeFromList(context.Background(), database.UnsubscribeFromListParams{
+    ListID: listID,
+    UserID: user.ID,
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error unsubscribing from list", err)
+    return
+  }
+  if removed == 0 {
+    respondWithError(w, http.StatusNotFound, "Subscription not found", nil)
+    return
+  }
+
+  w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListSubscribedLists lists the public lists the caller subscribes to.
+// Lists their owner has since made private drop out.
+func (cfg *apiConfig) handleListSubscribedLists(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  lists, err := cfg.db.ListSubscribedLists(context.Background(), user.ID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error listing subscriptions", err)
+    return
+  }
+  respondWithJSON(w, http.StatusOK, newListResponses(lists))
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+  "context"
+  "database/sql"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "log"
+  "math"
+  "net"
+  "net/http"
+  "strconv"
+  "time"
+  "chirpy/internal/auth"
+  "chirpy/internal/database"
+  "chirpy/internal/jobs"
+  "github.com/google/uuid"
+)
+
+const (
+  lockoutScopeAccount = "account"
+  lockoutScopeIP      = "ip"
+
+  // failures older than this no longer count towards a lockout
+  loginFailureWindow      = 24 * time.Hour
+  accountLockoutThreshold = 5
+  ipLockoutThreshold      = 20
+  lockoutBaseDuration     = time.Minute
+  lockoutMaxDuration      = 12 * time.Hour
+
+  jobSendUnlockEmail = "email.unlock"
+)
+
+// lockoutDuration returns how long a subject stays locked after its
+// attempts-th consecutive failure. The lock doubles with every failure past
+// the threshold, up to lockoutMaxDuration.
+func lockoutDuration(attempts, threshold int32) time.Duration {
+  if attempts < threshold {
+    return 0
+  }
+  d := lockoutBaseDuration
+  for i := threshold; i < attempts; i++ {
+    d *= 2
+    if d >= lockoutMaxDuration {
+      return lockoutMaxDuration
+    }
+  }
+  return d
+}
+
+func clientIP(r *http.Request) string {
+  host, _, err := net.SplitHostPort(r.RemoteAddr)
+  if err != nil {
+    return r.RemoteAddr
+  }
+  return host
+}
+
+// lockoutSubject is what a login attempt is counted against for the
+// account scope: the email exactly as given, the same way UserByEmail
+// matches it, so a lockout covers every spelling that could log in.
+func lockoutSubject(email string) string {
+  return email
+}
+
+// loginRetryAfter reports how long the caller has to wait before trying to log
+// in again, or zero when neither the account nor the IP is locked.
+func (cfg *apiConfig) loginRetryAfter(ctx context.Context, email, ip string) (time.Duration, error) {
+  keys := []database.GetLoginLockoutParams{
+    {Scope: lockoutScopeAccount, Subject: lockoutSubject(email)},
+    {Scope: lockoutScopeIP, Subject: ip},
+  }
+
+  var wait time.Duration
+  for _, key := range keys {
+    lockout, err := cfg.db.GetLoginLockout(ctx, key)
+    if errors.Is(err, sql.ErrNoRows) {
+      continue
+    }
+    if err != nil {
+      return 0, err
+    }
+    if !lockout.LockedUntil.Valid {
+      continue
+    }
+    if remaining := time.Until(lockout.LockedUntil.Time); remaining > wait {
+      wait = remaining
+    }
+  }
+  return wait, nil
+}
+
+// recordLoginFailure counts a failed attempt against both the account and the
+// IP, locking either one out once it crosses its threshold. It does the same
+// work whether or not email belongs to an account, so the two can't be told
+// apart; the unlock email job checks that.
+func (cfg *apiConfig) recordLoginFailure(ctx context.Context, email, ip string) error {
+  now := time.Now()
+  scopes := []struct {
+    scope     string
+    subject   string
+    threshold int32
+  }{
+    {lockoutScopeAccount, lockoutSubject(email), accountLockoutThreshold},
+    {lockoutScopeIP, ip, ipLockoutThreshold},
+  }
+
+  for _, s := range scopes {
+    lockout, err := cfg.db.RecordFailedLogin(ctx, database.RecordFailedLoginParams{
+      ID:          uuid.New(),
+      Now:         now,
+      Scope:       s.scope,
+      Subject:     s.subject,
+      WindowStart: now.Add(-loginFailureWindow),
+    })
+    if err != nil {
+      return fmt.Errorf("error recording failed login: %v", err)
+    }
+
+    d := lockoutDuration(lockout.FailedAttempts, s.threshold)
+    if d == 0 {
+      continue
+    }
+
+    err = cfg.db.LockLogin(ctx, database.LockLoginParams{
+      ID:          lockout.ID,
+      LockedUntil: sql.NullTime{Time: now.Add(d), Valid: true},
+    })
+    if err != nil {
+      return fmt.Errorf("error locking login: %v", err)
+    }
+    if s.scope == lockoutScopeAccount && lockout.FailedAttempts == s.threshold {
+      _, err = cfg.jobs.Enqueue(ctx, jobSendUnlockEmail, unlockEmailArgs{
+        LockoutID: lockout.ID,
+        Email:     email,
+      }, jobs.MaxAttempts(5))
+      if err != nil {
+        log.Printf("Error queueing unlock email: %v", err)
+      }
+    }
+  }
+  return nil
+}
+
+type unlockEmailArgs struct {
+  LockoutID uuid.UUID `json:"lockout_id"`
+  Email     string    `json:"email"`
+}
+
+// handleSendUnlockEmailJob gives a locked account an unlock token and emails
+// it, if the email belongs to an account. The token is made here rather
+// than when the account is locked, so it never sits in the jobs table; a
+// retry replaces it.
+func (cfg *apiConfig) handleSendUnlockEmailJob(ctx context.Context, job jobs.Job[unlockEmailArgs]) error {
+  user, err := cfg.db.UserByEmail(ctx, job.Args.Email)
+  if errors.Is(err, sql.ErrNoRows) {
+    return nil
+  }
+  if err != nil {
+    return fmt.Errorf("error fetching user: %v", err)
+  }
+
+  token, err := auth.MakeRefreshToken()
+  if err != nil {
+    return err
+  }
+  n, err := cfg.db.SetUnlockToken(ctx, database.SetUnlockTokenParams{
+    ID:          job.Args.LockoutID,
+    UnlockToken: sql.NullString{String: token, Valid: true},
+  })
+  if err != nil {
+    return fmt.Errorf("error setting unlock token: %v", err)
+  }
+  if n == 0 {
+    // unlocked or cleared since
+    return nil
+  }
+
+  body := "We noticed several failed attempts to log in to your Chirpy account, " +
+    "so we have temporarily locked it.\n\n" +
+    "If this was you, you can unlock your account right away by sending this token to " +
+    cfg.BaseURL + "/api/login/unlock:\n\n" + token + "\n"
+  return cfg.mailer.Send(ctx, user.Email, "Your Chirpy account has been locked", body)
+}
+
+func setRetryAfter(w http.ResponseWriter, wait time.Duration) {
+  seconds := int(math.Ceil(wait.Seconds()))
+  w.Header().Set("Retry-After", strconv.Itoa(seconds))
+}
+
+func (cfg *apiConfig) handleUnlockLogin(w http.ResponseWriter, r *http.Request) {
+  var params struct {
+    Token string `json:"token"`
+  }
+  err := json.NewDecoder(r.Body).Decode(&params)
+  if err != nil || params.Token == "" {
+    respondWithError(w, http.StatusBadRequest, "Missing unlock token", err)
+    return
+  }
+
+  _, err = cfg.db.UnlockLoginByToken(context.Background(), sql.NullString{String: params.Token, Valid: true})
+  if err != nil {
+    if errors.Is(err, sql.ErrNoRows) {
+      respondWithError(w, http.StatusNotFound, "Unlock token not found", nil)
+      return
+    }
+    respondWithError(w, http.StatusInternalServerError, "Error unlocking account", err)
+    return
+  }
+
+  w.WriteHeader(http.StatusNoContent)
+}
+
+type lockoutResponse struct {
+  ID             uuid.UUID  `json:"id"`
+  Scope          string     `json:"scope"`
+  Subject        string     `json:"subject"`
+  FailedAttempts int32      `json:"failed_attempts"`
+  LastFailedAt   time.Time  `json:"last_failed_at"`
+  LockedUntil    *time.Time `json:"locked_until"`
+}
+
+func (cfg *apiConfig) handleListLockouts(w http.ResponseWriter, r *http.Request) {
+  lockouts, err := cfg.db.ListLoginLockouts(context.Background())
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error listing lockouts", err)
+    return
+  }
+
+  response := make([]lockoutResponse, 0, len(lockouts))
+  for _, l := range lockouts {
+    item := lockoutResponse{
+      ID:             l.ID,
+      Scope:          l.Scope,
+      Subject:        l.Subject,
+      FailedAttempts: l.FailedAttempts,
+      LastFailedAt:   l.LastFailedAt,
+    }
+    if l.LockedUntil.Valid {
+      item.LockedUntil = &l.LockedUntil.Time
+    }
+    response = append(response, item)
+  }
+
+  respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handleClearLockout(w http.ResponseWriter, r *http.Request) {
+  lockoutID, err := uuid.Parse(r.PathValue("lockoutID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid lockout ID", err)
+    return
+  }
+
+  err = cfg.db.DeleteLoginLockout(context.Background(), lockoutID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error clearing lockout", err)
+    return
+  }
+
+  w.WriteHeader(http.StatusNoContent)
+}
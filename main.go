@@ -1,24 +1,56 @@
 package main
 
 import (
+	"chirpy/internal/activitypub"
+	"chirpy/internal/auth"
+	"chirpy/internal/contentfilter"
 	"chirpy/internal/database"
+	"chirpy/internal/jobs"
+	"chirpy/internal/mailer"
+	"chirpy/internal/passwordpolicy"
+	"chirpy/internal/ratelimit"
+	"chirpy/internal/spam"
+	"chirpy/internal/webhooks"
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/smtp"
 	"os"
+	"os/signal"
+	"strconv"
+	"sync"
 	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type apiConfig struct {
-  fileserverHits  atomic.Int32
-  db              *database.Queries
-  SecretKey       string
-  PolkaKey        string
+  fileserverHits      atomic.Int32
+  db                  *database.Queries
+  sqlDB               *sql.DB
+  SecretKey           string
+  PolkaKey            string
+  PolkaWebhookSecret  string
+  BaseURL             string
+  mailer              mailer.Mailer
+  passwordPolicy      passwordpolicy.Policy
+  rateLimitStore      ratelimit.Store
+  rateLimitPolicies   map[string]rateLimitPolicy
+  jobs                *jobs.Queue
+  webhooks            *webhooks.Dispatcher
+  contentFilter       *contentfilter.Filter
+  spam                spam.Config
+  exportDir           string
+  deletedReplies      string
+  importDir           string
+  federation          *activitypub.Client
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -40,6 +72,10 @@ func (cfg *apiConfig) resetNumReq(w http.ResponseWriter, r *http.Request) {
       w.WriteHeader(http.StatusInternalServerError)
       fmt.Errorf("Error deleting users: %w", err)
     }
+    cfg.audit(r, auditEntry{
+      Type:    auditAdminReset,
+      ActorID: cfg.viewerID(r),
+    })
 
     cfg.fileserverHits.Store(0)
     w.Write([]byte("File server hits set to 0"))
@@ -95,38 +131,256 @@ func main() {
 	defer db.Close()
 
   dbQueries := database.New(db)
+  queue := jobs.New(dbQueries)
   secKey := os.Getenv("SECRET")
+  baseURL := os.Getenv("BASE_URL")
+  if baseURL == "" {
+    baseURL = "http://localhost:" + port
+  }
   apiCfg := apiConfig{
-    fileserverHits: atomic.Int32{},
-    db:             dbQueries,
-    SecretKey:      secKey,
-    PolkaKey:       polka,
+    fileserverHits:     atomic.Int32{},
+    db:                 dbQueries,
+    sqlDB:              db,
+    SecretKey:          secKey,
+    PolkaKey:           polka,
+    PolkaWebhookSecret: os.Getenv("POLKA_WEBHOOK_SECRET"),
+    BaseURL:            baseURL,
+    mailer:             newMailer(),
+    jobs:               queue,
+    webhooks:           webhooks.NewDispatcher(dbQueries, queue, webhooks.NewClient()),
+    contentFilter:      contentfilter.New(dbQueries),
+    exportDir:          loadExportDir(),
+    importDir:          loadImportDir(),
+    federation:         activitypub.NewClient(webhooks.NewClient()),
+  }
+  hashing, err := loadPasswordHashing()
+  if err != nil {
+    log.Fatalf("error configuring password hashing: %v", err)
+  }
+  auth.SetPasswordHashing(hashing)
+  apiCfg.passwordPolicy, err = loadPasswordPolicy()
+  if err != nil {
+    log.Fatalf("error loading password policy: %v", err)
   }
 
+  apiCfg.rateLimitStore = newRateLimitStore(&apiCfg)
+  apiCfg.rateLimitPolicies, err = loadRateLimitPolicies()
+  if err != nil {
+    log.Fatalf("error loading rate limits: %v", err)
+  }
+  apiCfg.spam, err = loadSpamConfig()
+  if err != nil {
+    log.Fatalf("error loading spam thresholds: %v", err)
+  }
+  apiCfg.deletedReplies, err = loadDeletedReplies()
+  if err != nil {
+    log.Fatalf("error loading account deletion settings: %v", err)
+  }
+  err = apiCfg.contentFilter.Reload(context.Background())
+  if err != nil {
+    log.Fatalf("error loading content filter: %v", err)
+  }
+  err = apiCfg.registerJobs()
+  if err != nil {
+    log.Fatalf("error registering jobs: %v", err)
+  }
+  // computed up front so the first login for an unknown email isn't slower
+  dummyPasswordHash()
+
 	mux := http.NewServeMux()
 	mux.Handle("/app/", cfg.middlewareMetricsInc(http.HandlerFunc(homeHandler)))
 	mux.HandleFunc("GET /api/healthz", handlerReadiness)
 	mux.HandleFunc("GET /admin/metrics", cfg.metrics)
   // the below request should be a DELETE method instead
   mux.HandleFunc("POST /admin/reset", apiCfg.resetNumReq)
-  mux.HandleFunc("POST /api/users", apiCfg.handleCreateNewUser)
-  mux.HandleFunc("POST /api/chirps", apiCfg.handleCreateChirp)
-  mux.HandleFunc("POST /api/login", apiCfg.handleUserLogin)
+  mux.HandleFunc("POST /api/users", apiCfg.rateLimit(rateLimitSignup, apiCfg.handleCreateNewUser))
+  mux.HandleFunc("POST /api/chirps", apiCfg.rateLimit(rateLimitChirpCreate, apiCfg.handleCreateChirp))
+  mux.HandleFunc("POST /api/login", apiCfg.rateLimit(rateLimitLogin, apiCfg.handleUserLogin))
+  mux.HandleFunc("POST /api/login/unlock", apiCfg.handleUnlockLogin)
+  mux.HandleFunc("POST /api/password/forgot", apiCfg.rateLimit(rateLimitLogin, apiCfg.handleForgotPassword))
+  mux.HandleFunc("POST /api/password/reset", apiCfg.rateLimit(rateLimitLogin, apiCfg.handleResetPassword))
   mux.HandleFunc("POST /api/refresh", apiCfg.handleRefreshToken)
   mux.HandleFunc("POST /api/revoke", apiCfg.handleRevokeToken)
   mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handleWebhooks)
   mux.HandleFunc("PUT /api/users", apiCfg.handleUpdateUser)
-  mux.HandleFunc("GET /api/chirps", apiCfg.handleGetChirps)
-  mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handleGetOneChirp)
+  mux.HandleFunc("DELETE /api/users/me", apiCfg.handleDeleteAccount)
+  mux.HandleFunc("GET /api/users/{userID}", apiCfg.rateLimit(rateLimitRead, apiCfg.handleGetProfile))
+  mux.HandleFunc("GET /users/{userID}/feed.atom", apiCfg.rateLimit(rateLimitRead, apiCfg.handleUserAtomFeed))
+  mux.HandleFunc("GET /users/{userID}/feed.rss", apiCfg.rateLimit(rateLimitRead, apiCfg.handleUserRSSFeed))
+  mux.HandleFunc("GET /hashtags/{tag}/feed.atom", apiCfg.rateLimit(rateLimitRead, apiCfg.handleHashtagAtomFeed))
+  mux.HandleFunc("GET /hashtags/{tag}/feed.rss", apiCfg.rateLimit(rateLimitRead, apiCfg.handleHashtagRSSFeed))
+  mux.HandleFunc("GET /.well-known/webfinger", apiCfg.rateLimit(rateLimitRead, apiCfg.handleWebFinger))
+  mux.HandleFunc("GET /ap/users/{userID}", apiCfg.rateLimit(rateLimitRead, apiCfg.handleGetActor))
+  mux.HandleFunc("GET /ap/users/{userID}/outbox", apiCfg.rateLimit(rateLimitRead, apiCfg.handleGetOutbox))
+  mux.HandleFunc("GET /ap/users/{userID}/followers", apiCfg.rateLimit(rateLimitRead, apiCfg.handleGetFollowers))
+  mux.HandleFunc("GET /ap/users/{userID}/following", apiCfg.rateLimit(rateLimitRead, apiCfg.handleGetFollowing))
+  mux.HandleFunc("POST /ap/users/{userID}/inbox", apiCfg.handleInbox)
+  mux.HandleFunc("POST /ap/inbox", apiCfg.handleInbox)
+  mux.HandleFunc("GET /ap/chirps/{chirpID}", apiCfg.rateLimit(rateLimitRead, apiCfg.handleGetNote))
+  mux.HandleFunc("GET /api/chirps", apiCfg.rateLimit(rateLimitRead, apiCfg.handleGetChirps))
+  mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.rateLimit(rateLimitRead, apiCfg.handleGetOneChirp))
+  mux.HandleFunc("PUT /api/chirps/{chirpID}", apiCfg.handleUpdateChirp)
   mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handleDeleteOneChirp)
+  mux.HandleFunc("POST /api/chirps/{chirpID}/poll/votes", apiCfg.handleVotePoll)
+  mux.HandleFunc("PUT /api/chirps/{chirpID}/pin", apiCfg.handlePinChirp)
+  mux.HandleFunc("DELETE /api/chirps/{chirpID}/pin", apiCfg.handleUnpinChirp)
+  mux.HandleFunc("GET /api/chirps/{chirpID}/quotes", apiCfg.rateLimit(rateLimitRead, apiCfg.handleGetQuotes))
+  mux.HandleFunc("POST /api/reports", apiCfg.handleCreateReport)
+  mux.HandleFunc("POST /api/blocks", apiCfg.handleBlockUser)
+  mux.HandleFunc("GET /api/blocks", apiCfg.handleListBlocks)
+  mux.HandleFunc("DELETE /api/blocks/{userID}", apiCfg.handleUnblockUser)
+  mux.HandleFunc("GET /api/me/entitlements", apiCfg.handleGetEntitlements)
+  mux.HandleFunc("GET /api/me/security-log", apiCfg.handleGetSecurityLog)
+  mux.HandleFunc("POST /api/me/exports", apiCfg.handleRequestDataExport)
+  mux.HandleFunc("GET /api/me/exports", apiCfg.handleListDataExports)
+  mux.HandleFunc("GET /api/exports/{exportID}/download", apiCfg.handleDownloadDataExport)
+  mux.HandleFunc("POST /api/me/imports", apiCfg.handleCreateChirpImport)
+  mux.HandleFunc("GET /api/me/imports", apiCfg.handleListChirpImports)
+  mux.HandleFunc("GET /api/me/imports/{importID}", apiCfg.handleGetChirpImport)
+  mux.HandleFunc("POST /api/me/following", apiCfg.handleFollowRemote)
+  mux.HandleFunc("GET /api/me/following", apiCfg.handleListFollowing)
+  mux.HandleFunc("DELETE /api/me/following/{userID}", apiCfg.handleUnfollowRemote)
+  mux.HandleFunc("POST /api/me/bookmarks", apiCfg.handleAddBookmark)
+  mux.HandleFunc("GET /api/me/bookmarks", apiCfg.handleListBookmarks)
+  mux.HandleFunc("DELETE /api/me/bookmarks/{chirpID}", apiCfg.handleRemoveBookmark)
+  mux.HandleFunc("POST /api/me/collections", apiCfg.handleCreateCollection)
+  mux.HandleFunc("GET /api/me/collections", apiCfg.handleListCollections)
+  mux.HandleFunc("PUT /api/me/collections/{collectionID}", apiCfg.handleRenameCollection)
+  mux.HandleFunc("DELETE /api/me/collections/{collectionID}", apiCfg.handleDeleteCollection)
+  mux.HandleFunc("GET /api/me/lists/subscriptions", apiCfg.handleListSubscribedLists)
+  mux.HandleFunc("POST /api/lists", apiCfg.handleCreateList)
+  mux.HandleFunc("GET /api/lists", apiCfg.handleListLists)
+  mux.HandleFunc("GET /api/lists/{listID}", apiCfg.handleGetList)
+  mux.HandleFunc("PUT /api/lists/{listID}", apiCfg.handleUpdateList)
+  mux.HandleFunc("DELETE /api/lists/{listID}", apiCfg.handleDeleteList)
+  mux.HandleFunc("GET /api/lists/{listID}/members", apiCfg.handleListListMembers)
+  mux.HandleFunc("POST /api/lists/{listID}/members", apiCfg.handleAddListMember)
+  mux.HandleFunc("DELETE /api/lists/{listID}/members/{userID}", apiCfg.handleRemoveListMember)
+  mux.HandleFunc("GET /api/lists/{listID}/chirps", apiCfg.rateLimit(rateLimitRead, apiCfg.handleGetListChirps))
+  mux.HandleFunc("PUT /api/lists/{listID}/subscription", apiCfg.handleSubscribeList)
+  mux.HandleFunc("DELETE /api/lists/{listID}/subscription", apiCfg.handleUnsubscribeList)
+  mux.HandleFunc("POST /api/drafts", apiCfg.handleCreateDraft)
+  mux.HandleFunc("GET /api/drafts", apiCfg.handleListDrafts)
+  mux.HandleFunc("GET /api/drafts/{draftID}", apiCfg.handleGetDraft)
+  mux.HandleFunc("PUT /api/drafts/{draftID}", apiCfg.handleUpdateDraft)
+  mux.HandleFunc("DELETE /api/drafts/{draftID}", apiCfg.handleDeleteDraft)
+  mux.HandleFunc("PUT /api/drafts/{draftID}/schedule", apiCfg.handleScheduleDraft)
+  mux.HandleFunc("DELETE /api/drafts/{draftID}/schedule", apiCfg.handleUnscheduleDraft)
+  mux.HandleFunc("POST /api/drafts/{draftID}/publish", apiCfg.rateLimit(rateLimitChirpCreate, apiCfg.handlePublishDraft))
+  mux.HandleFunc("POST /api/webhooks", apiCfg.handleCreateWebhookEndpoint)
+  mux.HandleFunc("GET /api/webhooks", apiCfg.handleListWebhookEndpoints)
+  mux.HandleFunc("DELETE /api/webhooks/{endpointID}", apiCfg.handleDeleteWebhookEndpoint)
+  mux.HandleFunc("POST /api/webhooks/{endpointID}/enable", apiCfg.handleEnableWebhookEndpoint)
+  mux.HandleFunc("GET /api/webhooks/{endpointID}/deliveries", apiCfg.handleListWebhookDeliveries)
+  mux.HandleFunc("GET /admin/webhooks/events", apiCfg.requireRole(apiCfg.handleListWebhookEvents))
+  mux.HandleFunc("GET /admin/webhooks/events/{eventID}", apiCfg.requireRole(apiCfg.handleGetWebhookEvent))
+  mux.HandleFunc("POST /admin/webhooks/events/{eventID}/replay", apiCfg.requireRole(apiCfg.handleReplayWebhookEvent))
+  mux.HandleFunc("GET /admin/lockouts", apiCfg.requireRole(apiCfg.handleListLockouts))
+  mux.HandleFunc("DELETE /admin/lockouts/{lockoutID}", apiCfg.requireRole(apiCfg.handleClearLockout))
+  mux.HandleFunc("GET /admin/moderation/queue", apiCfg.requireRole(apiCfg.handleGetModerationQueue, roleModerator))
+  mux.HandleFunc("GET /admin/moderation/cases/{caseID}", apiCfg.requireRole(apiCfg.handleGetModerationCase, roleModerator))
+  mux.HandleFunc("POST /admin/moderation/cases/{caseID}/actions", apiCfg.requireRole(apiCfg.handleModerationAction, roleModerator))
+  mux.HandleFunc("GET /admin/moderation/actions", apiCfg.requireRole(apiCfg.handleListModerationActions, roleModerator))
+  mux.HandleFunc("POST /admin/moderation/users/{userID}/actions", apiCfg.requireRole(apiCfg.handleAccountAction, roleModerator))
+  mux.HandleFunc("GET /admin/content-filter/rules", apiCfg.requireRole(apiCfg.handleListFilterRules))
+  mux.HandleFunc("POST /admin/content-filter/rules", apiCfg.requireRole(apiCfg.handleCreateFilterRule))
+  mux.HandleFunc("PUT /admin/content-filter/rules/{ruleID}", apiCfg.requireRole(apiCfg.handleUpdateFilterRule))
+  mux.HandleFunc("DELETE /admin/content-filter/rules/{ruleID}", apiCfg.requireRole(apiCfg.handleDeleteFilterRule))
+  mux.HandleFunc("POST /admin/content-filter/test", apiCfg.requireRole(apiCfg.handleTestContentFilter))
+  mux.HandleFunc("GET /admin/audit", apiCfg.requireRole(apiCfg.handleListAuditEvents))
+  mux.HandleFunc("GET /admin/jobs", apiCfg.requireRole(apiCfg.handleListJobs))
+  mux.HandleFunc("POST /admin/jobs/{jobID}/retry", apiCfg.requireRole(apiCfg.handleRetryJob))
 
   srv := &http.Server{
 		Addr:    ":" + port,
-		Handler: mux,
+		Handler: withRequestID(mux),
 	}
 
+  // on SIGINT/SIGTERM workers stop claiming jobs and finish the ones they
+  // are running while the server drains its connections
+  ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+  defer stop()
+  var background sync.WaitGroup
+  background.Add(3)
+  go func() {
+    defer background.Done()
+    queue.Run(ctx)
+  }()
+  go func() {
+    defer background.Done()
+    // rules changed through another instance
+    apiCfg.contentFilter.Run(ctx)
+  }()
+  go func() {
+    defer background.Done()
+    <-ctx.Done()
+    shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+    defer cancel()
+    err := srv.Shutdown(shutdownCtx)
+    if err != nil {
+      log.Printf("Error shutting down server: %v", err)
+    }
+  }()
+
 	log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
-	log.Fatal(srv.ListenAndServe())
+  err = srv.ListenAndServe()
+  if !errors.Is(err, http.ErrServerClosed) {
+    log.Fatal(err)
+  }
+  background.Wait()
+  log.Println("Server stopped")
+}
+
+// loadPasswordHashing hashes new passwords with Argon2id, tuned through
+// ARGON2_MEMORY_KIB, ARGON2_ITERATIONS and ARGON2_PARALLELISM, unless
+// PASSWORD_HASHER is "bcrypt". Hashes from either algorithm still verify.
+func loadPasswordHashing() (auth.PasswordHashing, error) {
+  params := auth.DefaultArgon2idParams
+  for env, dst := range map[string]*uint32{
+    "ARGON2_MEMORY_KIB": &params.Memory,
+    "ARGON2_ITERATIONS": &params.Iterations,
+  } {
+    if v := os.Getenv(env); v != "" {
+      n, err := strconv.ParseUint(v, 10, 32)
+      if err != nil || n == 0 {
+        return auth.PasswordHashing{}, fmt.Errorf("invalid %s: %q", env, v)
+      }
+      *dst = uint32(n)
+    }
+  }
+  if v := os.Getenv("ARGON2_PARALLELISM"); v != "" {
+    n, err := strconv.ParseUint(v, 10, 8)
+    if err != nil || n == 0 {
+      return auth.PasswordHashing{}, fmt.Errorf("invalid ARGON2_PARALLELISM: %q", v)
+    }
+    params.Parallelism = uint8(n)
+  }
+
+  argon := auth.Argon2idHasher{Params: params}
+  bcrypt := auth.BcryptHasher{Cost: 10}
+  if os.Getenv("PASSWORD_HASHER") == "bcrypt" {
+    return auth.PasswordHashing{Current: bcrypt, Legacy: []auth.Hasher{argon}}, nil
+  }
+  return auth.PasswordHashing{Current: argon, Legacy: []auth.Hasher{bcrypt}}, nil
+}
+
+// newMailer delivers through SMTP_ADDR when it is set and logs messages
+// otherwise.
+func newMailer() mailer.Mailer {
+  addr := os.Getenv("SMTP_ADDR")
+  if addr == "" {
+    return mailer.LogMailer{}
+  }
+
+  m := mailer.SMTPMailer{
+    Addr: addr,
+    From: os.Getenv("SMTP_FROM"),
+  }
+  if username := os.Getenv("SMTP_USERNAME"); username != "" {
+    host, _, _ := net.SplitHostPort(addr)
+    m.Auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+  }
+  return m
 }
 
 func handlerReadiness(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,770 @@
+package main
+
+import (
+  "context"
+  "database/sql"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net/http"
+  "strconv"
+  "strings"
+  "time"
+  "unicode/utf8"
+  "chirpy/internal/activitypub"
+  "chirpy/internal/database"
+  "chirpy/internal/jobs"
+  "chirpy/internal/webhooks"
+  "github.com/google/uuid"
+)
+
+const (
+  caseOpen     = "open"
+  caseResolved = "resolved"
+
+  actionDismiss     = "dismiss"
+  actionRemoveChirp = "remove_chirp"
+  actionWarn        = "warn"
+  actionSuspend     = "suspend"
+  actionBan         = "ban"
+  actionShadowBan   = "shadow_ban"
+  actionReinstate   = "reinstate"
+
+  maxReportDetailsLength = 500
+  maxSuspendDays         = 365
+)
+
+var reportReasons = map[string]bool{
+  "spam":           true,
+  "harassment":     true,
+  "hate":           true,
+  "violence":       true,
+  "self_harm":      true,
+  "sexual":         true,
+  "misinformation": true,
+  "other":          true,
+}
+
+// handleCreateReport files a report against a chirp or a user. Reports of
+// the same target are gathered into one open case, and reporting the same
+// case twice only counts once.
+func (cfg *apiConfig) handleCreateReport(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  var params struct {
+    ChirpID *uuid.UUID `json:"chirp_id"`
+    UserID  *uuid.UUID `json:"user_id"`
+    Reason  string     `json:"reason"`
+    Details string     `json:"details"`
+  }
+  err = json.NewDecoder(r.Body).Decode(&params)
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+    return
+  }
+  if (params.ChirpID == nil) == (params.UserID == nil) {
+    respondWithError(w, http.StatusBadRequest, "Report either a chirp_id or a user_id", nil)
+    return
+  }
+  if !reportReasons[params.Reason] {
+    respondWithError(w, http.StatusBadRequest, "Invalid reason", nil)
+    return
+  }
+  params.Details = strings.TrimSpace(params.Details)
+  if utf8.RuneCountInString(params.Details) > maxReportDetailsLength {
+    respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Details can be at most %d characters", maxReportDetailsLength), nil)
+    return
+  }
+
+  var targetKey string
+  var targetUser uuid.UUID
+  var chirpID uuid.NullUUID
+  if params.ChirpID != nil {
+    chirp, err := cfg.db.GetOneChirp(context.Background(), *params.ChirpID)
+    if errors.Is(err, sql.ErrNoRows) {
+      respondWithError(w, http.StatusNotFound, "Chirp not found", nil)
+      return
+    }
+    if err != nil {
+      respondWithError(w, http.StatusInternalServerError, "Error fetching chirp", err)
+      return
+    }
+    targetKey = "chirp:" + chirp.ID.String()
+    targetUser = chirp.UserID
+    chirpID = uuid.NullUUID{UUID: chirp.ID, Valid: true}
+  } else {
+    reported, err := cfg.db.GetUserById(context.Background(), *params.UserID)
+    if err != nil {
+      respondWithError(w, http.StatusNotFound, "User not found", nil)
+      return
+    }
+    targetKey = "user:" + reported.ID.String()
+    targetUser = reported.ID
+  }
+  if targetUser == user.ID {
+    respondWithError(w, http.StatusBadRequest, "You can't report yourself", nil)
+    return
+  }
+
+  tx, err := cfg.sqlDB.BeginTx(context.Background(), nil)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error filing report", err)
+    return
+  }
+  defer tx.Rollback()
+  qtx := cfg.db.WithTx(tx)
+
+  now := time.Now()
+  modCase, err := qtx.OpenModerationCase(context.Background(), database.OpenModerationCaseParams{
+    ID:        uuid.New(),
+    CreatedAt: now,
+    TargetKey: targetKey,
+    UserID:    targetUser,
+    ChirpID:   chirpID,
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error filing report", err)
+    return
+  }
+  created, err := qtx.CreateReport(context.Background(), database.CreateReportParams{
+    ID:         uuid.New(),
+    CreatedAt:  now,
+    CaseID:     modCase.ID,
+    ReporterID: user.ID,
+    Reason:     params.Reason,
+    Details:    params.Details,
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error filing report", err)
+    return
+  }
+  if created > 0 {
+    err = qtx.IncrementCaseReports(context.Background(), database.IncrementCaseReportsParams{
+      ID:             modCase.ID,
+      LastReportedAt: now,
+    })
+    if err != nil {
+      respondWithError(w, http.StatusInternalServerError, "Error filing report", err)
+      return
+    }
+  }
+  err = tx.Commit()
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error filing report", err)
+    return
+  }
+
+  w.WriteHeader(http.StatusAccepted)
+}
+
+type moderationCaseResponse struct {
+  ID             uuid.UUID  `json:"id"`
+  CreatedAt      time.Time  `json:"created_at"`
+  UpdatedAt      time.Time  `json:"updated_at"`
+  UserID         uuid.UUID  `json:"user_id"`
+  ChirpID        *uuid.UUID `json:"chirp_id,omitempty"`
+  ChirpBody      string     `json:"chirp_body,omitempty"`
+  Status         string     `json:"status"`
+  ReportCount    int32      `json:"report_count"`
+  LastReportedAt time.Time  `json:"last_reported_at"`
+  ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+  Resolution     string     `json:"resolution,omitempty"`
+  // the content filter rule that held the chirp, if the filter opened the
+  // case
+  FilterRuleID *uuid.UUID `json:"filter_rule_id,omitempty"`
+  // the spam score of a chirp the spam heuristics held
+  SpamScore *int32 `json:"spam_score,omitempty"`
+}
+
+func newModerationCaseResponse(c database.ModerationCase) moderationCaseResponse {
+  resp := moderationCaseResponse{
+    ID:             c.ID,
+    CreatedAt:      c.CreatedAt,
+    UpdatedAt:      c.UpdatedAt,
+    UserID:         c.UserID,
+    Status:         c.Status,
+    ReportCount:    c.ReportCount,
+    LastReportedAt: c.LastReportedAt,
+    Resolution:     c.Resolution.String,
+  }
+  if c.ChirpID.Valid {
+    resp.ChirpID = &c.ChirpID.UUID
+  }
+  if c.ResolvedAt.Valid {
+    resp.ResolvedAt = &c.ResolvedAt.Time
+  }
+  if c.FilterRuleID.Valid {
+    resp.FilterRuleID = &c.FilterRuleID.UUID
+  }
+  if c.SpamScore.Valid {
+    resp.SpamScore = &c.SpamScore.Int32
+  }
+  return resp
+}
+
+// moderationCaseResponses renders cases along with the current body of the
+// chirps they are about, so moderators can triage from the queue.
+func (cfg *apiConfig) moderationCaseResponses(ctx context.Context, cases []database.ModerationCase) ([]moderationCaseResponse, error) {
+  var chirpIDs []uuid.UUID
+  for _, c := range cases {
+    if c.ChirpID.Valid {
+      chirpIDs = append(chirpIDs, c.ChirpID.UUID)
+    }
+  }
+  bodies := map[uuid.UUID]string{}
+  if len(chirpIDs) > 0 {
+    chirps, err := cfg.db.GetChirpsByIDs(ctx, chirpIDs)
+    if err != nil {
+      return nil, fmt.Errorf("error fetching reported chirps: %v", err)
+    }
+    for _, c := range chirps {
+      bodies[c.ID] = c.Body
+    }
+  }
+
+  response := make([]moderationCaseResponse, 0, len(cases))
+  for _, c := range cases {
+    resp := newModerationCaseResponse(c)
+    if c.ChirpID.Valid {
+      resp.ChirpBody = bodies[c.ChirpID.UUID]
+    }
+    response = append(response, resp)
+  }
+  return response, nil
+}
+
+type reportResponse struct {
+  ID         uuid.UUID `json:"id"`
+  CreatedAt  time.Time `json:"created_at"`
+  ReporterID uuid.UUID `json:"reporter_id"`
+  Reason     string    `json:"reason"`
+  Details    string    `json:"details,omitempty"`
+}
+
+type moderationActionResponse struct {
+  ID           uuid.UUID  `json:"id"`
+  CreatedAt    time.Time  `json:"created_at"`
+  ModeratorID  *uuid.UUID `json:"moderator_id,omitempty"`
+  CaseID       *uuid.UUID `json:"case_id,omitempty"`
+  TargetUserID uuid.UUID  `json:"target_user_id"`
+  ChirpID      *uuid.UUID `json:"chirp_id,omitempty"`
+  Action       string     `json:"action"`
+  Note         string     `json:"note,omitempty"`
+  ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+}
+
+func newModerationActionResponse(a database.ModerationAction) moderationActionResponse {
+  resp := moderationActionResponse{
+    ID:           a.ID,
+    CreatedAt:    a.CreatedAt,
+    TargetUserID: a.TargetUserID,
+    Action:       a.Action,
+    Note:         a.Note,
+  }
+  if a.ModeratorID.Valid {
+    resp.ModeratorID = &a.ModeratorID.UUID
+  }
+  if a.CaseID.Valid {
+    resp.CaseID = &a.CaseID.UUID
+  }
+  if a.ChirpID.Valid {
+    resp.ChirpID = &a.ChirpID.UUID
+  }
+  if a.ExpiresAt.Valid {
+    resp.ExpiresAt = &a.ExpiresAt.Time
+  }
+  return resp
+}
+
+// handleGetModerationQueue lists cases with the most reported first.
+func (cfg *apiConfig) handleGetModerationQueue(w http.ResponseWriter, r *http.Request) {
+  params := database.ListModerationQueueParams{Status: caseOpen, RowLimit: 50}
+  if status := r.URL.Query().Get("status"); status != "" {
+    if status != caseOpen && status != caseResolved {
+      respondWithError(w, http.StatusBadRequest, "Invalid status", nil)
+      return
+    }
+    params.Status = status
+  }
+  if limit := r.URL.Query().Get("limit"); limit != "" {
+    n, err := strconv.Atoi(limit)
+    if err != nil || n <= 0 || n > 500 {
+      respondWithError(w, http.StatusBadRequest, "Invalid limit", err)
+      return
+    }
+    params.RowLimit = int32(n)
+  }
+
+  cases, err := cfg.db.ListModerationQueue(context.Background(), params)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error listing cases", err)
+    return
+  }
+  response, err := cfg.moderationCaseResponses(context.Background(), cases)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error listing cases", err)
+    return
+  }
+  respondWithJSON(w, http.StatusOK, response)
+}
+
+// handleGetModerationCase shows a case with its reports and the actions
+// taken on it.
+func (cfg *apiConfig) handleGetModerationCase(w http.ResponseWriter, r *http.Request) {
+  caseID, err := uuid.Parse(r.PathValue("caseID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid case ID", err)
+    return
+  }
+
+  modCase, err := cfg.db.GetModerationCase(context.Background(), caseID)
+  if errors.Is(err, sql.ErrNoRows) {
+    respondWithError(w, http.StatusNotFound, "Case not found", nil)
+    return
+  }
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching case", err)
+    return
+  }
+  rendered, err := cfg.moderationCaseResponses(context.Background(), []database.ModerationCase{modCase})
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching case", err)
+    return
+  }
+  reports, err := cfg.db.ListCaseReports(context.Background(), caseID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching reports", err)
+    return
+  }
+  actions, err := cfg.db.ListModerationActions(context.Background(), database.ListModerationActionsParams{
+    CaseID:   uuid.NullUUID{UUID: caseID, Valid: true},
+    RowLimit: 100,
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching actions", err)
+    return
+  }
+
+  response := struct {
+    moderationCaseResponse
+    Reports []reportResponse           `json:"reports"`
+    Actions []moderationActionResponse `json:"actions"`
+  }{
+    moderationCaseResponse: rendered[0],
+    Reports:                make([]reportResponse, 0, len(reports)),
+    Actions:                make([]moderationActionResponse, 0, len(actions)),
+  }
+  for _, rep := range reports {
+    response.Reports = append(response.Reports, reportResponse{
+      ID:         rep.ID,
+      CreatedAt:  rep.CreatedAt,
+      ReporterID: rep.ReporterID,
+      Reason:     rep.Reason,
+      Details:    rep.Details,
+    })
+  }
+  for _, a := range actions {
+    response.Actions = append(response.Actions, newModerationActionResponse(a))
+  }
+  respondWithJSON(w, http.StatusOK, response)
+}
+
+type moderationActionParams struct {
+  Action      string `json:"action"`
+  Note        string `json:"note"`
+  SuspendDays int    `json:"suspend_days"`
+}
+
+// validate returns a message for the moderator if the action can't be
+// taken, or "" if it can. modCase is nil for actions taken directly on an
+// account, which can't dismiss or remove anything but can reinstate.
+func (p moderationActionParams) validate(modCase *database.ModerationCase) string {
+  switch p.Action {
+  case actionWarn, actionBan, actionShadowBan:
+  case actionDismiss:
+    if modCase == nil {
+      return "Only cases can be dismissed"
+    }
+  case actionRemoveChirp:
+    if modCase == nil || !modCase.ChirpID.Valid {
+      return "This case isn't about a chirp"
+    }
+  case actionReinstate:
+    if modCase != nil {
+      return "Reinstate the account directly, not through a case"
+    }
+  case actionSuspend:
+    if p.SuspendDays < 1 || p.SuspendDays > maxSuspendDays {
+      return fmt.Sprintf("suspend_days must be between 1 and %d", maxSuspendDays)
+    }
+  default:
+    return "Invalid action"
+  }
+  return ""
+}
+
+// moderationNotice is the email telling a user about an action taken
+// against them, or "" for actions they aren't told about. Shadow bans are
+// deliberately kept quiet.
+func moderationNotice(action, note string, expiresAt time.Time) (string, string) {
+  var subject, body string
+  switch action {
+  case actionRemoveChirp:
+    subject = "One of your chirps was removed"
+    body = "A moderator removed one of your chirps for breaking the rules."
+  case actionWarn:
+    subject = "A warning about your account"
+    body = "A moderator reviewed reports about your account and is warning you to follow the rules."
+  case actionSuspend:
+    subject = "Your account has been suspended"
+    body = "Your account is suspended until " + expiresAt.UTC().Format(time.RFC1123) + "."
+  case actionBan:
+    subject = "Your account has been banned"
+    body = "Your account has been permanently banned."
+  case actionReinstate:
+    subject = "Your account has been reinstated"
+    body = "A moderator lifted the restrictions on your account."
+  default:
+    return "", ""
+  }
+  if note != "" {
+    body += "\n\nNote from the moderator:\n" + note
+  }
+  return subject, body + "\n"
+}
+
+// errModerationForbidden is returned for actions moderators may not take.
+var errModerationForbidden = errors.New("admins can't be restricted")
+
+// applyModerationAction carries out an action against target inside qtx and
+// records it in the audit trail. It reports whether a chirp was removed so
+// the caller can announce it once the transaction commits.
+func applyModerationAction(ctx context.Context, qtx *database.Queries, moderatorID uuid.UUID, target database.User, modCase *database.ModerationCase, params moderationActionParams) (database.ModerationAction, bool, error) {
+  restricts := params.Action == actionSuspend || params.Action == actionBan || params.Action == actionShadowBan
+  if restricts && target.Role == roleAdmin {
+    return database.ModerationAction{}, false, errModerationForbidden
+  }
+
+  now := time.Now()
+  var expiresAt sql.NullTime
+  var chirpID, caseID uuid.NullUUID
+  if modCase != nil {
+    chirpID = modCase.ChirpID
+    caseID = uuid.NullUUID{UUID: modCase.ID, Valid: true}
+  }
+  removed := false
+  var err error
+  switch params.Action {
+  case actionRemoveChirp:
+    var n int64
+    n, err = qtx.RemoveChirp(ctx, chirpID.UUID)
+    removed = n > 0
+  case actionSuspend:
+    expiresAt = sql.NullTime{Time: now.Add(time.Duration(params.SuspendDays) * 24 * time.Hour), Valid: true}
+    err = qtx.SetAccountStatus(ctx, database.SetAccountStatusParams{
+      ID:             target.ID,
+      AccountStatus:  accountSuspended,
+      SuspendedUntil: expiresAt,
+    })
+  case actionBan:
+    err = qtx.SetAccountStatus(ctx, database.SetAccountStatusParams{
+      ID:            target.ID,
+      AccountStatus: accountBanned,
+    })
+  case actionShadowBan:
+    err = qtx.SetAccountStatus(ctx, database.SetAccountStatusParams{
+      ID:            target.ID,
+      AccountStatus: accountShadowBanned,
+    })
+  case actionReinstate:
+    err = qtx.SetAccountStatus(ctx, database.SetAccountStatusParams{
+      ID:            target.ID,
+      AccountStatus: accountActive,
+    })
+  }
+  if err != nil {
+    return database.ModerationAction{}, false, err
+  }
+  if params.Action == actionSuspend || params.Action == actionBan {
+    // access tokens are checked against the account on every request, but
+    // refresh tokens would outlive the ban
+    err = qtx.RevokeUserRefreshTokens(ctx, target.ID)
+    if err != nil {
+      return database.ModerationAction{}, false, err
+    }
+  }
+
+  action, err := qtx.CreateModerationAction(ctx, database.CreateModerationActionParams{
+    ID:           uuid.New(),
+    CreatedAt:    now,
+    ModeratorID:  uuid.NullUUID{UUID: moderatorID, Valid: true},
+    CaseID:       caseID,
+    TargetUserID: target.ID,
+    ChirpID:      chirpID,
+    Action:       params.Action,
+    Note:         params.Note,
+    ExpiresAt:    expiresAt,
+  })
+  if err != nil {
+    return database.ModerationAction{}, false, err
+  }
+
+  if subject, body := moderationNotice(params.Action, params.Note, expiresAt.Time); subject != "" {
+    _, err := jobs.EnqueueWith(ctx, qtx, jobSendEmail, emailArgs{
+      To:      target.Email,
+      Subject: subject,
+      Body:    body,
+    }, jobs.MaxAttempts(5))
+    if err != nil {
+      return database.ModerationAction{}, false, err
+    }
+  }
+  return action, removed, nil
+}
+
+// recordModerationAudit adds a moderation action to the security audit
+// trail, alongside its entry in moderation_actions.
+func recordModerationAudit(ctx context.Context, qtx *database.Queries, r *http.Request, action database.ModerationAction) error {
+  details := map[string]any{"action": action.Action, "moderation_action_id": action.ID}
+  if action.CaseID.Valid {
+    details["case_id"] = action.CaseID.UUID
+  }
+  return recordAudit(ctx, qtx, r, auditEntry{
+    Type:    auditModerationAction,
+    ActorID: action.ModeratorID,
+    UserID:  auditUser(action.TargetUserID),
+    Details: details,
+  })
+}
+
+// respondWithModerationAction finishes a moderation request once its
+// transaction has committed.
+func (cfg *apiConfig) respondWithModerationAction(w http.ResponseWriter, action database.ModerationAction, removed bool) {
+  if removed {
+    cfg.publishEvent(context.Background(), webhooks.Event{
+      Type:   webhooks.EventChirpDeleted,
+      UserID: action.TargetUserID,
+      Data:   map[string]uuid.UUID{"id": action.ChirpID.UUID, "user_id": action.TargetUserID},
+    })
+    cfg.federateChirp(context.Background(), database.Chirp{ID: action.ChirpID.UUID, UserID: action.TargetUserID}, activitypub.TypeDelete)
+  }
+  respondWithJSON(w, http.StatusOK, newModerationActionResponse(action))
+}
+
+// handleModerationAction carries out an action on an open case and resolves
+// it. The action, the case resolution and any email to the user are written
+// in one transaction, so the audit trail always matches what happened.
+// Dismissing a case about a held chirp releases the chirp.
+func (cfg *apiConfig) handleModerationAction(w http.ResponseWriter, r *http.Request) {
+  moderator, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+  caseID, err := uuid.Parse(r.PathValue("caseID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid case ID", err)
+    return
+  }
+
+  var params moderationActionParams
+  err = json.NewDecoder(r.Body).Decode(&params)
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+    return
+  }
+  params.Note = strings.TrimSpace(params.Note)
+
+  tx, err := cfg.sqlDB.BeginTx(context.Background(), nil)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error taking action", err)
+    return
+  }
+  defer tx.Rollback()
+  qtx := cfg.db.WithTx(tx)
+
+  modCase, err := qtx.LockOpenModerationCase(context.Background(), caseID)
+  if errors.Is(err, sql.ErrNoRows) {
+    respondWithError(w, http.StatusNotFound, "Open case not found", nil)
+    return
+  }
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error taking action", err)
+    return
+  }
+  if msg := params.validate(&modCase); msg != "" {
+    respondWithError(w, http.StatusBadRequest, msg, nil)
+    return
+  }
+  target, err := qtx.GetUserById(context.Background(), modCase.UserID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching user", err)
+    return
+  }
+
+  action, removed, err := applyModerationAction(context.Background(), qtx, moderator.ID, target, &modCase, params)
+  if errors.Is(err, errModerationForbidden) {
+    respondWithError(w, http.StatusForbidden, "Admins can't be suspended or banned", nil)
+    return
+  }
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error taking action", err)
+    return
+  }
+  err = recordModerationAudit(context.Background(), qtx, r, action)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error taking action", err)
+    return
+  }
+  err = qtx.ResolveModerationCase(context.Background(), database.ResolveModerationCaseParams{
+    ID:         modCase.ID,
+    Resolution: sql.NullString{String: params.Action, Valid: true},
+    ResolvedAt: sql.NullTime{Time: action.CreatedAt, Valid: true},
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error resolving case", err)
+    return
+  }
+  var released *database.Chirp
+  if params.Action == actionDismiss && modCase.ChirpID.Valid {
+    chirp, err := qtx.GetOneChirp(context.Background(), modCase.ChirpID.UUID)
+    if err != nil && !errors.Is(err, sql.ErrNoRows) {
+      respondWithError(w, http.StatusInternalServerError, "Error fetching chirp", err)
+      return
+    }
+    if err == nil && chirp.HeldAt.Valid {
+      err = qtx.ReleaseChirp(context.Background(), chirp.ID)
+      if err != nil {
+        respondWithError(w, http.StatusInternalServerError, "Error releasing chirp", err)
+        return
+      }
+      chirp.HeldAt = sql.NullTime{}
+      released = &chirp
+    }
+  }
+
+  err = tx.Commit()
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error taking action", err)
+    return
+  }
+  if released != nil {
+    // held chirps weren't announced when they were created
+    cfg.publishEvent(context.Background(), webhooks.Event{
+      Type:   webhooks.EventChirpCreated,
+      UserID: released.UserID,
+      Data:   chirpResponse{Chirp: *released},
+    })
+    cfg.federateChirp(context.Background(), *released, activitypub.TypeCreate)
+  }
+  cfg.respondWithModerationAction(w, action, removed)
+}
+
+// handleAccountAction acts on an account directly, without a report:
+// warning, suspending, banning, shadow-banning or reinstating it.
+func (cfg *apiConfig) handleAccountAction(w http.ResponseWriter, r *http.Request) {
+  moderator, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+  userID, err := uuid.Parse(r.PathValue("userID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+    return
+  }
+
+  var params moderationActionParams
+  err = json.NewDecoder(r.Body).Decode(&params)
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+    return
+  }
+  params.Note = strings.TrimSpace(params.Note)
+  if msg := params.validate(nil); msg != "" {
+    respondWithError(w, http.StatusBadRequest, msg, nil)
+    return
+  }
+
+  tx, err := cfg.sqlDB.BeginTx(context.Background(), nil)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error taking action", err)
+    return
+  }
+  defer tx.Rollback()
+  qtx := cfg.db.WithTx(tx)
+
+  target, err := qtx.GetUserById(context.Background(), userID)
+  if err != nil {
+    respondWithError(w, http.StatusNotFound, "User not found", nil)
+    return
+  }
+
+  action, removed, err := applyModerationAction(context.Background(), qtx, moderator.ID, target, nil, params)
+  if errors.Is(err, errModerationForbidden) {
+    respondWithError(w, http.StatusForbidden, "Admins can't be suspended or banned", nil)
+    return
+  }
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error taking action", err)
+    return
+  }
+  err = recordModerationAudit(context.Background(), qtx, r, action)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error taking action", err)
+    return
+  }
+
+  err = tx.Commit()
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error taking action", err)
+    return
+  }
+  cfg.respondWithModerationAction(w, action, removed)
+}
+
+// handleListModerationActions is the moderation audit trail, newest first,
+// optionally narrowed to a user or a case.
+func (cfg *apiConfig) handleListModerationActions(w http.ResponseWriter, r *http.Request) {
+  params := database.ListModerationActionsParams{RowLimit: 50}
+  if s := r.URL.Query().Get("user_id"); s != "" {
+    id, err := uuid.Parse(s)
+    if err != nil {
+      respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+      return
+    }
+    params.TargetUserID = uuid.NullUUID{UUID: id, Valid: true}
+  }
+  if s := r.URL.Query().Get("case_id"); s != "" {
+    id, err := uuid.Parse(s)
+    if err != nil {
+      respondWithError(w, http.StatusBadRequest, "Invalid case ID", err)
+      return
+    }
+    params.CaseID = uuid.NullUUID{UUID: id, Valid: true}
+  }
+  if limit := r.URL.Query().Get("limit"); limit != "" {
+    n, err := strconv.Atoi(limit)
+    if err != nil || n <= 0 || n > 500 {
+      respondWithError(w, http.StatusBadRequest, "Invalid limit", err)
+      return
+    }
+    params.RowLimit = int32(n)
+  }
+
+  actions, err := cfg.db.ListModerationActions(context.Background(), params)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error listing actions", err)
+    return
+  }
+
+  response := make([]moderationActionResponse, 0, len(actions))
+  for _, a := range actions {
+    response = append(response, newModerationActionResponse(a))
+  }
+  respondWithJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+  "context"
+  "database/sql"
+  "encoding/json"
+  "errors"
+  "log"
+  "net/http"
+  "net/url"
+  "time"
+  "chirpy/internal/auth"
+  "chirpy/internal/database"
+  "chirpy/internal/webhooks"
+  "github.com/google/uuid"
+)
+
+type webhookEndpointResponse struct {
+  ID                  uuid.UUID  `json:"id"`
+  CreatedAt           time.Time  `json:"created_at"`
+  URL                 string     `json:"url"`
+  Events              []string   `json:"events"`
+  Global              bool       `json:"global"`
+  Enabled             bool       `json:"enabled"`
+  ConsecutiveFailures int32      `json:"consecutive_failures"`
+  DisabledAt          *time.Time `json:"disabled_at"`
+  // only returned when the endpoint is created
+  Secret              string     `json:"secret,omitempty"`
+}
+
+func newWebhookEndpointResponse(e database.WebhookEndpoint) webhookEndpointResponse {
+  resp := webhookEndpointResponse{
+    ID:                  e.ID,
+    CreatedAt:           e.CreatedAt,
+    URL:                 e.Url,
+    Events:              e.Events,
+    Global:              e.Global,
+    Enabled:             e.Enabled,
+    ConsecutiveFailures: e.ConsecutiveFailures,
+  }
+  if e.DisabledAt.Valid {
+    resp.DisabledAt = &e.DisabledAt.Time
+  }
+  return resp
+}
+
+// publishEvent queues an outbound webhook. Failing to queue it never fails
+// the request that caused it.
+func (cfg *apiConfig) publishEvent(ctx context.Context, event webhooks.Event) {
+  if cfg.webhooks == nil {
+    return
+  }
+  err := cfg.webhooks.Publish(ctx, event)
+  if err != nil {
+    log.Printf("Error publishing %s event: %v", event.Type, err)
+  }
+}
+
+func (cfg *apiConfig) handleCreateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  var params struct {
+    URL    string   `json:"url"`
+    Events []string `json:"events"`
+    Global bool     `json:"global"`
+  }
+  err = json.NewDecoder(r.Body).Decode(&params)
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+    return
+  }
+
+  u, err := url.Parse(params.URL)
+  if err != nil || u.Scheme != "https" || u.Host == "" {
+    respondWithError(w, http.StatusBadRequest, "Webhook URL must be an absolute https URL", err)
+    return
+  }
+  if len(params.Events) == 0 {
+    respondWithError(w, http.StatusBadRequest, "Subscribe to at least one event", nil)
+    return
+  }
+  for _, event := range params.Events {
+    if !webhooks.ValidEvent(event) {
+      respondWithError(w, http.StatusBadRequest, "Unknown event: "+event, nil)
+      return
+    }
+  }
+  // global endpoints receive events about every user
+  if params.Global && user.Role != roleAdmin {
+    respondWithError(w, http.StatusForbidden, "Only admins can register global endpoints", nil)
+    return
+  }
+
+  secret, err := auth.MakeRefreshToken()
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error creating webhook secret", err)
+    return
+  }
+
+  endpoint, err := cfg.db.CreateWebhookEndpoint(context.Background(), database.CreateWebhookEndpointParams{
+    ID:        uuid.New(),
+    CreatedAt: time.Now(),
+    UserID:    user.ID,
+    Url:       u.String(),
+    Secret:    "whsec_" + secret,
+    Events:    params.Events,
+    Global:    params.Global,
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error creating webhook endpoint", err)
+    return
+  }
+
+  resp := newWebhookEndpointResponse(endpoint)
+  resp.Secret = endpoint.Secret
+  respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (cfg *apiConfig) handleListWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  endpoints, err := cfg.db.ListWebhookEndpointsByUser(context.Background(), user.ID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error listing webhook endpoints", err)
+    return
+  }
+
+  response := make([]webhookEndpointResponse, 0, len(endpoints))
+  for _, e := range endpoints {
+    response = append(response, newWebhookEndpointResponse(e))
+  }
+  respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handleDeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid endpoint ID", err)
+    return
+  }
+
+  deleted, err := cfg.db.DeleteWebhookEndpoint(context.Background(), database.DeleteWebhookEndpointParams{
+    ID:     endpointID,
+    UserID: user.ID,
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error deleting webhook endpoint", err)
+    return
+  }
+  if deleted == 0 {
+    respondWithError(w, http.StatusNotFound, "Webhook endpoint not found", nil)
+    return
+  }
+
+  w.WriteHeader(http.StatusNoContent)
+}
+
+// handleEnableWebhookEndpoint turns an endpoint back on after it was disabled
+// for failing too often.
+func (cfg *apiConfig) handleEnableWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid endpoint ID", err)
+    return
+  }
+
+  updated, err := cfg.db.EnableWebhookEndpoint(context.Background(), database.EnableWebhookEndpointParams{
+    ID:     endpointID,
+    UserID: user.ID,
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error enabling webhook endpoint", err)
+    return
+  }
+  if updated == 0 {
+    respondWithError(w, http.StatusNotFound, "Webhook endpoint not found", nil)
+    return
+  }
+
+  err = cfg.webhooks.Resume(context.Background(), endpointID)
+  if err != nil {
+    log.Println(err)
+  }
+
+  w.WriteHeader(http.StatusNoContent)
+}
+
+type webhookDeliveryResponse struct {
+  ID             uuid.UUID       `json:"id"`
+  CreatedAt      time.Time       `json:"created_at"`
+  Event          string          `json:"event"`
+  Payload        json.RawMessage `json:"payload"`
+  Status         string          `json:"status"`
+  Attempts       int32           `json:"attempts"`
+  NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+  LastStatusCode *int32          `json:"last_status_code"`
+  LastError      string          `json:"last_error,omitempty"`
+  DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+func (cfg *apiConfig) handleListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+
+  endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid endpoint ID", err)
+    return
+  }
+
+  endpoint, err := cfg.db.GetWebhookEndpoint(context.Background(), endpointID)
+  if err != nil || endpoint.UserID != user.ID {
+    if err == nil || errors.Is(err, sql.ErrNoRows) {
+      respondWithError(w, http.StatusNotFound, "Webhook endpoint not found", nil)
+      return
+    }
+    respondWithError(w, http.StatusInternalServerError, "Error fetching webhook endpoint", err)
+    return
+  }
+
+  deliveries, err := cfg.db.ListWebhookDeliveriesByEndpoint(context.Background(), database.ListWebhookDeliveriesByEndpointParams{
+    EndpointID: endpoint.ID,
+    Limit:      100,
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error listing deliveries", err)
+    return
+  }
+
+  response := make([]webhookDeliveryResponse, 0, len(deliveries))
+  for _, d := range deliveries {
+    item := webhookDeliveryResponse{
+      ID:        d.ID,
+      CreatedAt: d.CreatedAt,
+      Event:     d.Event,
+      Payload:   d.Payload,
+      Status:    d.Status,
+      Attempts:  d.Attempts,
+      LastError: d.LastError.String,
+    }
+    if d.Status == webhooks.StatusPending {
+      item.NextAttemptAt = &d.NextAttemptAt
+    }
+    if d.LastStatusCode.Valid {
+      item.LastStatusCode = &d.LastStatusCode.Int32
+    }
+    if d.DeliveredAt.Valid {
+      item.DeliveredAt = &d.DeliveredAt.Time
+    }
+    response = append(response, item)
+  }
+  respondWithJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+  "context"
+  "crypto/sha256"
+  "database/sql"
+  "encoding/hex"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "log"
+  "net/http"
+  "os"
+  "strconv"
+  "time"
+  "chirpy/internal/auth"
+  "chirpy/internal/database"
+  "chirpy/internal/jobs"
+  "chirpy/internal/passwordpolicy"
+  "github.com/google/uuid"
+)
+
+const (
+  passwordResetTTL     = time.Hour
+  jobSendPasswordReset = "email.password_reset"
+
+  // maxCredentialsBody bounds the requests that carry a password, which are
+  // open to anyone
+  maxCredentialsBody = 64 << 10
+)
+
+// loadPasswordPolicy starts from passwordpolicy.DefaultPolicy and applies
+// PASSWORD_MIN_LENGTH, PASSWORD_MIN_STRENGTH and BREACHED_PASSWORDS_PATH.
+func loadPasswordPolicy() (passwordpolicy.Policy, error) {
+  policy := passwordpolicy.DefaultPolicy
+
+  if v := os.Getenv("PASSWORD_MIN_LENGTH"); v != "" {
+    n, err := strconv.Atoi(v)
+    if err != nil {
+      return policy, err
+    }
+    policy.MinLength = n
+  }
+  if v := os.Getenv("PASSWORD_MIN_STRENGTH"); v != "" {
+    n, err := strconv.Atoi(v)
+    if err != nil {
+      return policy, err
+    }
+    policy.MinStrength = n
+  }
+  if path := os.Getenv("BREACHED_PASSWORDS_PATH"); path != "" {
+    corpus, err := passwordpolicy.OpenCorpus(path)
+    if err != nil {
+      return policy, err
+    }
+    policy.Breached = corpus
+  }
+
+  return policy, nil
+}
+
+// checkPassword applies the password policy and, when the password is
+// rejected, responds with a 400 listing every violation.
+func (cfg *apiConfig) checkPassword(w http.ResponseWriter, password, email string) bool {
+  violations, err := cfg.passwordPolicy.Check(password, email)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error checking password", err)
+    return false
+  }
+  if len(violations) == 0 {
+    return true
+  }
+
+  respondWithJSON(w, http.StatusBadRequest, struct {
+    Error      string                     `json:"error"`
+    Violations []passwordpolicy.Violation `json:"violations"`
+  }{
+    Error:      "Password does not meet the password policy",
+    Violations: violations,
+  })
+  return false
+}
+
+func hashResetToken(token string) string {
+  sum := sha256.Sum256([]byte(token))
+  return hex.EncodeToString(sum[:])
+}
+
+type passwordResetArgs struct {
+  UserID uuid.UUID `json:"user_id"`
+}
+
+// handleSendPasswordResetJob makes a reset token and emails it. Only the
+// token's hash is stored, so it is made here rather than when the job is
+// queued: job payloads are kept for days. A retry makes a new token.
+func (cfg *apiConfig) handleSendPasswordResetJob(ctx context.Context, job jobs.Job[passwordResetArgs]) error {
+  user, err := cfg.db.GetUserById(ctx, job.Args.UserID)
+  if errors.Is(err, sql.ErrNoRows) {
+    return nil
+  }
+  if err != nil {
+    return fmt.Errorf("error fetching user: %v", err)
+  }
+
+  token, err := auth.MakeRefreshToken()
+  if err != nil {
+    return err
+  }
+  now := time.Now()
+  err = cfg.db.CreatePasswordReset(ctx, database.CreatePasswordResetParams{
+    TokenHash: hashResetToken(token),
+    CreatedAt: now,
+    UserID:    user.ID,
+    ExpiresAt: now.Add(passwordResetTTL),
+  })
+  if err != nil {
+    return fmt.Errorf("error creating reset token: %v", err)
+  }
+
+  body := "Someone asked to reset the password for your Chirpy account.\n\n" +
+    "To choose a new password, send this token along with it to " +
+    cfg.BaseURL + "/api/password/reset within the next hour:\n\n" + token + "\n\n" +
+    "If you didn't ask for this, you can ignore this email.\n"
+  return cfg.mailer.Send(ctx, user.Email, "Reset your Chirpy password", body)
+}
+
+// handleForgotPassword emails a reset token. It answers 202 whether or not
+// the email belongs to an account.
+func (cfg *apiConfig) handleForgotPassword(w http.ResponseWriter, r *http.Request) {
+  var params struct {
+    Email string `json:"email"`
+  }
+  err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCredentialsBody)).Decode(&params)
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+    return
+  }
+
+  user, err := cfg.db.UserByEmail(context.Background(), params.Email)
+  if err != nil {
+    if !errors.Is(err, sql.ErrNoRows) {
+      log.Printf("Error fetching user for password reset: %v", err)
+    }
+    w.WriteHeader(http.StatusAccepted)
+    return
+  }
+
+  _, err = cfg.jobs.Enqueue(context.Background(), jobSendPasswordReset, passwordResetArgs{UserID: user.ID}, jobs.MaxAttempts(5))
+  if err != nil {
+    log.Printf("Error queueing password reset email: %v", err)
+  }
+  cfg.audit(r, auditEntry{
+    Type:   auditPasswordResetRequest,
+    UserID: auditUser(user.ID),
+  })
+
+  w.WriteHeader(http.StatusAccepted)
+}
+
+func (cfg *apiConfig) handleResetPassword(w http.ResponseWriter, r *http.Request) {
+  var params struct {
+    Token    string `json:"token"`
+    Password string `json:"password"`
+  }
+  err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCredentialsBody)).Decode(&params)
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+    return
+  }
+
+  tokenHash := hashResetToken(params.Token)
+  reset, err := cfg.db.GetPasswordReset(context.Background(), tokenHash)
+  if err != nil || reset.UsedAt.Valid || time.Now().After(reset.ExpiresAt) {
+    respondWithError(w, http.StatusUnauthorized, "Invalid or expired reset token", err)
+    return
+  }
+
+  user, err := cfg.db.GetUserById(context.Background(), reset.UserID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching user", err)
+    return
+  }
+
+  if !cfg.checkPassword(w, params.Password, user.Email) {
+    return
+  }
+
+  hashedPassword, err := auth.HashPassword(params.Password)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error hashing the password", err)
+    return
+  }
+
+  // claim the token before changing anything so it can only be used once
+  used, err := cfg.db.UsePasswordReset(context.Background(), tokenHash)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error using reset token", err)
+    return
+  }
+  if used == 0 {
+    respondWithError(w, http.StatusUnauthorized, "Invalid or expired reset token", nil)
+    return
+  }
+
+  err = cfg.db.UpdateUserPassword(context.Background(), database.UpdateUserPasswordParams{
+    ID:             user.ID,
+    HashedPassword: hashedPassword,
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error updating password", err)
+    return
+  }
+
+  err = cfg.db.RevokeUserRefreshTokens(context.Background(), user.ID)
+  if err != nil {
+    log.Printf("Error revoking refresh tokens after password reset: %v", err)
+  }
+  err = cfg.db.ClearLoginLockout(context.Background(), database.ClearLoginLockoutParams{
+    Scope:   lockoutScopeAccount,
+    Subject: lockoutSubject(user.Email),
+  })
+  if err != nil {
+    log.Printf("Error clearing login lockout after password reset: %v", err)
+  }
+  cfg.audit(r, auditEntry{
+    Type:    auditPasswordReset,
+    ActorID: auditUser(user.ID),
+    UserID:  auditUser(user.ID),
+  })
+
+  w.WriteHeader(http.StatusNoContent)
+}
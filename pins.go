@@ -0,0 +1,186 @@
+package main
+
+import (
+  "context"
+  "database/sql"
+  "errors"
+  "fmt"
+  "net/http"
+  "time"
+  "chirpy/internal/database"
+  "chirpy/internal/entitlements"
+  "github.com/google/uuid"
+)
+
+// chirpResponsesWithPinned renders pinned ahead of chirps, marking them as
+// pinned and leaving them out of chirps so nothing is listed twice.
+func (cfg *apiConfig) chirpResponsesWithPinned(ctx context.Context, pinned, chirps []database.Chirp, viewer uuid.NullUUID) ([]chirpResponse, error) {
+  if len(pinned) == 0 {
+    return cfg.chirpResponses(ctx, chirps, viewer)
+  }
+
+  isPinned := make(map[uuid.UUID]bool, len(pinned))
+  all := make([]database.Chirp, 0, len(pinned)+len(chirps))
+  for _, c := range pinned {
+    isPinned[c.ID] = true
+    all = append(all, c)
+  }
+  for _, c := range chirps {
+    if !isPinned[c.ID] {
+      all = append(all, c)
+    }
+  }
+
+  response, err := cfg.chirpResponses(ctx, all, viewer)
+  if err != nil {
+    return nil, err
+  }
+  for i := range response {
+    response[i].Pinned = isPinned[response[i].ID]
+  }
+  return response, nil
+}
+
+// handlePinChirp pins one of the caller's chirps to their profile, up to
+// the number their plan allows.
+func (cfg *apiConfig) handlePinChirp(w http.ResponseWriter, r *http.Request) {
+  chirp, ok := cfg.ownChirp(w, r)
+  if !ok {
+    return
+  }
+
+  ent, err := cfg.entitlementsFor(context.Background(), chirp.UserID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching entitlements", err)
+    return
+  }
+
+  pinned, err := cfg.db.PinChirp(context.Background(), database.PinChirpParams{
+    ChirpID:   chirp.ID,
+    UserID:    chirp.UserID,
+    CreatedAt: time.Now(),
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error pinning chirp", err)
+    return
+  }
+  if pinned == 0 {
+    // already pinned
+    w.WriteHeader(http.StatusNoContent)
+    return
+  }
+
+  // counting after the insert means two concurrent pins can't both squeeze
+  // under the limit
+  count, err := cfg.db.CountPinnedChirps(context.Background(), chirp.UserID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error pinning chirp", err)
+    return
+  }
+  if count > int64(ent.MaxPinnedChirps) {
+    _, err := cfg.db.UnpinChirp(context.Background(), database.UnpinChirpParams{
+      ChirpID: chirp.ID,
+      UserID:  chirp.UserID,
+    })
+    if err != nil {
+      respondWithError(w, http.StatusInternalServerError, "Error pinning chirp", err)
+      return
+    }
+    respondWithError(w, http.StatusConflict, fmt.Sprintf("You can pin at most %d chirps", ent.MaxPinnedChirps), nil)
+    return
+  }
+
+  w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleUnpinChirp(w http.ResponseWriter, r *http.Request) {
+  chirp, ok := cfg.ownChirp(w, r)
+  if !ok {
+    return
+  }
+
+  unpinned, err := cfg.db.UnpinChirp(context.Background(), database.UnpinChirpParams{
+    ChirpID: chirp.ID,
+    UserID:  chirp.UserID,
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error unpinning chirp", err)
+    return
+  }
+  if unpinned == 0 {
+    respondWithError(w, http.StatusNotFound, "Chirp isn't pinned", nil)
+    return
+  }
+
+  w.WriteHeader(http.StatusNoContent)
+}
+
+type profileResponse struct {
+  ID          uuid.UUID       `json:"id"`
+  CreatedAt   time.Time       `json:"created_at"`
+  IsChirpyRed bool            `json:"is_chirpy_red"`
+  // Account is the handle other servers follow the user by, or for remote
+  // users their handle on their own server
+  Account     string          `json:"account"`
+  Remote      bool            `json:"remote"`
+  Pinned      []chirpResponse `json:"pinned"`
+}
+
+// handleGetProfile shows a user's public profile. Users who blocked the
+// caller look like they don't exist.
+func (cfg *apiConfig) handleGetProfile(w http.ResponseWriter, r *http.Request) {
+  userID, err := uuid.Parse(r.PathValue("userID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+    return
+  }
+  viewer := cfg.viewerID(r)
+
+  user, err := cfg.db.GetUserById(context.Background(), userID)
+  if err != nil {
+    respondWithError(w, http.StatusNotFound, "User not found", nil)
+    return
+  }
+  blocked, err := cfg.isBlockedBy(context.Background(), viewer, user.ID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching profile", err)
+    return
+  }
+  if blocked {
+    respondWithError(w, http.StatusNotFound, "User not found", nil)
+    return
+  }
+
+  ent, err := cfg.entitlementsFor(context.Background(), user.ID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching profile", err)
+    return
+  }
+  pinned, err := cfg.db.GetPinnedChirps(context.Background(), user.ID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching profile", err)
+    return
+  }
+  pinnedResponse, err := cfg.chirpResponsesWithPinned(context.Background(), pinned, nil, viewer)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching profile", err)
+    return
+  }
+  account := user.ID.String() + "@" + cfg.localDomain()
+  remote, err := cfg.db.GetRemoteActor(context.Background(), user.ID)
+  if err == nil {
+    account = remote.Username + "@" + remote.Domain
+  } else if !errors.Is(err, sql.ErrNoRows) {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching profile", err)
+    return
+  }
+
+  respondWithJSON(w, http.StatusOK, profileResponse{
+    ID:          user.ID,
+    CreatedAt:   user.CreatedAt,
+    IsChirpyRed: ent.Plan == entitlements.PlanChirpyRed,
+    Account:     account,
+    Remote:      err == nil,
+    Pinned:      pinnedResponse,
+  })
+}
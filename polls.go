@@ -0,0 +1,307 @@
+package main
+
+import (
+  "context"
+  "database/sql"
+  "encoding/json"
+  "fmt"
+  "log"
+  "net/http"
+  "strings"
+  "time"
+  "unicode/utf8"
+  "chirpy/internal/database"
+  "chirpy/internal/jobs"
+  "github.com/google/uuid"
+)
+
+const (
+  minPollOptions      = 2
+  maxPollOptions      = 4
+  maxPollOptionLength = 25
+  minPollDuration     = 5 * time.Minute
+  maxPollDuration     = 7 * 24 * time.Hour
+
+  jobFinalizePoll = "poll.finalize"
+)
+
+type pollParams struct {
+  Options  []string  `json:"options"`
+  ClosesAt time.Time `json:"closes_at"`
+}
+
+// validate returns a message for the author if the poll can't be created,
+// or "" if it can.
+func (p pollParams) validate(now time.Time) string {
+  if len(p.Options) < minPollOptions || len(p.Options) > maxPollOptions {
+    return fmt.Sprintf("A poll needs %d to %d options", minPollOptions, maxPollOptions)
+  }
+  seen := map[string]bool{}
+  for _, option := range p.Options {
+    option = strings.TrimSpace(option)
+    if option == "" {
+      return "Poll options can't be empty"
+    }
+    if utf8.RuneCountInString(option) > maxPollOptionLength {
+      return fmt.Sprintf("Poll options can be at most %d characters", maxPollOptionLength)
+    }
+    if seen[strings.ToLower(option)] {
+      return "Poll options must be different"
+    }
+    seen[strings.ToLower(option)] = true
+  }
+  if p.ClosesAt.Before(now.Add(minPollDuration)) || p.ClosesAt.After(now.Add(maxPollDuration)) {
+    return "closes_at must be between 5 minutes and 7 days from now"
+  }
+  return ""
+}
+
+type finalizePollArgs struct {
+  ChirpID uuid.UUID `json:"chirp_id"`
+}
+
+// createPoll attaches a poll to a chirp that is being created in the same
+// transaction, and queues the job that closes it. The options have already
+// been through the content filter.
+func createPoll(ctx context.Context, qtx *database.Queries, chirpID uuid.UUID, params pollParams) error {
+  poll, err := qtx.CreatePoll(ctx, database.CreatePollParams{
+    ChirpID:   chirpID,
+    CreatedAt: time.Now(),
+    ClosesAt:  params.ClosesAt.Local(),
+  })
+  if err != nil {
+    return fmt.Errorf("error creating poll: %v", err)
+  }
+  for i, option := range params.Options {
+    err := qtx.CreatePollOption(ctx, database.CreatePollOptionParams{
+      ChirpID:  chirpID,
+      Position: int32(i),
+      Text:     strings.TrimSpace(option),
+    })
+    if err != nil {
+      return fmt.Errorf("error creating poll option: %v", err)
+    }
+  }
+  _, err = jobs.EnqueueWith(ctx, qtx, jobFinalizePoll, finalizePollArgs{ChirpID: chirpID}, jobs.RunAt(poll.ClosesAt))
+  return err
+}
+
+type pollOptionResponse struct {
+  Position int32  `json:"position"`
+  Text     string `json:"text"`
+  // nil while the results are hidden from the caller
+  Votes *int64 `json:"votes,omitempty"`
+}
+
+type pollResponse struct {
+  ClosesAt   time.Time            `json:"closes_at"`
+  Closed     bool                 `json:"closed"`
+  Options    []pollOptionResponse `json:"options"`
+  TotalVotes *int64               `json:"total_votes,omitempty"`
+  VotedFor   *int32               `json:"voted_for,omitempty"`
+}
+
+// pollsFor loads the polls attached to the given chirps, keyed by chirp ID.
+// Tallies are only filled in once the poll has closed or the viewer has
+// voted in it.
+func (cfg *apiConfig) pollsFor(ctx context.Context, chirpIDs []uuid.UUID, viewer uuid.NullUUID) (map[uuid.UUID]*pollResponse, error) {
+  polls := map[uuid.UUID]*pollResponse{}
+  if len(chirpIDs) == 0 {
+    return polls, nil
+  }
+
+  rows, err := cfg.db.GetPollsForChirps(ctx, chirpIDs)
+  if err != nil {
+    return nil, fmt.Errorf("error fetching polls: %v", err)
+  }
+  if len(rows) == 0 {
+    return polls, nil
+  }
+  pollIDs := make([]uuid.UUID, 0, len(rows))
+  now := time.Now()
+  for _, p := range rows {
+    polls[p.ChirpID] = &pollResponse{
+      ClosesAt: p.ClosesAt,
+      Closed:   !p.ClosesAt.After(now),
+    }
+    pollIDs = append(pollIDs, p.ChirpID)
+  }
+
+  if viewer.Valid {
+    votes, err := cfg.db.GetPollVotesByUser(ctx, database.GetPollVotesByUserParams{
+      UserID:   viewer.UUID,
+      ChirpIds: pollIDs,
+    })
+    if err != nil {
+      return nil, fmt.Errorf("error fetching poll votes: %v", err)
+    }
+    for _, v := range votes {
+      position := v.Position
+      polls[v.ChirpID].VotedFor = &position
+    }
+  }
+
+  tallies, err := cfg.db.GetPollTallies(ctx, pollIDs)
+  if err != nil {
+    return nil, fmt.Errorf("error fetching poll tallies: %v", err)
+  }
+  for _, t := range tallies {
+    poll := polls[t.ChirpID]
+    option := pollOptionResponse{
+      Position: t.Position,
+      Text:     t.Text,
+    }
+    if poll.Closed || poll.VotedFor != nil {
+      votes := t.Votes
+      option.Votes = &votes
+      if poll.TotalVotes == nil {
+        poll.TotalVotes = new(int64)
+      }
+      *poll.TotalVotes += votes
+    }
+    poll.Options = append(poll.Options, option)
+  }
+  return polls, nil
+}
+
+func (cfg *apiConfig) handleVotePoll(w http.ResponseWriter, r *http.Request) {
+  user, err := cfg.authenticatedUser(r)
+  if err != nil {
+    respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+    return
+  }
+  chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+    return
+  }
+
+  var params struct {
+    Option int32 `json:"option"`
+  }
+  err = json.NewDecoder(r.Body).Decode(&params)
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+    return
+  }
+
+  viewer := uuid.NullUUID{UUID: user.ID, Valid: true}
+  _, ok, err := cfg.visibleChirp(context.Background(), chirpID, viewer)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching poll", err)
+    return
+  }
+  if !ok {
+    respondWithError(w, http.StatusNotFound, "Poll not found", nil)
+    return
+  }
+  polls, err := cfg.pollsFor(context.Background(), []uuid.UUID{chirpID}, viewer)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching poll", err)
+    return
+  }
+  poll, ok := polls[chirpID]
+  if !ok {
+    respondWithError(w, http.StatusNotFound, "Poll not found", nil)
+    return
+  }
+  if poll.Closed {
+    respondWithError(w, http.StatusConflict, "Poll is closed", nil)
+    return
+  }
+  if poll.VotedFor != nil {
+    respondWithError(w, http.StatusConflict, "You already voted in this poll", nil)
+    return
+  }
+  if params.Option < 0 || int(params.Option) >= len(poll.Options) {
+    respondWithError(w, http.StatusBadRequest, "Invalid option", nil)
+    return
+  }
+
+  voted, err := cfg.db.CastPollVote(context.Background(), database.CastPollVoteParams{
+    ChirpID:   chirpID,
+    UserID:    user.ID,
+    Position:  params.Option,
+    CreatedAt: time.Now(),
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error casting vote", err)
+    return
+  }
+
+  polls, err = cfg.pollsFor(context.Background(), []uuid.UUID{chirpID}, viewer)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching poll", err)
+    return
+  }
+  // nothing is inserted once the poll has closed, even if it was open when
+  // it was checked above
+  if voted == 0 && polls[chirpID].VotedFor == nil {
+    respondWithError(w, http.StatusConflict, "Poll is closed", nil)
+    return
+  }
+  if voted == 0 {
+    respondWithError(w, http.StatusConflict, "You already voted in this poll", nil)
+    return
+  }
+  respondWithJSON(w, http.StatusOK, polls[chirpID])
+}
+
+// handleFinalizePollJob closes a poll and emails the results to everyone who
+// voted. The emails are queued in the same transaction that marks the poll
+// final, so voters hear about it exactly once.
+func (cfg *apiConfig) handleFinalizePollJob(ctx context.Context, job jobs.Job[finalizePollArgs]) error {
+  tx, err := cfg.sqlDB.BeginTx(ctx, nil)
+  if err != nil {
+    return err
+  }
+  defer tx.Rollback()
+  qtx := cfg.db.WithTx(tx)
+
+  finalized, err := qtx.FinalizePoll(ctx, database.FinalizePollParams{
+    ChirpID:     job.Args.ChirpID,
+    FinalizedAt: sql.NullTime{Time: time.Now(), Valid: true},
+  })
+  if err != nil {
+    return err
+  }
+  if finalized == 0 {
+    // already finalized, or the chirp was deleted
+    return nil
+  }
+
+  tallies, err := qtx.GetPollTallies(ctx, []uuid.UUID{job.Args.ChirpID})
+  if err != nil {
+    return err
+  }
+  var results strings.Builder
+  for _, t := range tallies {
+    fmt.Fprintf(&results, "  %s: %d\n", t.Text, t.Votes)
+  }
+  body := "A poll you voted in has closed. The final results are:\n\n" + results.String() +
+    "\nSee the chirp at " + cfg.BaseURL + "/api/chirps/" + job.Args.ChirpID.String() + "\n"
+
+  emails, err := qtx.ListPollVoterEmails(ctx, job.Args.ChirpID)
+  if err != nil {
+    return err
+  }
+  for _, email := range emails {
+    _, err := jobs.EnqueueWith(ctx, qtx, jobSendEmail, emailArgs{
+      To:      email,
+      Subject: "Poll results are in",
+      Body:    body,
+    }, jobs.MaxAttempts(5))
+    if err != nil {
+      return err
+    }
+  }
+
+  err = tx.Commit()
+  if err != nil {
+    return err
+  }
+  if len(emails) > 0 {
+    log.Printf("Poll on chirp %s closed, notifying %d voters", job.Args.ChirpID, len(emails))
+  }
+  return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+  "context"
+  "database/sql"
+  "errors"
+  "fmt"
+  "net/http"
+  "time"
+  "chirpy/internal/database"
+  "github.com/google/uuid"
+)
+
+// quotedChirp is the snapshot of a quoted chirp embedded in the quote. When
+// the chirp has been deleted, or is otherwise hidden from the viewer, only
+// the ID is shown.
+type quotedChirp struct {
+  ID        uuid.UUID  `json:"id"`
+  Available bool       `json:"available"`
+  CreatedAt *time.Time `json:"created_at,omitempty"`
+  Body      string     `json:"body,omitempty"`
+  UserID    *uuid.UUID `json:"user_id,omitempty"`
+}
+
+// quotedChirpsFor loads the snapshots of the chirps quoted by chirps, keyed
+// by the quoting chirp's ID. The snapshot is only shown while the quoted
+// chirp is still there for the viewer to see.
+func (cfg *apiConfig) quotedChirpsFor(ctx context.Context, chirps []database.Chirp, viewer uuid.NullUUID) (map[uuid.UUID]*quotedChirp, error) {
+  quoted := map[uuid.UUID]*quotedChirp{}
+  var quoteIDs, originalIDs []uuid.UUID
+  for _, c := range chirps {
+    if c.QuoteOf.Valid {
+      quoted[c.ID] = &quotedChirp{ID: c.QuoteOf.UUID}
+      quoteIDs = append(quoteIDs, c.ID)
+      originalIDs = append(originalIDs, c.QuoteOf.UUID)
+    }
+  }
+  if len(quoteIDs) == 0 {
+    return quoted, nil
+  }
+
+  originals, err := cfg.db.GetChirpsByIDs(ctx, originalIDs)
+  if err != nil {
+    return nil, fmt.Errorf("error fetching quoted chirps: %v", err)
+  }
+  originals, err = cfg.visibleChirps(ctx, originals, viewer)
+  if err != nil {
+    return nil, err
+  }
+  authors := make([]uuid.UUID, 0, len(originals))
+  for _, o := range originals {
+    authors = append(authors, o.UserID)
+  }
+  blockers, err := cfg.blockersAmong(ctx, viewer, authors)
+  if err != nil {
+    return nil, err
+  }
+  available := map[uuid.UUID]bool{}
+  for _, o := range originals {
+    available[o.ID] = !blockers[o.UserID]
+  }
+
+  snapshots, err := cfg.db.GetQuoteSnapshots(ctx, quoteIDs)
+  if err != nil {
+    return nil, fmt.Errorf("error fetching quote snapshots: %v", err)
+  }
+  for _, snapshot := range snapshots {
+    if !available[snapshot.QuotedID] {
+      continue
+    }
+    q := quoted[snapshot.ChirpID]
+    q.Available = true
+    q.CreatedAt = &snapshot.CreatedAt
+    q.Body = snapshot.Body
+    q.UserID = &snapshot.UserID
+  }
+  return quoted, nil
+}
+
+// checkQuotable fetches the chirp userID wants to quote, or returns a
+// message for the author if they can't quote it. A chirp userID can't see
+// looks the same as one that doesn't exist, and so does their own held
+// chirp, whose text the quote would otherwise show to everyone.
+func (cfg *apiConfig) checkQuotable(ctx context.Context, userID, quoteOf uuid.UUID) (database.Chirp, string, error) {
+  original, ok, err := cfg.visibleChirp(ctx, quoteOf, uuid.NullUUID{UUID: userID, Valid: true})
+  if err != nil {
+    return database.Chirp{}, "", err
+  }
+  if !ok || original.HeldAt.Valid {
+    return database.Chirp{}, "Quoted chirp not found", nil
+  }
+  return original, "", nil
+}
+
+// createQuoteSnapshot records what quoted said as chirpID quotes it, in the
+// transaction that creates chirpID.
+func createQuoteSnapshot(ctx context.Context, qtx *database.Queries, chirpID uuid.UUID, quoted database.Chirp) error {
+  err := qtx.CreateQuoteSnapshot(ctx, database.CreateQuoteSnapshotParams{
+    ChirpID:   chirpID,
+    QuotedID:  quoted.ID,
+    UserID:    quoted.UserID,
+    Body:      quoted.Body,
+    CreatedAt: quoted.CreatedAt,
+  })
+  if err != nil {
+    return fmt.Errorf("error creating quote snapshot: %v", err)
+  }
+  return nil
+}
+
+// handleGetQuotes lists the chirps quoting a chirp, newest first, leaving
+// out quotes from authors who blocked the caller.
+func (cfg *apiConfig) handleGetQuotes(w http.ResponseWriter, r *http.Request) {
+  chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+    return
+  }
+  viewer := cfg.viewerID(r)
+
+  original, err := cfg.db.GetOneChirp(context.Background(), chirpID)
+  if err != nil && !errors.Is(err, sql.ErrNoRows) {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching chirp", err)
+    return
+  }
+  if err == nil {
+    blocked, err := cfg.isBlockedBy(context.Background(), viewer, original.UserID)
+    if err != nil {
+      respondWithError(w, http.StatusInternalServerError, "Error fetching chirp", err)
+      return
+    }
+    if blocked {
+      respondWithError(w, http.StatusNotFound, "Chirp not found", nil)
+      return
+    }
+  }
+
+  quotes, err := cfg.db.GetQuotesOfChirp(context.Background(), database.GetQuotesOfChirpParams{
+    QuoteOf:  uuid.NullUUID{UUID: chirpID, Valid: true},
+    ViewerID: viewer,
+  })
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error listing quotes", err)
+    return
+  }
+
+  response, err := cfg.chirpResponses(context.Background(), quotes, viewer)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error listing quotes", err)
+    return
+  }
+  respondWithJSON(w, http.StatusOK, response)
+}
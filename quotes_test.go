@@ -0,0 +1,60 @@
+package main
+
+import (
+  "context"
+  "database/sql"
+  "testing"
+  "time"
+  "chirpy/internal/database"
+)
+
+func TestCheckQuotableHidesModeratedChirps(t *testing.T) {
+  cfg := testConfig(t)
+  ctx := context.Background()
+  quoter, _ := testUser(t, cfg)
+  author, _ := testUser(t, cfg)
+
+  visible := testChirp(t, cfg, author.ID, "fine to quote")
+  _, msg, err := cfg.checkQuotable(ctx, quoter.ID, visible.ID)
+  if err != nil || msg != "" {
+    t.Fatalf("checkQuotable(visible) = %q, %v; want quotable", msg, err)
+  }
+
+  held := testChirp(t, cfg, author.ID, "waiting for a moderator")
+  err = cfg.db.HoldChirp(ctx, database.HoldChirpParams{
+    ID:     held.ID,
+    HeldAt: sql.NullTime{Time: time.Now(), Valid: true},
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  ownHeld := testChirp(t, cfg, quoter.ID, "my own, also held")
+  err = cfg.db.HoldChirp(ctx, database.HoldChirpParams{
+    ID:     ownHeld.ID,
+    HeldAt: sql.NullTime{Time: time.Now(), Valid: true},
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  banned, _ := testUser(t, cfg)
+  shadowBanned := testChirp(t, cfg, banned.ID, "nobody sees this")
+  err = cfg.db.SetAccountStatus(ctx, database.SetAccountStatusParams{ID: banned.ID, AccountStatus: accountShadowBanned})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  for name, chirp := range map[string]database.Chirp{
+    "held":          held,
+    "own held":      ownHeld,
+    "shadow-banned": shadowBanned,
+  } {
+    _, msg, err := cfg.checkQuotable(ctx, quoter.ID, chirp.ID)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if msg != "Quoted chirp not found" {
+      t.Errorf("checkQuotable(%s) = %q, want not found", name, msg)
+    }
+  }
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+  "context"
+  "crypto/sha256"
+  "crypto/subtle"
+  "encoding/hex"
+  "log"
+  "net/http"
+  "os"
+  "strings"
+  "time"
+  "chirpy/internal/auth"
+  "chirpy/internal/entitlements"
+  "chirpy/internal/ratelimit"
+)
+
+const (
+  rateLimitChirpCreate = "chirp_create"
+  rateLimitLogin       = "login"
+  rateLimitSignup      = "signup"
+  rateLimitRead        = "read"
+)
+
+// rateLimitPolicy pairs the default quota with the one Chirpy Red members get.
+type rateLimitPolicy struct {
+  standard ratelimit.Policy
+  red      ratelimit.Policy
+}
+
+var defaultRateLimits = map[string][2]string{
+  rateLimitChirpCreate: {"30/1h", "120/1h"},
+  rateLimitLogin:       {"10/1m", "10/1m"},
+  rateLimitSignup:      {"5/1h", "5/1h"},
+  rateLimitRead:        {"120/1m", "600/1m"},
+}
+
+// loadRateLimitPolicies reads RATE_LIMIT_<NAME> and RATE_LIMIT_<NAME>_RED,
+// falling back to defaultRateLimits. Values look like "30/1m".
+func loadRateLimitPolicies() (map[string]rateLimitPolicy, error) {
+  policies := make(map[string]rateLimitPolicy, len(defaultRateLimits))
+  for name, defaults := range defaultRateLimits {
+    env := "RATE_LIMIT_" + strings.ToUpper(name)
+
+    spec := defaults[0]
+    if v := os.Getenv(env); v != "" {
+      spec = v
+    }
+    standard, err := ratelimit.ParsePolicy(name, spec)
+    if err != nil {
+      return nil, err
+    }
+
+    redSpec := defaults[1]
+    if v := os.Getenv(env + "_RED"); v != "" {
+      redSpec = v
+    }
+    red, err := ratelimit.ParsePolicy(name+"_red", redSpec)
+    if err != nil {
+      return nil, err
+    }
+
+    policies[name] = rateLimitPolicy{standard: standard, red: red}
+  }
+  return policies, nil
+}
+
+// newRateLimitStore shares buckets through Postgres when RATE_LIMIT_STORE is
+// "postgres" and keeps them in memory otherwise.
+func newRateLimitStore(cfg *apiConfig) ratelimit.Store {
+  if os.Getenv("RATE_LIMIT_STORE") == "postgres" {
+    return ratelimit.NewPostgresStore(cfg.db)
+  }
+  return ratelimit.NewMemoryStore()
+}
+
+// rateLimitKey identifies the caller by user ID when the request carries a
+// valid JWT, by API key when it carries the configured one, and by client IP
+// otherwise. It also reports whether the caller's plan comes with the higher
+// quotas.
+func (cfg *apiConfig) rateLimitKey(r *http.Request) (string, bool) {
+  if token, err := auth.GetBearerToken(r.Header); err == nil {
+    if userID, err := cfg.validateAccessToken(token); err == nil {
+      ent, err := cfg.entitlementsFor(context.Background(), userID)
+      if err == nil {
+        return "user:" + userID.String(), ent.RateLimitTier == entitlements.TierRed
+      }
+    }
+  }
+
+  // a key that isn't checked would let callers pick a fresh bucket per
+  // request, so only the configured key gets one
+  if apiKey, err := auth.GetAPIKey(r.Header); err == nil && cfg.PolkaKey != "" &&
+    subtle.ConstantTimeCompare([]byte(apiKey), []byte(cfg.PolkaKey)) == 1 {
+    sum := sha256.Sum256([]byte(apiKey))
+    return "key:" + hex.EncodeToString(sum[:]), false
+  }
+
+  return "ip:" + clientIP(r), false
+}
+
+// rateLimit meters next under the named policy and answers 429 once the
+// caller runs out of tokens. If the store fails, requests are let through.
+func (cfg *apiConfig) rateLimit(name string, next http.HandlerFunc) http.HandlerFunc {
+  return func(w http.ResponseWriter, r *http.Request) {
+    rule, ok := cfg.rateLimitPolicies[name]
+    if !ok {
+      next(w, r)
+      return
+    }
+
+    key, isRed := cfg.rateLimitKey(r)
+    policy := rule.standard
+    if isRed {
+      policy = rule.red
+    }
+
+    ctx, cancel := context.WithTimeout(r.Context(), time.Second)
+    defer cancel()
+    res, err := cfg.rateLimitStore.Take(ctx, name+":"+key, policy)
+    if err != nil {
+      log.Printf("Error checking rate limit: %v", err)
+      next(w, r)
+      return
+    }
+
+    res.WriteHeaders(policy, w.Header())
+    if !res.Allowed {
+      respondWithError(w, http.StatusTooManyRequests, "Rate limit exceeded", nil)
+      return
+    }
+    next(w, r)
+  }
+}
@@ -32,11 +32,26 @@ func (apiCfg *apiConfig) handleRefreshToken(w http.ResponseWriter, r *http.Reque
     return
   }
 
+  user, err := apiCfg.db.GetUserById(context.Background(), tokenStruct.UserID)
+  if err != nil {
+    http.Error(w, "Status Unauthorized", http.StatusUnauthorized)
+    return
+  }
+  if accountRestriction(user, time.Now()) != "" {
+    http.Error(w, "Status Unauthorized", http.StatusUnauthorized)
+    return
+  }
+
   accessToken, err := auth.MakeJWT(tokenStruct.UserID, apiCfg.SecretKey)
   if err != nil {
     http.Error(w, "Internal Server Error: unable to create access token", http.StatusInternalServerError)
     return
   }
+  apiCfg.audit(r, auditEntry{
+    Type:    auditTokenRefreshed,
+    ActorID: auditUser(user.ID),
+    UserID:  auditUser(user.ID),
+  })
 
 
   w.Header().Set("Content-Type", "application/json")
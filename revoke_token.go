@@ -19,6 +19,13 @@ func (apiCfg *apiConfig) handleRevokeToken(w http.ResponseWriter, r *http.Reques
     http.Error(w, "Unauthorized", http.StatusUnauthorized)
     return
   }
+  if refreshToken, err := apiCfg.db.GetToken(context.Background(), token); err == nil {
+    apiCfg.audit(r, auditEntry{
+      Type:    auditTokenRevoked,
+      ActorID: auditUser(refreshToken.UserID),
+      UserID:  auditUser(refreshToken.UserID),
+    })
+  }
 
   w.WriteHeader(http.StatusNoContent)
 }
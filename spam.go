@@ -0,0 +1,95 @@
+package main
+
+import (
+  "context"
+  "database/sql"
+  "encoding/json"
+  "fmt"
+  "log"
+  "os"
+  "strconv"
+  "time"
+  "chirpy/internal/database"
+  "chirpy/internal/spam"
+  "github.com/google/uuid"
+)
+
+// loadSpamConfig reads the spam thresholds from SPAM_HOLD_THRESHOLD and
+// SPAM_REJECT_THRESHOLD.
+func loadSpamConfig() (spam.Config, error) {
+  config := spam.DefaultConfig
+  for env, dst := range map[string]*int{
+    "SPAM_HOLD_THRESHOLD":   &config.HoldThreshold,
+    "SPAM_REJECT_THRESHOLD": &config.RejectThreshold,
+  } {
+    if v := os.Getenv(env); v != "" {
+      n, err := strconv.Atoi(v)
+      if err != nil || n <= 0 {
+        return config, fmt.Errorf("invalid %s: %q", env, v)
+      }
+      *dst = n
+    }
+  }
+  if config.RejectThreshold < config.HoldThreshold {
+    return config, fmt.Errorf("SPAM_REJECT_THRESHOLD is below SPAM_HOLD_THRESHOLD")
+  }
+  return config, nil
+}
+
+// spamLogEntry is the line logged for every scored chirp. Lines can be fed
+// back through cmd/spamscore to see how other thresholds would have done.
+type spamLogEntry struct {
+  UserID uuid.UUID   `json:"user_id"`
+  Input  spam.Input  `json:"input"`
+  Result spam.Result `json:"result"`
+}
+
+// scoreChirp scores a chirp userID is about to post and logs the signals.
+func (cfg *apiConfig) scoreChirp(ctx context.Context, userID uuid.UUID, body string) (spam.Result, error) {
+  user, err := cfg.db.GetUserById(ctx, userID)
+  if err != nil {
+    return spam.Result{}, fmt.Errorf("error fetching user: %v", err)
+  }
+  now := time.Now()
+  counts, err := cfg.db.GetSpamCounts(ctx, database.GetSpamCountsParams{
+    UserID: userID,
+    Body:   body,
+    Since:  now.Add(-cfg.spam.Window),
+  })
+  if err != nil {
+    return spam.Result{}, fmt.Errorf("error counting recent chirps: %v", err)
+  }
+
+  input := spam.Input{
+    Body:               body,
+    PostedAt:           now,
+    AccountCreatedAt:   user.CreatedAt,
+    RecentChirps:       int(counts.RecentChirps),
+    DuplicatesByAuthor: int(counts.DuplicatesByAuthor),
+    DuplicatesByOthers: int(counts.DuplicatesByOthers),
+  }
+  result := cfg.spam.Score(input)
+
+  entry, err := json.Marshal(spamLogEntry{UserID: userID, Input: input, Result: result})
+  if err == nil {
+    log.Printf("spam score: %s", entry)
+  }
+  return result, nil
+}
+
+// checkSpam scores a chirp userID is about to post, or edit into place, and
+// applies the verdict: a score past the reject threshold comes back as a
+// chirpRejection, and one past the hold threshold is added to hold.
+func (cfg *apiConfig) checkSpam(ctx context.Context, userID uuid.UUID, body string, hold *chirpHold) error {
+  score, err := cfg.scoreChirp(ctx, userID, body)
+  if err != nil {
+    return err
+  }
+  switch score.Verdict {
+  case spam.VerdictReject:
+    return chirpRejection{msg: "Chirp looks like spam"}
+  case spam.VerdictHold:
+    hold.spamScore = sql.NullInt32{Int32: int32(score.Score), Valid: true}
+  }
+  return nil
+}
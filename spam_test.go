@@ -0,0 +1,131 @@
+package main
+
+import (
+  "context"
+  "errors"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+  "time"
+  "chirpy/internal/database"
+  "chirpy/internal/spam"
+  "github.com/google/uuid"
+)
+
+// linkSpam scores 50 with the default heuristics: two links past the first
+// and a body that is mostly links.
+const linkSpam = "https://a.example https://b.example https://c.example"
+
+func testChirp(t *testing.T, cfg *apiConfig, userID uuid.UUID, body string) database.Chirp {
+  t.Helper()
+  now := time.Now()
+  chirp, err := cfg.db.CreateChirp(context.Background(), database.CreateChirpParams{
+    ID:        uuid.New(),
+    CreatedAt: now,
+    UpdatedAt: now,
+    Body:      body,
+    UserID:    userID,
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  return chirp
+}
+
+func editChirp(cfg *apiConfig, token string, chirpID uuid.UUID, body string) *httptest.ResponseRecorder {
+  req := httptest.NewRequest(http.MethodPut, "/api/chirps/"+chirpID.String(), strings.NewReader(`{"body":"`+body+`"}`))
+  req.SetPathValue("chirpID", chirpID.String())
+  req.Header.Set("Authorization", "Bearer "+token)
+  w := httptest.NewRecorder()
+  cfg.handleUpdateChirp(w, req)
+  return w
+}
+
+func TestEditIntoSpamIsRejected(t *testing.T) {
+  cfg := testConfig(t)
+  cfg.spam = spam.Config{HoldThreshold: 20, RejectThreshold: 40, Window: time.Minute}
+  user, token := testUser(t, cfg)
+  subscribe(t, cfg, user.ID)
+  chirp := testChirp(t, cfg, user.ID, "nothing to see here")
+
+  w := editChirp(cfg, token, chirp.ID, linkSpam)
+  if w.Code != http.StatusBadRequest {
+    t.Fatalf("edit = %d %s, want 400", w.Code, w.Body)
+  }
+  after, err := cfg.db.GetOneChirp(context.Background(), chirp.ID)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if after.Body != chirp.Body {
+    t.Errorf("body = %q after a rejected edit", after.Body)
+  }
+}
+
+func TestEditIntoSpamIsHeld(t *testing.T) {
+  cfg := testConfig(t)
+  cfg.spam = spam.Config{HoldThreshold: 40, RejectThreshold: 100, Window: time.Minute}
+  user, token := testUser(t, cfg)
+  subscribe(t, cfg, user.ID)
+  chirp := testChirp(t, cfg, user.ID, "nothing to see here")
+
+  w := editChirp(cfg, token, chirp.ID, linkSpam)
+  if w.Code != http.StatusOK {
+    t.Fatalf("edit = %d %s, want 200", w.Code, w.Body)
+  }
+  after, err := cfg.db.GetOneChirp(context.Background(), chirp.ID)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if after.Body != linkSpam || !after.HeldAt.Valid {
+    t.Errorf("%+v, want the edit held for review", after)
+  }
+}
+
+func testDraft(t *testing.T, cfg *apiConfig, userID uuid.UUID, body string) database.ChirpDraft {
+  t.Helper()
+  draft, err := cfg.db.CreateDraft(context.Background(), database.CreateDraftParams{
+    ID:        uuid.New(),
+    CreatedAt: time.Now(),
+    UserID:    userID,
+    Body:      body,
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  return draft
+}
+
+func anyDraft(database.ChirpDraft) bool { return true }
+
+func TestPublishSpamDraftIsRejected(t *testing.T) {
+  cfg := testConfig(t)
+  cfg.spam = spam.Config{HoldThreshold: 20, RejectThreshold: 40, Window: time.Minute}
+  user, _ := testUser(t, cfg)
+  draft := testDraft(t, cfg, user.ID, linkSpam)
+
+  _, err := cfg.publishDraft(context.Background(), draft.ID, anyDraft)
+  var rejection chirpRejection
+  if !errors.As(err, &rejection) {
+    t.Fatalf("publishDraft = %v, want a rejection", err)
+  }
+  _, err = cfg.db.GetDraft(context.Background(), database.GetDraftParams{ID: draft.ID, UserID: user.ID})
+  if err != nil {
+    t.Errorf("the rejected draft is gone: %v", err)
+  }
+}
+
+func TestPublishSpamDraftIsHeld(t *testing.T) {
+  cfg := testConfig(t)
+  cfg.spam = spam.Config{HoldThreshold: 40, RejectThreshold: 100, Window: time.Minute}
+  user, _ := testUser(t, cfg)
+  draft := testDraft(t, cfg, user.ID, linkSpam)
+
+  chirp, err := cfg.publishDraft(context.Background(), draft.ID, anyDraft)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if !chirp.HeldAt.Valid {
+    t.Errorf("%+v, want the chirp held for review", chirp)
+  }
+}
@@ -0,0 +1,134 @@
+-- name: CreateActorKey :exec
+INSERT INTO actor_keys (user_id, created_at, public_key_pem, private_key_pem)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id) DO NOTHING;
+
+-- name: GetActorKey :one
+SELECT * FROM actor_keys
+WHERE user_id = $1;
+
+-- name: CreateRemoteActor :one
+INSERT INTO remote_actors (user_id, created_at, updated_at, actor_id, username, domain, inbox, shared_inbox, key_id, public_key_pem)
+VALUES ($1, $2, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING *;
+
+-- name: UpdateRemoteActor :one
+UPDATE remote_actors
+SET username = $2, inbox = $3, shared_inbox = $4, key_id = $5, public_key_pem = $6, updated_at = CURRENT_TIMESTAMP
+WHERE user_id = $1
+RETURNING *;
+
+-- name: GetRemoteActor :one
+SELECT * FROM remote_actors
+WHERE user_id = $1;
+
+-- name: GetRemoteActorByActorID :one
+SELECT * FROM remote_actors
+WHERE actor_id = $1;
+
+-- name: GetRemoteActorByKeyID :one
+SELECT * FROM remote_actors
+WHERE key_id = $1
+LIMIT 1;
+
+-- name: ListRemoteInboxes :many
+SELECT DISTINCT COALESCE(shared_inbox, inbox)::text AS inbox
+FROM remote_actors
+WHERE user_id = ANY(sqlc.arg(user_ids)::uuid[]);
+
+-- a follow made again keeps its acceptance
+-- name: CreateFollow :one
+INSERT INTO follows (follower_id, followee_id, created_at, activity_id, accepted_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (follower_id, followee_id) DO UPDATE
+SET activity_id = excluded.activity_id,
+    accepted_at = COALESCE(follows.accepted_at, excluded.accepted_at)
+RETURNING *;
+
+-- name: AcceptFollow :execrows
+UPDATE follows
+SET accepted_at = $3
+WHERE activity_id = $1 AND followee_id = $2 AND accepted_at IS NULL;
+
+-- an Undo from the follower or a Reject from the followee
+-- name: DeleteFollowByActivity :execrows
+DELETE FROM follows
+WHERE activity_id = sqlc.arg(activity_id)
+  AND (follower_id = sqlc.arg(user_id) OR followee_id = sqlc.arg(user_id));
+
+-- name: DeleteFollow :one
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2
+RETURNING activity_id;
+
+-- name: ListFollowerInboxes :many
+SELECT DISTINCT COALESCE(remote_actors.shared_inbox, remote_actors.inbox)::text AS inbox
+FROM follows
+JOIN remote_actors ON remote_actors.user_id = follows.follower_id
+WHERE follows.followee_id = $1 AND follows.accepted_at IS NOT NULL;
+
+-- name: ListFollowing :many
+SELECT remote_actors.user_id, remote_actors.actor_id, remote_actors.username, remote_actors.domain,
+  follows.created_at, follows.accepted_at
+FROM follows
+JOIN remote_actors ON remote_actors.user_id = follows.followee_id
+WHERE follows.follower_id = $1
+ORDER BY follows.created_at DESC;
+
+-- name: ListFollowers :many
+SELECT remote_actors.user_id, remote_actors.actor_id, remote_actors.username, remote_actors.domain,
+  follows.created_at, follows.accepted_at
+FROM follows
+JOIN remote_actors ON remote_actors.user_id = follows.follower_id
+WHERE follows.followee_id = $1
+ORDER BY follows.created_at DESC;
+
+-- name: CountFollowers :one
+SELECT COUNT(*) FROM follows
+WHERE followee_id = $1 AND accepted_at IS NOT NULL;
+
+-- name: CountFollowing :one
+SELECT COUNT(*) FROM follows
+WHERE follower_id = $1 AND accepted_at IS NOT NULL;
+
+-- name: IsFollowed :one
+SELECT EXISTS (
+  SELECT 1 FROM follows
+  WHERE followee_id = $1 AND accepted_at IS NOT NULL
+);
+
+-- name: CreateRemoteChirp :exec
+INSERT INTO remote_chirps (object_id, chirp_id)
+VALUES ($1, $2);
+
+-- name: GetRemoteChirpID :one
+SELECT chirp_id FROM remote_chirps
+WHERE object_id = $1;
+
+-- name: GetRemoteChirpObjectID :one
+SELECT object_id FROM remote_chirps
+WHERE chirp_id = $1;
+
+-- name: CreateChirpReaction :exec
+INSERT INTO chirp_reactions (activity_id, created_at, chirp_id, user_id, type)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteChirpReaction :execrows
+DELETE FROM chirp_reactions
+WHERE activity_id = $1 AND user_id = $2;
+
+-- name: CountChirpReactions :one
+SELECT
+  COUNT(*) FILTER (WHERE type = 'Like')::int AS likes,
+  COUNT(*) FILTER (WHERE type = 'Announce')::int AS announces
+FROM chirp_reactions
+WHERE chirp_id = $1;
+
+-- name: ListReactionsToUserChirps :many
+SELECT chirp_reactions.chirp_id, chirp_reactions.type, chirp_reactions.created_at, remote_actors.actor_id
+FROM chirp_reactions
+JOIN chirps ON chirps.id = chirp_reactions.chirp_id
+JOIN remote_actors ON remote_actors.user_id = chirp_reactions.user_id
+WHERE chirps.user_id = $1
+ORDER BY chirp_reactions.created_at DESC;
@@ -0,0 +1,65 @@
+-- name: CreateBookmarkCollection :one
+INSERT INTO bookmark_collections (id, created_at, updated_at, user_id, name)
+VALUES (
+  $1,
+  $2,
+  $2,
+  $3,
+  $4
+)
+RETURNING *;
+
+-- name: GetBookmarkCollection :one
+SELECT *
+FROM bookmark_collections
+WHERE id = $1 AND user_id = $2;
+
+-- name: ListBookmarkCollections :many
+SELECT *
+FROM bookmark_collections
+WHERE user_id = $1
+ORDER BY name ASC;
+
+-- name: RenameBookmarkCollection :one
+UPDATE bookmark_collections
+SET name = $3, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: DeleteBookmarkCollection :execrows
+DELETE FROM bookmark_collections
+WHERE id = $1 AND user_id = $2;
+
+-- name: UpsertBookmark :exec
+INSERT INTO bookmarks (user_id, chirp_id, collection_id, created_at)
+VALUES (
+  $1,
+  $2,
+  $3,
+  $4
+)
+ON CONFLICT (user_id, chirp_id) DO UPDATE
+SET collection_id = EXCLUDED.collection_id;
+
+-- name: DeleteBookmark :execrows
+DELETE FROM bookmarks
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: ListBookmarks :many
+SELECT chirps.*, bookmarks.created_at AS bookmarked_at
+FROM bookmarks
+JOIN chirps ON chirps.id = bookmarks.chirp_id
+WHERE bookmarks.user_id = sqlc.arg(user_id)
+  AND chirps.deleted_at IS NULL
+  AND (sqlc.narg(collection_id)::uuid IS NULL OR bookmarks.collection_id = sqlc.narg(collection_id)::uuid)
+  AND (
+    sqlc.narg(before_created_at)::timestamp IS NULL
+    OR (bookmarks.created_at, bookmarks.chirp_id) < (sqlc.narg(before_created_at)::timestamp, sqlc.narg(before_chirp_id)::uuid)
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (user_blocks.blocker_id = chirps.user_id AND user_blocks.blocked_id = sqlc.arg(user_id))
+       OR (user_blocks.blocker_id = sqlc.arg(user_id) AND user_blocks.blocked_id = chirps.user_id)
+  )
+ORDER BY bookmarks.created_at DESC, bookmarks.chirp_id DESC
+LIMIT sqlc.arg(row_limit);
@@ -0,0 +1,59 @@
+-- name: CreateDraft :one
+INSERT INTO chirp_drafts (id, created_at, updated_at, user_id, body, status)
+VALUES (
+  $1,
+  $2,
+  $2,
+  $3,
+  $4,
+  'draft'
+)
+RETURNING *;
+
+-- name: GetDraft :one
+SELECT *
+FROM chirp_drafts
+WHERE id = $1 AND user_id = $2;
+
+-- name: ListDrafts :many
+SELECT *
+FROM chirp_drafts
+WHERE user_id = sqlc.arg(user_id)
+  AND (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status)::text)
+ORDER BY updated_at DESC;
+
+-- name: UpdateDraftBody :one
+UPDATE chirp_drafts
+SET body = $3,
+    status = CASE WHEN status = 'failed' THEN 'draft' ELSE status END,
+    last_error = NULL,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: ScheduleDraft :one
+UPDATE chirp_drafts
+SET status = 'scheduled', publish_at = $3, last_error = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: UnscheduleDraft :one
+UPDATE chirp_drafts
+SET status = 'draft', publish_at = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND user_id = $2 AND status = 'scheduled'
+RETURNING *;
+
+-- name: LockDraft :one
+SELECT *
+FROM chirp_drafts
+WHERE id = $1
+FOR UPDATE;
+
+-- name: FailDraft :exec
+UPDATE chirp_drafts
+SET status = 'failed', last_error = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: DeleteDraft :execrows
+DELETE FROM chirp_drafts
+WHERE id = $1 AND user_id = $2;
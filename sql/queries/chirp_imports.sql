@@ -0,0 +1,56 @@
+-- name: CreateChirpImport :one
+INSERT INTO chirp_imports (id, created_at, updated_at, user_id, source, status, dry_run, long_posts)
+VALUES (
+  $1,
+  $2,
+  $2,
+  $3,
+  $4,
+  'pending',
+  $5,
+  $6
+)
+RETURNING *;
+
+-- name: GetChirpImport :one
+SELECT *
+FROM chirp_imports
+WHERE id = $1;
+
+-- name: ListChirpImportsByUser :many
+SELECT *
+FROM chirp_imports
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT 20;
+
+-- name: StartChirpImport :exec
+UPDATE chirp_imports
+SET status = 'running', total = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND status IN ('pending', 'running');
+
+-- name: UpdateChirpImportProgress :exec
+UPDATE chirp_imports
+SET processed = $2, stats = $3, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: FinishChirpImport :exec
+UPDATE chirp_imports
+SET status = $2, completed_at = $3, last_error = $4, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND status IN ('pending', 'running');
+
+-- name: ListImportedPostIDs :many
+SELECT source_id
+FROM imported_posts
+WHERE user_id = $1 AND source = $2;
+
+-- name: RecordImportedPost :execrows
+INSERT INTO imported_posts (user_id, source, source_id, chirp_id, created_at)
+VALUES (
+  $1,
+  $2,
+  $3,
+  $4,
+  $5
+)
+ON CONFLICT DO NOTHING;
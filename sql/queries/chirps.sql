@@ -1,36 +1,108 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, quote_of)
 VALUES (
   $1,
   $2,
   $3,
   $4,
-  $5
+  $5,
+  $6
 )
 RETURNING *;
 
 -- name: GetAllChirps :many
 SELECT * FROM chirps
+WHERE deleted_at IS NULL
 ORDER BY created_at ASC;
 
 -- name: GetAllChirpsDesc :many 
 SELECT * FROM chirps 
+WHERE deleted_at IS NULL
 ORDER BY created_at DESC;
 
 -- name: GetOneChirp :one
 SELECT * FROM chirps
-WHERE id = $1;
+WHERE id = $1 AND deleted_at IS NULL;
 
--- name: GetChirpsByAuthor :many
+-- name: GetChirpsByAuthors :many
 SELECT * FROM chirps
-WHERE user_id = $1;
+WHERE user_id = ANY(sqlc.arg(user_ids)::uuid[]) AND deleted_at IS NULL
+ORDER BY created_at ASC;
 
--- name: GetChirpsByAuthorDesc :many 
-SELECT * FROM chirps 
-WHERE user_id = $1
+-- name: GetChirpsByAuthorsDesc :many
+SELECT * FROM chirps
+WHERE user_id = ANY(sqlc.arg(user_ids)::uuid[]) AND deleted_at IS NULL
 ORDER BY created_at DESC;
 
+-- name: GetLatestChirpsByAuthor :many
+SELECT * FROM chirps
+WHERE user_id = sqlc.arg(user_id)
+  AND deleted_at IS NULL
+  AND held_at IS NULL
+ORDER BY created_at DESC
+LIMIT sqlc.arg(row_limit);
+
+-- name: GetChirpsByHashtagDesc :many
+SELECT * FROM chirps
+WHERE body ~* ('(^|[^[:alnum:]_])#' || sqlc.arg(tag)::text || '($|[^[:alnum:]_])')
+  AND deleted_at IS NULL
+  AND held_at IS NULL
+ORDER BY created_at DESC
+LIMIT sqlc.arg(row_limit);
+
 -- name: DeleteOneChirp :exec
-DELETE FROM chirps 
-WHERE id = $1 AND user_id = $2; 
+UPDATE chirps
+SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg(ids)::uuid[]) AND deleted_at IS NULL;
+
+-- name: GetQuotesOfChirp :many
+SELECT * FROM chirps
+WHERE quote_of = sqlc.arg(quote_of)
+  AND deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE user_blocks.blocker_id = chirps.user_id
+      AND user_blocks.blocked_id = sqlc.narg(viewer_id)
+  )
+ORDER BY created_at DESC;
+
+-- name: RemoveChirp :execrows
+UPDATE chirps
+SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: HoldChirp :exec
+UPDATE chirps
+SET held_at = $2
+WHERE id = $1;
+
+-- name: ReleaseChirp :exec
+UPDATE chirps
+SET held_at = NULL
+WHERE id = $1;
+
+-- name: GetSpamCounts :one
+SELECT
+  COUNT(*) FILTER (WHERE user_id = sqlc.arg(user_id))::int AS recent_chirps,
+  COUNT(*) FILTER (WHERE user_id = sqlc.arg(user_id) AND lower(body) = lower(sqlc.arg(body)))::int AS duplicates_by_author,
+  COUNT(DISTINCT user_id) FILTER (WHERE user_id <> sqlc.arg(user_id) AND lower(body) = lower(sqlc.arg(body)))::int AS duplicates_by_others
+FROM chirps
+WHERE created_at > sqlc.arg(since) AND (user_id = sqlc.arg(user_id) OR lower(body) = lower(sqlc.arg(body)));
 
+-- name: AnonymizeRepliesByAuthor :execrows
+UPDATE chirps
+SET user_id = sqlc.arg(anonymous_id)
+WHERE user_id = sqlc.arg(user_id)
+  AND quote_of IS NOT NULL
+  AND deleted_at IS NULL
+  AND held_at IS NULL;
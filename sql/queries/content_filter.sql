@@ -0,0 +1,31 @@
+-- name: ListFilterRules :many
+SELECT * FROM content_filter_rules
+ORDER BY created_at ASC;
+
+-- name: ListEnabledFilterRules :many
+SELECT * FROM content_filter_rules
+WHERE enabled
+ORDER BY created_at ASC;
+
+-- name: CreateFilterRule :one
+INSERT INTO content_filter_rules (id, created_at, updated_at, kind, pattern, action, enabled)
+VALUES (
+  $1,
+  $2,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6
+)
+RETURNING *;
+
+-- name: UpdateFilterRule :one
+UPDATE content_filter_rules
+SET kind = $2, pattern = $3, action = $4, enabled = $5, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteFilterRule :execrows
+DELETE FROM content_filter_rules
+WHERE id = $1;
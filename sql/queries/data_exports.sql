@@ -0,0 +1,37 @@
+-- name: CreateDataExport :one
+INSERT INTO data_exports (id, created_at, user_id, status)
+VALUES (
+  $1,
+  $2,
+  $3,
+  'pending'
+)
+RETURNING *;
+
+-- name: GetDataExport :one
+SELECT *
+FROM data_exports
+WHERE id = $1;
+
+-- name: ListDataExportsByUser :many
+SELECT *
+FROM data_exports
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT 20;
+
+-- name: CompleteDataExport :execrows
+UPDATE data_exports
+SET status = 'ready', completed_at = $2, expires_at = $3, size_bytes = $4, last_error = NULL
+WHERE id = $1 AND status = 'pending';
+
+-- name: FailDataExport :exec
+UPDATE data_exports
+SET status = 'failed', completed_at = $2, last_error = $3
+WHERE id = $1 AND status = 'pending';
+
+-- name: ExpireDataExports :many
+UPDATE data_exports
+SET status = 'expired'
+WHERE status = 'ready' AND expires_at < $1
+RETURNING id;
@@ -0,0 +1,79 @@
+-- name: EnqueueJob :execrows
+INSERT INTO jobs (id, created_at, updated_at, kind, payload, status, max_attempts, run_at, unique_key)
+VALUES (
+  $1,
+  $2,
+  $2,
+  $3,
+  $4,
+  'pending',
+  $5,
+  $6,
+  $7
+)
+ON CONFLICT (unique_key) DO NOTHING;
+
+-- name: ClaimJob :one
+UPDATE jobs
+SET status = 'running',
+    attempts = attempts + 1,
+    locked_until = sqlc.arg(locked_until)::timestamp,
+    updated_at = sqlc.arg(now)::timestamp
+WHERE id = (
+  SELECT id
+  FROM jobs
+  WHERE kind = ANY(sqlc.arg(kinds)::text[])
+    AND (
+      (status = 'pending' AND run_at <= sqlc.arg(now)::timestamp)
+      OR (status = 'running' AND locked_until < sqlc.arg(now)::timestamp AND attempts < max_attempts)
+    )
+  ORDER BY run_at
+  LIMIT 1
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- CompleteJob, RetryJob and BuryJob only touch a job while the claim that
+-- ran it still holds the lease: attempts goes up with every claim, so a
+-- worker whose lease ran out can't overwrite the outcome of the worker
+-- that claimed the job after it.
+
+-- name: CompleteJob :execrows
+UPDATE jobs
+SET status = 'succeeded', locked_until = NULL, last_error = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND status = 'running' AND attempts = $2;
+
+-- name: RetryJob :execrows
+UPDATE jobs
+SET status = 'pending', run_at = $2, locked_until = NULL, last_error = $3, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND status = 'running' AND attempts = $4;
+
+-- name: BuryJob :execrows
+UPDATE jobs
+SET status = 'dead', locked_until = NULL, last_error = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND status = 'running' AND attempts = $3;
+
+-- ClaimJob doesn't take back a job whose lease ran out on its last
+-- attempt, which usually means it crashed its worker every time, so
+-- BuryExpiredJobs buries it.
+
+-- name: BuryExpiredJobs :execrows
+UPDATE jobs
+SET status = 'dead', locked_until = NULL, last_error = 'lease expired on the last attempt', updated_at = sqlc.arg(now)::timestamp
+WHERE status = 'running' AND locked_until < sqlc.arg(now)::timestamp AND attempts >= max_attempts;
+
+-- name: RequeueDeadJob :execrows
+UPDATE jobs
+SET status = 'pending', attempts = 0, run_at = CURRENT_TIMESTAMP, last_error = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND status = 'dead';
+
+-- name: ListJobs :many
+SELECT *
+FROM jobs
+WHERE sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status)::text
+ORDER BY updated_at DESC
+LIMIT sqlc.arg(row_limit);
+
+-- name: DeleteFinishedJobs :execrows
+DELETE FROM jobs
+WHERE status = 'succeeded' AND updated_at < $1;
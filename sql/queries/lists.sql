@@ -0,0 +1,69 @@
+-- name: CreateList :one
+INSERT INTO lists (id, created_at, updated_at, owner_id, name, description, is_private)
+VALUES (
+  $1,
+  $2,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6
+)
+RETURNING *;
+
+-- name: GetList :one
+SELECT * FROM lists
+WHERE id = $1;
+
+-- name: ListListsByOwner :many
+SELECT * FROM lists
+WHERE owner_id = $1
+ORDER BY created_at ASC;
+
+-- name: UpdateList :one
+UPDATE lists
+SET name = $3, description = $4, is_private = $5, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND owner_id = $2
+RETURNING *;
+
+-- name: DeleteList :execrows
+DELETE FROM lists
+WHERE id = $1 AND owner_id = $2;
+
+-- name: AddListMember :execrows
+INSERT INTO list_members (list_id, user_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (list_id, user_id) DO NOTHING;
+
+-- name: RemoveListMember :execrows
+DELETE FROM list_members
+WHERE list_id = $1 AND user_id = $2;
+
+-- name: ListListMembers :many
+SELECT * FROM list_members
+WHERE list_id = $1
+ORDER BY created_at ASC;
+
+-- name: CountListMembers :one
+SELECT COUNT(*) FROM list_members
+WHERE list_id = $1;
+
+-- name: GetListMemberIDs :many
+SELECT user_id FROM list_members
+WHERE list_id = $1;
+
+-- name: SubscribeToList :execrows
+INSERT INTO list_subscriptions (list_id, user_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (list_id, user_id) DO NOTHING;
+
+-- name: UnsubscribeFromList :execrows
+DELETE FROM list_subscriptions
+WHERE list_id = $1 AND user_id = $2;
+
+-- name: ListSubscribedLists :many
+SELECT lists.* FROM lists
+JOIN list_subscriptions ON list_subscriptions.list_id = lists.id
+WHERE list_subscriptions.user_id = $1
+  AND NOT lists.is_private
+ORDER BY list_subscriptions.created_at ASC;
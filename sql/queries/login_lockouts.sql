@@ -0,0 +1,53 @@
+-- name: GetLoginLockout :one
+SELECT *
+FROM login_lockouts
+WHERE scope = $1 AND subject = $2;
+
+-- name: RecordFailedLogin :one
+INSERT INTO login_lockouts (id, created_at, updated_at, scope, subject, failed_attempts, last_failed_at)
+VALUES (
+  sqlc.arg(id),
+  sqlc.arg(now),
+  sqlc.arg(now),
+  sqlc.arg(scope),
+  sqlc.arg(subject),
+  1,
+  sqlc.arg(now)
+)
+ON CONFLICT (scope, subject) DO UPDATE
+SET failed_attempts = CASE
+      WHEN login_lockouts.last_failed_at < sqlc.arg(window_start) THEN 1
+      ELSE login_lockouts.failed_attempts + 1
+    END,
+    last_failed_at = EXCLUDED.last_failed_at,
+    updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: LockLogin :exec
+UPDATE login_lockouts
+SET locked_until = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: SetUnlockToken :execrows
+UPDATE login_lockouts
+SET unlock_token = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND locked_until IS NOT NULL;
+
+-- name: ClearLoginLockout :exec
+DELETE FROM login_lockouts
+WHERE scope = $1 AND subject = $2;
+
+-- name: UnlockLoginByToken :one
+DELETE FROM login_lockouts
+WHERE unlock_token = $1
+RETURNING *;
+
+-- name: ListLoginLockouts :many
+SELECT *
+FROM login_lockouts
+WHERE locked_until IS NOT NULL
+ORDER BY locked_until DESC;
+
+-- name: DeleteLoginLockout :exec
+DELETE FROM login_lockouts
+WHERE id = $1;
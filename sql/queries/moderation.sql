@@ -0,0 +1,82 @@
+-- name: OpenModerationCase :one
+INSERT INTO moderation_cases (id, created_at, updated_at, target_key, user_id, chirp_id, last_reported_at, filter_rule_id, spam_score)
+VALUES (
+  $1,
+  $2,
+  $2,
+  $3,
+  $4,
+  $5,
+  $2,
+  $6,
+  $7
+)
+ON CONFLICT (target_key) WHERE status = 'open' DO UPDATE
+SET updated_at = EXCLUDED.updated_at,
+    filter_rule_id = COALESCE(EXCLUDED.filter_rule_id, moderation_cases.filter_rule_id),
+    spam_score = COALESCE(EXCLUDED.spam_score, moderation_cases.spam_score)
+RETURNING *;
+
+-- name: CreateReport :execrows
+INSERT INTO reports (id, created_at, case_id, reporter_id, reason, details)
+VALUES (
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6
+)
+ON CONFLICT (case_id, reporter_id) DO NOTHING;
+
+-- name: IncrementCaseReports :exec
+UPDATE moderation_cases
+SET report_count = report_count + 1, last_reported_at = $2, updated_at = $2
+WHERE id = $1;
+
+-- name: ListModerationQueue :many
+SELECT * FROM moderation_cases
+WHERE status = sqlc.arg(status)
+ORDER BY filter_rule_id IS NULL AND spam_score IS NULL, report_count DESC, created_at ASC
+LIMIT sqlc.arg(row_limit);
+
+-- name: GetModerationCase :one
+SELECT * FROM moderation_cases
+WHERE id = $1;
+
+-- name: LockOpenModerationCase :one
+SELECT * FROM moderation_cases
+WHERE id = $1 AND status = 'open'
+FOR UPDATE;
+
+-- name: ResolveModerationCase :exec
+UPDATE moderation_cases
+SET status = 'resolved', resolution = $2, resolved_at = $3, updated_at = $3
+WHERE id = $1;
+
+-- name: ListCaseReports :many
+SELECT * FROM reports
+WHERE case_id = $1
+ORDER BY created_at ASC;
+
+-- name: CreateModerationAction :one
+INSERT INTO moderation_actions (id, created_at, moderator_id, case_id, target_user_id, chirp_id, action, note, expires_at)
+VALUES (
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6,
+  $7,
+  $8,
+  $9
+)
+RETURNING *;
+
+-- name: ListModerationActions :many
+SELECT * FROM moderation_actions
+WHERE (sqlc.narg(target_user_id)::uuid IS NULL OR target_user_id = sqlc.narg(target_user_id)::uuid)
+  AND (sqlc.narg(case_id)::uuid IS NULL OR case_id = sqlc.narg(case_id)::uuid)
+ORDER BY created_at DESC
+LIMIT sqlc.arg(row_limit);
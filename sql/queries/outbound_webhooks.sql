@@ -0,0 +1,98 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, user_id, url, secret, events, global)
+VALUES (
+  $1,
+  $2,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6,
+  $7
+)
+RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT *
+FROM webhook_endpoints
+WHERE id = $1;
+
+-- name: ListWebhookEndpointsByUser :many
+SELECT *
+FROM webhook_endpoints
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = $1 AND user_id = $2;
+
+-- name: EnableWebhookEndpoint :execrows
+UPDATE webhook_endpoints
+SET enabled = TRUE, consecutive_failures = 0, disabled_at = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND user_id = $2;
+
+-- name: ListWebhookEndpointsForEvent :many
+SELECT *
+FROM webhook_endpoints
+WHERE enabled
+  AND sqlc.arg(event)::text = ANY(events)
+  AND (global OR user_id = sqlc.arg(user_id));
+
+-- name: RecordWebhookEndpointSuccess :exec
+UPDATE webhook_endpoints
+SET consecutive_failures = 0, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: RecordWebhookEndpointFailure :one
+UPDATE webhook_endpoints
+SET consecutive_failures = consecutive_failures + 1,
+    enabled = consecutive_failures + 1 < sqlc.arg(disable_after)::int,
+    disabled_at = CASE
+      WHEN consecutive_failures + 1 >= sqlc.arg(disable_after)::int THEN CURRENT_TIMESTAMP
+      ELSE disabled_at
+    END,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id)
+RETURNING enabled;
+
+-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (id, created_at, updated_at, endpoint_id, event, payload, status, next_attempt_at)
+VALUES (
+  $1,
+  $2,
+  $2,
+  $3,
+  $4,
+  $5,
+  'pending',
+  $2
+);
+
+-- name: GetWebhookDelivery :one
+SELECT *
+FROM webhook_deliveries
+WHERE id = $1;
+
+-- name: ListPendingWebhookDeliveries :many
+SELECT *
+FROM webhook_deliveries
+WHERE endpoint_id = $1 AND status = 'pending'
+ORDER BY created_at ASC;
+
+-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET status = 'delivered', attempts = attempts + 1, last_status_code = $2, last_error = NULL, delivered_at = $3, updated_at = $3
+WHERE id = $1;
+
+-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries
+SET status = $2, attempts = attempts + 1, next_attempt_at = $3, last_status_code = $4, last_error = $5, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: ListWebhookDeliveriesByEndpoint :many
+SELECT *
+FROM webhook_deliveries
+WHERE endpoint_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
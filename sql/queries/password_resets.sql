@@ -0,0 +1,18 @@
+-- name: CreatePasswordReset :exec
+INSERT INTO password_resets (token_hash, created_at, user_id, expires_at)
+VALUES (
+  $1,
+  $2,
+  $3,
+  $4
+);
+
+-- name: GetPasswordReset :one
+SELECT *
+FROM password_resets
+WHERE token_hash = $1;
+
+-- name: UsePasswordReset :execrows
+UPDATE password_resets
+SET used_at = CURRENT_TIMESTAMP
+WHERE token_hash = $1 AND used_at IS NULL;
@@ -0,0 +1,19 @@
+-- name: PinChirp :execrows
+INSERT INTO pinned_chirps (chirp_id, user_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (chirp_id) DO NOTHING;
+
+-- name: UnpinChirp :execrows
+DELETE FROM pinned_chirps
+WHERE chirp_id = $1 AND user_id = $2;
+
+-- name: CountPinnedChirps :one
+SELECT COUNT(*) FROM pinned_chirps
+JOIN chirps ON chirps.id = pinned_chirps.chirp_id
+WHERE pinned_chirps.user_id = $1 AND chirps.deleted_at IS NULL;
+
+-- name: GetPinnedChirps :many
+SELECT chirps.* FROM pinned_chirps
+JOIN chirps ON chirps.id = pinned_chirps.chirp_id
+WHERE pinned_chirps.user_id = $1 AND chirps.deleted_at IS NULL
+ORDER BY pinned_chirps.created_at DESC;
@@ -0,0 +1,55 @@
+-- name: CreatePoll :one
+INSERT INTO polls (chirp_id, created_at, closes_at)
+VALUES (
+  $1,
+  $2,
+  $3
+)
+RETURNING *;
+
+-- name: CreatePollOption :exec
+INSERT INTO poll_options (chirp_id, position, text)
+VALUES (
+  $1,
+  $2,
+  $3
+);
+
+-- name: GetPollsForChirps :many
+SELECT *
+FROM polls
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: GetPollTallies :many
+SELECT o.chirp_id, o.position, o.text, COUNT(v.user_id) AS votes
+FROM poll_options o
+LEFT JOIN poll_votes v ON v.chirp_id = o.chirp_id AND v.position = o.position
+WHERE o.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+GROUP BY o.chirp_id, o.position, o.text
+ORDER BY o.chirp_id, o.position;
+
+-- name: GetPollVotesByUser :many
+SELECT chirp_id, position
+FROM poll_votes
+WHERE user_id = sqlc.arg(user_id) AND chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- CastPollVote only counts votes while the poll is open, so one that
+-- lands as the poll closes can't change the results voters were sent.
+
+-- name: CastPollVote :execrows
+INSERT INTO poll_votes (chirp_id, user_id, position, created_at)
+SELECT chirp_id, $2, $3, $4
+FROM polls
+WHERE chirp_id = $1 AND closes_at > $4 AND finalized_at IS NULL
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
+
+-- name: FinalizePoll :execrows
+UPDATE polls
+SET finalized_at = $2
+WHERE chirp_id = $1 AND finalized_at IS NULL;
+
+-- name: ListPollVoterEmails :many
+SELECT users.email
+FROM poll_votes
+JOIN users ON users.id = poll_votes.user_id
+WHERE poll_votes.chirp_id = $1;
@@ -0,0 +1,7 @@
+-- name: CreateQuoteSnapshot :exec
+INSERT INTO quote_snapshots (chirp_id, quoted_id, user_id, body, created_at)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: GetQuoteSnapshots :many
+SELECT * FROM quote_snapshots
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
@@ -0,0 +1,24 @@
+-- Buckets are timed by the database's clock, so instances whose clocks
+-- drift apart still refill a shared bucket at the same rate.
+
+-- name: TakeRateLimitToken :one
+INSERT INTO rate_limit_buckets (key, tokens, allowed, updated_at)
+VALUES (
+  sqlc.arg(key),
+  sqlc.arg(capacity)::float8 - 1,
+  TRUE,
+  CURRENT_TIMESTAMP
+)
+ON CONFLICT (key) DO UPDATE
+SET tokens = CASE
+      WHEN LEAST(sqlc.arg(capacity)::float8, rate_limit_buckets.tokens + EXTRACT(EPOCH FROM (EXCLUDED.updated_at - rate_limit_buckets.updated_at))::float8 * sqlc.arg(refill_rate)::float8) >= 1
+      THEN LEAST(sqlc.arg(capacity)::float8, rate_limit_buckets.tokens + EXTRACT(EPOCH FROM (EXCLUDED.updated_at - rate_limit_buckets.updated_at))::float8 * sqlc.arg(refill_rate)::float8) - 1
+      ELSE LEAST(sqlc.arg(capacity)::float8, rate_limit_buckets.tokens + EXTRACT(EPOCH FROM (EXCLUDED.updated_at - rate_limit_buckets.updated_at))::float8 * sqlc.arg(refill_rate)::float8)
+    END,
+    allowed = LEAST(sqlc.arg(capacity)::float8, rate_limit_buckets.tokens + EXTRACT(EPOCH FROM (EXCLUDED.updated_at - rate_limit_buckets.updated_at))::float8 * sqlc.arg(refill_rate)::float8) >= 1,
+    updated_at = EXCLUDED.updated_at
+RETURNING tokens, allowed;
+
+-- name: DeleteStaleRateLimitBuckets :exec
+DELETE FROM rate_limit_buckets
+WHERE updated_at < $1;
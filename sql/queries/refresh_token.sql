@@ -22,3 +22,18 @@ WHERE refresh_tokens.token = $1;
 UPDATE refresh_tokens
 SET revoked_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP 
 WHERE refresh_tokens.token = $1;
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: DeleteExpiredRefreshTokens :execrows
+DELETE FROM refresh_tokens
+WHERE expires_at < $1 OR revoked_at < $1;
+
+-- name: ListRefreshTokensByUser :many
+SELECT created_at, updated_at, expires_at, revoked_at
+FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at DESC;
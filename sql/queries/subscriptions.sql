@@ -0,0 +1,37 @@
+-- name: GetSubscriptionByUser :one
+SELECT *
+FROM subscriptions
+WHERE user_id = $1;
+
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_end, cancel_at_period_end)
+VALUES (
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6,
+  $7,
+  $8
+)
+ON CONFLICT (user_id) DO UPDATE
+SET plan = EXCLUDED.plan,
+    status = EXCLUDED.status,
+    current_period_end = EXCLUDED.current_period_end,
+    cancel_at_period_end = EXCLUDED.cancel_at_period_end,
+    updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: ExpireLapsedSubscriptions :many
+WITH lapsed AS (
+  UPDATE subscriptions
+  SET status = 'expired', updated_at = sqlc.arg(now)::timestamp
+  WHERE status IN ('active', 'past_due')
+    AND current_period_end <= sqlc.arg(now)::timestamp
+  RETURNING user_id
+)
+UPDATE users
+SET is_chirpy_red = FALSE
+WHERE id IN (SELECT user_id FROM lapsed)
+RETURNING id;
@@ -0,0 +1,23 @@
+-- name: CreateBlock :exec
+INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+VALUES (
+  $1,
+  $2,
+  $3
+)
+ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+
+-- name: DeleteBlock :execrows
+DELETE FROM user_blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: ListBlocks :many
+SELECT *
+FROM user_blocks
+WHERE blocker_id = $1
+ORDER BY created_at DESC;
+
+-- name: ListBlockersAmong :many
+SELECT blocker_id
+FROM user_blocks
+WHERE blocked_id = sqlc.arg(blocked_id) AND blocker_id = ANY(sqlc.arg(user_ids)::uuid[]);
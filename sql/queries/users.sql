@@ -27,8 +27,66 @@ UPDATE users
 SET email = $1 , hashed_password = $2
 WHERE users.id = $3;
 
--- name: UpgradeToChirpyRed :exec 
-UPDATE users 
-SET is_chirpy_red = TRUE
+-- name: SetChirpyRed :exec
+UPDATE users
+SET is_chirpy_red = $2
+WHERE users.id = $1;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = CURRENT_TIMESTAMP
+WHERE users.id = $1;
+
+-- name: SetAccountStatus :exec
+UPDATE users
+SET account_status = $2, suspended_until = $3, updated_at = CURRENT_TIMESTAMP
+WHERE users.id = $1;
+
+-- name: ListShadowBannedAmong :many
+SELECT id FROM users
+WHERE id = ANY(sqlc.arg(user_ids)::uuid[]) AND account_status = 'shadow_banned';
+
+-- name: ScheduleAccountDeletion :one
+UPDATE users
+SET delete_after = COALESCE(delete_after, sqlc.arg(delete_after)::timestamp), updated_at = CURRENT_TIMESTAMP
+WHERE users.id = sqlc.arg(id)
+RETURNING delete_after;
+
+-- name: CancelAccountDeletion :execrows
+UPDATE users
+SET delete_after = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE users.id = $1 AND delete_after IS NOT NULL;
+
+-- name: ListAccountsDueForDeletion :many
+SELECT id FROM users
+WHERE delete_after <= $1
+ORDER BY delete_after ASC
+LIMIT 100;
+
+-- name: LockAccountDueForDeletion :one
+SELECT id FROM users
+WHERE users.id = $1 AND delete_after <= $2
+FOR UPDATE;
+
+-- name: DeleteUser :exec
+DELETE FROM users
 WHERE users.id = $1;
 
+-- the account anonymized chirps are moved to; it can't log in
+-- name: EnsureDeletedUser :exec
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, account_status)
+VALUES (
+  $1,
+  CURRENT_TIMESTAMP,
+  CURRENT_TIMESTAMP,
+  'deleted@chirpy.invalid',
+  '',
+  'banned'
+)
+ON CONFLICT DO NOTHING;
+
+-- the stand-in for a remote ActivityPub actor; it can't log in
+-- name: CreateShadowUser :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+VALUES ($1, $2, $2, $3, '')
+RETURNING *;
@@ -0,0 +1,47 @@
+-- name: InsertWebhookEvent :one
+INSERT INTO webhook_events (id, created_at, updated_at, source, idempotency_key, event, payload, status)
+VALUES (
+  $1,
+  $2,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6,
+  'received'
+)
+ON CONFLICT (source, idempotency_key) DO NOTHING
+RETURNING *;
+
+-- name: GetWebhookEventByKey :one
+SELECT *
+FROM webhook_events
+WHERE source = $1 AND idempotency_key = $2;
+
+-- name: GetWebhookEvent :one
+SELECT *
+FROM webhook_events
+WHERE id = $1;
+
+-- name: LockWebhookEvent :one
+SELECT *
+FROM webhook_events
+WHERE id = $1
+FOR UPDATE;
+
+-- name: MarkWebhookEventDone :exec
+UPDATE webhook_events
+SET status = $2, attempts = attempts + 1, last_error = NULL, processed_at = $3, updated_at = $3
+WHERE id = $1;
+
+-- name: MarkWebhookEventFailed :exec
+UPDATE webhook_events
+SET status = 'failed', attempts = attempts + 1, last_error = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: ListWebhookEvents :many
+SELECT *
+FROM webhook_events
+WHERE sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status)::text
+ORDER BY created_at DESC
+LIMIT sqlc.arg(row_limit);
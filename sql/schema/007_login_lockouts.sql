@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE login_lockouts (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    scope TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    failed_attempts INTEGER NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP,
+    unlock_token TEXT UNIQUE,
+    UNIQUE (scope, subject)
+);
+
+-- +goose Down
+DROP TABLE login_lockouts;
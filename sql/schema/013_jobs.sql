@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE jobs (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    kind TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    run_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP,
+    last_error TEXT,
+    unique_key TEXT UNIQUE
+);
+
+CREATE INDEX jobs_due_idx ON jobs (run_at) WHERE status IN ('pending', 'running');
+CREATE INDEX jobs_status_idx ON jobs (status, updated_at);
+
+-- +goose Down
+DROP TABLE jobs;
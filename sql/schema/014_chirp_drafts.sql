@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE chirp_drafts (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    status TEXT NOT NULL,
+    publish_at TIMESTAMP,
+    last_error TEXT
+);
+
+CREATE INDEX chirp_drafts_user_idx ON chirp_drafts (user_id, updated_at);
+
+-- +goose Down
+DROP TABLE chirp_drafts;
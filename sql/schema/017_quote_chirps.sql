@@ -0,0 +1,11 @@
+-- +goose Up
+-- no foreign key: a quote outlives the chirp it quotes, which then shows as
+-- unavailable
+ALTER TABLE chirps
+ADD COLUMN quote_of UUID;
+
+CREATE INDEX chirps_quote_of_idx ON chirps (quote_of, created_at) WHERE quote_of IS NOT NULL;
+
+-- +goose Down
+ALTER TABLE chirps
+DROP COLUMN quote_of;
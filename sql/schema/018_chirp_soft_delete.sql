@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN deleted_at TIMESTAMP;
+
+-- +goose Down
+DELETE FROM chirps WHERE deleted_at IS NOT NULL;
+ALTER TABLE chirps
+DROP COLUMN deleted_at;
@@ -0,0 +1,57 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN account_status TEXT NOT NULL DEFAULT 'active',
+ADD COLUMN suspended_until TIMESTAMP;
+
+-- one case per reported chirp or user while it is open; reports pile onto it
+CREATE TABLE moderation_cases (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    -- "chirp:<id>" or "user:<id>"
+    target_key TEXT NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID REFERENCES chirps(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'open',
+    report_count INTEGER NOT NULL DEFAULT 0,
+    last_reported_at TIMESTAMP NOT NULL,
+    resolved_at TIMESTAMP,
+    resolution TEXT
+);
+
+CREATE UNIQUE INDEX moderation_cases_open_target_idx ON moderation_cases (target_key) WHERE status = 'open';
+CREATE INDEX moderation_cases_queue_idx ON moderation_cases (report_count DESC, created_at) WHERE status = 'open';
+
+CREATE TABLE reports (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    case_id UUID NOT NULL REFERENCES moderation_cases(id) ON DELETE CASCADE,
+    reporter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    UNIQUE (case_id, reporter_id)
+);
+
+-- the audit trail; target_user_id and chirp_id have no foreign keys so
+-- actions outlive what they were taken against
+CREATE TABLE moderation_actions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    moderator_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    case_id UUID REFERENCES moderation_cases(id) ON DELETE SET NULL,
+    target_user_id UUID NOT NULL,
+    chirp_id UUID,
+    action TEXT NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMP
+);
+
+CREATE INDEX moderation_actions_target_idx ON moderation_actions (target_user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE moderation_actions;
+DROP TABLE reports;
+DROP TABLE moderation_cases;
+ALTER TABLE users
+DROP COLUMN suspended_until,
+DROP COLUMN account_status;
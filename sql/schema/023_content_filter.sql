@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE TABLE content_filter_rules (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    -- "word" or "regex"
+    kind TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    -- "mask", "reject" or "hold"
+    action TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+-- the words cleanChirpBody used to mask
+INSERT INTO content_filter_rules (id, created_at, updated_at, kind, pattern, action)
+VALUES
+  ('6d1f3b1e-7c1a-4c43-9b0e-2f6a1c9d0a01', NOW(), NOW(), 'word', 'kerfuffle', 'mask'),
+  ('6d1f3b1e-7c1a-4c43-9b0e-2f6a1c9d0a02', NOW(), NOW(), 'word', 'sharbert', 'mask'),
+  ('6d1f3b1e-7c1a-4c43-9b0e-2f6a1c9d0a03', NOW(), NOW(), 'word', 'fornax', 'mask');
+
+-- held chirps are only shown to their author until a moderator rules on them
+ALTER TABLE chirps
+ADD COLUMN held_at TIMESTAMP;
+
+-- the rule that held the chirp, for cases opened by the filter rather than
+-- by reports
+ALTER TABLE moderation_cases
+ADD COLUMN filter_rule_id UUID;
+
+-- +goose Down
+ALTER TABLE moderation_cases
+DROP COLUMN filter_rule_id;
+ALTER TABLE chirps
+DROP COLUMN held_at;
+DROP TABLE content_filter_rules;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- the spam score of a chirp held by the spam heuristics, for cases they
+-- opened rather than reports or the content filter
+ALTER TABLE moderation_cases
+ADD COLUMN spam_score INTEGER;
+
+-- +goose Down
+ALTER TABLE moderation_cases
+DROP COLUMN spam_score;
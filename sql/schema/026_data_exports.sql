@@ -0,0 +1,22 @@
+-- +goose Up
+-- archives of everything we hold about a user, built in the background
+CREATE TABLE data_exports (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'ready', 'failed', 'expired')),
+    completed_at TIMESTAMP,
+    -- when the download link stops working and the archive is deleted
+    expires_at TIMESTAMP,
+    size_bytes BIGINT,
+    last_error TEXT
+);
+
+CREATE INDEX data_exports_user_id_idx ON data_exports (user_id, created_at DESC);
+CREATE INDEX data_exports_expires_at_idx ON data_exports (expires_at) WHERE status = 'ready';
+-- one export in progress per user at a time
+CREATE UNIQUE INDEX data_exports_one_pending_idx ON data_exports (user_id) WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE data_exports;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- set while an account is in its grace period; logging in clears it
+ALTER TABLE users
+ADD COLUMN delete_after TIMESTAMP;
+
+CREATE INDEX users_delete_after_idx ON users (delete_after) WHERE delete_after IS NOT NULL;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN delete_after;
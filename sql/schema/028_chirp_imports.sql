@@ -0,0 +1,41 @@
+-- +goose Up
+-- chirps imported from another platform's archive, run in the background
+CREATE TABLE chirp_imports (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'running', 'succeeded', 'failed')),
+    -- a dry run counts what would be imported without creating anything
+    dry_run BOOLEAN NOT NULL,
+    -- "split" or "truncate"
+    long_posts TEXT NOT NULL,
+    total INTEGER NOT NULL DEFAULT 0,
+    processed INTEGER NOT NULL DEFAULT 0,
+    -- counts of what happened to the posts processed so far
+    stats JSONB NOT NULL DEFAULT '{}',
+    completed_at TIMESTAMP,
+    last_error TEXT
+);
+
+CREATE INDEX chirp_imports_user_id_idx ON chirp_imports (user_id, created_at DESC);
+-- one import at a time per user
+CREATE UNIQUE INDEX chirp_imports_one_active_idx ON chirp_imports (user_id) WHERE status IN ('pending', 'running');
+
+-- posts already imported, so importing the same archive again, or retrying
+-- part of one, doesn't duplicate them. No foreign key to chirps: a chirp
+-- the user deleted after importing it stays imported.
+CREATE TABLE imported_posts (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source TEXT NOT NULL,
+    source_id TEXT NOT NULL,
+    chirp_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, source, source_id)
+);
+
+-- +goose Down
+DROP TABLE imported_posts;
+DROP TABLE chirp_imports;
@@ -0,0 +1,65 @@
+-- +goose Up
+-- the key each local account signs its ActivityPub requests with, made the
+-- first time it is needed
+CREATE TABLE actor_keys (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    public_key_pem TEXT NOT NULL,
+    private_key_pem TEXT NOT NULL
+);
+
+-- accounts on other ActivityPub servers. Each has a shadow user, which
+-- can't log in, to own its chirps, follows and reports like anyone else.
+CREATE TABLE remote_actors (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    actor_id TEXT NOT NULL UNIQUE,
+    username TEXT NOT NULL,
+    domain TEXT NOT NULL,
+    inbox TEXT NOT NULL,
+    shared_inbox TEXT,
+    key_id TEXT NOT NULL,
+    public_key_pem TEXT NOT NULL
+);
+
+CREATE INDEX remote_actors_key_id_idx ON remote_actors (key_id);
+
+-- one account following another. For now one side is always remote: remote
+-- actors follow local accounts, and local accounts follow remote actors. A
+-- follow is pending until it is accepted.
+CREATE TABLE follows (
+    follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    -- the Follow activity, which Accept, Reject and Undo refer to
+    activity_id TEXT NOT NULL,
+    accepted_at TIMESTAMP,
+    PRIMARY KEY (follower_id, followee_id)
+);
+
+CREATE INDEX follows_followee_id_idx ON follows (followee_id) WHERE accepted_at IS NOT NULL;
+
+-- remote notes stored as chirps, by the note's id
+CREATE TABLE remote_chirps (
+    object_id TEXT PRIMARY KEY,
+    chirp_id UUID NOT NULL UNIQUE REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+-- likes and boosts of local chirps from remote actors
+CREATE TABLE chirp_reactions (
+    activity_id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL CHECK (type IN ('Like', 'Announce'))
+);
+
+CREATE UNIQUE INDEX chirp_reactions_once_idx ON chirp_reactions (chirp_id, user_id, type);
+
+-- +goose Down
+DROP TABLE chirp_reactions;
+DROP TABLE remote_chirps;
+DROP TABLE follows;
+DROP TABLE remote_actors;
+DROP TABLE actor_keys;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- what a quoted chirp said when it was quoted, so editing it later doesn't
+-- change the quote. Deleting the quoted author's account deletes it.
+CREATE TABLE quote_snapshots (
+    chirp_id UUID PRIMARY KEY REFERENCES chirps(id) ON DELETE CASCADE,
+    quoted_id UUID NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+INSERT INTO quote_snapshots (chirp_id, quoted_id, user_id, body, created_at)
+SELECT quotes.id, quoted.id, quoted.user_id, quoted.body, quoted.created_at
+FROM chirps quotes
+JOIN chirps quoted ON quoted.id = quotes.quote_of;
+
+-- +goose Down
+DROP TABLE quote_snapshots;
@@ -0,0 +1,154 @@
+package main
+
+import (
+  "context"
+  "database/sql"
+  "errors"
+  "fmt"
+  "log"
+  "time"
+  "chirpy/internal/database"
+  "github.com/google/uuid"
+)
+
+const (
+  planChirpyRed = "chirpy_red"
+
+  subscriptionActive   = "active"
+  subscriptionPastDue  = "past_due"
+  subscriptionCanceled = "canceled"
+  subscriptionRefunded = "refunded"
+  subscriptionExpired  = "expired"
+
+  // used when Polka doesn't tell us when the paid period ends
+  subscriptionPeriod = 30 * 24 * time.Hour
+)
+
+const (
+  eventUserUpgraded      = "user.upgraded"
+  eventUserRenewed       = "user.renewed"
+  eventUserPaymentFailed = "user.payment_failed"
+  eventUserDowngraded    = "user.downgraded"
+  eventUserRefunded      = "user.refunded"
+)
+
+var errNoSubscription = errors.New("user has no subscription")
+
+func isSubscriptionEvent(event string) bool {
+  switch event {
+  case eventUserUpgraded, eventUserRenewed, eventUserPaymentFailed, eventUserDowngraded, eventUserRefunded:
+    return true
+  }
+  return false
+}
+
+// subscriptionEntitled reports whether a subscription in this state still
+// grants Chirpy Red. Past-due members keep it until the paid period runs out.
+func subscriptionEntitled(status string, periodEnd, now time.Time) bool {
+  return (status == subscriptionActive || status == subscriptionPastDue) && now.Before(periodEnd)
+}
+
+// nextSubscription applies a Polka event to the user's current subscription,
+// which is nil when they have never subscribed.
+func nextSubscription(current *database.Subscription, userID uuid.UUID, webhook Webhook, now time.Time) (database.UpsertSubscriptionParams, error) {
+  next := database.UpsertSubscriptionParams{
+    ID:        uuid.New(),
+    CreatedAt: now,
+    UpdatedAt: now,
+    UserID:    userID,
+    Plan:      planChirpyRed,
+  }
+  if current != nil {
+    next.ID = current.ID
+    next.CreatedAt = current.CreatedAt
+    next.Plan = current.Plan
+    next.Status = current.Status
+    next.CurrentPeriodEnd = current.CurrentPeriodEnd
+    next.CancelAtPeriodEnd = current.CancelAtPeriodEnd
+  } else if webhook.Event != eventUserUpgraded {
+    return next, errNoSubscription
+  }
+  if webhook.Data.Plan != "" {
+    next.Plan = webhook.Data.Plan
+  }
+
+  periodEnd := now.Add(subscriptionPeriod)
+  if webhook.Data.CurrentPeriodEnd != nil {
+    periodEnd = webhook.Data.CurrentPeriodEnd.Local()
+  }
+
+  switch webhook.Event {
+  case eventUserUpgraded:
+    next.Status = subscriptionActive
+    next.CurrentPeriodEnd = periodEnd
+    next.CancelAtPeriodEnd = false
+  case eventUserRenewed:
+    if webhook.Data.CurrentPeriodEnd == nil && next.CurrentPeriodEnd.After(now) {
+      periodEnd = next.CurrentPeriodEnd.Add(subscriptionPeriod)
+    }
+    next.Status = subscriptionActive
+    next.CurrentPeriodEnd = periodEnd
+    next.CancelAtPeriodEnd = false
+  case eventUserPaymentFailed:
+    if next.Status == subscriptionActive {
+      next.Status = subscriptionPastDue
+    }
+  case eventUserDowngraded:
+    if webhook.Data.CancelAtPeriodEnd {
+      next.CancelAtPeriodEnd = true
+    } else {
+      next.Status = subscriptionCanceled
+      next.CurrentPeriodEnd = now
+    }
+  case eventUserRefunded:
+    next.Status = subscriptionRefunded
+    next.CurrentPeriodEnd = now
+  default:
+    return next, fmt.Errorf("unknown subscription event %q", webhook.Event)
+  }
+
+  return next, nil
+}
+
+// applySubscriptionEvent updates the user's subscription and brings
+// is_chirpy_red in line with it. qtx should be bound to a transaction so the
+// two stay consistent.
+func applySubscriptionEvent(ctx context.Context, qtx *database.Queries, userID uuid.UUID, webhook Webhook) error {
+  var current *database.Subscription
+  sub, err := qtx.GetSubscriptionByUser(ctx, userID)
+  if err == nil {
+    current = &sub
+  } else if !errors.Is(err, sql.ErrNoRows) {
+    return err
+  }
+
+  now := time.Now()
+  next, err := nextSubscription(current, userID, webhook, now)
+  if err != nil {
+    return err
+  }
+
+  sub, err = qtx.UpsertSubscription(ctx, next)
+  if err != nil {
+    return err
+  }
+
+  return qtx.SetChirpyRed(ctx, database.SetChirpyRedParams{
+    ID:          userID,
+    IsChirpyRed: subscriptionEntitled(sub.Status, sub.CurrentPeriodEnd, now),
+  })
+}
+
+// expireLapsedSubscriptions ends memberships whose paid period is over,
+// including ones that were cancelled at period end or never recovered from a
+// failed payment.
+func (cfg *apiConfig) expireLapsedSubscriptions(ctx context.Context) error {
+  expired, err := cfg.db.ExpireLapsedSubscriptions(ctx, time.Now())
+  if err != nil {
+    return fmt.Errorf("error expiring subscriptions: %v", err)
+  }
+  if len(expired) > 0 {
+    log.Printf("Expired %d Chirpy Red subscriptions", len(expired))
+  }
+  return nil
+}
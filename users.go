@@ -9,6 +9,9 @@ import (
   "encoding/json"
   "database/sql"
   "context"
+  "errors"
+  "log"
+  "sync"
   "github.com/google/uuid"
   _ "github.com/lib/pq"
 )
@@ -41,7 +44,7 @@ func getExpirationDuration(seconds int) time.Duration {
 
 func (apiCfg *apiConfig) handleCreateNewUser(w http.ResponseWriter, r *http.Request) {
   var usrData UserData
-  decoder := json.NewDecoder(r.Body)
+  decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCredentialsBody))
   err := decoder.Decode(&usrData)
   if err != nil {
     w.WriteHeader(http.StatusBadRequest)
@@ -50,6 +53,9 @@ func (apiCfg *apiConfig) handleCreateNewUser(w http.ResponseWriter, r *http.Requ
   }
   emailVal := usrData.EmailVal
   unHashedPass := usrData.Password
+  if !apiCfg.checkPassword(w, unHashedPass, emailVal) {
+    return
+  }
   passwordVal, err := auth.HashPassword(unHashedPass)
   if err != nil {
     w.WriteHeader(http.StatusBadRequest)
@@ -93,10 +99,28 @@ func (apiCfg *apiConfig) handleCreateNewUser(w http.ResponseWriter, r *http.Requ
   return
 }
 
+var (
+  dummyHashOnce sync.Once
+  dummyHash     string
+)
+
+// dummyPasswordHash returns a hash that is compared against when the login
+// email does not exist, so that lookups for unknown accounts cost the same.
+func dummyPasswordHash() string {
+  dummyHashOnce.Do(func() {
+    hash, err := auth.HashPassword(uuid.NewString())
+    if err != nil {
+      log.Fatalf("Error creating dummy password hash: %v", err)
+    }
+    dummyHash = hash
+  })
+  return dummyHash
+}
+
 func (apiCfg *apiConfig) handleUserLogin(w http.ResponseWriter, r *http.Request) {
 
   var usrData UserData
-  decoder := json.NewDecoder(r.Body)
+  decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCredentialsBody))
   err := decoder.Decode(&usrData)
   if err != nil {
     w.WriteHeader(http.StatusBadRequest)
@@ -105,20 +129,91 @@ func (apiCfg *apiConfig) handleUserLogin(w http.ResponseWriter, r *http.Request)
   }
   emailVal := usrData.EmailVal
   unHashedPass := usrData.Password
+  ip := clientIP(r)
 
-  user, err := apiCfg.db.UserByEmail(context.Background(), emailVal)
+  wait, err := apiCfg.loginRetryAfter(context.Background(), emailVal, ip)
   if err != nil {
-    w.WriteHeader(http.StatusInternalServerError)
-    fmt.Errorf("Error fetching user by email")
+    respondWithError(w, http.StatusInternalServerError, "Error checking login lockout", err)
+    return
+  }
+  if wait > 0 {
+    apiCfg.audit(r, auditEntry{
+      Type:    auditLoginFailed,
+      Details: map[string]any{"email": emailVal, "reason": "locked_out"},
+    })
+    setRetryAfter(w, wait)
+    respondWithError(w, http.StatusTooManyRequests, "Too many failed login attempts", nil)
     return
   }
 
-  err = auth.CheckPasswordHash(unHashedPass, user.HashedPassword)
-  if err != nil {
-    w.WriteHeader(http.StatusUnauthorized)
+  // Unknown emails are checked against a dummy hash so that the response
+  // takes as long as it would for a real account with a wrong password.
+  var found *database.User
+  user, err := apiCfg.db.UserByEmail(context.Background(), emailVal)
+  if err == nil {
+    found = &user
+  } else if !errors.Is(err, sql.ErrNoRows) {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching user by email", err)
+    return
+  }
+
+  hash := dummyPasswordHash()
+  if found != nil {
+    hash = user.HashedPassword
+  }
+  err = auth.CheckPasswordHash(unHashedPass, hash)
+  if err != nil || found == nil {
+    failure := auditEntry{
+      Type:    auditLoginFailed,
+      Details: map[string]any{"email": emailVal, "reason": "unknown_email"},
+    }
+    if found != nil {
+      failure.UserID = auditUser(found.ID)
+      failure.Details["reason"] = "wrong_password"
+    }
+    apiCfg.audit(r, failure)
+    err = apiCfg.recordLoginFailure(context.Background(), emailVal, ip)
+    if err != nil {
+      log.Printf("Error recording login failure: %v", err)
+    }
+    respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", nil)
     return
   }
 
+  // only after the password checks out, so probing an email doesn't reveal
+  // whether the account is banned
+  if msg := accountRestriction(user, time.Now()); msg != "" {
+    apiCfg.audit(r, auditEntry{
+      Type:    auditLoginFailed,
+      UserID:  auditUser(user.ID),
+      Details: map[string]any{"email": emailVal, "reason": user.AccountStatus},
+    })
+    respondWithError(w, http.StatusForbidden, msg, nil)
+    return
+  }
+  apiCfg.cancelAccountDeletion(r, user)
+
+  if auth.NeedsRehash(user.HashedPassword) {
+    rehashed, err := auth.HashPassword(unHashedPass)
+    if err == nil {
+      err = apiCfg.db.UpdateUserPassword(context.Background(), database.UpdateUserPasswordParams{
+        ID:             user.ID,
+        HashedPassword: rehashed,
+      })
+    }
+    if err != nil {
+      log.Printf("Error rehashing password: %v", err)
+    }
+  }
+
+  err = apiCfg.db.ClearLoginLockout(context.Background(), database.ClearLoginLockoutParams{
+    Scope:   lockoutScopeAccount,
+    Subject: lockoutSubject(emailVal),
+  })
+  if err != nil {
+    log.Printf("Error clearing login lockout: %v", err)
+  }
+
   jwtToken, err := auth.MakeJWT(user.ID, apiCfg.SecretKey)
   if err != nil {
     w.WriteHeader(http.StatusInternalServerError)
@@ -150,6 +245,11 @@ func (apiCfg *apiConfig) handleUserLogin(w http.ResponseWriter, r *http.Request)
     fmt.Errorf("Error creating refresh token")
     return
   }
+  apiCfg.audit(r, auditEntry{
+    Type:    auditLoginSucceeded,
+    ActorID: auditUser(user.ID),
+    UserID:  auditUser(user.ID),
+  })
 
   response := UserResponse {
     ID:           user.ID,
@@ -183,7 +283,7 @@ func (apiCfg *apiConfig) handleUpdateUser(w http.ResponseWriter, r *http.Request
     return
   }
   
-  userID, err := auth.ValidateJWT(token, apiCfg.SecretKey)
+  userID, err := apiCfg.validateAccessToken(token)
   if err != nil {
     w.WriteHeader(http.StatusUnauthorized)
     return
@@ -191,7 +291,7 @@ func (apiCfg *apiConfig) handleUpdateUser(w http.ResponseWriter, r *http.Request
 
 
   var usrData UserData
-  decoder := json.NewDecoder(r.Body)
+  decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCredentialsBody))
   err = decoder.Decode(&usrData)
   if err != nil {
     w.WriteHeader(http.StatusInternalServerError)
@@ -200,12 +300,20 @@ func (apiCfg *apiConfig) handleUpdateUser(w http.ResponseWriter, r *http.Request
   }
 
   password := usrData.Password
+  if !apiCfg.checkPassword(w, password, usrData.EmailVal) {
+    return
+  }
   hashedPassword, err := auth.HashPassword(password)
   if err != nil {
     w.WriteHeader(http.StatusInternalServerError)
     fmt.Errorf("Error hashing the password")
     return
   }
+  before, err := apiCfg.db.GetUserById(context.Background(), userID)
+  if err != nil {
+    w.WriteHeader(http.StatusInternalServerError)
+    return
+  }
 
 
   err = apiCfg.db.UpdateUser(context.Background(), database.UpdateUserParams{
@@ -219,6 +327,19 @@ func (apiCfg *apiConfig) handleUpdateUser(w http.ResponseWriter, r *http.Request
     fmt.Errorf("Error updating the user info")
     return
   }
+  apiCfg.audit(r, auditEntry{
+    Type:    auditPasswordChanged,
+    ActorID: auditUser(userID),
+    UserID:  auditUser(userID),
+  })
+  if before.Email != usrData.EmailVal {
+    apiCfg.audit(r, auditEntry{
+      Type:    auditEmailChanged,
+      ActorID: auditUser(userID),
+      UserID:  auditUser(userID),
+      Details: map[string]any{"old_email": before.Email, "new_email": usrData.EmailVal},
+    })
+  }
 
   updatedUser := struct {
     Email   string    `json:"email"` 
@@ -233,56 +354,3 @@ func (apiCfg *apiConfig) handleUpdateUser(w http.ResponseWriter, r *http.Request
   return
   
 }
-
-type Webhook struct {
-	Event string `json:"event"`
-	Data  struct {
-		UserID string `json:"user_id"`
-	} `json:"data"`
-}
-
-func (apiCfg *apiConfig) handleWebhooks(w http.ResponseWriter, r *http.Request) {
-  apiKey, err := auth.GetAPIKey(r.Header)
-  if err != nil {
-    w.WriteHeader(http.StatusUnauthorized)
-    return
-  }
-
-  if apiKey != apiCfg.PolkaKey {
-    w.WriteHeader(http.StatusUnauthorized)
-    return
-  }
-
-
-  var webhook Webhook
-  decoder := json.NewDecoder(r.Body)
-  err = decoder.Decode(&webhook)
-  if err != nil {
-    w.WriteHeader(http.StatusInternalServerError)
-    return
-  }
-
-  if webhook.Event != "user.upgraded" {
-    w.WriteHeader(http.StatusNoContent)
-    return
-  }
-
-  userID, _ := uuid.Parse(webhook.Data.UserID)
-
-  _, err = apiCfg.db.GetUserById(context.Background(), userID)
-  if err != nil {
-    w.WriteHeader(http.StatusNotFound)
-    return
-  }
-
-  err = apiCfg.db.UpgradeToChirpyRed(context.Background(), userID)
-  if err != nil {
-    w.WriteHeader(http.StatusInternalServerError)
-    return
-  }
-
-  w.Header().Set("Content-Type", "application/json; charset=utf-8")
-  w.WriteHeader(http.StatusNoContent)
-  return
-
-}
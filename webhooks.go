@@ -0,0 +1,348 @@
+package main
+
+import (
+  "context"
+  "crypto/sha256"
+  "database/sql"
+  "encoding/hex"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io"
+  "log"
+  "net/http"
+  "strconv"
+  "time"
+  "chirpy/internal/auth"
+  "chirpy/internal/database"
+  "chirpy/internal/webhooks"
+  "github.com/google/uuid"
+)
+
+const (
+  webhookSourcePolka = "polka"
+
+  webhookReceived   = "received"
+  webhookProcessed  = "processed"
+  webhookIgnored    = "ignored"
+  webhookFailed     = "failed"
+
+  webhookSignatureTolerance = 5 * time.Minute
+  maxWebhookBodyBytes       = 1 << 20
+)
+
+var errWebhookUserNotFound = errors.New("webhook user not found")
+
+type Webhook struct {
+	ID    string `json:"id,omitempty"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID            string     `json:"user_id"`
+		Plan              string     `json:"plan,omitempty"`
+		CurrentPeriodEnd  *time.Time `json:"current_period_end,omitempty"`
+		CancelAtPeriodEnd bool       `json:"cancel_at_period_end,omitempty"`
+	} `json:"data"`
+}
+
+// authenticatePolka verifies the Polka-Signature header. Without a webhook
+// secret configured there is nothing to verify against, so every delivery
+// is turned away.
+func (apiCfg *apiConfig) authenticatePolka(r *http.Request, body []byte) error {
+  if apiCfg.PolkaWebhookSecret == "" {
+    return errors.New("no webhook secret configured")
+  }
+  return auth.VerifyWebhookSignature(
+    r.Header.Get("Polka-Signature"),
+    body,
+    apiCfg.PolkaWebhookSecret,
+    webhookSignatureTolerance,
+    time.Now(),
+  )
+}
+
+// webhookIdempotencyKey identifies a delivery by what the signature covers:
+// the event's id when it has one, and otherwise the signed timestamp and
+// body together. Headers aren't signed, so a captured delivery replayed
+// under a new Idempotency-Key is still the same event, while a later event
+// with the same body, such as the next renewal, is signed at a different
+// time and gets a key of its own.
+func webhookIdempotencyKey(webhook Webhook, signature string, body []byte) string {
+  if webhook.ID != "" {
+    return "id:" + webhook.ID
+  }
+  h := sha256.New()
+  h.Write([]byte(auth.WebhookSignatureTimestamp(signature)))
+  h.Write([]byte("."))
+  h.Write(body)
+  return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+func (apiCfg *apiConfig) handleWebhooks(w http.ResponseWriter, r *http.Request) {
+  body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodyBytes))
+  if err != nil {
+    w.WriteHeader(http.StatusBadRequest)
+    return
+  }
+
+  err = apiCfg.authenticatePolka(r, body)
+  if err != nil {
+    w.WriteHeader(http.StatusUnauthorized)
+    return
+  }
+
+  var webhook Webhook
+  err = json.Unmarshal(body, &webhook)
+  if err != nil {
+    w.WriteHeader(http.StatusBadRequest)
+    return
+  }
+
+  event, err := apiCfg.recordWebhookEvent(context.Background(), webhookIdempotencyKey(webhook, r.Header.Get("Polka-Signature"), body), webhook.Event, body)
+  if err != nil {
+    log.Printf("Error recording webhook event: %v", err)
+    w.WriteHeader(http.StatusInternalServerError)
+    return
+  }
+
+  err = apiCfg.processWebhookEvent(context.Background(), event.ID)
+  if err != nil {
+    if errors.Is(err, errWebhookUserNotFound) || errors.Is(err, errNoSubscription) {
+      w.WriteHeader(http.StatusNotFound)
+      return
+    }
+    log.Printf("Error processing webhook event %s: %v", event.ID, err)
+    w.WriteHeader(http.StatusInternalServerError)
+    return
+  }
+
+  w.WriteHeader(http.StatusNoContent)
+}
+
+// recordWebhookEvent stores the delivery, or returns the stored event when
+// the same idempotency key was seen before.
+func (apiCfg *apiConfig) recordWebhookEvent(ctx context.Context, key, eventName string, body []byte) (database.WebhookEvent, error) {
+  event, err := apiCfg.db.InsertWebhookEvent(ctx, database.InsertWebhookEventParams{
+    ID:             uuid.New(),
+    CreatedAt:      time.Now(),
+    Source:         webhookSourcePolka,
+    IdempotencyKey: key,
+    Event:          eventName,
+    Payload:        json.RawMessage(body),
+  })
+  if errors.Is(err, sql.ErrNoRows) {
+    return apiCfg.db.GetWebhookEventByKey(ctx, database.GetWebhookEventByKeyParams{
+      Source:         webhookSourcePolka,
+      IdempotencyKey: key,
+    })
+  }
+  return event, err
+}
+
+// processWebhookEvent applies a stored event exactly once. The event row is
+// locked for the duration and marked done in the same transaction as the
+// changes it causes, so concurrent or repeated deliveries are no-ops.
+func (apiCfg *apiConfig) processWebhookEvent(ctx context.Context, eventID uuid.UUID) error {
+  err := apiCfg.applyWebhookEvent(ctx, eventID)
+  if err != nil {
+    markErr := apiCfg.db.MarkWebhookEventFailed(ctx, database.MarkWebhookEventFailedParams{
+      ID:        eventID,
+      LastError: sql.NullString{String: err.Error(), Valid: true},
+    })
+    if markErr != nil {
+      log.Printf("Error marking webhook event %s failed: %v", eventID, markErr)
+    }
+  }
+  return err
+}
+
+func (apiCfg *apiConfig) applyWebhookEvent(ctx context.Context, eventID uuid.UUID) error {
+  tx, err := apiCfg.sqlDB.BeginTx(ctx, nil)
+  if err != nil {
+    return err
+  }
+  defer tx.Rollback()
+  qtx := apiCfg.db.WithTx(tx)
+
+  event, err := qtx.LockWebhookEvent(ctx, eventID)
+  if err != nil {
+    return err
+  }
+  if event.Status == webhookProcessed || event.Status == webhookIgnored {
+    return nil
+  }
+
+  var webhook Webhook
+  err = json.Unmarshal(event.Payload, &webhook)
+  if err != nil {
+    return fmt.Errorf("error decoding payload: %v", err)
+  }
+
+  status := webhookProcessed
+  if isSubscriptionEvent(webhook.Event) {
+    userID, err := uuid.Parse(webhook.Data.UserID)
+    if err != nil {
+      return errWebhookUserNotFound
+    }
+    _, err = qtx.GetUserById(ctx, userID)
+    if errors.Is(err, sql.ErrNoRows) {
+      return errWebhookUserNotFound
+    }
+    if err != nil {
+      return err
+    }
+
+    err = applySubscriptionEvent(ctx, qtx, userID, webhook)
+    if err != nil {
+      return err
+    }
+    // recorded with the change, so replays that do nothing aren't recorded
+    err = recordAudit(ctx, qtx, nil, auditEntry{
+      Type:    auditSubscriptionChanged,
+      UserID:  auditUser(userID),
+      Details: map[string]any{"event": webhook.Event, "webhook_event_id": eventID},
+    })
+    if err != nil {
+      return err
+    }
+  } else {
+    status = webhookIgnored
+  }
+
+  err = qtx.MarkWebhookEventDone(ctx, database.MarkWebhookEventDoneParams{
+    ID:          eventID,
+    Status:      status,
+    ProcessedAt: sql.NullTime{Time: time.Now(), Valid: true},
+  })
+  if err != nil {
+    return err
+  }
+
+  err = tx.Commit()
+  if err != nil {
+    return err
+  }
+
+  if webhook.Event == eventUserUpgraded {
+    apiCfg.publishEvent(ctx, webhooks.Event{
+      Type:   webhooks.EventUserUpgraded,
+      UserID: uuid.MustParse(webhook.Data.UserID),
+      Data:   map[string]string{"user_id": webhook.Data.UserID},
+    })
+  }
+  return nil
+}
+
+type webhookEventResponse struct {
+  ID             uuid.UUID       `json:"id"`
+  CreatedAt      time.Time       `json:"created_at"`
+  UpdatedAt      time.Time       `json:"updated_at"`
+  Source         string          `json:"source"`
+  IdempotencyKey string          `json:"idempotency_key"`
+  Event          string          `json:"event"`
+  Payload        json.RawMessage `json:"payload"`
+  Status         string          `json:"status"`
+  Attempts       int32           `json:"attempts"`
+  LastError      string          `json:"last_error,omitempty"`
+  ProcessedAt    *time.Time      `json:"processed_at"`
+}
+
+func newWebhookEventResponse(e database.WebhookEvent) webhookEventResponse {
+  resp := webhookEventResponse{
+    ID:             e.ID,
+    CreatedAt:      e.CreatedAt,
+    UpdatedAt:      e.UpdatedAt,
+    Source:         e.Source,
+    IdempotencyKey: e.IdempotencyKey,
+    Event:          e.Event,
+    Payload:        e.Payload,
+    Status:         e.Status,
+    Attempts:       e.Attempts,
+    LastError:      e.LastError.String,
+  }
+  if e.ProcessedAt.Valid {
+    resp.ProcessedAt = &e.ProcessedAt.Time
+  }
+  return resp
+}
+
+func (cfg *apiConfig) handleListWebhookEvents(w http.ResponseWriter, r *http.Request) {
+  params := database.ListWebhookEventsParams{RowLimit: 50}
+  if status := r.URL.Query().Get("status"); status != "" {
+    params.Status = sql.NullString{String: status, Valid: true}
+  }
+  if limit := r.URL.Query().Get("limit"); limit != "" {
+    n, err := strconv.Atoi(limit)
+    if err != nil || n <= 0 || n > 500 {
+      respondWithError(w, http.StatusBadRequest, "Invalid limit", err)
+      return
+    }
+    params.RowLimit = int32(n)
+  }
+
+  events, err := cfg.db.ListWebhookEvents(context.Background(), params)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error listing webhook events", err)
+    return
+  }
+
+  response := make([]webhookEventResponse, 0, len(events))
+  for _, e := range events {
+    response = append(response, newWebhookEventResponse(e))
+  }
+  respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handleGetWebhookEvent(w http.ResponseWriter, r *http.Request) {
+  eventID, err := uuid.Parse(r.PathValue("eventID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid event ID", err)
+    return
+  }
+
+  event, err := cfg.db.GetWebhookEvent(context.Background(), eventID)
+  if err != nil {
+    if errors.Is(err, sql.ErrNoRows) {
+      respondWithError(w, http.StatusNotFound, "Webhook event not found", nil)
+      return
+    }
+    respondWithError(w, http.StatusInternalServerError, "Error fetching webhook event", err)
+    return
+  }
+
+  respondWithJSON(w, http.StatusOK, newWebhookEventResponse(event))
+}
+
+// handleReplayWebhookEvent runs a failed event through processing again.
+func (cfg *apiConfig) handleReplayWebhookEvent(w http.ResponseWriter, r *http.Request) {
+  eventID, err := uuid.Parse(r.PathValue("eventID"))
+  if err != nil {
+    respondWithError(w, http.StatusBadRequest, "Invalid event ID", err)
+    return
+  }
+
+  event, err := cfg.db.GetWebhookEvent(context.Background(), eventID)
+  if err != nil {
+    if errors.Is(err, sql.ErrNoRows) {
+      respondWithError(w, http.StatusNotFound, "Webhook event not found", nil)
+      return
+    }
+    respondWithError(w, http.StatusInternalServerError, "Error fetching webhook event", err)
+    return
+  }
+  if event.Status != webhookFailed {
+    respondWithError(w, http.StatusConflict, "Only failed events can be replayed", nil)
+    return
+  }
+
+  processErr := cfg.processWebhookEvent(context.Background(), eventID)
+
+  event, err = cfg.db.GetWebhookEvent(context.Background(), eventID)
+  if err != nil {
+    respondWithError(w, http.StatusInternalServerError, "Error fetching webhook event", err)
+    return
+  }
+  if processErr != nil {
+    log.Printf("Replay of webhook event %s failed: %v", eventID, processErr)
+  }
+  respondWithJSON(w, http.StatusOK, newWebhookEventResponse(event))
+}